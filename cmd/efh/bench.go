@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// table is the common interface of the measured implementations.
+type table interface {
+	TryInsert(key []byte, value any) error
+	Get(key []byte) (any, bool)
+}
+
+// implementations are the constructors of tables to measure with the given capacity.
+var implementations = map[string]func(capacity int) table{
+	"funnel":  func(capacity int) table { return funnel.NewHashTableDefault(capacity) },
+	"elastic": func(capacity int) table { return elastic.NewHashTableDefault(capacity) },
+	"map":     func(capacity int) table { return make(mapTable, capacity) },
+}
+
+// mapTable is a Go map used as a baseline.
+type mapTable map[string]any
+
+func (m mapTable) TryInsert(key []byte, value any) error {
+	m[string(key)] = value
+	return nil
+}
+
+func (m mapTable) Get(key []byte) (any, bool) {
+	v, ok := m[string(key)]
+	return v, ok
+}
+
+// benchResult is a report row.
+type benchResult struct {
+	Impl          string
+	Load          float64
+	Entries       int // Successfully inserted entries
+	Failed        int // Insertions failed due to no free space
+	Insert        latency
+	GetHit        latency
+	GetMiss       latency
+	BytesPerEntry float64
+}
+
+// latency is the distribution of operation latencies.
+type latency struct {
+	Avg, P50, P90, P99 time.Duration
+}
+
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	impls := fs.String("impl", "funnel,elastic,map", "comma-separated implementations to measure")
+	capacity := fs.Int("capacity", 100000, "table capacity")
+	loads := fs.String("loads", "0.5,0.75,0.9,0.95,0.99", "comma-separated load factors")
+	format := fs.String("format", "markdown", "report format: csv, markdown or gnuplot")
+	output := fs.String("o", "", "write the report to file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *capacity <= 0 {
+		return fmt.Errorf("capacity must be positive")
+	}
+
+	var loadFactors []float64
+	for _, s := range strings.Split(*loads, ",") {
+		lf, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || lf <= 0 || lf > 1 {
+			return fmt.Errorf("invalid load factor %q", s)
+		}
+		loadFactors = append(loadFactors, lf)
+	}
+	var names []string
+	for _, name := range strings.Split(*impls, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := implementations[name]; !ok {
+			return fmt.Errorf("unknown implementation %q", name)
+		}
+		names = append(names, name)
+	}
+	writeReport := writeMarkdown
+	switch *format {
+	case "csv":
+		writeReport = writeCSV
+	case "gnuplot":
+		writeReport = writeGnuplot
+	case "markdown":
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	var results []benchResult
+	for _, name := range names {
+		for _, lf := range loadFactors {
+			fmt.Fprintf(os.Stderr, "measuring %s at load %v\n", name, lf)
+			results = append(results, runBench(name, *capacity, lf))
+		}
+	}
+	return writeReport(out, results)
+}
+
+// runBench fills a new table up to the load factor and measures its operations.
+func runBench(name string, capacity int, load float64) benchResult {
+	count := int(float64(capacity) * load)
+	keys := makeKeys(count)
+	misses := makeKeys(count)
+	res := benchResult{Impl: name, Load: load}
+	samples := make([]time.Duration, 0, count) // Allocated in advance to not count it as the table memory
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	t := implementations[name](capacity)
+	inserted := keys[:0:0]
+	for i, k := range keys {
+		start := time.Now()
+		err := t.TryInsert(k, i)
+		samples = append(samples, time.Since(start))
+		if err == nil {
+			inserted = append(inserted, k)
+		} else {
+			res.Failed++
+		}
+	}
+	res.Insert = measure(samples)
+	res.Entries = len(inserted)
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if res.Entries > 0 {
+		res.BytesPerEntry = float64(after.HeapAlloc-min(after.HeapAlloc, before.HeapAlloc)) / float64(res.Entries)
+	}
+
+	res.GetHit = measure(timeGets(t, inserted, samples[:0]))
+	res.GetMiss = measure(timeGets(t, misses, samples[:0]))
+
+	runtime.KeepAlive(t)
+	return res
+}
+
+// makeKeys returns the random 8-byte keys.
+func makeKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = binary.BigEndian.AppendUint64(nil, rand.Uint64())
+	}
+	return keys
+}
+
+// timeGets looks up the keys and appends every lookup latency to samples.
+func timeGets(t table, keys [][]byte, samples []time.Duration) []time.Duration {
+	for _, k := range keys {
+		start := time.Now()
+		t.Get(k)
+		samples = append(samples, time.Since(start))
+	}
+	return samples
+}
+
+// measure returns the distribution of latency samples. The samples are sorted in place.
+func measure(samples []time.Duration) latency {
+	if len(samples) == 0 {
+		return latency{}
+	}
+	slices.Sort(samples)
+	var sum time.Duration
+	for _, d := range samples {
+		sum += d
+	}
+	percentile := func(q float64) time.Duration { return samples[int(q*float64(len(samples)-1))] }
+	return latency{
+		Avg: sum / time.Duration(len(samples)),
+		P50: percentile(0.5),
+		P90: percentile(0.9),
+		P99: percentile(0.99),
+	}
+}
+
+func writeCSV(w io.Writer, results []benchResult) error {
+	cw := csv.NewWriter(w)
+	header := []string{"impl", "load", "entries", "failed"}
+	for _, op := range []string{"insert", "get_hit", "get_miss"} {
+		header = append(header, op+"_ns", op+"_p50_ns", op+"_p90_ns", op+"_p99_ns")
+	}
+	cw.Write(append(header, "bytes_per_entry"))
+	for _, r := range results {
+		row := []string{
+			r.Impl,
+			strconv.FormatFloat(r.Load, 'f', -1, 64),
+			strconv.Itoa(r.Entries),
+			strconv.Itoa(r.Failed),
+		}
+		for _, l := range []latency{r.Insert, r.GetHit, r.GetMiss} {
+			for _, d := range []time.Duration{l.Avg, l.P50, l.P90, l.P99} {
+				row = append(row, strconv.FormatInt(d.Nanoseconds(), 10))
+			}
+		}
+		cw.Write(append(row, strconv.FormatFloat(r.BytesPerEntry, 'f', 1, 64)))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, results []benchResult) error {
+	fmt.Fprintln(w, "| impl | load | entries | failed | insert, ns | insert p99, ns | get hit, ns | get hit p99, ns | get miss, ns | get miss p99, ns | bytes/entry |")
+	fmt.Fprintln(w, "|------|-----:|--------:|-------:|-----------:|---------------:|------------:|----------------:|-------------:|-----------------:|------------:|")
+	for _, r := range results {
+		_, err := fmt.Fprintf(
+			w, "| %s | %v | %d | %d | %d | %d | %d | %d | %d | %d | %.1f |\n",
+			r.Impl, r.Load, r.Entries, r.Failed,
+			r.Insert.Avg.Nanoseconds(), r.Insert.P99.Nanoseconds(),
+			r.GetHit.Avg.Nanoseconds(), r.GetHit.P99.Nanoseconds(),
+			r.GetMiss.Avg.Nanoseconds(), r.GetMiss.P99.Nanoseconds(),
+			r.BytesPerEntry,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGnuplot writes a gnuplot script drawing the p50 and p99 latencies and the memory per entry of every
+// implementation by load factor to an SVG image, e.g. `efh bench -format gnuplot | gnuplot > report.svg`.
+func writeGnuplot(w io.Writer, results []benchResult) error {
+	var impls []string
+	for _, r := range results {
+		if !slices.Contains(impls, r.Impl) {
+			impls = append(impls, r.Impl)
+		}
+	}
+	for _, impl := range impls {
+		fmt.Fprintf(w, "$%s << EOD\n", impl)
+		fmt.Fprintln(w, "# load insert_p50 insert_p99 get_hit_p50 get_hit_p99 get_miss_p50 get_miss_p99 bytes_per_entry")
+		for _, r := range results {
+			if r.Impl == impl {
+				fmt.Fprintf(
+					w, "%v %d %d %d %d %d %d %.1f\n", r.Load,
+					r.Insert.P50.Nanoseconds(), r.Insert.P99.Nanoseconds(),
+					r.GetHit.P50.Nanoseconds(), r.GetHit.P99.Nanoseconds(),
+					r.GetMiss.P50.Nanoseconds(), r.GetMiss.P99.Nanoseconds(),
+					r.BytesPerEntry,
+				)
+			}
+		}
+		fmt.Fprintln(w, "EOD")
+	}
+
+	fmt.Fprintln(w, "set terminal svg size 1200,900 dynamic")
+	fmt.Fprintln(w, "set multiplot layout 2,2")
+	fmt.Fprintln(w, "set xlabel 'load factor'")
+	fmt.Fprintln(w, "set key left top")
+	plots := []struct {
+		title  string
+		column int
+	}{{"insert, ns", 2}, {"get hit, ns", 4}, {"get miss, ns", 6}}
+	for _, p := range plots {
+		fmt.Fprintf(w, "set title '%s'\n", p.title)
+		var lines []string
+		for _, impl := range impls {
+			lines = append(lines,
+				fmt.Sprintf("$%s using 1:%d with linespoints title '%s p50'", impl, p.column, impl),
+				fmt.Sprintf("$%s using 1:%d with linespoints title '%s p99'", impl, p.column+1, impl),
+			)
+		}
+		fmt.Fprintf(w, "plot %s\n", strings.Join(lines, ", "))
+	}
+	fmt.Fprintln(w, "set title 'bytes/entry'")
+	var lines []string
+	for _, impl := range impls {
+		lines = append(lines, fmt.Sprintf("$%s using 1:8 with linespoints title '%s'", impl, impl))
+	}
+	fmt.Fprintf(w, "plot %s\n", strings.Join(lines, ", "))
+	_, err := fmt.Fprintln(w, "unset multiplot")
+	return err
+}
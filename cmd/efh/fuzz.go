@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+)
+
+// fuzzTable is the common interface of the implementations compared by fuzz command.
+type fuzzTable interface {
+	TrySet(key []byte, value any) (bool, error)
+	Get(key []byte) (any, bool)
+	SoftDelete(key []byte) bool
+	Delete(key []byte) bool
+	Purge() int
+}
+
+// fuzzImplementations are the constructors of tables to compare. The tables are seeded, so a workload is reproducible.
+var fuzzImplementations = map[string]func(capacity int, seed uint64) fuzzTable{
+	"funnel": func(capacity int, seed uint64) fuzzTable {
+		t, err := funnel.New(funnel.Config{
+			Capacity: capacity, Delta: 0.1, BankShrink: 0.75, HashSeed: seed, Seed: seed | 1,
+		})
+		if err != nil {
+			panic(err)
+		}
+		return t
+	},
+	"elastic": func(capacity int, seed uint64) fuzzTable {
+		t := elastic.NewHashTableDefault(capacity)
+		t.Hasher, t.HashSeed = elastic.SeededHasher(seed), seed
+		return t
+	},
+}
+
+type fuzzOpKind int
+
+const (
+	fuzzSet fuzzOpKind = iota
+	fuzzGet
+	fuzzDelete
+	fuzzPurge
+)
+
+// fuzzOp is a workload operation. The value is set by fuzzSet only.
+type fuzzOp struct {
+	Kind  fuzzOpKind
+	Key   uint64
+	Value int
+}
+
+func (op fuzzOp) String() string {
+	switch op.Kind {
+	case fuzzSet:
+		return fmt.Sprintf("set %d=%d", op.Key, op.Value)
+	case fuzzGet:
+		return fmt.Sprintf("get %d", op.Key)
+	case fuzzDelete:
+		return fmt.Sprintf("delete %d", op.Key)
+	}
+	return "purge"
+}
+
+func fuzzCommand(args []string) error {
+	fs := flag.NewFlagSet("fuzz", flag.ContinueOnError)
+	impls := fs.String("impl", "funnel,elastic", "two comma-separated implementations to compare")
+	capacity := fs.Int("capacity", 1000, "table capacity")
+	ops := fs.Int("ops", 10000, "operations in a workload")
+	keys := fs.Int("keys", 0, "distinct keys in a workload, 2*capacity if zero")
+	runs := fs.Int("runs", 100, "workloads to run")
+	seed := fs.Uint64("seed", 0, "seed of the first workload, random if zero")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *capacity <= 0 || *ops <= 0 || *runs <= 0 || *keys < 0 {
+		return fmt.Errorf("capacity, ops and runs must be positive, keys must not be negative")
+	}
+	if *keys == 0 {
+		*keys = 2 * *capacity
+	}
+	names := strings.Split(*impls, ",")
+	if len(names) != 2 {
+		return fmt.Errorf("exactly two implementations are required, got %q", *impls)
+	}
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if _, ok := fuzzImplementations[names[i]]; !ok {
+			return fmt.Errorf("unknown implementation %q", names[i])
+		}
+	}
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+
+	for run := 0; run < *runs; run++ {
+		s := *seed + uint64(run)
+		workload := makeWorkload(s, *ops, *keys)
+		run := func(ops []fuzzOp) error {
+			return runWorkload(names, [2]fuzzTable{
+				fuzzImplementations[names[0]](*capacity, s),
+				fuzzImplementations[names[1]](*capacity, s),
+			}, ops)
+		}
+		if err := run(workload); err != nil {
+			fmt.Fprintf(os.Stderr, "seed %d failed, minimizing the workload\n", s)
+			minimal := minimize(workload[:failedOp(err)+1], func(ops []fuzzOp) bool { return run(ops) != nil })
+			return fmt.Errorf("seed %d: %w\nminimal workload:\n  %s", s, run(minimal), joinOps(minimal, "\n  "))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d workloads passed, seeds %d..%d\n", *runs, *seed, *seed+uint64(*runs)-1)
+	return nil
+}
+
+// makeWorkload returns the random operations over the given number of keys.
+func makeWorkload(seed uint64, n, keys int) []fuzzOp {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	ops := make([]fuzzOp, n)
+	for i := range ops {
+		op := fuzzOp{Key: rnd.Uint64N(uint64(keys))}
+		switch p := rnd.IntN(100); {
+		case p < 50:
+			op.Kind, op.Value = fuzzSet, i
+		case p < 85:
+			op.Kind = fuzzGet
+		case p < 99:
+			op.Kind = fuzzDelete
+		default:
+			op.Kind = fuzzPurge
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// opError is a mismatch of the tables with each other or with the reference map on an operation.
+type opError struct {
+	Index int
+	Op    fuzzOp
+	Msg   string
+}
+
+func (e *opError) Error() string {
+	return fmt.Sprintf("op %d (%v): %s", e.Index, e.Op, e.Msg)
+}
+
+// failedOp returns the index of the operation an error of runWorkload was returned on.
+func failedOp(err error) int {
+	var oe *opError
+	if errors.As(err, &oe) {
+		return oe.Index
+	}
+	return 0
+}
+
+// runWorkload runs the operations through both tables and the reference map in lockstep, and returns the first
+// mismatch. The results of the tables are compared with each other first, then with the reference map.
+//
+// The tables may run out of space before their capacity, each at its own load. A set failed with ErrFull is skipped in
+// the reference map, and is rolled back in the other table if it succeeded there, so the tables stay in sync.
+// In the end, all keys of the reference map are looked up.
+func runWorkload(names []string, tables [2]fuzzTable, ops []fuzzOp) error {
+	ref := make(map[uint64]int)
+	for i, op := range ops {
+		key := binary.BigEndian.AppendUint64(nil, op.Key)
+		want, exists := ref[op.Key]
+		var got [2]string
+		var expect string
+		switch op.Kind {
+		case fuzzSet:
+			var full [2]bool
+			for j, t := range tables {
+				updated, err := t.TrySet(key, op.Value)
+				if full[j] = errors.Is(err, funnel.ErrFull) || errors.Is(err, elastic.ErrFull); !full[j] && err != nil {
+					return &opError{Index: i, Op: op, Msg: fmt.Sprintf("%s: unexpected error %v", names[j], err)}
+				}
+				got[j] = fmt.Sprintf("updated %v", updated)
+			}
+			if full[0] || full[1] {
+				for j, t := range tables {
+					if !full[j] {
+						rollbackSet(t, key, want, exists)
+					}
+				}
+				continue
+			}
+			expect = fmt.Sprintf("updated %v", exists)
+			ref[op.Key] = op.Value
+		case fuzzGet:
+			for j, t := range tables {
+				v, ok := t.Get(key)
+				got[j] = fmt.Sprintf("got %v, %v", v, ok)
+			}
+			expect = fmt.Sprintf("got %v, %v", refValue(want, exists), exists)
+		case fuzzDelete:
+			for j, t := range tables {
+				got[j] = fmt.Sprintf("deleted %v", t.SoftDelete(key))
+			}
+			expect = fmt.Sprintf("deleted %v", exists)
+			delete(ref, op.Key)
+		case fuzzPurge:
+			for _, t := range tables {
+				t.Purge()
+			}
+			continue
+		}
+		if err := compareResults(names, got, expect); err != "" {
+			return &opError{Index: i, Op: op, Msg: err}
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(ref)) {
+		var got [2]string
+		for j, t := range tables {
+			v, ok := t.Get(binary.BigEndian.AppendUint64(nil, k))
+			got[j] = fmt.Sprintf("got %v, %v", v, ok)
+		}
+		if err := compareResults(names, got, fmt.Sprintf("got %v, true", ref[k])); err != "" {
+			return &opError{Index: len(ops) - 1, Op: fuzzOp{Kind: fuzzGet, Key: k}, Msg: "after workload " + err}
+		}
+	}
+	return nil
+}
+
+// rollbackSet restores the key in a table to its state in the reference map before a set.
+func rollbackSet(t fuzzTable, key []byte, want int, exists bool) {
+	if exists {
+		t.TrySet(key, want)
+	} else {
+		t.Delete(key)
+	}
+}
+
+// refValue returns the value of a key in the reference map the way Get of a table returns it.
+func refValue(v int, ok bool) any {
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// compareResults returns the description of a mismatch of the tables results with each other or with the expected
+// one, or empty string if they all match.
+func compareResults(names []string, got [2]string, expect string) string {
+	switch {
+	case got[0] != got[1]:
+		return fmt.Sprintf(
+			"%s and %s differ: %s: %s, %s: %s, want %s", names[0], names[1], names[0], got[0], names[1], got[1], expect,
+		)
+	case got[0] != expect:
+		return fmt.Sprintf("both differ from the reference map: %s, want %s", got[0], expect)
+	}
+	return ""
+}
+
+// minimize removes the chunks of operations while the workload still fails, halving the chunk size down to a single
+// operation.
+func minimize(ops []fuzzOp, fails func(ops []fuzzOp) bool) []fuzzOp {
+	for chunk := len(ops) / 2; chunk > 0; chunk /= 2 {
+		for i := 0; i < len(ops); {
+			candidate := slices.Concat(ops[:i], ops[min(i+chunk, len(ops)):])
+			if fails(candidate) {
+				ops = candidate
+			} else {
+				i += chunk
+			}
+		}
+	}
+	return ops
+}
+
+func joinOps(ops []fuzzOp, sep string) string {
+	s := make([]string, len(ops))
+	for i, op := range ops {
+		s[i] = op.String()
+	}
+	return strings.Join(s, sep)
+}
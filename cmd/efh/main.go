@@ -0,0 +1,48 @@
+// Command efh is a companion tool for the funnel and elastic hash tables.
+//
+// Usage:
+//
+//	efh <command> [flags]
+//
+// Commands:
+//
+//	bench    measure the hash table implementations at various load factors and print a report
+//	fuzz     run random workloads through two implementations and a reference map in lockstep, and report the differences
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+var commands = map[string]func(args []string) error{
+	"bench": benchCommand,
+	"fuzz":  fuzzCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "Usage: efh <command> [flags]\n\nCommands: %v\n", names)
+}
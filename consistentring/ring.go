@@ -0,0 +1,92 @@
+// Package consistentring maps the keys to nodes with consistent hashing, so the tables sharded across processes lose
+// few keys when a node is added or removed. It uses the seeded hasher of the tables, so the shards and the tables are
+// configured with plain seeds the same way.
+package consistentring
+
+import (
+	"cmp"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"slices"
+	"strconv"
+)
+
+// Ring is a consistent hashing ring of the nodes. Every node is placed on the ring at Replicas points (virtual nodes),
+// which spread its keys evenly. A key belongs to the node of the first point following the key hash.
+//
+// Not safe for concurrent use.
+type Ring struct {
+	replicas int
+	hasher   func(b []byte) uint64
+	points   []point // Sorted by hash
+}
+
+type point struct {
+	hash uint64
+	node string
+}
+
+// New creates a new ring with the given nodes, the hasher seed and virtual nodes per node. The tables select the
+// banks by the key hash as well, so the seed must differ from the tables ones, otherwise the keys of a node fill up
+// only a part of its table banks.
+//
+// Panics if replicas is not positive.
+func New(seed uint64, replicas int, nodes ...string) *Ring {
+	if replicas <= 0 {
+		panic("replicas must be positive")
+	}
+	r := &Ring{replicas: replicas, hasher: funnel.SeededHasher(seed)}
+	r.Add(nodes...)
+	return r
+}
+
+// Add adds the nodes to the ring, the nodes already in the ring are skipped.
+func (r *Ring) Add(nodes ...string) {
+	for _, node := range nodes {
+		if r.has(node) {
+			continue
+		}
+		for i := 0; i < r.replicas; i++ {
+			r.points = append(r.points, point{hash: r.hasher([]byte(node + "#" + strconv.Itoa(i))), node: node})
+		}
+	}
+	// The collided points are ordered by node, so the ring does not depend on the order the nodes were added in
+	slices.SortFunc(r.points, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.node, b.node))
+	})
+}
+
+// Remove removes a node from the ring. Returns false if there is no such node.
+func (r *Ring) Remove(node string) bool {
+	n := len(r.points)
+	r.points = slices.DeleteFunc(r.points, func(p point) bool { return p.node == node })
+	return len(r.points) < n
+}
+
+// Node returns the node a key belongs to, or an empty string if the ring is empty.
+func (r *Ring) Node(key []byte) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := r.hasher(key)
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(r.points) {
+		i = 0 // The ring wraps around
+	}
+	return r.points[i].node
+}
+
+// Nodes returns the nodes of the ring in lexical order.
+func (r *Ring) Nodes() []string {
+	var res []string
+	for _, p := range r.points {
+		res = append(res, p.node)
+	}
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+func (r *Ring) has(node string) bool {
+	return slices.ContainsFunc(r.points, func(p point) bool { return p.node == node })
+}
@@ -0,0 +1,84 @@
+package consistentring
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const keys = 10000
+
+func assignment(r *Ring) map[int]string {
+	res := make(map[int]string, keys)
+	for i := 0; i < keys; i++ {
+		res[i] = r.Node([]byte(fmt.Sprint(i)))
+	}
+	return res
+}
+
+func TestRing(t *testing.T) {
+	t.Run("empty ring; should return no node", func(t *testing.T) {
+		r := New(1, 10)
+
+		assert.Empty(t, r.Node([]byte("key")))
+		assert.Empty(t, r.Nodes())
+	})
+
+	t.Run("several nodes; should spread the keys evenly", func(t *testing.T) {
+		r := New(1, 100, "a", "b", "c", "d")
+
+		counts := make(map[string]int)
+		for _, node := range assignment(r) {
+			counts[node]++
+		}
+
+		assert.Len(t, counts, 4)
+		for node, n := range counts {
+			assert.InDelta(t, keys/4, n, keys/4*0.25, "node: %v", node)
+		}
+	})
+
+	t.Run("same seed and nodes in another order; should map the keys the same way", func(t *testing.T) {
+		assert.Equal(t, assignment(New(1, 50, "a", "b", "c")), assignment(New(1, 50, "c", "a", "b")))
+		assert.NotEqual(t, assignment(New(1, 50, "a", "b", "c")), assignment(New(2, 50, "a", "b", "c")))
+	})
+
+	t.Run("add node; should move only the keys of the new node", func(t *testing.T) {
+		r := New(1, 100, "a", "b", "c")
+		before := assignment(r)
+
+		r.Add("d", "a")
+		after := assignment(r)
+
+		var moved int
+		for i, node := range after {
+			if node != before[i] {
+				assert.Equal(t, "d", node)
+				moved++
+			}
+		}
+		assert.InDelta(t, keys/4, moved, keys/4*0.25)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, r.Nodes())
+	})
+
+	t.Run("remove node; should move only its keys", func(t *testing.T) {
+		r := New(1, 100, "a", "b", "c")
+		before := assignment(r)
+
+		assert.True(t, r.Remove("b"))
+		assert.False(t, r.Remove("b"))
+		after := assignment(r)
+
+		for i, node := range after {
+			if before[i] != "b" {
+				assert.Equal(t, before[i], node)
+			} else {
+				assert.NotEqual(t, "b", node)
+			}
+		}
+	})
+
+	t.Run("no replicas; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { New(1, 0) })
+	})
+}
@@ -0,0 +1,87 @@
+// Package convert migrates the entries between the hash table implementations, e.g. to try another one on the same
+// data or to rebuild a table with other parameters.
+package convert
+
+import (
+	"context"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/bdragon300/elastic-funnel-hash/internal/ctxcheck"
+	"iter"
+	"math"
+)
+
+// Source is a table to copy the entries from, e.g. *funnel.HashTable or *elastic.HashTable.
+type Source interface {
+	All() iter.Seq2[[]byte, any]
+}
+
+// Target is a table to copy the entries into, e.g. *funnel.HashTable or *elastic.HashTable.
+type Target interface {
+	TryInsert(key []byte, value any) error
+}
+
+// Copy inserts all src entries into dst and returns the number of inserted entries. It stops on the first insert
+// error. Only keys and values are copied, the entries flags and versions are not. The keys are not deduplicated, so
+// dst should be empty.
+func Copy(dst Target, src Source) (int, error) {
+	return CopyContext(context.Background(), dst, src)
+}
+
+// CopyContext is like Copy, but stops once ctx is done and returns the number of entries inserted so far along with
+// the context error.
+func CopyContext(ctx context.Context, dst Target, src Source) (int, error) {
+	var n int
+	for k, v := range src.All() {
+		if err := ctxcheck.Err(ctx, n); err != nil {
+			return n, err
+		}
+		if err := dst.TryInsert(k, v); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ToFunnel creates a funnel table with the given config and copies the src entries into it, see Copy. If
+// cfg.Capacity is zero, the table is sized for src entries.
+func ToFunnel(src Source, cfg funnel.Config) (*funnel.HashTable, error) {
+	if cfg.Capacity == 0 {
+		cfg.Capacity = max(count(src), 1)
+	}
+	t, err := funnel.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = Copy(t, src); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ToElastic creates an elastic table with the given parameters (see elastic.NewHashTable) and copies the src entries
+// into it, see Copy. If capacity is zero, the table is sized for src entries keeping delta slots free.
+//
+// An elastic insert may fail below the table capacity, so the table gets a stash for such keys, see
+// elastic.HashTable.UseStash.
+func ToElastic(src Source, capacity int, delta, bank2Occupation, bank1FillFactor float64) (*elastic.HashTable, error) {
+	if capacity == 0 {
+		capacity = max(int(math.Ceil(float64(count(src))/(1-delta))), 1)
+	}
+	t := elastic.NewHashTable(capacity, delta, bank2Occupation, bank1FillFactor)
+	t.UseStash()
+	if _, err := Copy(t, src); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// count returns the number of src entries. Len of a table does not count the spilled entries, so they are iterated.
+func count(src Source) int {
+	var n int
+	for range src.All() {
+		n++
+	}
+	return n
+}
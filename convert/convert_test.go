@@ -0,0 +1,109 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/bdragon300/elastic-funnel-hash/internal/ctxcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const entries = 500
+
+func assertEntries(t *testing.T, table interface{ Get(key []byte) (any, bool) }) {
+	t.Helper()
+	for i := 0; i < entries; i++ {
+		v, ok := table.Get([]byte(fmt.Sprint(i)))
+		require.True(t, ok, "key: %v", i)
+		assert.Equal(t, i, v)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	t.Run("elastic to funnel; should keep all entries including stashed", func(t *testing.T) {
+		src := elastic.NewHashTableDefault(entries)
+		stash := src.UseStash()
+		for i := 0; i < entries; i++ {
+			src.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		require.Positive(t, stash.Len())
+
+		dst, err := ToFunnel(src, funnel.Config{Delta: 0.1, BankShrink: 0.75})
+
+		require.NoError(t, err)
+		assert.Equal(t, entries, dst.Len())
+		assertEntries(t, dst)
+	})
+
+	t.Run("funnel to elastic; should keep all entries", func(t *testing.T) {
+		src := funnel.NewHashTableDefault(entries)
+		for i := 0; i < entries; i++ {
+			src.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		dst, err := ToElastic(src, 0, 0.1, 0.75, 200)
+
+		require.NoError(t, err)
+		assertEntries(t, dst)
+	})
+
+	t.Run("elastic to elastic with other parameters; should keep all entries", func(t *testing.T) {
+		src := elastic.NewHashTableDefault(2 * entries)
+		src.UseStash()
+		for i := 0; i < entries; i++ {
+			src.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		dst, err := ToElastic(src, 4*entries, 0.2, 0.5, 100)
+
+		require.NoError(t, err)
+		assert.Equal(t, 4*entries, dst.Cap())
+		assertEntries(t, dst)
+	})
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("target too small; should stop on insert error", func(t *testing.T) {
+		src := funnel.NewHashTableDefault(entries)
+		for i := 0; i < entries; i++ {
+			src.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		dst := funnel.NewHashTableDefault(entries / 2)
+
+		n, err := Copy(dst, src)
+
+		assert.ErrorIs(t, err, funnel.ErrFull)
+		assert.Equal(t, dst.Len(), n)
+	})
+	t.Run("context canceled; should stop and return the copied count", func(t *testing.T) {
+		src := funnel.NewHashTableDefault(5000)
+		for i := 0; i < 5000; i++ {
+			src.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		dst := &cancelingTarget{HashTable: funnel.NewHashTableDefault(5000), cancel: cancel, after: 1500}
+
+		n, err := CopyContext(ctx, dst, src)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 2*ctxcheck.Interval, n)
+		assert.Equal(t, n, dst.Len())
+	})
+}
+
+// cancelingTarget cancels the context after the given number of inserts.
+type cancelingTarget struct {
+	*funnel.HashTable
+	cancel context.CancelFunc
+	after  int
+}
+
+func (c *cancelingTarget) TryInsert(key []byte, value any) error {
+	if c.Len() == c.after {
+		c.cancel()
+	}
+	return c.HashTable.TryInsert(key, value)
+}
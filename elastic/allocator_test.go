@@ -0,0 +1,123 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// countingAllocator counts the slots and slices allocated and not freed yet.
+type countingAllocator struct {
+	HeapAllocator
+	slots, bytes int
+}
+
+func (a *countingAllocator) NewSlot() *Slot {
+	a.slots++
+	return a.HeapAllocator.NewSlot()
+}
+
+func (a *countingAllocator) FreeSlot(*Slot) {
+	a.slots--
+}
+
+func (a *countingAllocator) Bytes(n int) []byte {
+	a.bytes++
+	return a.HeapAllocator.Bytes(n)
+}
+
+func (a *countingAllocator) FreeBytes([]byte) {
+	a.bytes--
+}
+
+func TestAllocator(t *testing.T) {
+	t.Run("custom allocator; should allocate the slots and key copies from it", func(t *testing.T) {
+		alloc := &countingAllocator{}
+		table := NewHashTableDefault(100)
+		table.CopyKeys, table.Allocator = true, alloc
+		inserted := fillAllocator(table)
+
+		assert.Equal(t, len(inserted), alloc.slots)
+		assert.Equal(t, len(inserted), alloc.bytes)
+		for _, i := range inserted {
+			v, ok := table.Get(allocKey(i))
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("short keys; should keep them in the slots", func(t *testing.T) {
+		alloc := &countingAllocator{}
+		table := NewHashTableDefault(100)
+		table.CopyKeys, table.Allocator = true, alloc
+		buf := make([]byte, 0, inlineKeySize)
+		var inserted []int
+		for i := 0; i < table.Cap(); i++ {
+			buf = fmt.Append(buf[:0], "key", i)
+			if table.TryInsert(buf, i) == nil {
+				inserted = append(inserted, i)
+			}
+		}
+
+		assert.Equal(t, len(inserted), alloc.slots)
+		assert.Zero(t, alloc.bytes)
+		for _, i := range inserted {
+			v, ok := table.Get([]byte(fmt.Sprint("key", i)))
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+		assert.Equal(t, len(inserted), table.DeleteIf(func([]byte, any) bool { return true }))
+		assert.Zero(t, alloc.bytes)
+	})
+
+	t.Run("keys not copied; should allocate only the slots", func(t *testing.T) {
+		alloc := &countingAllocator{}
+		table := NewHashTableDefault(100)
+		table.Allocator = alloc
+		inserted := fillAllocator(table)
+
+		assert.Equal(t, len(inserted), alloc.slots)
+		assert.Zero(t, alloc.bytes)
+		assert.Zero(t, table.DeleteIf(func([]byte, any) bool { return false }))
+	})
+
+	t.Run("removed entries; should release the key copies", func(t *testing.T) {
+		alloc := &countingAllocator{}
+		table := NewHashTableDefault(100)
+		table.CopyKeys, table.Allocator = true, alloc
+		inserted := fillAllocator(table)
+
+		assert.Equal(t, len(inserted), table.DeleteIf(func([]byte, any) bool { return true }))
+		assert.Zero(t, alloc.bytes)
+	})
+
+	t.Run("dropped insert; should release the key copy", func(t *testing.T) {
+		alloc := &countingAllocator{}
+		table := NewHashTableDefault(100)
+		table.CopyKeys, table.Allocator = true, alloc
+		table.OnFull = func([]byte, any) FullPolicy { return FullDrop }
+		for i := 0; i < 2*table.Cap(); i++ {
+			require.NoError(t, table.TryInsert(allocKey(i), i))
+		}
+
+		assert.Equal(t, table.Len(), alloc.bytes)
+		assert.Equal(t, table.Len(), alloc.slots)
+	})
+}
+
+// allocKey returns a test key, long enough not to be stored in the slot itself.
+func allocKey(i int) []byte {
+	return []byte(fmt.Sprint("key-longer-than-inline-", i))
+}
+
+// fillAllocator inserts the keys up to the table capacity, and returns the indexes of the inserted ones.
+func fillAllocator(table *HashTable) []int {
+	var inserted []int
+	for i := 0; i < table.Cap(); i++ {
+		if table.TryInsert(allocKey(i), i) == nil {
+			inserted = append(inserted, i)
+		}
+	}
+	return inserted
+}
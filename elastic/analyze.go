@@ -0,0 +1,64 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+	"iter"
+	"math"
+)
+
+// Analysis is the report of how the table hasher distributes the keys, see Analyze.
+// Bins are the banks pairs, since a key is placed only into the banks pair selected by its hash.
+type Analysis struct {
+	Keys       int // Keys analyzed
+	Collisions int // Keys with the same hash as one of the previous keys
+	Bins       int
+	// ChiSquare is the χ² statistic of the bins load against the uniform distribution. For a good hasher it's close
+	// to Bins-1 with the standard deviation of sqrt(2*(Bins-1)), the much greater values mean skew
+	ChiSquare       float64
+	MaxLoad         int // Maximum keys in a bin
+	ExpectedMaxLoad int // Maximum keys in a bin expected for a uniform hasher
+}
+
+// Analyze hashes the distinct keys with the table hasher and reports their distribution over the table. Useful to
+// validate a custom Hasher before putting the production data into the table. The table is not modified.
+func (t *HashTable) Analyze(keys iter.Seq[[]byte]) Analysis {
+	bins := len(t.Banks)
+	loads := make([]int, bins)
+	hashes := make(map[uint64]struct{})
+	var a Analysis
+	for key := range keys {
+		hsh := t.Hasher(t.canonKey(key))
+		if _, ok := hashes[hsh]; ok {
+			a.Collisions++
+		}
+		hashes[hsh] = struct{}{}
+		bin := hasher.Reduce(hsh, bins)
+		loads[bin]++
+		a.MaxLoad = max(a.MaxLoad, loads[bin])
+		a.Keys++
+	}
+
+	a.Bins = bins
+	if a.Keys == 0 {
+		return a
+	}
+	mean := float64(a.Keys) / float64(bins)
+	for _, l := range loads {
+		a.ChiSquare += (float64(l) - mean) * (float64(l) - mean) / mean
+	}
+	a.ExpectedMaxLoad = expectedMaxLoad(mean, bins)
+	return a
+}
+
+// expectedMaxLoad returns the expected maximum load of bins with uniformly distributed keys, i.e. the smallest load
+// exceeded by less than one bin on average. A bin load is approximated by Poisson distribution.
+func expectedMaxLoad(mean float64, bins int) int {
+	tail := 1.0 // P(load >= k)
+	for k := 0; ; k++ {
+		lg, _ := math.Lgamma(float64(k + 1))
+		tail -= math.Exp(float64(k)*math.Log(mean) - mean - lg) // P(load = k)
+		if tail*float64(bins) < 1 {
+			return k
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package elastic
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+)
+
+func BenchmarkLookup(b *testing.B) {
+	const capacity = 1<<14 - 1
+	var banks []*Bank
+	for size := 1 << 13; size > 0; size /= 2 {
+		banks = append(banks, &Bank{Data: make([]*Slot, size)})
+	}
+	table := HashTable{
+		Bank1FillFactor: 200,
+		Bank2Occupation: 0.75,
+		Capacity:        capacity,
+		Delta:           0.1,
+		Banks:           banks,
+		Rnd:             newProbeRand([32]byte{}),
+		Rnd2:            newProbeRand([32]byte{}),
+	}
+	var (
+		hashes []uint64
+		keys   [][]byte
+	)
+	for i := 0; i < capacity*3/4; i++ {
+		hsh := rand.Uint64()
+		key := binary.BigEndian.AppendUint64(nil, hsh)
+		if insert(&table, nil, hsh, key, i) != nil {
+			hashes = append(hashes, hsh)
+			keys = append(keys, key)
+		}
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		j := i % len(hashes)
+		if _, ok := lookup(&table, nil, hashes[j], keys[j]); !ok {
+			b.Fatal("key not found")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCacheHashes(t *testing.T) {
+	newTable := func(cache bool, compared *int) *HashTable {
+		table := NewHashTableDefault(1000)
+		table.Hasher, table.HashSeed = SeededHasher(1), 1
+		table.CacheHashes = cache
+		table.KeyEqual = func(a, b []byte) bool {
+			*compared++
+			return string(a) == string(b)
+		}
+		return table
+	}
+
+	t.Run("lookup keys; should compare only the keys with equal hashes", func(t *testing.T) {
+		var compared, uncached int
+		table, plain := newTable(true, &compared), newTable(false, &uncached)
+		var keys []string
+		for i := 0; i < 900; i++ {
+			key := fmt.Sprint("key", i)
+			if table.TryInsert([]byte(key), i) == nil {
+				require.NoError(t, plain.TryInsert([]byte(key), i))
+				keys = append(keys, key)
+			}
+		}
+		compared, uncached = 0, 0
+
+		for _, key := range keys {
+			_, ok := table.Get([]byte(key))
+			require.True(t, ok)
+			_, ok = plain.Get([]byte(key))
+			require.True(t, ok)
+		}
+		_, ok := table.Get([]byte("missing"))
+		assert.False(t, ok)
+
+		assert.Equal(t, len(keys), compared) // No hash collisions among these keys
+		assert.Greater(t, uncached, compared)
+	})
+}
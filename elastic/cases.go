@@ -0,0 +1,48 @@
+package elastic
+
+import (
+	"strconv"
+)
+
+// InsertCase is a case of the insertion into a banks pair, see the Paper pages 8-9. The ε1 and ε2 below are the free
+// slots fractions of the Ai and Ai+1 banks.
+type InsertCase int
+
+const (
+	InsertFirstBank InsertCase = iota // The A1 bank, which is used without a pair
+	InsertCase1                       // ε1 > δ/2 and ε2 > 1-Bank2Occupation: limited probing of Ai, then Ai+1
+	InsertCase2                       // ε1 ≤ δ/2: Ai is almost full, probing Ai+1
+	InsertCase3                       // ε2 ≤ 1-Bank2Occupation: Ai+1 is full, probing Ai
+	InsertBatchOver                   // Both banks are full, the insert fails
+
+	insertCasesCount = 5
+)
+
+func (c InsertCase) String() string {
+	switch c {
+	case InsertFirstBank:
+		return "first-bank"
+	case InsertCase1:
+		return "case1"
+	case InsertCase2:
+		return "case2"
+	case InsertCase3:
+		return "case3"
+	case InsertBatchOver:
+		return "batch-over"
+	}
+	return "InsertCase(" + strconv.Itoa(int(c)) + ")"
+}
+
+// freeFraction returns the free slots fraction of a bank, ε parameter in Paper.
+func freeFraction(bank *Bank) float64 {
+	return epsilon(len(bank.Data), bank.Inserts)
+}
+
+// epsilon returns the free slots fraction of a bank of the given size and inserts count.
+func epsilon(size, inserts int) float64 {
+	if size == 0 {
+		return 1
+	}
+	return float64(size-inserts) / float64(size)
+}
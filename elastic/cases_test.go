@@ -0,0 +1,70 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInsertCases(t *testing.T) {
+	t.Run("fill table; should count every insert in one case", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		var failed int
+		for i := 0; i < 900; i++ {
+			if table.TryInsert([]byte(fmt.Sprint(i)), i) != nil {
+				failed++
+			}
+		}
+
+		var cases [insertCasesCount]int
+		for _, b := range table.Banks {
+			for c, n := range b.Cases {
+				cases[c] += n
+			}
+		}
+		var total int
+		for _, n := range cases {
+			total += n
+		}
+		assert.Equal(t, 900, total)
+		assert.Equal(t, table.Banks[0].Cases[InsertFirstBank], cases[InsertFirstBank]) // Only A1 bank has no pair
+		assert.LessOrEqual(t, cases[InsertBatchOver], failed)
+		assert.Positive(t, cases[InsertCase1])
+
+		p := table.Params()
+		for i, b := range table.Banks {
+			assert.InDelta(t, 1-float64(b.Inserts)/float64(len(b.Data)), p.FreeFractions[i], 1e-9, "bank: %v", i)
+		}
+	})
+}
+
+func TestInsertCaseString(t *testing.T) {
+	assert.Equal(t, "case2", InsertCase2.String())
+	assert.Equal(t, "InsertCase(10)", InsertCase(10).String())
+}
+
+func TestMaxResumeProbes(t *testing.T) {
+	t.Run("bound or disable resumed probing; should check fewer slots on misses", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		for i := 0; i < 700; i++ {
+			_ = table.TryInsert([]byte(fmt.Sprint(i)), i)
+		}
+		var probes int
+		table.Hooks = &Hooks{Done: func(_ Op, n int, _ bool) { probes += n }}
+		missProbes := func(limit int) int {
+			table.MaxResumeProbes = limit
+			probes = 0
+			for i := 0; i < 100; i++ {
+				table.Get([]byte(fmt.Sprint("missing", i)))
+			}
+			return probes
+		}
+
+		unlimited := missProbes(0)
+		bounded := missProbes(1)
+		disabled := missProbes(NoResume)
+
+		assert.LessOrEqual(t, bounded, unlimited)
+		assert.Less(t, disabled, bounded)
+	})
+}
@@ -0,0 +1,33 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/dedup"
+)
+
+// FindDuplicates returns the keys having more than one visible entry in the table, since Insert does not deduplicate
+// keys. Lookups return only one of the entries, see Dedup to remove the others. The keys are compared bytewise,
+// KeyEqual is not used.
+func (t *HashTable) FindDuplicates() [][]byte {
+	return dedup.Keys(t.duplicates())
+}
+
+// Dedup keeps the most recently inserted entry of every duplicate key and removes the others, see FindDuplicates.
+// Returns the number of removed entries.
+func (t *HashTable) Dedup() int {
+	drop := dedup.Stale(t.duplicates(), func(s *Slot) uint64 { return s.seq })
+	if len(drop) == 0 {
+		return 0
+	}
+	return t.remove(func(s *Slot) bool { return drop[s] })
+}
+
+// duplicates returns the visible entries grouped by key. Only the keys having more than one entry are included.
+func (t *HashTable) duplicates() map[string][]*Slot {
+	return dedup.Groups(t.slots(), func(s *Slot) []byte { return s.Key })
+}
+
+// nextSeq returns the insertion order of a new entry.
+func (t *HashTable) nextSeq() uint64 {
+	t.seq++
+	return t.seq
+}
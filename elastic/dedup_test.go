@@ -0,0 +1,46 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	t.Run("duplicate inserts; should report the key and keep the latest value", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		key := bigBanksKey(table)
+		for v := 1; v <= 3; v++ {
+			table.Insert(key, v)
+		}
+
+		assert.Equal(t, [][]byte{key}, table.FindDuplicates())
+		assert.Equal(t, 2, table.Dedup())
+
+		assert.Equal(t, 1, table.Len())
+		v, ok := table.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, 3, v)
+		assert.Empty(t, table.FindDuplicates())
+	})
+
+	t.Run("soft-deleted duplicate; should be ignored", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		key := bigBanksKey(table)
+		table.Insert(key, 1)
+		table.SoftDelete(key)
+		table.Insert(key, 2)
+
+		assert.Empty(t, table.FindDuplicates())
+		assert.Zero(t, table.Dedup())
+	})
+}
+
+// bigBanksKey returns a key from a big banks pair, so the key has room for several entries.
+func bigBanksKey(table *HashTable) []byte {
+	key := []byte("key")
+	for i := 0; table.Hasher(key)%uint64(len(table.Banks)) < uint64(len(table.Banks))/2; i++ {
+		key = []byte(fmt.Sprint("key", i))
+	}
+	return key
+}
@@ -0,0 +1,131 @@
+package elastic
+
+// SoftDelete hides an entry from lookups, but keeps it in the table until Purge, so it can be restored with Undelete.
+// Returns false if the key does not exist. The soft-deleted entries still occupy their slots and are counted by Len.
+//
+// If the key is set again before Purge, the new entry is inserted, and the deleted one can no longer be restored.
+// The Spill entries are soft-deleted if it has the SoftDelete, Undelete and Purge methods.
+func (t *HashTable) SoftDelete(key []byte) bool {
+	slot, ok := t.slot(key)
+	if ok {
+		slot.Deleted = true
+		t.mutate(MutationDelete, slot.Key, slot.Value, slot.Version)
+		return true
+	}
+	if s, ok := t.Spill.(spillSoftDelete); ok {
+		return s.SoftDelete(t.canonKey(key))
+	}
+	return false
+}
+
+// Undelete restores an entry hidden by SoftDelete. Returns false if there is no such entry, it was purged, or the key
+// was set again after deletion.
+func (t *HashTable) Undelete(key []byte) bool {
+	key = t.canonKey(key)
+	if _, ok := t.slot(key); ok {
+		return false
+	}
+	pr := newProbe(t, OpLookup)
+	pr.deleted = true
+	slot, ok := lookup(t, pr, t.Hasher(key), key)
+	if ok {
+		slot.Deleted = false
+		t.mutate(MutationInsert, slot.Key, slot.Value, slot.Version)
+		return true
+	}
+	if s, ok := t.Spill.(spillSoftDelete); ok {
+		return s.Undelete(key)
+	}
+	return false
+}
+
+// Delete removes the entry of a key and returns true, or returns false if the key does not exist. Unlike SoftDelete,
+// the entry cannot be restored. The soft-deleted entries are not found, see Purge. The key is deleted from the Spill
+// table on miss if it has the Delete method.
+func (t *HashTable) Delete(key []byte) bool {
+	key = t.canonKey(key)
+	var last Step // The slot holding the key is the last one checked
+	pr := newProbe(t, OpLookup)
+	pr.hooks = JoinHooks(t.Hooks, &Hooks{Slot: func(_ Op, step Step) { last = step }})
+	slot, ok := lookup(t, pr, t.Hasher(key), key)
+	if !ok {
+		if s, ok := t.Spill.(spillDeleter); ok {
+			return s.Delete(key)
+		}
+		return false
+	}
+	if t.OnWatermark != nil {
+		defer t.crossWatermarks(t.Inserts)
+	}
+	t.mutateRemoved(slot)
+	t.account(slot.Key, slot.Value, -1)
+	t.tombstone(slot)
+	t.Banks[last.Bank].Inserts--
+	t.Inserts--
+	return true
+}
+
+// Purge removes the soft-deleted entries from the table and returns their count. The freed slots are reused by
+// subsequent inserts. The Spill table is purged too if it supports soft deletion, see SoftDelete.
+func (t *HashTable) Purge() int {
+	n := t.remove(func(s *Slot) bool { return s.Deleted })
+	if s, ok := t.Spill.(spillSoftDelete); ok {
+		n += s.Purge()
+	}
+	return n
+}
+
+// DeleteIf removes the entries fn returns true for, and returns their count. The table is scanned once, so it's
+// cheaper than deleting the keys one by one. The soft-deleted entries are skipped. The Spill table is scanned too if
+// it has the DeleteIf method.
+//
+// fn must not modify the table.
+func (t *HashTable) DeleteIf(fn func(key []byte, value any) bool) int {
+	n := t.remove(func(s *Slot) bool { return !s.Deleted && fn(s.Key, s.Value) })
+	if s, ok := t.Spill.(spillDeleterIf); ok {
+		n += s.DeleteIf(fn)
+	}
+	return n
+}
+
+// remove removes the entries match returns true for, and returns their count. The freed slots are reused by
+// subsequent inserts.
+func (t *HashTable) remove(match func(s *Slot) bool) int {
+	if t.OnWatermark != nil {
+		defer t.crossWatermarks(t.Inserts)
+	}
+	var n int
+	for _, b := range t.Banks {
+		for _, s := range b.Data {
+			if removable(s, t.Epoch, match) {
+				t.mutateRemoved(s)
+				t.account(s.Key, s.Value, -1)
+				t.tombstone(s)
+				b.Inserts--
+				n++
+			}
+		}
+	}
+	t.Inserts -= n
+	return n
+}
+
+// tombstone frees a bank slot. Lookups stop at the first empty slot, so the slot is marked as purged instead of
+// emptied.
+func (t *HashTable) tombstone(s *Slot) {
+	if t.Wipe {
+		wipeEntry(s, t.CopyKeys)
+	}
+	t.releaseKey(s.Key)
+	s.Key, s.Value, s.purged = nil, nil, true
+}
+
+// removable returns true if a slot holds an entry in the given table epoch, and match returns true for it.
+func removable(slot *Slot, epoch uint32, match func(s *Slot) bool) bool {
+	return live(slot, epoch) && !slot.purged && match(slot)
+}
+
+// vacant returns true if a slot is free or purged in the given table epoch.
+func vacant(slot *Slot, epoch uint32) bool {
+	return !live(slot, epoch) || slot.purged
+}
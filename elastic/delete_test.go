@@ -0,0 +1,157 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSoftDelete(t *testing.T) {
+	t.Run("delete and undelete; should hide and restore the entry", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		assert.True(t, table.SoftDelete([]byte("key")))
+		_, ok := table.Get([]byte("key"))
+		assert.False(t, ok)
+		assert.Equal(t, 1, table.Len())
+
+		assert.True(t, table.Undelete([]byte("key")))
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.False(t, table.Undelete([]byte("key")))
+	})
+
+	t.Run("set after delete; should insert new entry and not restore the old one", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		// The key takes two slots, so pick the one from a big banks pair
+		key := []byte("key")
+		for i := 0; table.Hasher(key)%uint64(len(table.Banks)) < uint64(len(table.Banks))/2; i++ {
+			key = []byte(fmt.Sprint("key", i))
+		}
+		table.Insert(key, 1)
+		table.SoftDelete(key)
+
+		assert.False(t, table.Set(key, 2))
+
+		assert.False(t, table.Undelete(key))
+		v, _ := table.Get(key)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("missing key; should fail", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+
+		assert.False(t, table.SoftDelete([]byte("missing")))
+		assert.False(t, table.Undelete([]byte("missing")))
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("delete entries in full table; should free their slots and keep other entries", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		mutations := recordMutations(table)
+		var deleted int
+		for i := 0; i < n; i += 2 {
+			assert.True(t, table.Delete([]byte(fmt.Sprint(i))), "key: %v", i)
+			deleted++
+		}
+
+		assert.Equal(t, n-deleted, table.Len())
+		assert.Len(t, *mutations, deleted)
+		var banks int
+		for _, b := range table.Banks {
+			banks += b.Inserts
+		}
+		assert.Equal(t, table.Len(), banks)
+		for i := 0; i < n; i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+			if ok {
+				assert.Equal(t, i, v)
+			}
+		}
+		assert.False(t, table.Delete([]byte("0")))
+		assert.False(t, table.Undelete([]byte("0")))
+		var inserted int
+		for i := 0; i < deleted; i++ {
+			if table.TryInsert([]byte(fmt.Sprint("new", i)), i) == nil {
+				inserted++
+			}
+		}
+		assert.NotZero(t, inserted)
+	})
+
+	t.Run("missing or soft-deleted key; should fail", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hasher, table.HashSeed = SeededHasher(1), 1 // Fixes the banks pair of the key, so the insert succeeds
+		table.Insert([]byte("key"), 1)
+		table.SoftDelete([]byte("key"))
+
+		assert.False(t, table.Delete([]byte("key")))
+		assert.False(t, table.Delete([]byte("missing")))
+		assert.Equal(t, 1, table.Len())
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Run("purge deleted entries in full table; should keep other entries and reuse slots", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		var deleted int
+		for i := 0; i < n; i += 2 {
+			assert.True(t, table.SoftDelete([]byte(fmt.Sprint(i))))
+			deleted++
+		}
+
+		assert.Equal(t, deleted, table.Purge())
+
+		assert.Equal(t, n-deleted, table.Len())
+		assert.Zero(t, table.Purge())
+		for i := 0; i < n; i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+			if ok {
+				assert.Equal(t, i, v)
+			}
+		}
+		assert.False(t, table.Undelete([]byte("0")))
+
+		var inserted []int
+		for i := 0; i < deleted; i++ {
+			if table.TryInsert([]byte(fmt.Sprint("new", i)), i) == nil {
+				inserted = append(inserted, i)
+			}
+		}
+		assert.NotEmpty(t, inserted)
+		for _, i := range inserted {
+			v, ok := table.Get([]byte(fmt.Sprint("new", i)))
+			assert.True(t, ok, "key: new%v", i)
+			assert.Equal(t, i, v)
+		}
+	})
+}
+
+func TestDeleteIf(t *testing.T) {
+	t.Run("delete even values; should remove only the matching visible entries", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		table.SoftDelete([]byte("0"))
+		var even int
+		for i := 2; i < n; i += 2 {
+			even++
+		}
+
+		assert.Equal(t, even, table.DeleteIf(func(_ []byte, value any) bool { return value.(int)%2 == 0 }))
+
+		assert.Equal(t, n-even, table.Len())
+		for i := 1; i < n; i++ {
+			_, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+		}
+		assert.True(t, table.Undelete([]byte("0")))
+		assert.Zero(t, table.DeleteIf(func([]byte, any) bool { return false }))
+	})
+}
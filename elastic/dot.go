@@ -0,0 +1,45 @@
+package elastic
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DumpDot writes the table layout in Graphviz DOT format to w: banks with their sizes and fill levels. The edges
+// connect the banks working in pairs. Render it with e.g. `dot -Tsvg`.
+func (t *HashTable) DumpDot(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph elastic {\n\trankdir=LR;\n\tnode [shape=record, style=filled, colorscheme=blues9];\n")
+	fmt.Fprintf(&buf, "\tlabel=\"capacity %d, inserts %d, delta %v\";\n", t.Capacity, t.Inserts, t.Delta)
+
+	for i, b := range t.Banks {
+		size := len(b.Data)
+		fmt.Fprintf(
+			&buf, "\tbank%d [label=\"bank %d|size %d|used %d (%s)\", fillcolor=%d];\n",
+			i, i, size, b.Inserts, percent(b.Inserts, size), fillColor(b.Inserts, size),
+		)
+		if i > 0 {
+			fmt.Fprintf(&buf, "\tbank%d -> bank%d;\n", i-1, i)
+		}
+	}
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func percent(used, size int) string {
+	if size == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(used)*100/float64(size))
+}
+
+// fillColor returns the color index in "blues9" Graphviz color scheme by fill level.
+func fillColor(used, size int) int {
+	if size == 0 {
+		return 1
+	}
+	return 1 + used*8/size
+}
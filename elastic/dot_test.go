@@ -0,0 +1,26 @@
+package elastic
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestDumpDot(t *testing.T) {
+	t.Run("table with entries; should describe all banks", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		var buf bytes.Buffer
+
+		require.NoError(t, table.DumpDot(&buf))
+
+		out := buf.String()
+		assert.True(t, strings.HasPrefix(out, "digraph elastic {"))
+		assert.True(t, strings.HasSuffix(out, "}\n"))
+		assert.Equal(t, len(table.Banks), strings.Count(out, "[label=\"bank "))
+		assert.Equal(t, len(table.Banks)-1, strings.Count(out, " -> "))
+		assert.Equal(t, 1, strings.Count(out, "|used 1 ("))
+	})
+}
@@ -0,0 +1,25 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/filter"
+)
+
+// Filter is a Bloom filter of the table keys, see HashTable.ExportFilter. It answers whether a key may be in the
+// table without the table itself, e.g. on the edge nodes needing only the key existence checks. The keys absent
+// from the table are reported with the false positive rate given on export, the keys present are always reported.
+// The keys passed to Has must be canonical, see HashTable.KeyCanon.
+type Filter = filter.Filter
+
+// ExportFilter returns a Bloom filter of all keys in the table (including Spill, see All) for the given false
+// positive rate. The soft-deleted keys are not added. Panics if fpRate is not in range (0,1).
+func (t *HashTable) ExportFilter(fpRate float64) *Filter {
+	var n int
+	for range t.All() {
+		n++
+	}
+	f := filter.New(n, fpRate, t.mutations)
+	for k := range t.All() {
+		f.Add(k)
+	}
+	return f
+}
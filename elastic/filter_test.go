@@ -0,0 +1,35 @@
+package elastic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExportFilter(t *testing.T) {
+	t.Run("soft-deleted key; should not be added", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hasher, table.HashSeed = SeededHasher(1), 1 // Fixes the banks pairs of the keys, so the inserts succeed
+		table.Insert([]byte("key1"), 1)
+		table.Insert([]byte("key2"), 2)
+		table.SoftDelete([]byte("key2"))
+
+		f := table.ExportFilter(1e-9)
+
+		assert.True(t, f.Has([]byte("key1")))
+		assert.False(t, f.Has([]byte("key2")))
+	})
+
+	t.Run("mutations reported; should keep the last mutation seq", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hasher, table.HashSeed = SeededHasher(1), 1
+		var last uint64
+		table.OnMutation = func(m Mutation) { last = m.Seq }
+		table.Insert([]byte("key1"), 1)
+		table.Set([]byte("key1"), 2)
+
+		f := table.ExportFilter(0.01)
+
+		assert.Equal(t, uint64(2), last)
+		assert.Equal(t, last, f.Seq)
+	})
+}
@@ -0,0 +1,20 @@
+package elastic
+
+import (
+	"context"
+	"github.com/bdragon300/elastic-funnel-hash/internal/find"
+)
+
+// FindValues returns the keys of the entries whose values satisfy pred (including Spill, see All), e.g. to find out
+// which keys map to an object. Returns at most limit keys if limit is positive. The table is scanned, so it's meant
+// for the admin and debug operations rather than for serving requests.
+func (t *HashTable) FindValues(pred func(value any) bool, limit int) [][]byte {
+	keys, _ := t.FindValuesContext(context.Background(), pred, limit)
+	return keys
+}
+
+// FindValuesContext is like FindValues, but stops once ctx is done. Then it returns the keys found so far along with
+// the context error.
+func (t *HashTable) FindValuesContext(ctx context.Context, pred func(value any) bool, limit int) ([][]byte, error) {
+	return find.Values(ctx, t.All(), pred, limit)
+}
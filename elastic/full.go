@@ -0,0 +1,136 @@
+package elastic
+
+import (
+	"bytes"
+	"errors"
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+	"strconv"
+)
+
+// ErrFull is returned by TryInsert if a key cannot be placed into the table.
+var ErrFull = errors.New("no free slots")
+
+// ErrProbeBudget is returned if an operation checks more slots than HashTable.MaxProbes allows.
+var ErrProbeBudget = errors.New("probe budget exceeded")
+
+// FullPolicy is a decision what to do with a key that cannot be placed into the table, see HashTable.OnFull.
+type FullPolicy int
+
+const (
+	FullError       FullPolicy = iota // Fail the insertion: TryInsert returns ErrFull, Insert panics
+	FullDrop                          // Silently discard the key
+	FullEvictRandom                   // Replace the entry in the first slot the key is probed at
+	FullSpill                         // Insert the key into HashTable.Spill table
+)
+
+func (p FullPolicy) String() string {
+	switch p {
+	case FullError:
+		return "error"
+	case FullDrop:
+		return "drop"
+	case FullEvictRandom:
+		return "evict-random"
+	case FullSpill:
+		return "spill"
+	}
+	return "FullPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// Spill is a secondary table receiving the keys that do not fit into the main table, see FullSpill.
+//
+// The table also calls the optional Spill methods, if implemented: Len, Delete, Clear, DeleteIf, SoftDelete, Undelete
+// and Purge, of the same signatures as the table ones. See Stash.
+type Spill interface {
+	Set(key []byte, value any) bool
+	Get(key []byte) (any, bool)
+}
+
+// The optional Spill methods.
+type (
+	spillLen       interface{ Len() int }
+	spillDeleter   interface{ Delete(key []byte) bool }
+	spillClearer   interface{ Clear() }
+	spillDeleterIf interface {
+		DeleteIf(fn func(key []byte, value any) bool) int
+	}
+	spillSoftDelete interface {
+		SoftDelete(key []byte) bool
+		Undelete(key []byte) bool
+		Purge() int
+	}
+)
+
+// onFull applies the policy chosen by table.OnFull to a key that cannot be placed into the table.
+func onFull(table *HashTable, key []byte, value any) error {
+	policy := FullError
+	if table.OnFull != nil {
+		policy = table.OnFull(key, value)
+	}
+
+	switch policy {
+	case FullDrop:
+		table.releaseKey(key) // The key copy is not stored
+		return nil
+	case FullEvictRandom:
+		if evict(table, key, value) {
+			table.countUnique(key)
+			return nil
+		}
+	case FullSpill:
+		if table.Spill != nil {
+			if table.CopyKeys {
+				// The slots keep the short keys and the Allocator may reuse the key copies, so the Spill gets its own
+				spilled := bytes.Clone(key)
+				table.releaseKey(key)
+				key = spilled
+			}
+			table.Spill.Set(key, value)
+			table.countUnique(key)
+			return nil
+		}
+	}
+	return ErrFull
+}
+
+// spilled returns true if a key is in the Spill table.
+func (t *HashTable) spilled(key []byte) bool {
+	_, ok := t.Spill.Get(key)
+	return ok
+}
+
+// makeRoom removes a random entry, if the table is at capacity and the given slot of a new key is free. The slots may
+// outnumber Capacity, so filling a free slot would grow Len past it.
+func (t *HashTable) makeRoom(slot *Slot) {
+	if !vacant(slot, t.Epoch) || t.Inserts < t.Capacity {
+		return
+	}
+	if victims := t.sampleSlots(1); len(victims) > 0 {
+		t.remove(func(s *Slot) bool { return s == victims[0] })
+	} else {
+		t.Purge() // Only the soft-deleted entries are left
+	}
+}
+
+// evict replaces the entry in the first slot of the key probe sequence in the Ai+1 bank, so the key is found on lookup
+// right after the limited probing of the Ai bank. The victim is effectively random, since the slot depends only on
+// the new key hash.
+func evict(table *HashTable, key []byte, value any) bool {
+	hsh := table.Hasher(key)
+	bank := table.Banks[hasher.Reduce(hsh, len(table.Banks))]
+	slot := &bank.Data[hasher.Reduce(hsh, len(bank.Data))]
+	table.makeRoom(*slot)
+	if vacant(*slot, table.Epoch) {
+		bank.Inserts++
+		table.Inserts++
+	} else {
+		table.mutateRemoved(*slot)
+		table.account((*slot).Key, (*slot).Value, -1)
+	}
+	table.account(key, value, 1)
+	pr := newProbe(table, OpInsert)
+	pr.seq, pr.hash = table.nextSeq(), hsh
+	*slot = pr.slot(*slot, key, value)
+	table.mutate(MutationInsert, (*slot).Key, value, 0)
+	return true
+}
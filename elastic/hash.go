@@ -1,15 +1,22 @@
 package elastic
 
 import (
+	"bytes"
 	"fmt"
-	"hash/maphash"
+	"github.com/bdragon300/elastic-funnel-hash/internal/load"
 	"math"
 	"math/rand/v2"
+	"unsafe"
 )
 
-const prime32 = 0xfffffffb // Just the last 32-bit prime number
-
-// TODO: go run -gcflags="-d=ssa/check_bce" example2.go
+const (
+	NoResume = -1 // HashTable.MaxResumeProbes value disabling the resumed probing of the Ai bank on lookup
+	// inlineKeySize is the longest key stored in the slot itself, see Slot.setKey
+	inlineKeySize = 16
+	// maxSlots is the most slots a table may have, so that the slot indexes and the slots array size fit int on
+	// the platform, e.g. 2^29 on 32-bit ones
+	maxSlots = math.MaxInt / int(unsafe.Sizeof((*Slot)(nil)))
+)
 
 // NewHashTableDefault creates a new hash table with default parameters.
 func NewHashTableDefault(capacity int) *HashTable {
@@ -29,6 +36,9 @@ func NewHashTable(capacity int, delta, bank2Occupation, bank1FillFactor float64)
 	if capacity <= 0 {
 		panic(fmt.Errorf("capacity must be positive"))
 	}
+	if capacity > maxSlots/4 { // The banks take less than 4*capacity slots
+		panic(fmt.Errorf("capacity %d is too large for this platform, %d at most", capacity, maxSlots/4))
+	}
 	if delta <= 0 || delta >= 1 {
 		panic(fmt.Errorf("delta must be in range (0, 1)"))
 	}
@@ -50,14 +60,20 @@ func NewHashTable(capacity int, delta, bank2Occupation, bank1FillFactor float64)
 	banks = append(banks, &Bank{
 		Data: make([]*Slot, int(math.Pow(2, float64(len(banks))))),
 	})
-	return &HashTable{
-		Hasher:          defaultHasher(maphash.MakeSeed()),
+	hashSeed := rand.Uint64()
+	t := &HashTable{
+		Hasher:          SeededHasher(hashSeed),
+		HashSeed:        hashSeed,
 		Bank1FillFactor: bank1FillFactor,
 		Bank2Occupation: bank2Occupation,
 		Capacity:        capacity,
 		Delta:           delta,
 		Banks:           banks,
+		Rnd:             newProbeRand([32]byte{}),
+		Rnd2:            newProbeRand([32]byte{}),
 	}
+	t.updateThresholds()
+	return t
 }
 
 // HashTable is an implementation of hash table with elastic hashing algorithm. Table size is fixed and set on creation.
@@ -80,68 +96,299 @@ func NewHashTable(capacity int, delta, bank2Occupation, bank1FillFactor float64)
 //
 // [Paper]: https://arxiv.org/abs/2501.02305
 type HashTable struct {
-	Hasher func(b []byte) uint32
+	Hasher func(b []byte) uint64
+	// HashSeed is the seed of the default Hasher. Persist it along with the table data to hash the keys the same way
+	// after reload. Zero if Hasher was set by the user
+	HashSeed uint64
+	Hooks    *Hooks // Instrumentation callbacks, optional. See ProfileHooks
+	// HotKeys counts the keys accessed by the inserts, the value updates and the lookups, e.g. to find the hot keys
+	// saturating their banks early. Optional, see NewSketch
+	HotKeys *Sketch
+	// ProbeSampler records the probe traces of a fraction of operations, see DumpProbeSamples. Optional, see
+	// NewProbeSampler
+	ProbeSampler *ProbeSampler
+	// UniqueKeys estimates the number of distinct keys inserted into the table (including Spill), since Len counts
+	// the duplicate keys too, see Insert. The removals do not decrease it, Reset it along with Clear. Optional, see
+	// NewHyperLogLog and UniqueKeysEstimate
+	UniqueKeys *HyperLogLog
+	// OnFull is called when a key cannot be placed into the table, and returns what to do with it. If nil, FullError
+	// policy is used.
+	OnFull func(key []byte, value any) FullPolicy
+	Spill  Spill // Secondary table for FullSpill policy, optional. Lookups fall back to it on miss
+	// MaxProbes is the maximum slots an operation may check, 0 is unlimited. If an operation exceeds it,
+	// it fails with ErrProbeBudget. Bounds the worst case latency at the cost of false misses
+	MaxProbes int
+	// LatencySample makes every LatencySample-th operation measure the time it spends probing every layer into
+	// LayerLatency, 0 disables the measurement
+	LatencySample int
+	// KeyEqual and KeyCanon customize the keys comparison, e.g. to make them case-insensitive. KeyCanon returns
+	// the canonical form of a key, it's applied to every key before hashing and storing, so it must be idempotent.
+	// KeyEqual compares the canonical keys, slices.Equal by default. Both are optional and must be set before
+	// the first insert
+	KeyEqual func(a, b []byte) bool
+	KeyCanon func(key []byte) []byte
+	// CopyKeys makes the inserts copy the keys into the table-owned memory. Otherwise, the table keeps the caller's
+	// key slice, so it must not be modified after insert, e.g. a reused buffer corrupts the stored key. The keys up
+	// to 16 bytes are always copied into the slots. The lookups never keep the key
+	CopyKeys bool
+	// Wipe makes the table zero the key copies and the []byte values of the removed entries, e.g. holding tokens or
+	// credentials, before the memory is dropped or returned to the Allocator. Set CopyKeys along with it, otherwise
+	// only the keys up to 16 bytes are wiped, the longer ones are the caller's slices. Clear empties and wipes all
+	// slots right away then. The []byte values must not be used after their entries are removed, and the keys
+	// handed out by the table are copied, see Allocator. Set it before UseStash
+	Wipe bool
+	// CacheHashes makes the slots keep the key hashes. The probing compares the keys only if their hashes are equal,
+	// and the entries are looked up without rehashing the keys, e.g. by StatsJSON. Saves time on long keys.
+	// Must be set before the first insert
+	CacheHashes bool
+	// Allocator allocates the slot records and the key copies, the Go heap if nil. The slots are released to it once
+	// the table drops them, e.g. on removal or reuse. Unless it's HeapAllocator, the keys handed out by the table,
+	// e.g. by All, Sample or to OnMutation, are copied, so they stay valid after that. Must be set before the first
+	// insert
+	Allocator Allocator
+	// OnWatermark is called when the load factor (Len/Cap) crosses one of Watermarks levels, e.g. to provision
+	// a bigger table before inserts start failing. The rising is true if the load factor has grown above the level
+	Watermarks  []float64
+	OnWatermark func(level float64, rising bool)
+	// OnMutation is called after every change of the entries, e.g. to mirror the table to another node or to durable
+	// storage. The entries placed into Spill or dropped by OnFull are not reported. The key must be copied to be kept
+	// after the call. OnMutation must not modify the table
+	OnMutation func(m Mutation)
+	// Loader and Writer connect the table to a backing store, so it works as a read-through/write-through cache.
+	// Loader is called by Get on miss, its result is inserted into the table. Concurrent misses of the same key share
+	// a single Loader call, see GetOrLoad. Writer is called by Set before updating the table.
+	// Both receive the canonical keys and are optional
+	Loader func(key []byte) (any, error)
+	Writer func(key []byte, value any) error
+	// MaxResumeProbes bounds the last lookup phase, that resumes probing the rest of the Ai bank, 0 is unlimited.
+	// NoResume disables it. This phase makes the misses scan nearly two whole banks, but it finds the keys
+	// placed by case 3 inserts (see InsertCase3) and the keys placed by case 1 beyond the current probe limit, e.g.
+	// after Bank1FillFactor was lowered. With the bound such keys may be reported missing
+	MaxResumeProbes int
+
+	scratch   []byte     // Reused buffer for structured keys on lookups, see GetK
+	loads     load.Group // In-flight GetOrLoad calls by canonical key
+	seq       uint64     // Insertion order of the last inserted entry, see Dedup
+	mutations uint64     // Reported mutations, see OnMutation
+	sampled   int        // Operations counted for LatencySample
+	// thresholds are the parameters the bank thresholds were computed for
+	thresholds thresholds
 
 	Bank1FillFactor float64 // data bank fullness coefficient for the next bank usage, c parameter in Paper
 	Bank2Occupation float64 // rate of bank size decrease, 3/4 in Paper
 	Capacity        int     // total number of slots, n parameter in Paper
 	Inserts         int     // Metric of total number of occupied slots
+	Hits            int     // Metric of lookups by Get that found the key, see HitRatio
+	Misses          int     // Metric of lookups by Get that did not find the key
+	Epoch           uint32  // Generation of the table entries, incremented by Clear
 	Delta           float64 // δ parameter in Paper
 	Banks           []*Bank
-	Rnd, Rnd2       *rand.ChaCha8
+	Rnd, Rnd2       *probeRand
+
+	// KeyBytes and ValueBytes are metrics of bytes held in the keys and values of the entries, see Memory. Only
+	// []byte and string values are measured
+	KeyBytes   int
+	ValueBytes int
+	// LayerLatency is a metric of the sampled bank probing times, indexed by Layer. See LatencySample
+	LayerLatency [layersCount]Latency
 }
 
 // Insert inserts a new key-value pair into the hash table. It does not deduplicate keys, so if the key already exists,
 // it will be inserted again.
 //
 // To set a value for a key, as any “map” type does, use Set method.
+//
+// Panics if the key cannot be placed into the table and OnFull policy does not resolve this. See TryInsert.
 func (t *HashTable) Insert(key []byte, value any) {
+	if err := t.TryInsert(key, value); err != nil {
+		panic(err)
+	}
+}
+
+// TryInsert is like Insert, but returns an error instead of panicking: ErrFull if there is no room for the key, or
+// ErrProbeBudget if MaxProbes is exceeded.
+func (t *HashTable) TryInsert(key []byte, value any) error {
+	return t.tryInsert(newProbe(t, OpInsert), key, value)
+}
+
+// tryInsert is TryInsert with the probe of the operation, which may have the key hash given.
+func (t *HashTable) tryInsert(pr *probe, key []byte, value any) (err error) {
+	if t.OnWatermark != nil {
+		defer t.crossWatermarks(t.Inserts)
+	}
+	key = t.ownKey(t.canonKey(key))
+	t.hit(key)
+	if t.CopyKeys {
+		defer func() {
+			if err != nil {
+				t.releaseKey(key) // The key copy is not stored
+			}
+		}()
+	}
 	if t.Inserts >= t.Capacity {
-		panic("capacity exceeded")
+		return onFull(t, key, value)
 	}
-	hsh := t.Hasher(key)
-	slot := insert(t, hsh, key, value)
-	if slot == nil {
-		panic("no free space")
+	pr.seq = t.nextSeq()
+	if insert(t, pr, pr.keyHash(t.Hasher, key), key, value) == nil {
+		if pr.exhausted {
+			return ErrProbeBudget
+		}
+		return onFull(t, key, value)
 	}
+	t.account(key, value, 1)
+	t.countUnique(key)
+	t.mutate(MutationInsert, key, value, 0)
+	return nil
 }
 
 // Set sets a value for a key. If the key already exists, it updates the value. Otherwise, it inserts a new key-value
 // pair.
+//
+// Panics if the key cannot be inserted, if Writer fails, or with ErrProbeBudget if MaxProbes is exceeded while
+// looking for the key, since inserting it could duplicate the key. See TrySet.
 func (t *HashTable) Set(key []byte, value any) bool {
-	hsh := t.Hasher(key)
-	slot, ok := lookup(t, hsh, key)
-	if ok {
-		slot.Value = value
-	} else {
-		t.Insert(key, value)
+	ok, err := t.TrySet(key, value)
+	if err != nil {
+		panic(err)
 	}
 	return ok
 }
 
+// TrySet is like Set, but returns an error instead of panicking. If Writer is set, the value is written to it first,
+// and the table is not modified if it fails.
+func (t *HashTable) TrySet(key []byte, value any) (bool, error) {
+	key = t.canonKey(key)
+	if t.Writer != nil {
+		if err := t.Writer(key, value); err != nil {
+			return false, err
+		}
+	}
+	hsh := t.Hasher(key)
+	pr := newProbe(t, OpLookup)
+	slot, ok := lookup(t, pr, hsh, key)
+	switch {
+	case ok:
+		t.hit(key)
+		t.setValue(slot, value)
+		return true, nil
+	case pr.exhausted:
+		return false, ErrProbeBudget
+	case t.Spill != nil && t.spilled(key):
+		return t.Spill.Set(key, value), nil
+	}
+	return false, t.TryInsert(key, value)
+}
+
 // Get returns a value for a key. If the key does not exist, it returns nil and false.
 func (t *HashTable) Get(key []byte) (any, bool) {
-	hsh := t.Hasher(key)
-	if slot, ok := lookup(t, hsh, key); ok {
-		return slot.Value, true
+	v, ok, _ := t.TryGet(key)
+	return v, ok
+}
+
+// TryGet is like Get, but also returns ErrProbeBudget if MaxProbes is exceeded before the key was found, i.e.
+// the key may be in the table.
+//
+// If Loader is set, the missing key is loaded and inserted as GetOrLoad does, and the loader or insert error is
+// returned.
+func (t *HashTable) TryGet(key []byte) (any, bool, error) {
+	return t.tryGet(newProbe(t, OpLookup), key)
+}
+
+// tryGet is TryGet with the probe of the operation, which may have the key hash given.
+func (t *HashTable) tryGet(pr *probe, key []byte) (any, bool, error) {
+	v, ok, err := t.get(pr, key)
+	if ok || err != nil || t.Loader == nil {
+		return v, ok, err
+	}
+	key = t.canonKey(key)
+	v, err = t.GetOrLoad(key, func() (any, error) { return t.Loader(key) })
+	return v, err == nil, err
+}
+
+// GetWithTrace is like Get, but also returns the slots checked by lookup in probing order. Useful to find out why
+// a key is slow to look up or is missing.
+func (t *HashTable) GetWithTrace(key []byte) (any, bool, []Step) {
+	var steps []Step
+	pr := newProbe(t, OpLookup)
+	pr.hooks = JoinHooks(t.Hooks, &Hooks{Slot: func(_ Op, step Step) {
+		steps = append(steps, step)
+	}})
+	v, ok, _ := t.get(pr, key)
+	return v, ok, steps
+}
+
+func (t *HashTable) get(pr *probe, key []byte) (_ any, ok bool, _ error) {
+	defer func() { t.countLookup(ok) }()
+	key = t.canonKey(key)
+	t.hit(key)
+	if slot, ok := lookup(t, pr, pr.keyHash(t.Hasher, key), key); ok {
+		return slot.Value, true, nil
+	}
+	if t.Spill != nil {
+		if v, ok := t.Spill.Get(key); ok {
+			return v, true, nil
+		}
+	}
+	if pr.exhausted {
+		return nil, false, ErrProbeBudget
+	}
+	return nil, false, nil
+}
+
+// slot returns the slot of a key in the table, the Spill table is not consulted.
+func (t *HashTable) slot(key []byte) (*Slot, bool) {
+	key = t.canonKey(key)
+	return lookup(t, newProbe(t, OpLookup), t.Hasher(key), key)
+}
+
+// canonKey returns the canonical form of a key, see KeyCanon.
+func (t *HashTable) canonKey(key []byte) []byte {
+	if t.KeyCanon != nil {
+		return t.KeyCanon(key)
 	}
-	return nil, false
+	return key
 }
 
-// Len returns the number of elements in the hash table.
+// Len returns the number of elements in the hash table. The Spill entries are counted if it has the Len method.
 func (t *HashTable) Len() int {
+	if s, ok := t.Spill.(spillLen); ok {
+		return t.Inserts + s.Len()
+	}
 	return t.Inserts
 }
 
-// Cap returns the capacity of the hash table.
-func (t *HashTable) Cap() int {
-	return t.Capacity
+// ownKey returns a copy of a key to store if CopyKeys is set, or the key itself otherwise. The short keys are
+// returned as is, since the slots keep their copies.
+func (t *HashTable) ownKey(key []byte) []byte {
+	if len(key) <= inlineKeySize {
+		return key
+	}
+	return t.copyKey(key)
 }
 
-func defaultHasher(seed maphash.Seed) func(b []byte) uint32 {
-	return func(b []byte) uint32 {
-		h := maphash.Bytes(seed, b)
-		// fold 64-bit hash to 32-bit
-		return uint32(h % prime32)
+// copyKey returns a copy of a key if CopyKeys is set, or the key itself otherwise.
+func (t *HashTable) copyKey(key []byte) []byte {
+	switch {
+	case !t.CopyKeys:
+		return key
+	case t.Allocator != nil:
+		b := t.Allocator.Bytes(len(key))
+		copy(b, key)
+		return b
 	}
+	return bytes.Clone(key)
+}
+
+// slotHash returns the key hash of an entry, the cached one if CacheHashes is set or the entry was inserted by
+// InsertHashed.
+func (t *HashTable) slotHash(s *Slot) uint64 {
+	if t.CacheHashes || s.ownHash {
+		return s.hash
+	}
+	return t.Hasher(s.Key)
+}
+
+// Cap returns the capacity of the hash table.
+func (t *HashTable) Cap() int {
+	return t.Capacity
 }
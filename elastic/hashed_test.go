@@ -0,0 +1,55 @@
+package elastic
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHashed(t *testing.T) {
+	t.Run("precomputed hashes; should not hash the keys again", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		stash := table.UseStash() // Inserts may fail in the tiny banks
+		keys := make([][]byte, 50)
+		for i := range keys {
+			keys[i] = []byte(fmt.Sprint("key", i))
+		}
+		hashes := table.HashKeys(keys)
+		hasher := table.Hasher
+		var calls int
+		table.Hasher = func(b []byte) uint64 {
+			calls++
+			return hasher(b)
+		}
+		stash.Hasher = hasher // Only the banks take the precomputed hashes, the stash hashes the keys by itself
+
+		for i, key := range keys {
+			table.InsertHashed(key, hashes[i], i)
+		}
+		for i, key := range keys {
+			v, ok := table.GetHashed(key, hashes[i])
+			require.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+
+		assert.Zero(t, calls)
+		v, ok := table.Get([]byte("key42"))
+		assert.True(t, ok)
+		assert.Equal(t, 42, v)
+	})
+
+	t.Run("canonical keys; should hash the canonical form", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.KeyCanon = bytes.ToLower
+		table.Insert([]byte("key"), 1)
+
+		hashes := table.HashKeys([][]byte{[]byte("KEY")})
+
+		assert.Equal(t, []uint64{table.Hasher([]byte("key"))}, hashes)
+		v, ok := table.GetHashed([]byte("Key"), hashes[0])
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+}
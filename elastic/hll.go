@@ -0,0 +1,30 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/hll"
+)
+
+// HyperLogLog estimates the number of distinct keys added to it in a fixed memory of 2^precision bytes, see
+// HashTable.UniqueKeys. The standard error of the estimate is 1.04/√(2^precision), e.g. 1.6% for precision 12.
+type HyperLogLog = hll.HyperLogLog
+
+// NewHyperLogLog creates a new estimator of 2^precision registers. Panics if precision is not in range [4,18].
+func NewHyperLogLog(precision int) *HyperLogLog {
+	return hll.New(precision)
+}
+
+// UniqueKeysEstimate returns the estimated number of distinct keys inserted into the table, or 0 if UniqueKeys is
+// not set. Unlike Len, it does not count the duplicate keys, see Insert.
+func (t *HashTable) UniqueKeysEstimate() uint64 {
+	if t.UniqueKeys == nil {
+		return 0
+	}
+	return t.UniqueKeys.Estimate()
+}
+
+// countUnique adds an inserted key to UniqueKeys, if it's set.
+func (t *HashTable) countUnique(key []byte) {
+	if t.UniqueKeys != nil {
+		t.UniqueKeys.Add(key)
+	}
+}
@@ -0,0 +1,77 @@
+package elastic
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	type visit struct {
+		op    Op
+		layer Layer
+		bank  int
+	}
+
+	t.Run("insert and lookup; should report probed banks", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		var visits []visit
+		var left int
+		table.Hooks = &Hooks{Layer: func(op Op, layer Layer, bank int) func() {
+			visits = append(visits, visit{op, layer, bank})
+			return func() { left++ }
+		}}
+		table.Hasher = func(b []byte) uint64 { return uint64(b[0]) }
+
+		// Banks pair is A2, A3. A2 is empty, so it gets no probes in case 1, and the key goes to A3
+		table.Insert([]byte{3}, 1)
+		_, ok := table.Get([]byte{3})
+		assert.True(t, ok)
+		expect := []visit{
+			{OpInsert, LayerBank1, 2}, {OpInsert, LayerBank2, 3},
+			{OpLookup, LayerBank1, 2}, {OpLookup, LayerBank2, 3},
+		}
+		assert.Equal(t, expect, visits)
+		assert.Equal(t, len(visits), left)
+	})
+
+	t.Run("profile hooks; should not affect results", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hooks = ProfileHooks(context.Background())
+
+		table.Insert([]byte("key"), 1)
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+}
+
+func TestGetWithTrace(t *testing.T) {
+	t.Run("existing key; should end with matched slot", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		v, ok, steps := table.GetWithTrace([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		require.NotEmpty(t, steps)
+		last := steps[len(steps)-1]
+		assert.True(t, last.Match)
+		assert.Equal(t, []byte("key"), table.Banks[last.Bank].Data[last.Slot].Key)
+	})
+
+	t.Run("missing key; should report every probed slot", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		var probes int
+		table.Hooks = &Hooks{Done: func(_ Op, p int, _ bool) { probes = p }}
+
+		_, ok, steps := table.GetWithTrace([]byte("missing"))
+		assert.False(t, ok)
+		assert.Len(t, steps, probes)
+		for _, step := range steps {
+			assert.Nil(t, table.Banks[step.Bank].Data[step.Slot])
+			assert.False(t, step.Match)
+		}
+	})
+}
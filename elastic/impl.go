@@ -1,164 +1,257 @@
 package elastic
 
 import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
 	"math"
-	"math/rand/v2"
-	"slices"
 )
 
 type Bank struct {
-	Data    []*Slot
+	Data    []*Slot // Size must be a power of 2
 	Inserts int
 	Seed    [32]byte
+	// Cases is a metric of inserts by the case taken in the banks pair, where this bank is Ai+1. Indexed by InsertCase
+	Cases      [insertCasesCount]int
+	thresholds bankThresholds
 }
 
 type Slot struct {
 	Key   []byte
 	Value any
+	// Version is incremented on every value update, see GetVersioned
+	Version uint64
+	seq     uint64 // Insertion order of the entry, see Dedup
+	hash    uint64 // Key hash, set if HashTable.CacheHashes is set
+	Epoch   uint32 // Table epoch the slot was written in, see Clear
+	Flags   uint8  // Application-defined entry marks, see SetFlags
+	Deleted bool   // The entry is hidden by SoftDelete
+	purged  bool   // The entry is removed by Purge, the slot may be reused
+	// ownHash is true if hash was given by InsertHashed, so it's kept regardless of HashTable.CacheHashes, since
+	// the Hasher may hash the key differently
+	ownHash bool
+	// inline keeps the key if it's not longer than inlineKeySize, so the short keys take no allocation and are read
+	// along with the slot. Key refers to it then
+	inline [inlineKeySize]byte
 }
 
-func insert(table *HashTable, hsh uint32, key []byte, value any) *Slot {
+// insert inserts a key-value pair into the table. Returns nil if no slot was found.
+func insert(table *HashTable, pr *probe, hsh uint64, key []byte, value any) *Slot {
+	if pr != nil {
+		pr.hash = hsh
+	}
+	slot := pairInsert(table, pr, hsh, key, value)
+	pr.done(key, slot != nil)
+	return slot
+}
+
+// pairInsert inserts a key-value pair into a banks pair selected by hash. Returns nil if no slot was found.
+func pairInsert(table *HashTable, pr *probe, hsh uint64, key []byte, value any) *Slot {
 	// bankIndex points to Ai+1 bank, because according to the Paper, the insertion batch Bi goes to Ai+1 bank (B0 goes to A1, etc.)
-	bankIndex := int(hsh % uint32(len(table.Banks)))
+	table.updateThresholds()
+	bankIndex := hasher.Reduce(hsh, len(table.Banks))
 	bank := table.Banks[bankIndex] // Ai+1 bank
-	epsilon2 := 1.0                // Ai+1 free slots fraction, 0..1
-	if len(bank.Data) > 0 {
-		epsilon2 = float64(len(bank.Data)-bank.Inserts) / float64(len(bank.Data))
-	}
 
 	if bankIndex == 0 {
-		if epsilon2 <= 1-table.Bank2Occupation {
+		if bank.full2() {
+			bank.Cases[InsertBatchOver]++
 			return nil // No free slots
 		}
+		bank.Cases[InsertFirstBank]++
 		probes := len(bank.Data)
-		offset := int(hsh % uint32(len(bank.Data)))
-		return bankInsert(table, bank, key, value, offset, probes)
+		offset := hasher.Reduce(hsh, len(bank.Data))
+		defer pr.enter(LayerBank2, bankIndex)()
+		return bankInsert(table, pr, bank, key, value, offset, probes)
 	}
 
 	prevBank := table.Banks[bankIndex-1] // Ai bank
-	epsilon1 := 1.0                      // Ai free slots fraction, 0..1
-	if len(prevBank.Data) > 0 {
-		epsilon1 = float64(len(prevBank.Data)-prevBank.Inserts) / float64(len(prevBank.Data))
-	}
 
 	switch {
-	case epsilon1 <= table.Delta/2 && epsilon2 <= 1-table.Bank2Occupation:
+	case prevBank.full1() && bank.full2():
 		// The Paper states, that if epsilon1 ≤ δ/2 and epsilon2 ≤ 0.25 hold simultaneously, then batch Bi is over.
+		bank.Cases[InsertBatchOver]++
 		return nil
-	case epsilon1 <= table.Delta/2:
+	case prevBank.full1():
 		// Case 2
+		bank.Cases[InsertCase2]++
 		probes := len(bank.Data)
-		offset := int(hsh % uint32(len(bank.Data)))
-		return bankInsert(table, bank, key, value, offset, probes)
-	case epsilon2 <= 1-table.Bank2Occupation:
+		offset := hasher.Reduce(hsh, len(bank.Data))
+		defer pr.enter(LayerBank2, bankIndex)()
+		return bankInsert(table, pr, bank, key, value, offset, probes)
+	case bank.full2():
 		// Case 3
+		bank.Cases[InsertCase3]++
 		probes := len(prevBank.Data)
-		offset := int(hsh % uint32(len(prevBank.Data)))
-		return bankInsert(table, prevBank, key, value, offset, probes)
+		offset := hasher.Reduce(hsh, len(prevBank.Data))
+		defer pr.enter(LayerBank1, bankIndex-1)()
+		return bankInsert(table, pr, prevBank, key, value, offset, probes)
 	}
 
 	// Case 1
 	// epsilon1 > table.Delta/2 && epsilon2 > table.Bank2Occupation
-	probes := int(table.Bank1FillFactor * min(math.Pow(math.Log2(1/epsilon1), 2), math.Log2(1/table.Delta)))
-	probes = min(probes, len(prevBank.Data))
-	offset := int(hsh % uint32(len(prevBank.Data)))
-	slot := bankInsert(table, prevBank, key, value, offset, probes) // Ai bank
+	bank.Cases[InsertCase1]++
+	probes := prevBank.probeLimit()
+	offset := hasher.Reduce(hsh, len(prevBank.Data))
+	done := pr.enter(LayerBank1, bankIndex-1)
+	slot := bankInsert(table, pr, prevBank, key, value, offset, probes) // Ai bank
+	done()
 	if slot != nil {
 		return slot
 	}
 
 	probes = len(bank.Data)
-	offset = int(hsh % uint32(len(bank.Data)))
-	return bankInsert(table, bank, key, value, offset, probes) // Ai+1 bank
+	offset = hasher.Reduce(hsh, len(bank.Data))
+	defer pr.enter(LayerBank2, bankIndex)()
+	return bankInsert(table, pr, bank, key, value, offset, probes) // Ai+1 bank
 }
 
-func bankInsert(table *HashTable, bank *Bank, key []byte, value any, idx, probes int) *Slot {
+func bankInsert(table *HashTable, pr *probe, bank *Bank, key []byte, value any, idx, probes int) *Slot {
 	// Find the first free slot by random probing
-	if probes == 0 {
+	data := bank.Data
+	mask := uint64(len(data)) - 1 // Bank size is a power of 2
+	if probes == 0 || mask >= uint64(len(data)) {
 		return nil
 	}
 	table.Rnd.Seed(bank.Seed)
+	r := uint64(idx)
 	var j int
-	for j = 0; j < probes && bank.Data[idx] != nil; j++ {
-		idx = int(table.Rnd.Uint64() % uint64(len(bank.Data)))
+	for j = 0; j < probes; j++ {
+		if !pr.count(1) {
+			return nil
+		}
+		if pr.vacant(data[r&mask]) {
+			break
+		}
+		r = table.Rnd.Uint64()
 	}
 	if j == probes {
 		return nil // No free slots
 	}
-	bank.Data[idx] = newSlot(key, value)
+	slot := pr.slot(data[r&mask], key, value)
+	data[r&mask] = slot
 	bank.Inserts++
 	table.Inserts++
-	return bank.Data[idx]
+	return slot
 }
 
-func lookup(table *HashTable, hsh uint32, key []byte) (*Slot, bool) {
+// lookup searches for a key in the table.
+func lookup(table *HashTable, pr *probe, hsh uint64, key []byte) (*Slot, bool) {
+	if pr != nil {
+		pr.hash = hsh
+	}
+	slot, ok := pairLookup(table, pr, hsh, key)
+	pr.done(key, ok)
+	return slot, ok
+}
+
+// pairLookup searches for a key in a banks pair selected by hash.
+func pairLookup(table *HashTable, pr *probe, hsh uint64, key []byte) (*Slot, bool) {
 	// bankIndex points to Ai+1 bank, because according to the Paper, the insertion batch Bi goes to Ai+1 bank (B0 goes to A1, etc.)
-	bankIndex := int(hsh % uint32(len(table.Banks)))
+	table.updateThresholds()
+	bankIndex := hasher.Reduce(hsh, len(table.Banks))
 	bank := table.Banks[bankIndex] // Ai+1 bank
 	if bankIndex == 0 {
-		offset := int(hsh % uint32(len(bank.Data)))
+		offset := hasher.Reduce(hsh, len(bank.Data))
 		probes := len(bank.Data)
 		table.Rnd.Seed(bank.Seed)
-		if idx, ok := bankLookup(bank, key, offset, probes, table.Rnd); ok {
+		defer pr.enter(LayerBank2, bankIndex)()
+		if idx, ok := bankLookup(pr, bank, key, offset, probes, table.Rnd); ok {
 			return bank.Data[idx], true
 		}
 		return nil, false
 	}
 
-	epsilon1 := 1.0                      // Ai free slots fraction, 0..1
 	prevBank := table.Banks[bankIndex-1] // Ai bank
-	if len(prevBank.Data) > 0 {
-		epsilon1 = float64(len(prevBank.Data)-prevBank.Inserts) / float64(len(prevBank.Data))
-	}
 
 	// Probe items from the most probable cases to the least probable, see the Paper pages 8-9
 	// Limited probe the Ai bank (case 1)
-	probes1 := int(table.Bank1FillFactor * min(math.Pow(math.Log2(1/epsilon1), 2), math.Log2(1/table.Delta)))
-	probes1 = min(probes1, len(prevBank.Data))
-	offset1 := int(hsh % uint32(len(prevBank.Data)))
+	probes1 := prevBank.probeLimit()
+	offset1 := hasher.Reduce(hsh, len(prevBank.Data))
 	table.Rnd.Seed(prevBank.Seed)
-	idx1, ok := bankLookup(prevBank, key, offset1, probes1, table.Rnd)
+	done := pr.enter(LayerBank1, bankIndex-1)
+	idx1, ok := bankLookup(pr, prevBank, key, offset1, probes1, table.Rnd)
+	done()
 	if ok {
 		return prevBank.Data[idx1], true
 	}
 
 	// Probe the Ai+1 bank (case 2)
 	probes2 := len(bank.Data)
-	offset2 := int(hsh % uint32(len(bank.Data)))
+	offset2 := hasher.Reduce(hsh, len(bank.Data))
 	table.Rnd2.Seed(bank.Seed)
-	if idx, ok := bankLookup(bank, key, offset2, probes2, table.Rnd2); ok {
-		return bank.Data[idx], true
+	done = pr.enter(LayerBank2, bankIndex)
+	idx2, ok := bankLookup(pr, bank, key, offset2, probes2, table.Rnd2)
+	done()
+	if ok {
+		return bank.Data[idx2], true
 	}
 
 	// Resume probing the Ai bank (case 3)
 	probes1 = len(prevBank.Data) - probes1
-	if idx1, ok = bankLookup(prevBank, key, idx1, probes1, table.Rnd); ok {
+	switch {
+	case table.MaxResumeProbes == NoResume:
+		return nil, false
+	case table.MaxResumeProbes > 0:
+		probes1 = min(probes1, table.MaxResumeProbes)
+	}
+	defer pr.enter(LayerBank1, bankIndex-1)()
+	if idx1, ok = bankLookup(pr, prevBank, key, idx1, probes1, table.Rnd); ok {
 		return prevBank.Data[idx1], true
 	}
 	return nil, false
 }
 
-// bankLookup searches for a key in the bank by random probing.
+// limitedProbes returns the number of slots to probe in the Ai bank of the given size and free slots fraction in
+// case 1, see the Paper page 8.
+func limitedProbes(table *HashTable, epsilon float64, size int) int {
+	probes := int(table.Bank1FillFactor * min(math.Pow(math.Log2(1/epsilon), 2), math.Log2(1/table.Delta)))
+	return min(probes, size)
+}
+
+// bankLookup searches for a key in the bank by random probing. Stops on the first free slot, since the inserted key
+// would have taken it.
 //
 // Returns the index of the key and true if the key is found, or the next index to probe and false if the key is not found.
-func bankLookup(bank *Bank, key []byte, idx, probes int, rnd *rand.ChaCha8) (int, bool) {
+func bankLookup(pr *probe, bank *Bank, key []byte, idx, probes int, rnd *probeRand) (int, bool) {
+	data := bank.Data
+	mask := uint64(len(data)) - 1 // Bank size is a power of 2
+	if mask >= uint64(len(data)) {
+		return idx, false
+	}
+	r := uint64(idx)
 	// Random probing
 	for j := 0; j < probes; j++ {
-		if bank.Data[idx] == nil {
-			continue
+		if !pr.count(1) {
+			break
+		}
+		slot := data[r&mask]
+		live := pr.live(slot)
+		pr.visit(int(r&mask), live)
+		if !live {
+			break
 		}
-		if slices.Equal(bank.Data[idx].Key, key) {
-			return idx, true
+		if pr.found(slot, key) {
+			return int(r & mask), true
 		}
-		idx = int(rnd.Uint64() % uint64(len(bank.Data)))
+		r = rnd.Uint64()
 	}
 
-	return idx, false
+	return int(r & mask), false
 }
 
-func newSlot(key []byte, value any) *Slot {
-	return &Slot{
-		Key:   key,
+func newSlot(key []byte, value any, epoch uint32) *Slot {
+	s := &Slot{
 		Value: value,
+		Epoch: epoch,
+	}
+	s.setKey(key)
+	return s
+}
+
+// setKey sets the slot key, the short keys are copied into the slot itself.
+func (s *Slot) setKey(key []byte) {
+	if n := len(key); n > 0 && n <= inlineKeySize {
+		s.Key = s.inline[:n:n]
+		copy(s.Key, key)
+		return
 	}
+	s.Key = key
 }
@@ -6,7 +6,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"math"
-	"math/rand/v2"
 	"slices"
 	"testing"
 )
@@ -31,28 +30,28 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           0.1,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		keys := []byte{7, 4, 19, 33, 47}
 		//rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
 		t.Logf("keys: %#v", keys)
 
-		var hashes []uint32
+		var hashes []uint64
 		for _, k := range keys {
-			hashes = append(hashes, uint32(k))
+			hashes = append(hashes, uint64(k))
 		}
 
 		for i, k := range keys {
-			slot := insert(&table, hashes[i], []byte{k}, []byte{k})
+			slot := insert(&table, nil, hashes[i], []byte{k}, []byte{k})
 			assert.NotNil(t, slot)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
 		}
 
 		for i, k := range keys {
-			slot, ok := lookup(&table, hashes[i], []byte{k})
+			slot, ok := lookup(&table, nil, hashes[i], []byte{k})
 			assert.True(t, ok)
 			assert.NotNil(t, slot)
 			assert.Equal(t, []byte{k}, slot.Key)
@@ -71,17 +70,17 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           0.1,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		key := byte(len(banks))
 
 		expectData := make([]*Slot, len(banks[0].Data))
-		hsh := uint32(key)
-		expectData[hsh%uint32(len(banks[0].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		hsh := uint64(key)
+		expectData[hsh%uint64(len(banks[0].Data))] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -104,16 +103,16 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           0.1,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		key := byte(len(banks))
 		banks[0].Inserts = int(float64(len(banks[0].Data)) * bank2Occupation)
 
-		hsh := uint32(key)
+		hsh := uint64(key)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.Nil(t, slot)
 
 		for bank := range banks {
@@ -132,17 +131,17 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           0.1,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		key := byte(len(banks) + 1) // banks[1]
 
 		expectData := make([]*Slot, len(banks[1].Data))
-		hsh := uint32(key)
-		expectData[hsh%uint32(len(banks[1].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		hsh := uint64(key)
+		expectData[hsh%uint64(len(banks[1].Data))] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -165,18 +164,18 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           0.1,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		key := byte(len(banks) + 1) // banks[1]
 		banks[0].Inserts = 4
 
 		expectData := make([]*Slot, len(banks[0].Data))
-		hsh := uint32(key)
-		expectData[hsh%uint32(len(banks[0].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		hsh := uint64(key)
+		expectData[hsh%uint64(len(banks[0].Data))] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -203,8 +202,8 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           delta,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		banks[0].Inserts = int(probes + 1)
@@ -217,10 +216,10 @@ func TestInsert(t *testing.T) {
 		key := byte(len(banks) + 1) // banks[1]
 
 		expectData := make([]*Slot, len(banks[1].Data))
-		hsh := uint32(key)
-		expectData[hsh%uint32(len(banks[1].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		hsh := uint64(key)
+		expectData[hsh%uint64(len(banks[1].Data))] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -244,8 +243,8 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           delta,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		banks[0].Inserts = len(banks[0].Data) - int(float64(len(banks[0].Data))*(delta/2))
@@ -253,10 +252,10 @@ func TestInsert(t *testing.T) {
 		key := byte(len(banks) + 1) // banks[1]
 
 		expectData := make([]*Slot, len(banks[1].Data))
-		hsh := uint32(key)
-		expectData[hsh%uint32(len(banks[1].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		hsh := uint64(key)
+		expectData[hsh%uint64(len(banks[1].Data))] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -281,8 +280,8 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           delta,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		banks[1].Inserts = int(float64(len(banks[1].Data)) * bank2Occupation)
@@ -290,10 +289,10 @@ func TestInsert(t *testing.T) {
 		key := byte(len(banks) + 1) // banks[1]
 
 		expectData := make([]*Slot, len(banks[0].Data))
-		hsh := uint32(key)
-		expectData[hsh%uint32(len(banks[0].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		hsh := uint64(key)
+		expectData[hsh%uint64(len(banks[0].Data))] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -317,17 +316,17 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           delta,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		banks[0].Inserts = len(banks[0].Data) - int(float64(len(banks[0].Data))*(delta/2))
 		banks[1].Inserts = int(float64(len(banks[1].Data)) * bank2Occupation)
 
 		key := byte(len(banks) + 1) // banks[1]
-		hsh := uint32(key)
+		hsh := uint64(key)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.Nil(t, slot)
 
 		for bank := range banks {
@@ -348,8 +347,8 @@ func TestInsert(t *testing.T) {
 			Capacity:        capacity,
 			Delta:           delta,
 			Banks:           banks,
-			Rnd:             rand.NewChaCha8([32]byte{}),
-			Rnd2:            rand.NewChaCha8([32]byte{}),
+			Rnd:             newProbeRand([32]byte{}),
+			Rnd2:            newProbeRand([32]byte{}),
 		}
 
 		banks[0].Inserts = len(banks[0].Data) - int(float64(len(banks[0].Data))*(delta/2))
@@ -362,21 +361,24 @@ func TestInsert(t *testing.T) {
 		banks[0].Data = slices.Clone(data0)
 
 		key := byte(len(banks) + 1) // banks[1]
-		hsh := uint32(key)
+		hsh := uint64(key)
 
-		rnd := rand.NewChaCha8(rndSeed)
+		rnd := newProbeRand(rndSeed)
 		data1 := make([]*Slot, len(banks[1].Data))
-		idx := int(hsh % uint32(len(banks[1].Data)))
+		idx := int(hsh % uint64(len(banks[1].Data)))
 		for i := 0; i < banks[1].Inserts; i++ {
 			data1[idx] = &Slot{} // Dummy slot
 			idx = int(rnd.Uint64() % uint64(len(banks[1].Data)))
 		}
+		for data1[idx] != nil { // The probe sequence may repeat a slot
+			idx = int(rnd.Uint64() % uint64(len(banks[1].Data)))
+		}
 		banks[1].Data = slices.Clone(data1)
 
 		expectData := slices.Clone(data1)
-		expectData[idx] = &Slot{Key: []byte{key}, Value: []byte{key}}
+		expectData[idx] = newSlot([]byte{key}, []byte{key}, 0)
 
-		slot := insert(&table, hsh, []byte{key}, []byte{key})
+		slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 		assert.NotNil(t, slot)
 		assert.Equal(t, []byte{key}, slot.Key)
 		assert.Equal(t, []byte{key}, slot.Value)
@@ -402,8 +404,8 @@ func TestInsert(t *testing.T) {
 					Capacity:        capacity,
 					Delta:           0.1,
 					Banks:           banks,
-					Rnd:             rand.NewChaCha8([32]byte{}),
-					Rnd2:            rand.NewChaCha8([32]byte{}),
+					Rnd:             newProbeRand([32]byte{}),
+					Rnd2:            newProbeRand([32]byte{}),
 				}
 
 				for bank, size := range banksCounts {
@@ -414,9 +416,9 @@ func TestInsert(t *testing.T) {
 				}
 
 				key := byte(len(banks) + tbank) // banks[1]
-				hsh := uint32(key)
+				hsh := uint64(key)
 
-				slot := insert(&table, hsh, []byte{key}, []byte{key})
+				slot := insert(&table, nil, hsh, []byte{key}, []byte{key})
 				assert.Nil(t, slot)
 			})
 		}
@@ -446,8 +448,8 @@ func TestLookup(t *testing.T) {
 					Capacity:        capacity,
 					Delta:           0.1,
 					Banks:           banks,
-					Rnd:             rand.NewChaCha8([32]byte{}),
-					Rnd2:            rand.NewChaCha8([32]byte{}),
+					Rnd:             newProbeRand([32]byte{}),
+					Rnd2:            newProbeRand([32]byte{}),
 				}
 
 				for bank, size := range banksCounts {
@@ -458,10 +460,10 @@ func TestLookup(t *testing.T) {
 				}
 
 				key := byte(len(banks) + tbank) // banks[1]
-				hsh := uint32(key)
-				banks[tbank].Data[hsh%uint32(len(banks[tbank].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
+				hsh := uint64(key)
+				banks[tbank].Data[hsh%uint64(len(banks[tbank].Data))] = &Slot{Key: []byte{key}, Value: []byte{key}}
 
-				slot, ok := lookup(&table, hsh, []byte{key})
+				slot, ok := lookup(&table, nil, hsh, []byte{key})
 				assert.True(t, ok)
 				assert.NotNil(t, slot)
 				assert.Equal(t, []byte{key}, slot.Key)
@@ -484,8 +486,8 @@ func TestLookup(t *testing.T) {
 					Capacity:        capacity,
 					Delta:           0.1,
 					Banks:           banks,
-					Rnd:             rand.NewChaCha8([32]byte{}),
-					Rnd2:            rand.NewChaCha8([32]byte{}),
+					Rnd:             newProbeRand([32]byte{}),
+					Rnd2:            newProbeRand([32]byte{}),
 				}
 
 				for bank, size := range banksCounts {
@@ -497,17 +499,17 @@ func TestLookup(t *testing.T) {
 
 				key := byte(len(banks) + tbank) // banks[tbank]
 
-				hsh := uint32(key)
-				rnd := rand.NewChaCha8(rndSeed)
+				hsh := uint64(key)
+				rnd := newProbeRand(rndSeed)
 				data1 := make([]*Slot, len(banks[tbank].Data))
-				idx := int(hsh % uint32(len(banks[tbank].Data)))
+				idx := int(hsh % uint64(len(banks[tbank].Data)))
 				for i := 0; i < len(banks[tbank].Data)-2; i++ {
 					data1[idx] = &Slot{} // Dummy slot
 					idx = int(rnd.Uint64() % uint64(len(banks[tbank].Data)))
 				}
 				banks[tbank].Data[idx] = &Slot{Key: []byte{key}, Value: []byte{key}}
 
-				slot, ok := lookup(&table, hsh, []byte{key})
+				slot, ok := lookup(&table, nil, hsh, []byte{key})
 				assert.True(t, ok)
 				assert.NotNil(t, slot)
 				assert.Equal(t, []byte{key}, slot.Key)
@@ -529,8 +531,8 @@ func TestLookup(t *testing.T) {
 					Capacity:        capacity,
 					Delta:           0.1,
 					Banks:           banks,
-					Rnd:             rand.NewChaCha8([32]byte{}),
-					Rnd2:            rand.NewChaCha8([32]byte{}),
+					Rnd:             newProbeRand([32]byte{}),
+					Rnd2:            newProbeRand([32]byte{}),
 				}
 
 				for bank, size := range banksCounts {
@@ -541,9 +543,9 @@ func TestLookup(t *testing.T) {
 				}
 
 				key := byte(len(banks) + tbank) // banks[tbank]
-				hsh := uint32(key)
+				hsh := uint64(key)
 
-				_, ok := lookup(&table, hsh, []byte{key})
+				_, ok := lookup(&table, nil, hsh, []byte{key})
 				assert.False(t, ok)
 			})
 		}
@@ -562,16 +564,23 @@ func TestLookup(t *testing.T) {
 					Capacity:        capacity,
 					Delta:           0.1,
 					Banks:           banks,
-					Rnd:             rand.NewChaCha8([32]byte{}),
-					Rnd2:            rand.NewChaCha8([32]byte{}),
+					Rnd:             newProbeRand([32]byte{}),
+					Rnd2:            newProbeRand([32]byte{}),
 				}
 
 				key := byte(len(banks) + tbank) // banks[tbank]
-				hsh := uint32(key)
+				hsh := uint64(key)
 
-				_, ok := lookup(&table, hsh, []byte{key})
+				_, ok := lookup(&table, nil, hsh, []byte{key})
 				assert.False(t, ok)
 			})
 		}
 	})
 }
+
+func TestNewHashTable(t *testing.T) {
+	t.Run("capacity not representable on the platform; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewHashTableDefault(math.MaxInt) })
+		assert.Panics(t, func() { NewHashTableDefault(maxSlots/4 + 1) })
+	})
+}
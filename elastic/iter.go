@@ -0,0 +1,51 @@
+package elastic
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the table entries, e.g. to copy them into another table. The soft-deleted entries are
+// skipped. If Spill has the All method of the same signature, the spilled entries follow the table ones, see
+// Stash.All.
+//
+// The order is unspecified. The table must not be modified during iteration.
+func (t *HashTable) All() iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		for slot := range t.slots() {
+			if !yield(t.exportKey(slot.Key), slot.Value) {
+				return
+			}
+		}
+		if s, ok := t.Spill.(interface{ All() iter.Seq2[[]byte, any] }); ok {
+			for k, v := range s.All() {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// All returns an iterator over the stash entries. The order is unspecified.
+func (s *Stash) All() iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		for _, slot := range s.slots {
+			if slot != nil && !yield(slot.Key, slot.Value) {
+				return
+			}
+		}
+	}
+}
+
+// slots returns an iterator over the slots of visible entries in the table.
+func (t *HashTable) slots() iter.Seq[*Slot] {
+	return func(yield func(*Slot) bool) {
+		for _, b := range t.Banks {
+			for _, s := range b.Data {
+				if !vacant(s, t.Epoch) && !s.Deleted && !yield(s) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	t.Run("iterate full table; should yield every visible entry once", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		table.SoftDelete([]byte("0"))
+
+		entries := make(map[string]any)
+		for k, v := range table.All() {
+			entries[string(k)] = v
+		}
+
+		assert.Len(t, entries, n-1)
+		for i := 1; i < n; i++ {
+			assert.Equal(t, i, entries[fmt.Sprint(i)])
+		}
+	})
+
+	t.Run("break the loop; should stop iteration", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		fillTable(t, table)
+
+		var n int
+		for range table.All() {
+			n++
+			break
+		}
+
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("table with stash; should yield the stashed entries too", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		stash := table.UseStash()
+		for i := 0; i < table.Cap(); i++ {
+			table.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		var n int
+		for range table.All() {
+			n++
+		}
+
+		assert.Positive(t, stash.Len())
+		assert.Equal(t, table.Len(), n)
+	})
+}
@@ -0,0 +1,122 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestKeyComparer(t *testing.T) {
+	t.Run("case-insensitive keys; should find the key in any case", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.KeyCanon = bytes.ToLower
+		table.Insert([]byte("Example.COM"), 1)
+
+		v, ok := table.Get([]byte("example.com"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.True(t, table.Set([]byte("EXAMPLE.com"), 2))
+		v, ok = table.Get([]byte("Example.Com"))
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+		assert.Equal(t, 1, table.Len())
+	})
+
+	t.Run("custom equality; should be used for comparison", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.KeyCanon = func(key []byte) []byte { return key[:min(len(key), 3)] }
+		var compared int
+		table.KeyEqual = func(a, b []byte) bool {
+			compared++
+			return bytes.Equal(a, b)
+		}
+		table.Insert([]byte("abcdef"), 1)
+
+		v, ok := table.Get([]byte("abcxyz"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.NotZero(t, compared)
+	})
+}
+
+// compositeID is a key implementing KeyAppender.
+type compositeID struct {
+	tenant uint32
+	id     uint64
+}
+
+func (c compositeID) MarshalBinary() ([]byte, error) { return c.AppendKey(nil), nil }
+
+func (c compositeID) AppendKey(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint32(b, c.tenant), c.id)
+}
+
+func TestStructuredKeys(t *testing.T) {
+	t.Run("appender keys; should insert and look up without allocations", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		var inserted []int // Elastic may fail to insert a key well below capacity
+		for i := 0; i < 10; i++ {
+			if err := table.InsertK(compositeID{tenant: 1, id: uint64(i)}, i); err == nil {
+				inserted = append(inserted, i)
+			} else {
+				assert.ErrorIs(t, err, ErrFull)
+			}
+		}
+
+		for _, i := range inserted {
+			v, ok, err := table.GetK(compositeID{tenant: 1, id: uint64(i)})
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+		_, ok, err := table.GetK(compositeID{tenant: 2, id: 1})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Zero(t, testing.AllocsPerRun(100, func() {
+			table.GetK(compositeID{tenant: 1, id: 5})
+		}))
+	})
+}
+
+func TestCopyKeys(t *testing.T) {
+	t.Run("insert keys from reused buffer with CopyKeys; should keep the keys intact", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.CopyKeys = true
+		buf := make([]byte, 0, 16)
+		var inserted [][]byte
+		for i := 0; i < 100; i++ {
+			buf = binary.BigEndian.AppendUint64(buf[:0], uint64(i))
+			if table.TryInsert(buf, i) == nil {
+				inserted = append(inserted, bytes.Clone(buf))
+			}
+		}
+
+		require.NotEmpty(t, inserted)
+		for _, k := range inserted {
+			v, ok := table.Get(k)
+			assert.True(t, ok)
+			assert.Equal(t, binary.BigEndian.Uint64(k), uint64(v.(int)))
+		}
+	})
+	t.Run("load missing structured key with Loader; should not keep the scratch buffer", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.Loader = func(key []byte) (any, error) { return binary.BigEndian.Uint64(key[4:]), nil }
+		var loaded []compositeID
+		for i := 0; i < 10; i++ {
+			k := compositeID{tenant: 1, id: uint64(i)}
+			if _, ok, err := table.GetK(k); ok && err == nil {
+				loaded = append(loaded, k)
+			}
+		}
+
+		require.NotEmpty(t, loaded)
+		table.Loader = nil
+		for _, k := range loaded {
+			v, ok := table.Get(k.AppendKey(nil))
+			assert.True(t, ok)
+			assert.Equal(t, k.id, v)
+		}
+	})
+}
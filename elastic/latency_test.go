@@ -0,0 +1,100 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHitRatio(t *testing.T) {
+	t.Run("hits and misses; should count the lookups", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		assert.Zero(t, table.HitRatio())
+		table.Insert([]byte("key"), 1)
+
+		table.Get([]byte("key"))
+		table.Get([]byte("key"))
+		table.Get([]byte("key"))
+		table.Get([]byte("missing"))
+
+		assert.Equal(t, 3, table.Hits)
+		assert.Equal(t, 1, table.Misses)
+		assert.InDelta(t, 0.75, table.HitRatio(), 1e-9)
+		var buf bytes.Buffer
+		require.NoError(t, table.WriteOpenMetrics(&buf, "t"))
+		assert.Contains(t, buf.String(), `efh_lookups_total{table="t",result="hit"} 3`+"\n")
+		assert.Contains(t, buf.String(), `efh_lookups_total{table="t",result="miss"} 1`+"\n")
+	})
+}
+
+func TestLayerLatency(t *testing.T) {
+	t.Run("sample every operation; should measure every layer probing", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.LatencySample = 1
+		var layers int
+		table.Hooks = &Hooks{Layer: func(Op, Layer, int) func() {
+			layers++
+			return nil
+		}}
+
+		table.Insert([]byte("key"), 1)
+		table.Get([]byte("key"))
+
+		var count int
+		for _, lat := range table.LayerLatency {
+			count += lat.Count
+			assert.LessOrEqual(t, lat.Mean(), lat.Max)
+		}
+		assert.Equal(t, layers, count)
+	})
+
+	t.Run("sample every 2nd operation; should measure half of them", func(t *testing.T) {
+		table, all := NewHashTableDefault(100), NewHashTableDefault(100)
+		all.Hasher = table.Hasher // The key is probed in the same layers
+		table.Insert([]byte("key"), 1)
+		all.Insert([]byte("key"), 1)
+		table.LatencySample, all.LatencySample = 2, 1
+
+		for i := 0; i < 10; i++ {
+			table.Get([]byte("key"))
+			all.Get([]byte("key"))
+		}
+
+		var sampled, measured int
+		for l := range table.LayerLatency {
+			sampled += table.LayerLatency[l].Count
+			measured += all.LayerLatency[l].Count
+		}
+		assert.Equal(t, measured/2, sampled)
+	})
+
+	t.Run("stats; should report the sampled latency", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.LatencySample = 1
+		table.LayerLatency[0] = Latency{Count: 2, Total: 3 * time.Microsecond, Max: 2 * time.Microsecond}
+
+		var buf bytes.Buffer
+		require.NoError(t, table.StatsJSON(&buf))
+		var stats struct {
+			LayerLatency map[string]latencyStats `json:"layer_latency"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &stats))
+
+		assert.Equal(t, latencyStats{Count: 2, MeanNs: 1500, MaxNs: 2000}, stats.LayerLatency[Layer(0).String()])
+		buf.Reset()
+		require.NoError(t, table.WriteOpenMetrics(&buf, "t"))
+		assert.Contains(t, buf.String(), `efh_layer_latency_seconds_count{table="t",layer="`+Layer(0).String()+`"} 2`+"\n")
+	})
+
+	t.Run("no latency sample; should not measure", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+
+		table.Insert([]byte("key"), 1)
+		table.Get([]byte("key"))
+
+		assert.Equal(t, [layersCount]Latency{}, table.LayerLatency)
+	})
+}
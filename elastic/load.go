@@ -0,0 +1,16 @@
+package elastic
+
+// GetOrLoad returns a value for a key. If the key does not exist, it calls loader and inserts its result, so
+// the table works as a read-through cache. Concurrent GetOrLoad calls for the same missing key share a single
+// loader call.
+//
+// GetOrLoad calls are safe for concurrent use with each other, but not with other table methods. Loader is called
+// without holding the lock. If loader fails, its error is returned to all waiting callers and nothing is inserted.
+// If the loaded value cannot be inserted, it is returned along with the TryInsert error.
+func (t *HashTable) GetOrLoad(key []byte, loader func() (any, error)) (any, error) {
+	get := func() (any, bool) {
+		v, ok, _ := t.get(newProbe(t, OpLookup), key)
+		return v, ok
+	}
+	return t.loads.Do(t.canonKey(key), get, loader, t.TryInsert)
+}
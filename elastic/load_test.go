@@ -0,0 +1,104 @@
+package elastic
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	t.Run("concurrent loads of missing key; should call loader once", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		var calls atomic.Int32
+		release := make(chan struct{})
+		loader := func() (any, error) {
+			calls.Add(1)
+			<-release
+			return 42, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := table.GetOrLoad([]byte("key"), loader)
+				assert.NoError(t, err)
+				assert.Equal(t, 42, v)
+			}()
+		}
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load())
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 42, v)
+		assert.Equal(t, 1, table.Len())
+	})
+}
+
+func TestLoaderWriter(t *testing.T) {
+	t.Run("get missing key with loader; should load and insert it once", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		var calls int
+		table.Loader = func(key []byte) (any, error) {
+			calls++
+			return string(key) + "-loaded", nil
+		}
+
+		for i := 0; i < 2; i++ {
+			v, ok := table.Get([]byte("key"))
+			assert.True(t, ok)
+			assert.Equal(t, "key-loaded", v)
+		}
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, 1, table.Len())
+	})
+
+	t.Run("loader fails; should return error and insert nothing", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		errLoad := errors.New("load failed")
+		table.Loader = func([]byte) (any, error) { return nil, errLoad }
+
+		_, ok, err := table.TryGet([]byte("key"))
+
+		assert.False(t, ok)
+		assert.ErrorIs(t, err, errLoad)
+		assert.Zero(t, table.Len())
+	})
+
+	t.Run("set with writer; should write value through", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		store := map[string]any{}
+		table.Writer = func(key []byte, value any) error {
+			store[string(key)] = value
+			return nil
+		}
+
+		table.Set([]byte("key"), 1)
+		table.Set([]byte("key"), 2)
+
+		assert.Equal(t, map[string]any{"key": 2}, store)
+		v, _ := table.Get([]byte("key"))
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("writer fails; should return error and keep table unchanged", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Set([]byte("key"), 1)
+		errWrite := errors.New("write failed")
+		table.Writer = func([]byte, any) error { return errWrite }
+
+		_, err := table.TrySet([]byte("key"), 2)
+		assert.ErrorIs(t, err, errWrite)
+		assert.PanicsWithError(t, errWrite.Error(), func() { table.Set([]byte("other"), 1) })
+
+		v, _ := table.Get([]byte("key"))
+		assert.Equal(t, 1, v)
+		_, ok := table.Get([]byte("other"))
+		assert.False(t, ok)
+	})
+}
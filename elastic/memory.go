@@ -0,0 +1,63 @@
+package elastic
+
+import (
+	"unsafe"
+)
+
+// Memory is the memory held by the table in bytes, see HashTable.Memory.
+type Memory struct {
+	Keys   int // Keys of the entries, see KeyBytes
+	Values int // Values of the entries, only []byte and string values are measured, see ValueBytes
+	Slots  int // Slot structures of the entries
+	Arrays int // Bank arrays of slot pointers, probe budgets and the bank structures
+}
+
+// Total returns the sum of all parts.
+func (m Memory) Total() int {
+	return m.Keys + m.Values + m.Slots + m.Arrays
+}
+
+// Memory returns the memory held by the table, e.g. to enforce a memory budget. It's calculated from the metrics
+// and the allocated arrays, so it's cheap to call. The Spill table is not counted.
+//
+// The keys are shared with the caller unless CopyKeys is set, and the removed entries stay reachable until their
+// slots are reused, see Clear. So the actual memory may differ.
+func (t *HashTable) Memory() Memory {
+	ptrSize := int(unsafe.Sizeof((*Slot)(nil)))
+	var arrays int
+	for _, b := range t.Banks {
+		arrays += int(unsafe.Sizeof(Bank{})) + cap(b.Data)*ptrSize +
+			cap(b.thresholds.budgets)*int(unsafe.Sizeof(probeBudget{}))
+	}
+	return Memory{
+		Keys:   t.KeyBytes,
+		Values: t.ValueBytes,
+		Slots:  t.Inserts * int(unsafe.Sizeof(Slot{})),
+		Arrays: arrays,
+	}
+}
+
+// account adds the sizes of an entry to KeyBytes and ValueBytes metrics if sign is 1, or subtracts them if sign is -1.
+func (t *HashTable) account(key []byte, value any, sign int) {
+	t.KeyBytes += sign * len(key)
+	t.ValueBytes += sign * valueBytes(value)
+}
+
+// setValue updates the value of an entry.
+func (t *HashTable) setValue(slot *Slot, value any) {
+	t.ValueBytes += valueBytes(value) - valueBytes(slot.Value)
+	slot.Value = value
+	slot.Version++
+	t.mutate(MutationUpdate, slot.Key, value, slot.Version)
+}
+
+// valueBytes returns the bytes held in a value, or 0 if it's not measurable.
+func valueBytes(value any) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	}
+	return 0
+}
@@ -0,0 +1,12 @@
+//go:build unix && !tinygo && !efhtiny
+
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/alloc"
+)
+
+// MmapAllocator keeps the key copies in the memory mapped outside the Go heap, so they add nothing to the garbage
+// collector work. Set CopyKeys along with it, otherwise the keys are not copied. The memory is unmapped once all keys
+// in it are removed, or by Close. The table must not be used after Close, but the keys got from it stay valid.
+type MmapAllocator = alloc.Mmap[Slot]
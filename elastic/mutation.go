@@ -0,0 +1,71 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/mutation"
+)
+
+// MutationOp is a kind of entries change reported to HashTable.OnMutation.
+type MutationOp = mutation.Op
+
+const (
+	MutationInsert = mutation.Insert // A new entry, or an entry restored by Undelete
+	MutationUpdate = mutation.Update // An entry value update
+	MutationDelete = mutation.Delete // An entry removal, or hiding by SoftDelete
+	MutationClear  = mutation.Clear  // Removal of all entries by Clear, the mutation has no key and value
+)
+
+// Mutation is a change of the table entries, see HashTable.OnMutation. The tables of all implementations report
+// the same type, so a table may replicate the table of another implementation with Apply.
+type Mutation = mutation.Mutation
+
+// mutate reports a change of the entries to OnMutation.
+func (t *HashTable) mutate(op MutationOp, key []byte, value any, version uint64) {
+	if t.OnMutation == nil {
+		return
+	}
+	t.mutations++
+	t.OnMutation(Mutation{Op: op, Key: t.exportKey(key), Value: value, Version: version, Seq: t.mutations})
+}
+
+// mutateRemoved reports the removal of an entry, unless it was reported by SoftDelete already.
+func (t *HashTable) mutateRemoved(s *Slot) {
+	if !s.Deleted {
+		t.mutate(MutationDelete, s.Key, s.Value, s.Version)
+	}
+}
+
+// MutationLog keeps the latest mutations of a table, so a replicator may resume the feed from the last mutation it
+// applied, see Replica.CatchUp. Set its Record method to OnMutation:
+//
+//	log := NewMutationLog(10000)
+//	table.OnMutation = log.Record
+type MutationLog = mutation.Log
+
+// NewMutationLog creates a new log keeping size latest mutations. Panics if size is not positive.
+func NewMutationLog(size int) *MutationLog {
+	return mutation.NewLog(size)
+}
+
+// Apply applies a mutation reported by another table, so this table becomes its copy, see Replica. The inserts and
+// updates set the value and the version of the key, purging the soft-deleted entries once if the table is full.
+// The deletions soft-delete the key.
+//
+// The mutation key is copied, since it's valid only during the OnMutation call.
+func (t *HashTable) Apply(m Mutation) error {
+	return mutation.Apply(applyTarget{t}, m, ErrFull)
+}
+
+// applyTarget is a table as the target of Apply.
+type applyTarget struct {
+	*HashTable
+}
+
+func (a applyTarget) SetVersion(key []byte, version uint64) {
+	if slot, ok := a.slot(key); ok {
+		slot.Version = version
+	}
+}
+
+func (a applyTarget) CopiesKeys() bool {
+	return a.CopyKeys
+}
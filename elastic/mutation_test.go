@@ -0,0 +1,85 @@
+package elastic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recordMutations makes the table record its mutations without Seq into the returned slice.
+func recordMutations(table *HashTable) *[]Mutation {
+	var res []Mutation
+	var seq uint64
+	table.OnMutation = func(m Mutation) {
+		seq++
+		if m.Seq != seq {
+			panic("mutation seq gap")
+		}
+		m.Key, m.Seq = append([]byte(nil), m.Key...), 0
+		res = append(res, m)
+	}
+	return &res
+}
+
+func TestOnMutation(t *testing.T) {
+	t.Run("insert, update and delete; should report them in order", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		mutations := recordMutations(table)
+
+		table.Set([]byte("key"), 1)
+		table.Set([]byte("key"), 2)
+		table.UpdateInPlace([]byte("key"), func(value *any) { *value = 3 })
+		table.SetIfVersion([]byte("key"), 4, 2)
+		table.SoftDelete([]byte("key"))
+		table.Undelete([]byte("key"))
+		table.DeleteIf(func([]byte, any) bool { return true })
+		table.Clear()
+
+		assert.Equal(t, []Mutation{
+			{Op: MutationInsert, Key: []byte("key"), Value: 1},
+			{Op: MutationUpdate, Key: []byte("key"), Value: 2, Version: 1},
+			{Op: MutationUpdate, Key: []byte("key"), Value: 3, Version: 2},
+			{Op: MutationUpdate, Key: []byte("key"), Value: 4, Version: 3},
+			{Op: MutationDelete, Key: []byte("key"), Value: 4, Version: 3},
+			{Op: MutationInsert, Key: []byte("key"), Value: 4, Version: 3},
+			{Op: MutationDelete, Key: []byte("key"), Value: 4, Version: 3},
+			{Op: MutationClear},
+		}, *mutations)
+	})
+
+	t.Run("purge soft-deleted entry; should not report it twice", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		mutations := recordMutations(table)
+
+		table.SoftDelete([]byte("key"))
+		table.Purge()
+
+		assert.Equal(t, []Mutation{{Op: MutationDelete, Key: []byte("key"), Value: 1}}, *mutations)
+	})
+
+	t.Run("evict entry; should report the victim removal", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.OnFull = func([]byte, any) FullPolicy { return FullEvictRandom }
+		mutations := recordMutations(table)
+
+		for i := 0; i < 200; i++ {
+			table.Insert([]byte{byte(i)}, i)
+		}
+
+		live := make(map[string]any)
+		for _, m := range *mutations {
+			switch m.Op {
+			case MutationInsert:
+				live[string(m.Key)] = m.Value
+			case MutationDelete:
+				assert.Contains(t, live, string(m.Key))
+				delete(live, string(m.Key))
+			}
+		}
+		all := make(map[string]any)
+		for k, v := range table.All() {
+			all[string(k)] = v
+		}
+		assert.Equal(t, all, live)
+	})
+}
@@ -0,0 +1,89 @@
+package elastic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteOpenMetrics writes the table stats to w in OpenMetrics text format, e.g. for batch jobs exporting metrics via
+// the node-exporter textfile collector. The metrics match the ones recorded by the otelmetrics module, every sample
+// is labeled with the table name.
+func (t *HashTable) WriteOpenMetrics(w io.Writer, table string) error {
+	m := openMetrics{w: w, table: table}
+	m.family("efh_capacity", "gauge", "Hash table capacity")
+	m.sample("efh_capacity", float64(t.Capacity))
+	m.family("efh_entries", "gauge", "Hash table entries")
+	m.sample("efh_entries", float64(t.Inserts))
+	m.family("efh_occupancy", "gauge", "Hash table entries to capacity ratio")
+	m.sample("efh_occupancy", float64(t.Inserts)/float64(t.Capacity))
+	m.family("efh_lookups", "counter", "Hash table lookups by result")
+	m.sample("efh_lookups_total", float64(t.Hits), "result", "hit")
+	m.sample("efh_lookups_total", float64(t.Misses), "result", "miss")
+	if t.LatencySample > 0 {
+		m.family("efh_layer_latency_seconds", "summary", "Sampled hash table layer probing time")
+		for l, lat := range t.LayerLatency {
+			m.sample("efh_layer_latency_seconds_count", float64(lat.Count), "layer", Layer(l).String())
+			m.sample("efh_layer_latency_seconds_sum", lat.Total.Seconds(), "layer", Layer(l).String())
+		}
+	}
+	m.family("efh_bank_slots", "gauge", "Elastic hash table bank slots")
+	for i, b := range t.Banks {
+		m.sample("efh_bank_slots", float64(len(b.Data)), "bank", strconv.Itoa(i))
+	}
+	m.family("efh_bank_entries", "gauge", "Elastic hash table bank entries")
+	for i, b := range t.Banks {
+		m.sample("efh_bank_entries", float64(b.Inserts), "bank", strconv.Itoa(i))
+	}
+	m.family("efh_bank_free", "gauge", "Elastic hash table bank free slots fraction")
+	for i, b := range t.Banks {
+		m.sample("efh_bank_free", freeFraction(b), "bank", strconv.Itoa(i))
+	}
+	m.family("efh_bank_cases", "counter", "Elastic hash table inserts by case taken in the pair where the bank is Ai+1")
+	for i, b := range t.Banks {
+		for c, n := range b.Cases {
+			m.sample("efh_bank_cases_total", float64(n), "bank", strconv.Itoa(i), "case", InsertCase(c).String())
+		}
+	}
+
+	m.eof()
+	return m.err
+}
+
+// labelEscaper escapes the label values in OpenMetrics text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// openMetrics writes the metric families in OpenMetrics text format. The first write error is kept, the subsequent
+// writes are skipped.
+type openMetrics struct {
+	w     io.Writer
+	table string
+	err   error
+}
+
+// family writes the metric family metadata. Its samples must follow.
+func (m *openMetrics) family(name, typ, help string) {
+	m.printf("# TYPE %s %s\n# HELP %s %s\n", name, typ, name, help)
+}
+
+// sample writes the metric sample with the table label and the given label name-value pairs.
+func (m *openMetrics) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(`table="` + labelEscaper.Replace(m.table) + `"`)
+	for i := 0; i+1 < len(labels); i += 2 {
+		b.WriteString(`,` + labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	m.printf("%s{%s} %s\n", name, b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// eof writes the end of the exposition.
+func (m *openMetrics) eof() {
+	m.printf("# EOF\n")
+}
+
+func (m *openMetrics) printf(format string, args ...any) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
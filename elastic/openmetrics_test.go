@@ -0,0 +1,41 @@
+package elastic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestWriteOpenMetrics(t *testing.T) {
+	t.Run("write table stats; should produce OpenMetrics text", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		for i := 0; i < 50; i++ {
+			_ = table.TryInsert([]byte(fmt.Sprint(i)), i)
+		}
+		var buf bytes.Buffer
+
+		require.NoError(t, table.WriteOpenMetrics(&buf, "sessions \"1\""))
+
+		out := buf.String()
+		label := `"sessions \"1\""`
+		assert.Contains(t, out, "# TYPE efh_capacity gauge\n")
+		assert.Contains(t, out, fmt.Sprintf("efh_capacity{table=%s} %d\n", label, table.Cap()))
+		assert.Contains(t, out, fmt.Sprintf("efh_entries{table=%s} %d\n", label, table.Len()))
+		assert.Contains(t, out, "efh_bank_entries{table="+label+`,bank="0"} `)
+		assert.True(t, strings.HasSuffix(out, "\n# EOF\n"))
+	})
+
+	t.Run("writer fails; should return error", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+
+		assert.Error(t, table.WriteOpenMetrics(failingWriter{}, "sessions"))
+	})
+}
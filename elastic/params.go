@@ -0,0 +1,34 @@
+package elastic
+
+// Params are the table parameters, including the derived ones, see HashTable.Params.
+type Params struct {
+	Capacity        int
+	Delta           float64 // δ parameter in Paper
+	Bank1FillFactor float64 // c parameter in Paper
+	Bank2Occupation float64
+	BankSizes       []int
+	// FreeFractions are the current free slots fractions of every bank, ε1 of a pair is the one of Ai bank and ε2 is
+	// the one of Ai+1 bank
+	FreeFractions []float64
+	// ProbeLimits are the slots probed in every bank as the 1st bank in pair (Ai bank) before falling back to
+	// the 2nd one, at the current bank fill. It's c*min(log2(1/ε)², log2(1/δ)), where ε is the bank free slots fraction
+	ProbeLimits []int
+}
+
+// Params returns the actual table parameters, e.g. to inspect the bank sizes and probe limits without repeating
+// the constructor math.
+func (t *HashTable) Params() Params {
+	p := Params{
+		Capacity:        t.Capacity,
+		Delta:           t.Delta,
+		Bank1FillFactor: t.Bank1FillFactor,
+		Bank2Occupation: t.Bank2Occupation,
+	}
+	for _, b := range t.Banks {
+		epsilon := freeFraction(b)
+		p.BankSizes = append(p.BankSizes, len(b.Data))
+		p.FreeFractions = append(p.FreeFractions, epsilon)
+		p.ProbeLimits = append(p.ProbeLimits, limitedProbes(t, epsilon, len(b.Data)))
+	}
+	return p
+}
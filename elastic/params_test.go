@@ -0,0 +1,38 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	t.Run("new table; should return constructor parameters and bank sizes", func(t *testing.T) {
+		table := NewHashTable(100, 0.1, 0.75, 200)
+
+		p := table.Params()
+
+		assert.Equal(t, 100, p.Capacity)
+		assert.Equal(t, 0.1, p.Delta)
+		assert.Equal(t, 200.0, p.Bank1FillFactor)
+		assert.Equal(t, 0.75, p.Bank2Occupation)
+		assert.Equal(t, []int{1, 2, 4, 8, 16, 32, 64, 128}, p.BankSizes)
+		assert.Equal(t, make([]int, len(p.BankSizes)), p.ProbeLimits) // log2(1/ε) is zero for empty banks
+	})
+
+	t.Run("filled table; should allow probes in filled banks within their sizes", func(t *testing.T) {
+		table := NewHashTable(1000, 0.1, 0.75, 1)
+		for i := 0; i < 500; i++ {
+			_ = table.TryInsert([]byte(fmt.Sprint(i)), i)
+		}
+
+		p := table.Params()
+
+		var total int
+		for i, limit := range p.ProbeLimits {
+			assert.LessOrEqual(t, limit, p.BankSizes[i], "bank: %v", i)
+			total += limit
+		}
+		assert.Positive(t, total)
+	})
+}
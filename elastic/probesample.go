@@ -0,0 +1,36 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/probesample"
+)
+
+// ProbeSample is the probe trace of a sampled operation, see ProbeSampler.
+type ProbeSample struct {
+	Op  Op
+	Key []byte // Canonical key of the operation
+	// Steps are the slots checked by the operation in probing order. Recorded for lookups only, see Hooks.Slot
+	Steps  []Step
+	Probes int  // Slots checked by the operation, see Hooks.Done
+	OK     bool // False if the key was not found on lookup, or there was no room for it on insert
+}
+
+// ProbeSampler records the probe traces of every n-th table operation into a ring buffer of the latest ones, see
+// HashTable.ProbeSampler. Tracing a small fraction of operations shows the pathological probe sequences in production
+// at a low cost.
+//
+// Not safe for concurrent use.
+type ProbeSampler = probesample.Sampler[ProbeSample]
+
+// NewProbeSampler creates a new sampler tracing every n-th operation and keeping size latest traces. Panics if any
+// argument is not positive.
+func NewProbeSampler(n, size int) *ProbeSampler {
+	return probesample.New[ProbeSample](n, size)
+}
+
+// DumpProbeSamples returns the probe traces recorded by ProbeSampler, the oldest first, or nil if it's not set.
+func (t *HashTable) DumpProbeSamples() []ProbeSample {
+	if t.ProbeSampler == nil {
+		return nil
+	}
+	return t.ProbeSampler.Samples()
+}
@@ -0,0 +1,31 @@
+//go:build !tinygo && !efhtiny
+
+package elastic
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// ProfileHooks returns hooks that attribute the table operations in CPU profiles and execution traces.
+//
+// Probing of every bank is wrapped in runtime/trace region named “op/layer” and labeled with pprof labels
+// "efh.op", "efh.layer" and "efh.bank". Bank indexes are bucketed by powers of 2 to keep the labels cardinality low.
+//
+// ctx is the caller context: its pprof labels are added to ours and restored after every bank.
+func ProfileHooks(ctx context.Context) *Hooks {
+	return &Hooks{
+		Layer: func(op Op, layer Layer, bank int) func() {
+			lctx := pprof.WithLabels(ctx, pprof.Labels(
+				"efh.op", string(op), "efh.layer", layer.String(), "efh.bank", bankBucketLabel(bank),
+			))
+			pprof.SetGoroutineLabels(lctx)
+			region := trace.StartRegion(lctx, string(op)+"/"+layer.String())
+			return func() {
+				region.End()
+				pprof.SetGoroutineLabels(ctx)
+			}
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestReplica(t *testing.T) {
+	t.Run("random workload; should keep the same entries as the primary", func(t *testing.T) {
+		// An elastic insert may fail below the table capacity, so the replica table places the keys the same way as
+		// the primary one, and the primary does not purge the deleted entries, which the replica keeps
+		primary := NewHashTableDefault(1000)
+		replica := NewReplica(twinTable(primary))
+		primary.OnMutation = func(m Mutation) { require.NoError(t, replica.Apply(m)) }
+		rnd := rand.New(rand.NewPCG(1, 2))
+
+		for i := 0; i < 5000; i++ {
+			key := []byte(fmt.Sprint(rnd.IntN(500)))
+			switch p := rnd.IntN(100); {
+			case p < 60:
+				_, _ = primary.TrySet(key, i) // The failed inserts are not reported
+			case p < 70:
+				primary.UpdateInPlace(key, func(value *any) { *value = -i })
+			case p < 99:
+				primary.SoftDelete(key)
+			default:
+				primary.Clear()
+			}
+		}
+
+		for i := 0; i < 500; i++ {
+			key := []byte(fmt.Sprint(i))
+			want, wantVersion, wantOk := primary.GetVersioned(key)
+			got, gotVersion, gotOk := replica.GetVersioned(key)
+			require.Equal(t, wantOk, gotOk, "key: %v", i)
+			assert.Equal(t, want, got, "key: %v", i)
+			assert.Equal(t, wantVersion, gotVersion, "key: %v", i)
+		}
+		assert.Equal(t, primary.mutations, replica.Seq())
+	})
+
+	t.Run("applied key; should be copied", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		key := []byte("key-longer-than-inline")
+
+		require.NoError(t, table.Apply(Mutation{Op: MutationInsert, Key: key, Value: 1}))
+		key[0] = 'x'
+
+		v, ok := table.Get([]byte("key-longer-than-inline"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("feed broken; should catch up from the mutation log", func(t *testing.T) {
+		primary := NewHashTableDefault(100)
+		primary.Hasher, primary.HashSeed = SeededHasher(1), 1 // An elastic insert may fail below the table capacity
+		replica := NewReplica(twinTable(primary))
+		log := NewMutationLog(100)
+		primary.OnMutation = log.Record
+		primary.Insert([]byte("a"), 1)
+		primary.Set([]byte("a"), 2)
+		primary.Insert([]byte("b"), 3)
+
+		require.NoError(t, replica.CatchUp(log))
+
+		v, version, ok := replica.GetVersioned([]byte("a"))
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+		assert.Equal(t, uint64(1), version)
+		assert.Equal(t, log.Last(), replica.Seq())
+	})
+
+	t.Run("unknown mutation; should return an error", func(t *testing.T) {
+		assert.Error(t, NewHashTableDefault(100).Apply(Mutation{Op: MutationOp(10)}))
+	})
+}
+
+// twinTable returns an empty table placing the keys the same way as the given one.
+func twinTable(t *HashTable) *HashTable {
+	table := NewHashTableDefault(t.Capacity)
+	table.Hasher, table.HashSeed = t.Hasher, t.HashSeed
+	for i, b := range table.Banks {
+		b.Seed = t.Banks[i].Seed
+	}
+	return table
+}
@@ -0,0 +1,77 @@
+package elastic
+
+import (
+	"iter"
+	"math/rand/v2"
+	"slices"
+)
+
+// Sample returns an iterator over n entries picked uniformly at random without replacement, e.g. for the cache
+// warmers, the estimators or finding out the key skew. If the table has fewer entries, all of them are returned.
+// The soft-deleted entries and the Spill entries are not sampled.
+//
+// The entries are counted and picked by scanning the slots, since the elastic banks have no occupancy bitmaps.
+// The table must not be modified during iteration.
+func (t *HashTable) Sample(n int) iter.Seq2[[]byte, any] {
+	slots := t.sampleSlots(n)
+	return func(yield func([]byte, any) bool) {
+		for _, s := range slots {
+			if !yield(t.exportKey(s.Key), s.Value) {
+				return
+			}
+		}
+	}
+}
+
+// sampleSlots picks n random visible slots in random order.
+func (t *HashTable) sampleSlots(n int) []*Slot {
+	var total int
+	for range t.slots() {
+		total++
+	}
+	ranks := pickRanks(n, total, make(map[int]bool))
+	res := make([]*Slot, 0, len(ranks))
+	var rank int
+	for s := range t.slots() {
+		if len(ranks) == 0 {
+			break
+		}
+		if ranks[0] == rank {
+			res = append(res, s)
+			ranks = ranks[1:]
+		}
+		rank++
+	}
+	rand.Shuffle(len(res), func(i, j int) { res[i], res[j] = res[j], res[i] })
+	return res
+}
+
+// pickRanks picks up to n distinct ranks in range [0,total) uniformly at random, skipping and marking the picked ones.
+// Returns the ranks in ascending order.
+func pickRanks(n, total int, picked map[int]bool) []int {
+	left := total - len(picked)
+	n = min(n, left)
+	var ranks []int
+	if n > left/2 {
+		// Most of the ranks are needed, so pick them from the not yet picked ones instead of retrying
+		for r := 0; r < total; r++ {
+			if !picked[r] {
+				ranks = append(ranks, r)
+			}
+		}
+		rand.Shuffle(len(ranks), func(i, j int) { ranks[i], ranks[j] = ranks[j], ranks[i] })
+		ranks = ranks[:n]
+	} else {
+		for len(ranks) < n {
+			if r := rand.IntN(total); !picked[r] {
+				picked[r] = true
+				ranks = append(ranks, r)
+			}
+		}
+	}
+	for _, r := range ranks {
+		picked[r] = true
+	}
+	slices.Sort(ranks)
+	return ranks
+}
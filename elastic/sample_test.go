@@ -0,0 +1,93 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iter"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	t.Run("n entries; should return n distinct table entries", func(t *testing.T) {
+		table := NewHashTableDefault(2000)
+		table.UseStash() // The keys not fitting into the tiny banks are stashed, so the inserts succeed
+		for i := 0; i < 1000; i++ {
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+		}
+
+		got := collectSample(table.Sample(10))
+
+		assert.Len(t, got, 10)
+		for k, v := range got {
+			want, ok := table.Get([]byte(k))
+			require.True(t, ok)
+			assert.Equal(t, want, v)
+		}
+	})
+
+	t.Run("full table; should return all entries", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		n, _ := fillTable(t, table)
+
+		got := collectSample(table.Sample(n + 10))
+
+		assert.Len(t, got, n)
+		for k, v := range table.All() {
+			assert.Equal(t, v, got[string(k)])
+		}
+	})
+
+	t.Run("soft-deleted entries; should not be sampled", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hasher, table.HashSeed = SeededHasher(2), 2 // Fixes the banks pairs of the keys, so the inserts succeed
+		for i := 0; i < 10; i++ {
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+		}
+		for i := 0; i < 10; i += 2 {
+			table.SoftDelete([]byte(fmt.Sprint("key", i)))
+		}
+
+		got := collectSample(table.Sample(10))
+
+		assert.Equal(t, map[string]any{"key1": 1, "key3": 3, "key5": 5, "key7": 7, "key9": 9}, got)
+	})
+
+	t.Run("one entry many times; should pick every entry evenly", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hasher, table.HashSeed = SeededHasher(2), 2
+		for i := 0; i < 10; i++ {
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+		}
+
+		counts := make(map[string]int)
+		for i := 0; i < 10000; i++ {
+			for k := range table.Sample(1) {
+				counts[string(k)]++
+			}
+		}
+
+		assert.Len(t, counts, 10)
+		for k, c := range counts {
+			assert.InDelta(t, 1000, c, 200, k)
+		}
+	})
+
+	t.Run("empty table or zero n; should return nothing", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hasher, table.HashSeed = SeededHasher(2), 2
+		assert.Empty(t, collectSample(table.Sample(10)))
+
+		table.Insert([]byte("key"), 1)
+		assert.Empty(t, collectSample(table.Sample(0)))
+	})
+}
+
+// collectSample returns the entries yielded by Sample.
+func collectSample(seq iter.Seq2[[]byte, any]) map[string]any {
+	res := make(map[string]any)
+	for k, v := range seq {
+		res[string(k)] = v
+	}
+	return res
+}
@@ -0,0 +1,202 @@
+package elastic
+
+import (
+	"slices"
+)
+
+const stashMinSize = 8
+
+// Stash is a small open-addressing table with linear probing for the keys that do not fit into their banks pair.
+// Such inserts may fail well below the table capacity, since only one banks pair is considered for a key. Stash
+// grows when it's 3/4 full, so it never fails. See UseStash.
+type Stash struct {
+	Hasher   func(b []byte) uint64  // Required
+	KeyEqual func(a, b []byte) bool // Optional, slices.Equal by default
+	// Wipe zeroes the keys up to 16 bytes and the []byte values of the removed entries, see HashTable.Wipe. The longer
+	// keys are the caller's slices
+	Wipe bool
+
+	slots []*Slot
+	count int
+}
+
+// UseStash makes the table put the keys that cannot be placed into the table to a new Stash instead of failing,
+// and returns it. The stash is set to the Spill field and consulted by lookups on miss. The stashed keys are
+// counted by Len, removed by Delete, DeleteIf and Clear, and may be soft-deleted.
+//
+// The stash hashes and compares the keys with the current table Hasher and KeyEqual. It wipes the removed entries
+// if the table Wipe is set by the time of the call.
+func (t *HashTable) UseStash() *Stash {
+	s := &Stash{
+		Hasher: func(b []byte) uint64 { return t.Hasher(b) },
+		KeyEqual: func(a, b []byte) bool {
+			if t.KeyEqual == nil {
+				return slices.Equal(a, b)
+			}
+			return t.KeyEqual(a, b)
+		},
+		Wipe: t.Wipe,
+	}
+	t.Spill = s
+	t.OnFull = func([]byte, any) FullPolicy { return FullSpill }
+	return s
+}
+
+// Set sets a value for a key. If the key already exists, it updates the value and returns true. A soft-deleted key
+// is set again and can no longer be restored.
+func (s *Stash) Set(key []byte, value any) bool {
+	if idx, ok := s.find(key); ok {
+		slot := s.slots[idx]
+		existed := !slot.Deleted
+		slot.Value, slot.Deleted = value, false
+		return existed
+	}
+	if (s.count+1)*4 > len(s.slots)*3 {
+		s.grow()
+	}
+	idx, _ := s.find(key)
+	s.slots[idx] = newSlot(key, value, 0)
+	s.count++
+	return false
+}
+
+// Get returns a value for a key. If the key does not exist or is soft-deleted, it returns nil and false.
+func (s *Stash) Get(key []byte) (any, bool) {
+	if idx, ok := s.find(key); ok && !s.slots[idx].Deleted {
+		return s.slots[idx].Value, true
+	}
+	return nil, false
+}
+
+// Delete removes the entry of a key and returns true, or returns false if the key does not exist. The soft-deleted
+// entries are not found, see Purge.
+func (s *Stash) Delete(key []byte) bool {
+	idx, ok := s.find(key)
+	if !ok || s.slots[idx].Deleted {
+		return false
+	}
+	s.removeAt(idx)
+	return true
+}
+
+// SoftDelete hides an entry from lookups until Purge, so it can be restored with Undelete. Returns false if the key
+// does not exist. The soft-deleted entries are counted by Len.
+func (s *Stash) SoftDelete(key []byte) bool {
+	idx, ok := s.find(key)
+	if !ok || s.slots[idx].Deleted {
+		return false
+	}
+	s.slots[idx].Deleted = true
+	return true
+}
+
+// Undelete restores an entry hidden by SoftDelete. Returns false if there is no such entry or it was purged.
+func (s *Stash) Undelete(key []byte) bool {
+	idx, ok := s.find(key)
+	if !ok || !s.slots[idx].Deleted {
+		return false
+	}
+	s.slots[idx].Deleted = false
+	return true
+}
+
+// Purge removes the soft-deleted entries and returns their count.
+func (s *Stash) Purge() int {
+	return s.remove(func(slot *Slot) bool { return slot.Deleted })
+}
+
+// DeleteIf removes the entries fn returns true for, and returns their count. The soft-deleted entries are skipped.
+//
+// fn must not modify the stash.
+func (s *Stash) DeleteIf(fn func(key []byte, value any) bool) int {
+	return s.remove(func(slot *Slot) bool { return !slot.Deleted && fn(slot.Key, slot.Value) })
+}
+
+// Clear removes all entries from the stash.
+func (s *Stash) Clear() {
+	if s.Wipe {
+		for _, slot := range s.slots {
+			if slot != nil {
+				wipeEntry(slot, false)
+			}
+		}
+	}
+	clear(s.slots)
+	s.count = 0
+}
+
+// Len returns the number of elements in the stash, including the soft-deleted ones.
+func (s *Stash) Len() int {
+	return s.count
+}
+
+// find returns the index of the key and true if the key is found, or the index of the free slot to put it to and
+// false if the key is not found.
+func (s *Stash) find(key []byte) (int, bool) {
+	if len(s.slots) == 0 {
+		return 0, false
+	}
+	equal := s.KeyEqual
+	if equal == nil {
+		equal = slices.Equal[[]byte]
+	}
+	mask := len(s.slots) - 1 // Size is a power of 2
+	for i := s.home(key); ; i = (i + 1) & mask {
+		slot := s.slots[i]
+		if slot == nil {
+			return i, false
+		}
+		if equal(slot.Key, key) {
+			return i, true
+		}
+	}
+}
+
+// home returns the index of the first slot probed for a key.
+func (s *Stash) home(key []byte) int {
+	return int(s.Hasher(key) & uint64(len(s.slots)-1))
+}
+
+// remove removes the entries match returns true for, and returns their count.
+func (s *Stash) remove(match func(slot *Slot) bool) int {
+	var n int
+	for i := 0; i < len(s.slots); {
+		if slot := s.slots[i]; slot != nil && match(slot) {
+			s.removeAt(i) // The next entry may be shifted to i, so it's checked again
+			n++
+			continue
+		}
+		i++
+	}
+	return n
+}
+
+// removeAt empties a slot. Lookups stop at the first empty slot, so the following entries of the probe sequence are
+// shifted back to fill the gap.
+func (s *Stash) removeAt(idx int) {
+	if s.Wipe {
+		wipeEntry(s.slots[idx], false)
+	}
+	mask := len(s.slots) - 1
+	for j := (idx + 1) & mask; s.slots[j] != nil; j = (j + 1) & mask {
+		// The entry can fill the gap if the gap is between its home slot and its current slot cyclically
+		if (j-s.home(s.slots[j].Key))&mask >= (j-idx)&mask {
+			s.slots[idx] = s.slots[j]
+			idx = j
+		}
+	}
+	s.slots[idx] = nil
+	s.count--
+}
+
+// grow doubles the stash size and reinserts all keys.
+func (s *Stash) grow() {
+	old := s.slots
+	s.slots = make([]*Slot, max(2*len(old), stashMinSize))
+	for _, slot := range old {
+		if slot != nil {
+			idx, _ := s.find(slot.Key)
+			s.slots[idx] = slot
+		}
+	}
+}
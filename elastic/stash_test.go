@@ -0,0 +1,167 @@
+package elastic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStash(t *testing.T) {
+	t.Run("insert keys until banks pairs saturate; should not fail and find all keys", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		stash := table.UseStash()
+
+		for i := 0; i < table.Cap(); i++ {
+			require.NoError(t, table.TryInsert([]byte(fmt.Sprint(i)), i))
+		}
+
+		assert.Positive(t, stash.Len())
+		assert.Equal(t, table.Cap(), table.Len()) // The stashed keys are counted
+		for i := 0; i < table.Cap(); i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			require.True(t, ok, "key: %v", i)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("set keys over growth; should update existing keys", func(t *testing.T) {
+		stash := &Stash{Hasher: SeededHasher(1)}
+
+		for i := 0; i < 100; i++ {
+			assert.False(t, stash.Set([]byte(fmt.Sprint(i)), i))
+		}
+		assert.True(t, stash.Set([]byte("0"), -1))
+
+		assert.Equal(t, 100, stash.Len())
+		v, ok := stash.Get([]byte("0"))
+		assert.True(t, ok)
+		assert.Equal(t, -1, v)
+		v, ok = stash.Get([]byte("99"))
+		assert.True(t, ok)
+		assert.Equal(t, 99, v)
+		_, ok = stash.Get([]byte("missing"))
+		assert.False(t, ok)
+	})
+}
+
+func TestStashRemove(t *testing.T) {
+	fill := func(t *testing.T) (*HashTable, *Stash) {
+		table := NewHashTableDefault(100)
+		stash := table.UseStash()
+		for i := 0; i < table.Cap(); i++ {
+			require.NoError(t, table.TryInsert([]byte(fmt.Sprint(i)), i))
+		}
+		require.Positive(t, stash.Len())
+		return table, stash
+	}
+	stashed := func(stash *Stash) (keys []string) {
+		for k := range stash.All() {
+			keys = append(keys, string(k))
+		}
+		return keys
+	}
+
+	t.Run("delete stashed keys; should remove them from the stash", func(t *testing.T) {
+		table, stash := fill(t)
+		keys := stashed(stash)
+
+		for _, k := range keys {
+			require.True(t, table.Delete([]byte(k)), "key: %v", k)
+		}
+
+		assert.Zero(t, stash.Len())
+		assert.Equal(t, table.Cap()-len(keys), table.Len())
+		for _, k := range keys {
+			_, ok := table.Get([]byte(k))
+			assert.False(t, ok, "key: %v", k)
+			assert.False(t, table.Delete([]byte(k)), "key: %v", k)
+		}
+		for k := range table.All() {
+			assert.NotContains(t, keys, string(k))
+		}
+	})
+
+	t.Run("delete every other stashed key; should find the rest", func(t *testing.T) {
+		stash := &Stash{Hasher: func([]byte) uint64 { return 0 }} // All keys collide, so the deletes shift them back
+
+		for i := 0; i < 20; i++ {
+			stash.Set([]byte(fmt.Sprint(i)), i)
+		}
+		for i := 0; i < 20; i += 2 {
+			require.True(t, stash.Delete([]byte(fmt.Sprint(i))))
+		}
+
+		assert.Equal(t, 10, stash.Len())
+		for i := 0; i < 20; i++ {
+			v, ok := stash.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+			if ok {
+				assert.Equal(t, i, v)
+			}
+		}
+	})
+
+	t.Run("clear; should clear the stash", func(t *testing.T) {
+		table, stash := fill(t)
+		key := []byte(stashed(stash)[0])
+
+		table.Clear()
+
+		assert.Zero(t, stash.Len())
+		assert.Zero(t, table.Len())
+		_, ok := table.Get(key)
+		assert.False(t, ok)
+	})
+
+	t.Run("delete if; should remove the matching stashed keys", func(t *testing.T) {
+		table, stash := fill(t)
+
+		n := table.DeleteIf(func(_ []byte, value any) bool { return value.(int)%2 == 0 })
+
+		assert.Equal(t, table.Cap()/2, n)
+		assert.Equal(t, table.Cap()/2, table.Len())
+		for _, v := range stash.All() {
+			assert.Equal(t, 1, v.(int)%2)
+		}
+	})
+
+	t.Run("soft delete, undelete and purge stashed key; should follow the table semantics", func(t *testing.T) {
+		table, stash := fill(t)
+		keys := stashed(stash)
+		key := []byte(keys[0])
+
+		require.True(t, table.SoftDelete(key))
+		_, ok := table.Get(key)
+		assert.False(t, ok)
+		assert.Equal(t, table.Cap(), table.Len())
+		assert.False(t, table.Delete(key))
+
+		require.True(t, table.Undelete(key))
+		_, ok = table.Get(key)
+		assert.True(t, ok)
+
+		for _, k := range keys {
+			require.True(t, table.SoftDelete([]byte(k)))
+		}
+		assert.Equal(t, len(keys), table.Purge())
+		assert.Zero(t, stash.Len())
+		assert.False(t, table.Undelete(key))
+	})
+
+	t.Run("hasher replaced after UseStash; should hash with the table one", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		stash := table.UseStash()
+		var calls int
+		table.Hasher = func(b []byte) uint64 {
+			calls++
+			return SeededHasher(1)(b)
+		}
+
+		stash.Set([]byte("key"), 1)
+		_, ok := stash.Get([]byte("key"))
+
+		assert.True(t, ok)
+		assert.Equal(t, 2, calls)
+	})
+}
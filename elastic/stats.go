@@ -0,0 +1,92 @@
+package elastic
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"slices"
+)
+
+const statsTopProbes = 10 // Number of the longest probe sequences in stats
+
+type tableStats struct {
+	Capacity  int          `json:"capacity"`
+	Inserts   int          `json:"inserts"`
+	Banks     []bankStats  `json:"banks"`
+	TopProbes []probeStats `json:"top_probes"`         // Keys with the longest lookup probe sequences, longest first
+	HotKeys   []HotKey     `json:"hot_keys,omitempty"` // See HashTable.HotKeys
+	Hits      int          `json:"hits"`
+	Misses    int          `json:"misses"`
+	// LayerLatency is the sampled probing time by bank in pair, if HashTable.LatencySample is set
+	LayerLatency map[string]latencyStats `json:"layer_latency,omitempty"`
+}
+
+type bankStats struct {
+	Size  int            `json:"size"`
+	Used  int            `json:"used"`
+	Free  float64        `json:"free"`            // Free slots fraction, ε parameter in Paper
+	Cases map[string]int `json:"cases,omitempty"` // Inserts by case taken in the pair where this bank is Ai+1
+	// ProbeBudgets are the probe limits of the bank as Ai bank by the free slots, from the empty bank to the full one
+	ProbeBudgets []probeBudget `json:"probe_budgets"`
+}
+
+type latencyStats struct {
+	Count  int   `json:"count"`
+	MeanNs int64 `json:"mean_ns"`
+	MaxNs  int64 `json:"max_ns"`
+}
+
+type probeStats struct {
+	Key    []byte `json:"key"`
+	Probes int    `json:"probes"`
+}
+
+// StatsJSON writes the table occupancy per bank, the keys with the longest lookup probe sequences, and the hottest
+// keys if HotKeys is set to w in JSON.
+//
+// Every key in the table is looked up to find the probe lengths, so it's slow on large tables.
+func (t *HashTable) StatsJSON(w io.Writer) error {
+	stats := tableStats{
+		Capacity: t.Capacity,
+		Inserts:  t.Inserts,
+	}
+
+	var probes []probeStats
+	t.updateThresholds()
+	for _, b := range t.Banks {
+		bs := bankStats{Size: len(b.Data), Used: b.Inserts, Free: freeFraction(b), ProbeBudgets: b.thresholds.budgets}
+		for c, n := range b.Cases {
+			if n > 0 {
+				if bs.Cases == nil {
+					bs.Cases = make(map[string]int)
+				}
+				bs.Cases[InsertCase(c).String()] = n
+			}
+		}
+		stats.Banks = append(stats.Banks, bs)
+		for _, s := range b.Data {
+			if !vacant(s, t.Epoch) {
+				pr := probe{op: OpLookup, equal: t.KeyEqual, epoch: t.Epoch}
+				lookup(t, &pr, t.slotHash(s), s.Key)
+				probes = append(probes, probeStats{Key: s.Key, Probes: pr.probes})
+			}
+		}
+	}
+
+	slices.SortStableFunc(probes, func(a, b probeStats) int { return cmp.Compare(b.Probes, a.Probes) })
+	stats.TopProbes = probes[:min(len(probes), statsTopProbes)]
+	if t.HotKeys != nil {
+		stats.HotKeys = t.HotKeys.TopK()
+	}
+	stats.Hits, stats.Misses = t.Hits, t.Misses
+	if t.LatencySample > 0 {
+		stats.LayerLatency = make(map[string]latencyStats, layersCount)
+		for l, lat := range t.LayerLatency {
+			stats.LayerLatency[Layer(l).String()] = latencyStats{
+				Count: lat.Count, MeanNs: lat.Mean().Nanoseconds(), MaxNs: lat.Max.Nanoseconds(),
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(stats)
+}
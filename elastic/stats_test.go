@@ -0,0 +1,65 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestStatsJSON(t *testing.T) {
+	t.Run("table with entries; should count them and sort the probe lengths", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		var n int
+		for i := 0; i < 500; i++ {
+			if table.TryInsert([]byte(fmt.Sprint(i)), i) == nil {
+				n++
+			}
+		}
+		var buf bytes.Buffer
+
+		require.NoError(t, table.StatsJSON(&buf))
+
+		var stats tableStats
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &stats))
+		assert.Equal(t, n, stats.Inserts)
+		var used int
+		for _, b := range stats.Banks {
+			used += b.Used
+		}
+		assert.Equal(t, n, used)
+		require.Len(t, stats.TopProbes, statsTopProbes)
+		for i := 1; i < len(stats.TopProbes); i++ {
+			assert.GreaterOrEqual(t, stats.TopProbes[i-1].Probes, stats.TopProbes[i].Probes)
+		}
+		pr := probe{op: OpLookup}
+		lookup(table, &pr, table.Hasher(stats.TopProbes[0].Key), stats.TopProbes[0].Key)
+		assert.Equal(t, pr.probes, stats.TopProbes[0].Probes)
+	})
+	t.Run("probe budgets; should follow the formula of the Paper", func(t *testing.T) {
+		table := NewHashTable(1000, 0.1, 0.75, 20)
+		var buf bytes.Buffer
+
+		require.NoError(t, table.StatsJSON(&buf))
+
+		var stats tableStats
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &stats))
+		for i, b := range stats.Banks {
+			require.NotEmpty(t, b.ProbeBudgets)
+			assert.Equal(t, 0, b.ProbeBudgets[len(b.ProbeBudgets)-1].Free)
+			budget := 0
+			for free := b.Size; free >= 0; free-- {
+				for free < b.ProbeBudgets[budget].Free {
+					budget++
+				}
+				// c*min(log2(1/ε)², log2(1/δ)), but not more than the bank size
+				epsilon := float64(free) / float64(b.Size)
+				expect := int(20 * min(math.Pow(math.Log2(1/epsilon), 2), math.Log2(1/0.1)))
+				assert.Equal(t, min(expect, b.Size), b.ProbeBudgets[budget].Probes, "bank %d, free %d", i, free)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package elastic
+
+import (
+	"sort"
+)
+
+// thresholds are the table parameters the bank thresholds were computed for, see updateThresholds.
+type thresholds struct {
+	banks                                   int // Banks count, zero before the first computation
+	delta, bank2Occupation, bank1FillFactor float64
+}
+
+// bankThresholds are the integer counterparts of the float conditions of the insertion cases, so that the operations
+// compare the bank Inserts with them instead of computing ε.
+type bankThresholds struct {
+	full1 int // Inserts from which the bank is full as Ai bank, ε ≤ δ/2
+	full2 int // Inserts from which the bank is full as Ai+1 bank, ε ≤ 1-Bank2Occupation
+	// budgets are the probe limits of the bank as Ai bank by the free slots, see probeBudgets. budget is the index
+	// of the current one, it's moved as the bank fill changes
+	budgets []probeBudget
+	budget  int
+}
+
+// probeBudget is the probe limit of a bank as Ai bank, that holds while the bank has at least Free free slots, and
+// less than Free of the previous budget. See limitedProbes.
+type probeBudget struct {
+	Free   int `json:"free"`
+	Probes int `json:"probes"`
+}
+
+// updateThresholds recomputes the bank thresholds if the table parameters were changed since the last call,
+// e.g. by Tuner.
+func (t *HashTable) updateThresholds() {
+	p := thresholds{len(t.Banks), t.Delta, t.Bank2Occupation, t.Bank1FillFactor}
+	if p == t.thresholds {
+		return
+	}
+	t.thresholds = p
+	for _, b := range t.Banks {
+		b.thresholds = bankThresholds{
+			full1:   firstInserts(len(b.Data), t.Delta/2),
+			full2:   firstInserts(len(b.Data), 1-t.Bank2Occupation),
+			budgets: probeBudgets(t, len(b.Data)),
+		}
+	}
+}
+
+// probeBudgets returns the probe limits of a bank of the given size as Ai bank, from the empty bank to the full one.
+// The limit grows as the bank fills up, so every budget is found by searching for the nearest fill with another limit.
+func probeBudgets(table *HashTable, size int) []probeBudget {
+	at := func(free int) int {
+		return limitedProbes(table, epsilon(size, size-free), size)
+	}
+	var res []probeBudget
+	for free := size; free >= 0; {
+		probes := at(free)
+		d := gallop(free, func(d int) bool { return at(free-d) != probes })
+		res = append(res, probeBudget{Free: free - d + 1, Probes: probes})
+		free -= d
+	}
+	return res
+}
+
+// firstInserts returns the least inserts count, at which the free slots fraction of a bank of the given size is not
+// greater than limit, or size+1 if there is no such count.
+func firstInserts(size int, limit float64) int {
+	return sort.Search(size+1, func(n int) bool {
+		return epsilon(size, n) <= limit
+	})
+}
+
+// full1 returns true if the bank is full as Ai bank, i.e. ε ≤ δ/2.
+func (b *Bank) full1() bool {
+	return b.Inserts >= b.thresholds.full1
+}
+
+// full2 returns true if the bank is full as Ai+1 bank, i.e. ε ≤ 1-Bank2Occupation.
+func (b *Bank) full2() bool {
+	return b.Inserts >= b.thresholds.full2
+}
+
+// probeLimit returns the slots to probe in the bank as Ai bank, see limitedProbes.
+func (b *Bank) probeLimit() int {
+	th := &b.thresholds
+	free := len(b.Data) - b.Inserts
+	for th.budget > 0 && free >= th.budgets[th.budget-1].Free {
+		th.budget--
+	}
+	for free < th.budgets[th.budget].Free {
+		th.budget++
+	}
+	return th.budgets[th.budget].Probes
+}
+
+// gallop returns the least d in [1, limit], that differs returns true for, or limit+1 if there is no such d.
+// differs must be monotone. Takes O(log d) calls.
+func gallop(limit int, differs func(d int) bool) int {
+	hi := 1
+	for hi <= limit && !differs(hi) {
+		hi *= 2
+	}
+	lo := hi / 2 // Does not differ, or 0
+	hi = min(hi, limit+1)
+	return lo + 1 + sort.Search(hi-lo-1, func(i int) bool { return differs(lo + 1 + i) })
+}
@@ -0,0 +1,75 @@
+package elastic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestThresholds(t *testing.T) {
+	// assertThresholds checks the bank thresholds against the float conditions at the given bank fills in order
+	assertThresholds := func(t *testing.T, table *HashTable, fills func(size int) []int) {
+		t.Helper()
+		table.updateThresholds()
+		for i, b := range table.Banks {
+			for _, n := range fills(len(b.Data)) {
+				b.Inserts = n
+				epsilon := freeFraction(b)
+				assert.Equal(t, epsilon <= table.Delta/2, b.full1(), "bank %d, inserts %d", i, n)
+				assert.Equal(t, epsilon <= 1-table.Bank2Occupation, b.full2(), "bank %d, inserts %d", i, n)
+				assert.Equal(t, limitedProbes(table, epsilon, len(b.Data)), b.probeLimit(), "bank %d, inserts %d", i, n)
+			}
+			b.Inserts = 0
+		}
+	}
+	rising := func(size int) []int {
+		var res []int
+		for n := 0; n <= size; n++ {
+			res = append(res, n)
+		}
+		return res
+	}
+
+	t.Run("bank fill rising and falling; should match the float conditions", func(t *testing.T) {
+		table := NewHashTableDefault(5000)
+
+		assertThresholds(t, table, rising)
+		assertThresholds(t, table, func(size int) []int {
+			res := rising(size)
+			for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+				res[i], res[j] = res[j], res[i]
+			}
+			return res
+		})
+	})
+
+	t.Run("random bank fills; should match the float conditions", func(t *testing.T) {
+		table := NewHashTable(5000, 0.05, 0.6, 30)
+		rnd := rand.New(rand.NewPCG(1, 2))
+
+		assertThresholds(t, table, func(size int) []int {
+			res := make([]int, 100)
+			for i := range res {
+				res[i] = rnd.IntN(size + 1)
+			}
+			return res
+		})
+	})
+
+	t.Run("parameters changed; should recompute the thresholds", func(t *testing.T) {
+		table := NewHashTableDefault(5000)
+		assertThresholds(t, table, rising)
+
+		table.Bank1FillFactor, table.Delta, table.Bank2Occupation = 1000, 0.2, 0.5
+
+		assertThresholds(t, table, rising)
+	})
+}
+
+func TestGallop(t *testing.T) {
+	for limit := 0; limit < 40; limit++ {
+		for first := 1; first <= limit+1; first++ {
+			assert.Equal(t, first, gallop(limit, func(d int) bool { return d >= first }), "limit %d", limit)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package elastic
+
+import "math"
+
+// Auto-tuning defaults
+const (
+	tuneWindow          = 1024 // Insertions observed before a decision
+	tuneMaxFailureRate  = 0.01 // Failed insertions fraction that makes the tuner probe Ai bank deeper
+	tuneMinFillFactor   = 1
+	tuneMaxFillFactor   = 10000
+	tuneFillFactorStep  = 1.5
+	tuneMaxDelta        = 0.5
+	tuneTargetProbesMul = 2 // Insertions probing more than this times log2(1/δ) slots on average are too slow
+)
+
+// Tuning is a decision made by the auto-tuning, reported to Hooks.Tune.
+type Tuning struct {
+	Bank1FillFactor  float64 // New Bank1FillFactor of the table
+	Previous         float64 // Bank1FillFactor before the decision
+	FailureRate      float64 // Failed insertions fraction in the observed window
+	MeanProbes       float64 // Average slots probed by an insertion in the observed window
+	RecommendedDelta float64 // Delta to use when the table is rebuilt, differs from the current one if it's too low
+}
+
+// Tuner observes the table insertions and adjusts the Bank1FillFactor. Create it with AutoTune.
+//
+// Every window of insertions, if too many of them failed, the tuner lets them probe the Ai bank deeper by raising
+// the Bank1FillFactor. If nothing failed, but insertions probe too many slots, it lowers the Bank1FillFactor to
+// move them to the Ai+1 bank earlier. If insertions keep failing at the maximum Bank1FillFactor, the bank pairs are
+// too dense for the workload, so it recommends a greater delta for the next rebuild.
+type Tuner struct {
+	table    *HashTable
+	notify   *Hooks // The table hooks set before AutoTune, receive the decisions
+	inserts  int
+	failures int
+	probes   int
+	last     Tuning
+}
+
+// AutoTune starts the auto-tuning of table. It wraps the table hooks, so they must be set before. The decisions are
+// reported to Hooks.Tune of these hooks.
+func AutoTune(table *HashTable) *Tuner {
+	tu := &Tuner{table: table, notify: table.Hooks}
+	tu.last = Tuning{Bank1FillFactor: table.Bank1FillFactor, RecommendedDelta: table.Delta}
+	table.Hooks = JoinHooks(table.Hooks, &Hooks{Done: tu.done})
+	return tu
+}
+
+// Last returns the last decision made by the tuner.
+func (tu *Tuner) Last() Tuning {
+	return tu.last
+}
+
+func (tu *Tuner) done(op Op, probes int, ok bool) {
+	if op != OpInsert {
+		return
+	}
+	tu.inserts++
+	tu.probes += probes
+	if !ok {
+		tu.failures++
+	}
+	if tu.inserts >= tuneWindow {
+		tu.decide()
+	}
+}
+
+func (tu *Tuner) decide() {
+	t := tu.table
+	d := Tuning{
+		Previous:         t.Bank1FillFactor,
+		Bank1FillFactor:  t.Bank1FillFactor,
+		FailureRate:      float64(tu.failures) / float64(tu.inserts),
+		MeanProbes:       float64(tu.probes) / float64(tu.inserts),
+		RecommendedDelta: tu.last.RecommendedDelta,
+	}
+	tu.inserts, tu.failures, tu.probes = 0, 0, 0
+
+	targetProbes := tuneTargetProbesMul * math.Log2(1/t.Delta)
+	switch {
+	case d.FailureRate > tuneMaxFailureRate && d.Bank1FillFactor >= tuneMaxFillFactor:
+		d.RecommendedDelta = min(d.RecommendedDelta*2, tuneMaxDelta)
+	case d.FailureRate > tuneMaxFailureRate:
+		d.Bank1FillFactor = min(d.Bank1FillFactor*tuneFillFactorStep, tuneMaxFillFactor)
+	case d.FailureRate == 0 && d.MeanProbes > targetProbes:
+		d.Bank1FillFactor = max(d.Bank1FillFactor/tuneFillFactorStep, tuneMinFillFactor)
+	}
+	if d.Bank1FillFactor == d.Previous && d.RecommendedDelta == tu.last.RecommendedDelta {
+		return
+	}
+
+	t.Bank1FillFactor = d.Bank1FillFactor
+	tu.last = d
+	if tu.notify != nil && tu.notify.Tune != nil {
+		tu.notify.Tune(d)
+	}
+}
@@ -0,0 +1,65 @@
+package elastic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAutoTune(t *testing.T) {
+	newTuned := func() (*HashTable, *Tuner, *[]Tuning) {
+		table := NewHashTableDefault(1000)
+		var decisions []Tuning
+		table.Hooks = &Hooks{Tune: func(d Tuning) { decisions = append(decisions, d) }}
+		return table, AutoTune(table), &decisions
+	}
+
+	t.Run("frequent insert failures; should raise fill factor", func(t *testing.T) {
+		table, _, decisions := newTuned()
+		for i := 0; i < tuneWindow; i++ {
+			table.Hooks.Done(OpInsert, 5, i%10 != 0)
+		}
+
+		require.Len(t, *decisions, 1)
+		d := (*decisions)[0]
+		assert.Equal(t, 200.0, d.Previous)
+		assert.Equal(t, 300.0, d.Bank1FillFactor)
+		assert.Equal(t, 300.0, table.Bank1FillFactor)
+		assert.InDelta(t, 0.1, d.FailureRate, 0.01)
+		assert.Equal(t, 0.1, d.RecommendedDelta)
+	})
+
+	t.Run("long probes without failures; should lower fill factor", func(t *testing.T) {
+		table, tuner, decisions := newTuned()
+		for i := 0; i < tuneWindow; i++ {
+			table.Hooks.Done(OpInsert, 100, true)
+			table.Hooks.Done(OpLookup, 1000, false) // Lookups are ignored
+		}
+
+		require.Len(t, *decisions, 1)
+		assert.InDelta(t, 200.0/1.5, table.Bank1FillFactor, 1e-9)
+		assert.Equal(t, (*decisions)[0], tuner.Last())
+	})
+
+	t.Run("failures at maximum fill factor; should recommend greater delta", func(t *testing.T) {
+		table, _, decisions := newTuned()
+		table.Bank1FillFactor = tuneMaxFillFactor
+		for i := 0; i < tuneWindow; i++ {
+			table.Hooks.Done(OpInsert, 5, false)
+		}
+
+		require.Len(t, *decisions, 1)
+		assert.Equal(t, float64(tuneMaxFillFactor), table.Bank1FillFactor)
+		assert.Equal(t, 0.2, (*decisions)[0].RecommendedDelta)
+	})
+
+	t.Run("healthy insertions; should change nothing", func(t *testing.T) {
+		table, _, decisions := newTuned()
+		for i := 0; i < 3*tuneWindow; i++ {
+			table.Hooks.Done(OpInsert, 2, true)
+		}
+
+		assert.Empty(t, *decisions)
+		assert.Equal(t, 200.0, table.Bank1FillFactor)
+	})
+}
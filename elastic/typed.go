@@ -0,0 +1,29 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/typed"
+	"math/rand/v2"
+)
+
+// StringMap is a hash table with string keys and values of type V.
+type StringMap[V any] struct {
+	typed.StringMap[*HashTable, V]
+}
+
+// NewStringMap creates a new StringMap with default table parameters.
+func NewStringMap[V any](capacity int) *StringMap[V] {
+	return &StringMap[V]{typed.NewStringMap[*HashTable, V](NewHashTableDefault(capacity))}
+}
+
+// Uint64Map is a hash table with uint64 keys and values of type V. The keys are hashed with a fast integer mixer
+// instead of the general purpose hasher.
+type Uint64Map[V any] struct {
+	typed.Uint64Map[*HashTable, V]
+}
+
+// NewUint64Map creates a new Uint64Map with default table parameters.
+func NewUint64Map[V any](capacity int) *Uint64Map[V] {
+	t := NewHashTableDefault(capacity)
+	t.Hasher = typed.Uint64Hasher(rand.Uint64())
+	return &Uint64Map[V]{typed.NewUint64Map[*HashTable, V](t)}
+}
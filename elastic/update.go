@@ -0,0 +1,36 @@
+package elastic
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/entry"
+)
+
+// UpdateInPlace calls fn with the value of an existing key, so fn may read and replace it without another lookup,
+// e.g. to increment a counter. The entry version is incremented, see GetVersioned. Returns false if the key does not
+// exist, fn is not called then.
+//
+// The value pointer is valid while the entry stays in the table: the slot keeping it is not moved or reused until
+// the entry is removed by Purge, DeleteIf, Dedup or Clear. So a pointer value, e.g. *int or a struct pointer, may be
+// updated without boxing a new value on every call.
+//
+// Entries in the Spill table are not updated. fn must not modify the table.
+func (t *HashTable) UpdateInPlace(key []byte, fn func(value *any)) bool {
+	return entry.UpdateInPlace(entries{t}, key, fn)
+}
+
+// entries is a table as the entry.Table.
+type entries struct {
+	*HashTable
+}
+
+func (e entries) Lookup(key []byte) (entry.Fields, bool) {
+	slot, ok := e.slot(key)
+	if !ok {
+		return entry.Fields{}, false
+	}
+	return entry.Fields{Key: slot.Key, Value: &slot.Value, Version: &slot.Version, Flags: &slot.Flags}, true
+}
+
+func (e entries) Updated(f entry.Fields, before any) {
+	e.ValueBytes += valueBytes(*f.Value) - valueBytes(before)
+	e.mutate(MutationUpdate, f.Key, *f.Value, *f.Version)
+}
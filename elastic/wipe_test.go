@@ -0,0 +1,102 @@
+package elastic
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWipe(t *testing.T) {
+	t.Run("removed entries; should zero the key copies and the values", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			remove func(table *HashTable)
+			wiped  int // Number of the first inserted entries removed
+		}{
+			{"delete", func(table *HashTable) { table.Delete([]byte(secretKeys[0])) }, 1},
+			{"purge", func(table *HashTable) { table.SoftDelete([]byte(secretKeys[0])); table.Purge() }, 1},
+			{"deleteIf", func(table *HashTable) { table.DeleteIf(func([]byte, any) bool { return true }) }, 2},
+			{"clear", func(table *HashTable) { table.Clear() }, 2},
+		}
+		for _, tt := range tests {
+			table := NewHashTableDefault(1000)
+			table.CopyKeys, table.Wipe = true, true
+			keys, values := insertSecrets(t, table)
+
+			tt.remove(table)
+
+			for i := range keys {
+				if i < tt.wiped {
+					assert.Equal(t, make([]byte, len(keys[i])), keys[i], "%v: key %v", tt.name, i)
+					assert.Equal(t, make([]byte, len(values[i])), values[i], "%v: value %v", tt.name, i)
+				} else {
+					assert.Equal(t, secretKeys[i], string(keys[i]), "%v: key %v", tt.name, i)
+					assert.Equal(t, fmt.Sprint("secret-value-", i), string(values[i]), "%v: value %v", tt.name, i)
+				}
+			}
+		}
+	})
+
+	t.Run("entries removed without wipe; should keep the values", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.CopyKeys = true
+		_, values := insertSecrets(t, table)
+
+		table.Clear()
+
+		assert.Equal(t, []byte("secret-value-0"), values[0])
+	})
+
+	t.Run("keys handed out; should stay intact after removal", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.CopyKeys, table.Wipe = true, true
+		insertSecrets(t, table)
+		var got [][]byte
+		for k := range table.All() {
+			got = append(got, k)
+		}
+
+		table.Clear()
+
+		for _, k := range got {
+			assert.True(t, bytes.HasPrefix(k, []byte("secret")), "key: %q", k)
+		}
+	})
+
+	t.Run("removed stashed entries; should zero the short keys and the values", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Wipe = true
+		stash := table.UseStash()
+		value, other := []byte("secret-value"), []byte("other-value")
+		stash.Set([]byte("secret"), value)
+		stash.Set([]byte("other"), other)
+		idx, _ := stash.find([]byte("secret"))
+		key := stash.slots[idx].Key
+
+		stash.Delete([]byte("secret"))
+		assert.Equal(t, make([]byte, len(key)), key)
+		assert.Equal(t, make([]byte, len(value)), value)
+		assert.Equal(t, []byte("other-value"), other)
+
+		stash.Clear()
+		assert.Equal(t, make([]byte, len(other)), other)
+	})
+}
+
+// secretKeys are a long and a short key.
+var secretKeys = []string{"secret-key-0-longer-than-inline", "secret1"}
+
+// insertSecrets inserts secretKeys with []byte values, and returns the stored keys and the values.
+func insertSecrets(t *testing.T, table *HashTable) (keys, values [][]byte) {
+	table.Hasher, table.HashSeed = SeededHasher(1), 1 // An elastic insert may fail below the table capacity
+	for i, key := range secretKeys {
+		value := []byte(fmt.Sprint("secret-value-", i))
+		require.NoError(t, table.TryInsert([]byte(key), value))
+		slot, ok := table.slot([]byte(key))
+		require.True(t, ok)
+		keys, values = append(keys, slot.Key), append(values, value)
+	}
+	return keys, values
+}
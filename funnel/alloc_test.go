@@ -0,0 +1,79 @@
+package funnel
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func allocKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = binary.BigEndian.AppendUint32(nil, uint32(i))
+	}
+	return keys
+}
+
+func TestAllocs(t *testing.T) {
+	const capacity = 10000
+	keys := allocKeys(capacity / 2)
+
+	t.Run("get existing and missing keys; should not allocate", func(t *testing.T) {
+		table := NewHashTableDefault(capacity)
+		for i, k := range keys {
+			table.Insert(k, i)
+		}
+		missing := []byte("missing")
+
+		assert.Zero(t, testing.AllocsPerRun(100, func() { table.Get(keys[7]) }))
+		assert.Zero(t, testing.AllocsPerRun(100, func() { table.Get(missing) }))
+		assert.Zero(t, testing.AllocsPerRun(100, func() { table.Set(keys[7], 7) }))
+	})
+	t.Run("insert keys; should allocate only the slot", func(t *testing.T) {
+		table := NewHashTableDefault(capacity)
+		var value any = "value"
+		var i int
+
+		allocs := testing.AllocsPerRun(len(keys)-1, func() {
+			table.Insert(keys[i], value)
+			i++
+		})
+
+		assert.LessOrEqual(t, allocs, 1.0)
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	const capacity = 1 << 16
+	table := NewHashTableDefault(capacity)
+	keys := allocKeys(capacity * 3 / 4)
+	for i, k := range keys {
+		table.Insert(k, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, ok := table.Get(keys[i%len(keys)]); !ok {
+			b.Fatal("key not found")
+		}
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	const capacity = 1 << 16
+	keys := allocKeys(capacity * 3 / 4)
+	table := NewHashTableDefault(capacity)
+	var value any = "value"
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if i%len(keys) == 0 && i > 0 {
+			b.StopTimer()
+			table.Clear()
+			b.StartTimer()
+		}
+		table.Insert(keys[i%len(keys)], value)
+	}
+}
@@ -0,0 +1,63 @@
+package funnel
+
+import (
+	"bytes"
+	"github.com/bdragon300/elastic-funnel-hash/internal/alloc"
+)
+
+// Allocator allocates the slot records and the key copies of a table, see HashTable.Allocator. Embedders with
+// custom memory management implement it to control the placement.
+type Allocator = alloc.Allocator[Slot]
+
+// HeapAllocator allocates on the Go heap and leaves the freeing to the garbage collector, as the table with nil
+// Allocator does.
+type HeapAllocator = alloc.Heap[Slot]
+
+// release returns a replaced slot and its key copy (if the keys are copied) to the allocator.
+func release(a Allocator, slot *Slot, ownKeys bool) {
+	if slot == nil {
+		return
+	}
+	if ownKeys && len(slot.Key) > inlineKeySize {
+		a.FreeBytes(slot.Key)
+	}
+	a.FreeSlot(slot)
+}
+
+// releaseSlot returns a slot dropped by the table to the Allocator. The entry is wiped first if Wipe is set.
+func (t *HashTable) releaseSlot(slot *Slot) {
+	if t.Wipe && slot != nil {
+		wipeEntry(slot, t.CopyKeys)
+	}
+	if t.Allocator != nil {
+		release(t.Allocator, slot, t.CopyKeys)
+	}
+}
+
+// releaseKey returns a key copy to the Allocator, e.g. of a slot turned into a tombstone.
+func (t *HashTable) releaseKey(key []byte) {
+	if t.Allocator != nil && t.CopyKeys && len(key) > inlineKeySize {
+		t.Allocator.FreeBytes(key)
+	}
+}
+
+// exportKey returns a stored key to hand out of the table. The Allocator may reuse or unmap the memory of the removed
+// entries, e.g. MmapAllocator does, and Wipe zeroes it, so then the key is copied to the Go heap.
+func (t *HashTable) exportKey(key []byte) []byte {
+	if _, heap := t.Allocator.(HeapAllocator); (t.Allocator == nil || heap) && !t.Wipe {
+		return key
+	}
+	return bytes.Clone(key)
+}
+
+// wipeEntry zeroes the key of a slot and its value if it's []byte, see HashTable.Wipe. The keys longer than
+// inlineKeySize are zeroed only if they are the table copies, i.e. ownKeys is set.
+func wipeEntry(slot *Slot, ownKeys bool) {
+	clear(slot.inline[:])
+	if ownKeys && len(slot.Key) > inlineKeySize {
+		clear(slot.Key)
+	}
+	if v, ok := slot.Value.([]byte); ok {
+		clear(v)
+	}
+}
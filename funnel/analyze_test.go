@@ -0,0 +1,55 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"iter"
+	"math"
+	"testing"
+)
+
+func seqKeys(n int) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for i := 0; i < n; i++ {
+			if !yield([]byte(fmt.Sprint(i))) {
+				return
+			}
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	t.Run("default hasher; should distribute keys uniformly", func(t *testing.T) {
+		table := NewHashTableDefault(10000)
+
+		a := table.Analyze(seqKeys(2000))
+
+		assert.Equal(t, 2000, a.Keys)
+		assert.LessOrEqual(t, a.Collisions, 1)
+		df := float64(a.Bins - 1)
+		assert.Less(t, a.ChiSquare, df+6*math.Sqrt(2*df))
+		assert.Positive(t, a.ExpectedMaxLoad)
+		assert.LessOrEqual(t, a.MaxLoad, 2*a.ExpectedMaxLoad)
+		assert.Zero(t, table.Len())
+	})
+
+	t.Run("constant hasher; should report skew and collisions", func(t *testing.T) {
+		table := NewHashTableDefault(10000)
+		table.Hasher = func([]byte) uint64 { return 7 }
+
+		a := table.Analyze(seqKeys(2000))
+
+		assert.Equal(t, 1999, a.Collisions)
+		assert.Equal(t, 2000, a.MaxLoad)
+		assert.Greater(t, a.ChiSquare, 100*float64(a.Bins))
+	})
+
+	t.Run("no keys; should return empty report", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+
+		a := table.Analyze(seqKeys(0))
+
+		assert.Zero(t, a.Keys)
+		assert.Zero(t, a.ChiSquare)
+	})
+}
@@ -0,0 +1,53 @@
+package funnel
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// BenchmarkBankLookup measures the lookups in 16-slot buckets of the banks, with the buckets selected by the hash
+// modulo the buckets count or by the mask if the counts are powers of 2.
+func BenchmarkBankLookup(b *testing.B) {
+	const bucketSize = 16
+	for _, bc := range []struct {
+		name   string
+		counts []int
+	}{
+		{"modulo", []int{1024, 768, 576, 432, 324, 243, 182, 137}},
+		{"mask", []int{1024, 512, 256, 128, 64, 32, 16, 8}},
+		{"small-modulo", []int{12, 9, 7, 5, 4, 3}},
+		{"small-mask", []int{16, 8, 4, 2, 1}},
+	} {
+		var bank *Bank
+		for i := len(bc.counts) - 1; i >= 0; i-- {
+			bank = &Bank{Size: bc.counts[i] * bucketSize, Next: bank}
+			if strings.HasSuffix(bc.name, "mask") {
+				bank.BucketMask = uint64(bc.counts[i] - 1)
+			}
+		}
+		rnd := rand.New(rand.NewPCG(1, 2))
+		var (
+			hashes []uint64
+			keys   [][]byte
+		)
+		for i := 0; i < bc.counts[0]*bucketSize; i++ {
+			hsh := rnd.Uint64()
+			key := binary.BigEndian.AppendUint64(nil, hsh)
+			if bankInsert(nil, bank, hsh, key, i, bucketSize) {
+				hashes = append(hashes, hsh)
+				keys = append(keys, key)
+			}
+		}
+
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				j := i % len(hashes)
+				if _, ok := bankLookup(nil, bank, hashes[j], keys[j], bucketSize); !ok {
+					b.Fatal("key not found")
+				}
+			}
+		})
+	}
+}
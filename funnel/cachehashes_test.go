@@ -0,0 +1,70 @@
+package funnel
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCacheHashes(t *testing.T) {
+	newTable := func(cache bool, compared *int) *HashTable {
+		table, err := New(Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75, HashSeed: 1})
+		require.NoError(t, err)
+		table.CacheHashes = cache
+		table.KeyEqual = func(a, b []byte) bool {
+			*compared++
+			return string(a) == string(b)
+		}
+		return table
+	}
+
+	t.Run("lookup keys; should compare only the keys with equal hashes", func(t *testing.T) {
+		var compared, uncached int
+		table, plain := newTable(true, &compared), newTable(false, &uncached)
+		var keys [][]byte
+		for i := 0; i < 900; i++ {
+			key := binary.BigEndian.AppendUint64(nil, uint64(i))
+			if table.TryInsert(key, i) == nil {
+				require.NoError(t, plain.TryInsert(key, i))
+				keys = append(keys, key)
+			}
+		}
+		compared, uncached = 0, 0
+
+		for i, key := range keys {
+			v, ok := table.Get(key)
+			require.True(t, ok)
+			assert.Equal(t, i, v)
+			_, ok = plain.Get(key)
+			require.True(t, ok)
+		}
+		_, ok := table.Get([]byte("missing"))
+		assert.False(t, ok)
+
+		assert.Equal(t, len(keys), compared) // No hash collisions among these keys
+		assert.Greater(t, uncached, compared)
+	})
+
+	t.Run("remove entries; should shift the entries back by the cached hashes", func(t *testing.T) {
+		var compared int
+		table := newTable(true, &compared)
+		for i := 0; i < 900; i++ {
+			table.Insert(binary.BigEndian.AppendUint64(nil, uint64(i)), i)
+		}
+		hasher := table.Hasher
+		table.Hasher = func(b []byte) uint64 {
+			require.Fail(t, "key is rehashed")
+			return hasher(b)
+		}
+
+		assert.Equal(t, 450, table.DeleteIf(func(_ []byte, v any) bool { return v.(int)%2 == 0 }))
+
+		table.Hasher = hasher
+		for i := 1; i < 900; i += 2 {
+			v, ok := table.Get(binary.BigEndian.AppendUint64(nil, uint64(i)))
+			require.True(t, ok, i)
+			assert.Equal(t, i, v)
+		}
+	})
+}
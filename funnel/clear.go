@@ -0,0 +1,57 @@
+package funnel
+
+// Clear removes all entries from the table without touching the slots, so it takes the same time for any number
+// of entries.
+//
+// Instead of wiping the slots, it starts a new table epoch. The slots written in previous epochs are treated as free
+// and are reclaimed lazily by subsequent inserts, so the removed keys and values stay reachable until overwritten.
+// If Wipe is set, the slots are emptied and wiped right away instead, which takes time proportional to the table size.
+// The Spill table is cleared if it has the Clear method.
+func (t *HashTable) Clear() {
+	before := t.Inserts
+	t.Epoch++
+	if t.Epoch == 0 || t.Wipe {
+		// The epoch has wrapped around, so the slots written 2^32 clears ago would become live again. Or the entries
+		// must be wiped
+		t.wipe()
+	}
+	t.Inserts = 0
+	t.KeyBytes, t.ValueBytes = 0, 0
+	t.LayerInserts = [layersCount]int{}
+	if s, ok := t.Spill.(spillClearer); ok {
+		s.Clear()
+	}
+	if t.OnWatermark != nil {
+		t.crossWatermarks(before)
+	}
+	t.mutate(MutationClear, nil, nil, 0)
+}
+
+// wipe empties all slots.
+func (t *HashTable) wipe() {
+	for _, slots := range t.arrays() {
+		for _, slot := range slots {
+			t.releaseSlot(slot)
+		}
+		clear(slots)
+	}
+	for b := t.Banks; b != nil; b = b.Next {
+		clear(b.Occupied)
+	}
+	clear(t.Overflow2.Epochs)
+	t.Overflow2.Ctrl = newCtrl(len(t.Overflow2.Slots), int(2*t.Overflow2.Loglogn))
+}
+
+// arrays returns the slot arrays of all layers.
+func (t *HashTable) arrays() [][]*Slot {
+	var arrays [][]*Slot
+	for b := t.Banks; b != nil; b = b.Next {
+		arrays = append(arrays, b.Data)
+	}
+	return append(arrays, t.Overflow1.Slots, t.Overflow2.Slots)
+}
+
+// live returns true if a slot is occupied in the given table epoch.
+func live(slot *Slot, epoch uint32) bool {
+	return slot != nil && slot.Epoch == epoch
+}
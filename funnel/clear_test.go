@@ -0,0 +1,42 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestClear(t *testing.T) {
+	t.Run("clear full table; should remove all keys and reuse slots", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+
+		table.Clear()
+
+		assert.Zero(t, table.Len())
+		for i := 0; i < n; i++ {
+			_, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.False(t, ok, "key: %v", i)
+		}
+		m, _ := fillTable(t, table)
+		assert.Equal(t, n, m)
+		for i := 0; i < m; i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.True(t, ok, "key: %v", i)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("epoch wraps around; should not bring back the old keys", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Epoch = math.MaxUint32
+		table.Insert([]byte("key"), 1)
+
+		table.Clear()
+
+		assert.Zero(t, table.Epoch)
+		_, ok := table.Get([]byte("key"))
+		assert.False(t, ok)
+	})
+}
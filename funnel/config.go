@@ -0,0 +1,79 @@
+package funnel
+
+// NoMinBanks set to Config.MinBanks derives the banks count without the additive constant.
+const NoMinBanks = -1
+
+// Overflow1Policy is what Overflow1 does when Overflow2 is disabled, so the keys have nowhere to go after Overflow1.
+type Overflow1Policy int
+
+const (
+	// Overflow1FullScan probes all Overflow1 slots. Inserts succeed while there are free slots, but every miss
+	// scans the whole Overflow1
+	Overflow1FullScan Overflow1Policy = iota
+	// Overflow1Bounded probes only Overflow1Probes slots as usual. Misses are fast, but inserts may fail with
+	// free slots left
+	Overflow1Bounded
+	// Overflow1WithOverflow2 enables the minimal Overflow2 taken from Overflow1 slots, so that Overflow1 is probed
+	// as usual. Fails if the overflow slots are not enough for it
+	Overflow1WithOverflow2
+)
+
+// Config is a hash table configuration for New. Capacity, Delta and BankShrink are required, they have the same
+// meaning as NewHashTable parameters. Other parameters are derived from them (as NewHashTable does) if left zero,
+// or pin the table layout otherwise.
+type Config struct {
+	Capacity   int
+	Delta      float64
+	BankShrink float64
+
+	BucketSize int // Bank bucket size, β parameter in Paper
+	Banks      int // Maximum banks count excluding overflow, α parameter in Paper
+	// MinBanks is the constant added to the derived banks count: α = ⌈4·log2(1/δ)⌉ + MinBanks, 10 as in Paper by
+	// default. More banks mean smaller tail banks, so the keys hop more before reaching the overflow layers: the worst
+	// case insert probes α·β slots in banks. Fewer banks send the keys to the overflow layers earlier. Ignored if
+	// Banks is set
+	MinBanks int
+	// MinBankBuckets merges the tail banks of fewer buckets into their predecessors. The tiny tail banks add a bank
+	// hop to every miss, but hold few keys. Zero keeps all banks. The merged layout is seen in Plan and StatsJSON
+	MinBankBuckets int
+	// PowerOfTwoBuckets rounds the buckets count of every bank to the nearest power of 2, so the operations select
+	// the bucket by masking the key hash instead of the division. The banks get more or fewer slots than derived, see
+	// Layout.BucketRounding
+	PowerOfTwoBuckets bool
+	// Overflow1Frac and Overflow2Frac are the fractions of table slots given to the overflow layers. Overflow2Frac
+	// set to zero disables Overflow2, unless both are zero and the fractions are derived
+	Overflow1Frac float64
+	Overflow2Frac float64
+	// Overflow1Probes is the slots probed in Overflow1 by an operation, ⌊log2(log2(n))⌋ by default. More probes raise
+	// the chance to place a key into Overflow1 at the cost of slower lookups of the missing keys
+	Overflow1Probes int
+	Overflow1Policy Overflow1Policy // What Overflow1 does if Overflow2 is disabled, Overflow1FullScan by default
+	// FingerprintBits is the width of the Overflow2 slot fingerprints: 8 (default), 16 or 32. The 8-bit one is kept in
+	// the control byte only and gives a false match in 1/128 compares. The wider ones add a 2 or 4 byte tag per slot,
+	// checked before the key comparison. Worth it for very large tables with long keys
+	FingerprintBits int
+
+	Hasher   func(b []byte) uint64 // Key hash function, SeededHasher with HashSeed by default
+	HashSeed uint64                // Seed of the default hasher, random by default
+	Seed     uint64                // Seed of overflow probe sequences, time-based by default
+	// Rand creates the generator of Overflow1 probe sequences, ChaCha8 (splitmix64 in the tiny build profile) by
+	// default. Along with Seed and HashSeed, it makes the keys placement reproducible across platforms and releases
+	Rand     func() ProbeSource
+	CopyKeys bool // Copy the keys on insert, see HashTable.CopyKeys
+}
+
+// Validate returns an error if the config is invalid or its explicit parameters cannot be satisfied, e.g. the
+// Overflow2 gets too few buckets or not all Banks fit the capacity.
+func (c Config) Validate() error {
+	_, err := Plan(c)
+	return err
+}
+
+// New creates a new hash table with the given config. It's a shortcut for Plan and Build.
+func New(cfg Config) (*HashTable, error) {
+	l, err := Plan(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return Build(l)
+}
@@ -0,0 +1,207 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("only required parameters; should derive the same layout as NewHashTable", func(t *testing.T) {
+		table, err := New(Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75, Seed: 1})
+		require.NoError(t, err)
+		expect := NewHashTable(1000, 0.1, 0.75)
+
+		assert.Equal(t, expect.BucketSize, table.BucketSize)
+		assert.Equal(t, expect.Capacity, table.Capacity)
+		assert.Equal(t, bankSizes(expect), bankSizes(table))
+		assert.Len(t, table.Overflow1.Slots, len(expect.Overflow1.Slots))
+		assert.Len(t, table.Overflow2.Slots, len(expect.Overflow2.Slots))
+		assert.Equal(t, uint64(1), table.Overflow1.Seed)
+	})
+
+	t.Run("pinned parameters; should use them", func(t *testing.T) {
+		hasher := func(b []byte) uint64 { return uint64(len(b)) }
+		table, err := New(Config{
+			Capacity:      1000,
+			Delta:         0.1,
+			BankShrink:    0.75,
+			BucketSize:    5,
+			Banks:         3,
+			Overflow1Frac: 0.1,
+			Overflow2Frac: 0.1,
+			Hasher:        hasher,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 5, table.BucketSize)
+		sizes := bankSizes(table)
+		assert.Len(t, sizes, 3)
+		for _, size := range sizes {
+			assert.Zero(t, size%5)
+		}
+		assert.Equal(t, 112, len(table.Overflow1.Slots)) // Gets 2 slots left from overflow2 rounding
+		assert.Equal(t, 108, len(table.Overflow2.Slots)) // Rounded down to 6-slot buckets
+		assert.Equal(t, uint64(3), table.Hasher([]byte("key")))
+	})
+
+	t.Run("invalid parameters; should return error", func(t *testing.T) {
+		tests := map[string]Config{
+			"zero capacity":            {Delta: 0.1, BankShrink: 0.75},
+			"delta out of range":       {Capacity: 100, Delta: 1, BankShrink: 0.75},
+			"shrink out of range":      {Capacity: 100, Delta: 0.1, BankShrink: 0.4},
+			"negative bucket size":     {Capacity: 100, Delta: 0.1, BankShrink: 0.75, BucketSize: -1},
+			"overflow takes all":       {Capacity: 100, Delta: 0.1, BankShrink: 0.75, Overflow1Frac: 0.5, Overflow2Frac: 0.5},
+			"too many banks":           {Capacity: 100, Delta: 0.1, BankShrink: 0.75, Banks: 50},
+			"overflow2 too small":      {Capacity: 100, Delta: 0.1, BankShrink: 0.75, Overflow1Frac: 0.05, Overflow2Frac: 0.01},
+			"negative overflow frac":   {Capacity: 100, Delta: 0.1, BankShrink: 0.75, Overflow1Frac: -0.1},
+			"negative min banks":       {Capacity: 100, Delta: 0.1, BankShrink: 0.75, MinBanks: -2},
+			"negative min buckets":     {Capacity: 100, Delta: 0.1, BankShrink: 0.75, MinBankBuckets: -1},
+			"negative probes":          {Capacity: 100, Delta: 0.1, BankShrink: 0.75, Overflow1Probes: -1},
+			"unknown overflow1 policy": {Capacity: 100, Delta: 0.1, BankShrink: 0.75, Overflow1Policy: 10},
+			"no room for overflow2":    {Capacity: 10, Delta: 0.1, BankShrink: 0.75, Overflow1Policy: Overflow1WithOverflow2},
+			"unknown fingerprint bits": {Capacity: 100, Delta: 0.1, BankShrink: 0.75, FingerprintBits: 12},
+			"capacity too large":       {Capacity: math.MaxInt, Delta: 0.1, BankShrink: 0.75},
+		}
+		for name, cfg := range tests {
+			assert.Error(t, cfg.Validate(), name)
+			_, err := New(cfg)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("min banks; should change the derived banks count", func(t *testing.T) {
+		// 4*log2(1/0.1) = 14 banks without the constant
+		none, err := New(Config{Capacity: 100000, Delta: 0.1, BankShrink: 0.9, MinBanks: NoMinBanks})
+		require.NoError(t, err)
+		two, err := New(Config{Capacity: 100000, Delta: 0.1, BankShrink: 0.9, MinBanks: 2})
+		require.NoError(t, err)
+		def, err := New(Config{Capacity: 100000, Delta: 0.1, BankShrink: 0.9})
+		require.NoError(t, err)
+
+		assert.Len(t, bankSizes(none), 14)
+		assert.Len(t, bankSizes(two), 16)
+		assert.Len(t, bankSizes(def), 24)
+	})
+
+	t.Run("min bank buckets; should merge the tiny tail banks into their predecessors", func(t *testing.T) {
+		cfg := Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75}
+		all, err := Plan(cfg)
+		require.NoError(t, err)
+		cfg.MinBankBuckets = 4
+		merged, err := Plan(cfg)
+		require.NoError(t, err)
+
+		require.Less(t, all.Banks[len(all.Banks)-1], 4*all.BucketSize)
+		assert.Less(t, len(merged.Banks), len(all.Banks))
+		assert.Equal(t, all.Banks[:len(merged.Banks)-1], merged.Banks[:len(merged.Banks)-1])
+		for _, size := range merged.Banks {
+			assert.GreaterOrEqual(t, size, 4*merged.BucketSize)
+		}
+		assert.Greater(t, merged.Banks[len(merged.Banks)-1], all.Banks[len(merged.Banks)-1])
+		assert.Equal(t, all.Slots(), merged.Slots())
+		table, err := Build(merged)
+		require.NoError(t, err)
+		fillTable(t, table)
+	})
+
+	t.Run("rand source; should probe overflow1 with it", func(t *testing.T) {
+		var sources []*countingSource
+		cfg := Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75, HashSeed: 1, Seed: 1, Rand: func() ProbeSource {
+			sources = append(sources, &countingSource{ProbeSource: rand.NewChaCha8([32]byte{})})
+			return sources[len(sources)-1]
+		}}
+		table1, err := New(cfg)
+		require.NoError(t, err)
+		table2, err := New(cfg)
+		require.NoError(t, err)
+
+		fillTable(t, table1)
+		fillTable(t, table2)
+
+		require.Len(t, sources, 2)
+		assert.Positive(t, sources[0].seeds)
+		assert.Equal(t, sources[0].seeds, sources[1].seeds)
+		assert.Equal(t, slotKeys(table1.Overflow1.Slots), slotKeys(table2.Overflow1.Slots))
+	})
+
+	t.Run("overflow1 probes; should place more keys into overflow1 with more probes", func(t *testing.T) {
+		cfg := Config{Capacity: 10000, Delta: 0.1, BankShrink: 0.75, HashSeed: 1, Seed: 1, Overflow1Probes: 1}
+		few, err := New(cfg)
+		require.NoError(t, err)
+		cfg.Overflow1Probes = 16
+		many, err := New(cfg)
+		require.NoError(t, err)
+
+		fillTable(t, few)
+		fillTable(t, many)
+
+		assert.Equal(t, 1, few.Overflow1.probeLimit())
+		assert.Equal(t, 16, many.Overflow1.probeLimit())
+		assert.Greater(t, many.LayerInserts[LayerOverflow1], few.LayerInserts[LayerOverflow1])
+	})
+
+	t.Run("overflow2 disabled; should apply overflow1 policy", func(t *testing.T) {
+		cfg := Config{Capacity: 200, Delta: 0.1, BankShrink: 0.75, HashSeed: 1, Seed: 1}
+		scan, err := New(cfg)
+		require.NoError(t, err)
+		cfg.Overflow1Policy = Overflow1Bounded
+		bounded, err := New(cfg)
+		require.NoError(t, err)
+		cfg.Overflow1Policy = Overflow1WithOverflow2
+		withOverflow2, err := New(cfg)
+		require.NoError(t, err)
+
+		assert.Empty(t, scan.Overflow2.Slots)
+		assert.True(t, scan.Overflow1.FullProbe)
+		assert.Empty(t, bounded.Overflow2.Slots)
+		assert.False(t, bounded.Overflow1.FullProbe)
+		assert.Equal(t, Overflow1Bounded, bounded.Layout().Overflow1Policy)
+		assert.Len(t, withOverflow2.Overflow2.Slots, minOverflow2Buckets*overflow2BucketSize(withOverflow2.Capacity))
+		assert.Equal(t, len(scan.Overflow1.Slots), len(withOverflow2.Overflow1.Slots)+len(withOverflow2.Overflow2.Slots))
+		assert.False(t, withOverflow2.Overflow1.FullProbe)
+
+		scanned, _ := fillTable(t, scan)
+		probed, _ := fillTable(t, bounded)
+		assert.GreaterOrEqual(t, scanned, probed)
+	})
+
+	t.Run("derived overflow2 too small; should disable it silently", func(t *testing.T) {
+		cfg := Config{Capacity: 10, Delta: 0.1, BankShrink: 0.75}
+		require.NoError(t, cfg.Validate())
+		table, err := New(cfg)
+		require.NoError(t, err)
+		assert.Empty(t, table.Overflow2.Slots)
+	})
+}
+
+func bankSizes(table *HashTable) []int {
+	var sizes []int
+	for b := table.Banks; b != nil; b = b.Next {
+		sizes = append(sizes, b.Size)
+	}
+	return sizes
+}
+
+// countingSource counts the probe sequences generated by the wrapped source.
+type countingSource struct {
+	ProbeSource
+	seeds int
+}
+
+func (s *countingSource) Seed(seed [32]byte) {
+	s.seeds++
+	s.ProbeSource.Seed(seed)
+}
+
+func slotKeys(slots []*Slot) []string {
+	res := make([]string, len(slots))
+	for i, s := range slots {
+		if s != nil {
+			res[i] = string(s.Key)
+		}
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package funnel
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// Control bytes keep a short hash fingerprint of every Overflow2 slot, so that the candidate buckets are filtered
+// with a couple of word-wide compares before any key bytes are touched (the same idea as in Swiss tables).
+//
+// Every bucket has its own control group padded to a multiple of ctrlWord bytes. A control byte is either
+// a 7-bit fingerprint, ctrlEmpty for the free slot, or ctrlSentinel for the padding at the end of a group.
+const (
+	ctrlEmpty    = 0x80
+	ctrlSentinel = 0xff
+	ctrlWord     = 8 // Bytes compared at once
+
+	ctrlLSB = 0x0101010101010101
+	ctrlMSB = 0x8080808080808080
+	// ctrlGather moves the most significant bit of every byte to the top byte of a word, see compactMSB
+	ctrlGather = 0x0002040810204081
+)
+
+// newCtrl returns the control bytes for the given number of slots divided into buckets of bucketSize slots.
+func newCtrl(slots, bucketSize int) []byte {
+	if slots == 0 || bucketSize == 0 {
+		return nil
+	}
+	stride := ctrlStride(bucketSize)
+	ctrl := make([]byte, slots/bucketSize*stride)
+	for i := range ctrl {
+		if i%stride < bucketSize {
+			ctrl[i] = ctrlEmpty
+		} else {
+			ctrl[i] = ctrlSentinel
+		}
+	}
+	return ctrl
+}
+
+// ctrlStride returns the size of a bucket's control group, i.e. bucketSize rounded up to ctrlWord.
+func ctrlStride(bucketSize int) int {
+	return (bucketSize + ctrlWord - 1) / ctrlWord * ctrlWord
+}
+
+// fingerprint returns the 7-bit fingerprint of a hash stored in control bytes. We take the top bits, because
+// the low ones are already used to select a bucket.
+func fingerprint(hsh uint64) byte {
+	return byte(hsh >> 57)
+}
+
+// matchGroupGeneric returns a bitmask of control bytes in group equal to fp, the bit i corresponds to group[i].
+// The group length must be a multiple of ctrlWord and not greater than 64.
+//
+// The result may contain false positives right after the true match, so the key must be compared anyway.
+//
+// This is a portable implementation of matchGroup, the platforms may provide their own in assembly.
+func matchGroupGeneric(group []byte, fp byte) uint64 {
+	var mask uint64
+	for i := 0; i+ctrlWord <= len(group); i += ctrlWord {
+		w := binary.LittleEndian.Uint64(group[i:]) ^ (ctrlLSB * uint64(fp))
+		mask |= compactMSB((w-ctrlLSB)&^w&ctrlMSB) << i
+	}
+	return mask
+}
+
+// matchEmptyGeneric returns a bitmask of free slots in group, the bit i corresponds to group[i].
+//
+// This is a portable implementation of matchEmpty, the platforms may provide their own in assembly.
+func matchEmptyGeneric(group []byte) uint64 {
+	var mask uint64
+	for i := 0; i+ctrlWord <= len(group); i += ctrlWord {
+		w := binary.LittleEndian.Uint64(group[i:])
+		// ctrlEmpty is the only value with the highest bit set and bit 1 clear
+		mask |= compactMSB(w&^(w<<6)&ctrlMSB) << i
+	}
+	return mask
+}
+
+// compactMSB packs the most significant bits of every byte in w into the lowest byte. All other bits in w must be zero.
+func compactMSB(w uint64) uint64 {
+	return (w * ctrlGather) >> 56
+}
+
+// firstSlot returns the index of the lowest set bit in mask, or 64 if mask is zero.
+func firstSlot(mask uint64) int {
+	return bits.TrailingZeros64(mask)
+}
+
+// Wide fingerprint sizes in bits, see Config.FingerprintBits.
+const (
+	fingerprint8  = 8
+	fingerprint16 = 16
+	fingerprint32 = 32
+)
+
+// tagSize returns the bytes of a slot tag for the fingerprint width, 0 if the control byte is enough.
+func tagSize(fingerprintBits int) int {
+	if fingerprintBits <= fingerprint8 {
+		return 0
+	}
+	return fingerprintBits / 8
+}
+
+// setTag stores the wide fingerprint of a hash for the slot, if tags are enabled. The tags fold the whole hash, so that
+// the keys of the same bucket, which share the low bits, still get different tags.
+func (ovf *Overflow) setTag(slot int, hsh uint64) {
+	switch ovf.TagSize {
+	case 2:
+		binary.LittleEndian.PutUint16(ovf.Tags[2*slot:], tag16(hsh))
+	case 4:
+		binary.LittleEndian.PutUint32(ovf.Tags[4*slot:], tag32(hsh))
+	}
+}
+
+// tagMatch reports whether the wide fingerprint of the slot matches a hash. Always true if tags are disabled.
+func (ovf *Overflow) tagMatch(slot int, hsh uint64) bool {
+	switch ovf.TagSize {
+	case 2:
+		return binary.LittleEndian.Uint16(ovf.Tags[2*slot:]) == tag16(hsh)
+	case 4:
+		return binary.LittleEndian.Uint32(ovf.Tags[4*slot:]) == tag32(hsh)
+	}
+	return true
+}
+
+// tag16 folds a hash to the 16-bit tag.
+func tag16(hsh uint64) uint16 {
+	hsh ^= hsh >> 32
+	return uint16(hsh ^ hsh>>16)
+}
+
+// tag32 folds a hash to the 32-bit tag.
+func tag32(hsh uint64) uint32 {
+	return uint32(hsh ^ hsh>>32)
+}
+
+// setKeyLen stores the key length class for the slot, if key lengths are kept.
+func (ovf *Overflow) setKeyLen(slot, n int) {
+	if len(ovf.KeyLens) > 0 {
+		ovf.KeyLens[slot] = keyLenClass(n)
+	}
+}
+
+// keyLenMatch reports whether the key length class of the slot matches a key length. Always true if key lengths are
+// not kept.
+func (ovf *Overflow) keyLenMatch(slot, n int) bool {
+	return len(ovf.KeyLens) == 0 || ovf.KeyLens[slot] == keyLenClass(n)
+}
+
+// keyLenClass returns the class of a key length, the lengths from 255 share the last class.
+func keyLenClass(n int) byte {
+	return byte(min(n, math.MaxUint8))
+}
@@ -0,0 +1,65 @@
+//go:build amd64 && !purego && !tinygo && !efhtiny
+
+package funnel
+
+const matchNative = true // matchGroup and matchEmpty are implemented in assembly
+
+// useAVX2 selects the AVX2 match kernels, SSE2 ones are always available on amd64.
+var useAVX2 = hasAVX2()
+
+// matchGroup returns a bitmask of control bytes in group equal to fp, the bit i corresponds to group[i].
+// The group length must be a multiple of ctrlWord and not greater than 64.
+//
+// Implemented in assembly with SSE2, or with AVX2 for the groups of 32 bytes and more if the CPU supports it. A shorter
+// group fits into a single 128-bit register, so AVX2 is slower on it. Unlike matchGroupGeneric, the result has no
+// false positives.
+//
+//go:noescape
+func matchGroup(group []byte, fp byte) uint64
+
+// matchEmpty returns a bitmask of free slots in group, the bit i corresponds to group[i].
+//
+//go:noescape
+func matchEmpty(group []byte) uint64
+
+// matchGroupSSE2 is matchGroup comparing 16 control bytes at once.
+//
+//go:noescape
+func matchGroupSSE2(group []byte, fp byte) uint64
+
+// matchEmptySSE2 is matchEmpty comparing 16 control bytes at once.
+//
+//go:noescape
+func matchEmptySSE2(group []byte) uint64
+
+// matchGroupAVX2 is matchGroup comparing 32 control bytes at once.
+//
+//go:noescape
+func matchGroupAVX2(group []byte, fp byte) uint64
+
+// matchEmptyAVX2 is matchEmpty comparing 32 control bytes at once.
+//
+//go:noescape
+func matchEmptyAVX2(group []byte) uint64
+
+// hasAVX2 reports whether the CPU supports AVX2 and the OS saves the YMM registers.
+func hasAVX2() bool {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false
+	}
+	const osxsave, avx = 1 << 27, 1 << 28
+	if _, _, ecx, _ := cpuid(1, 0); ecx&osxsave == 0 || ecx&avx == 0 {
+		return false
+	}
+	if xcr0, _ := xgetbv(); xcr0&0b110 != 0b110 { // XMM and YMM state
+		return false
+	}
+	const avx2 = 1 << 5
+	_, ebx, _, _ := cpuid(7, 0)
+	return ebx&avx2 != 0
+}
+
+func cpuid(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
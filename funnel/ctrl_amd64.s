@@ -0,0 +1,172 @@
+//go:build amd64 && !purego && !tinygo && !efhtiny
+
+#include "textflag.h"
+
+// MATCH compares every byte of the group at SI of length DI with the byte in AX, and puts the bitmask to DX.
+#define MATCH \
+	MOVQ      AX, X0                                                   \
+	PUNPCKLBW X0, X0                                                   \ // Broadcast AX to all 16 bytes of X0
+	PUNPCKLWL X0, X0                                                   \
+	PSHUFL    $0, X0, X0                                               \
+	CMPQ      DI, $16                                                  \ // Fast path for a single 16-byte group
+	JNE       general                                                  \
+	MOVOU     (SI), X1                                                 \
+	PCMPEQB   X0, X1                                                   \
+	PMOVMSKB  X1, DX                                                   \
+	JMP       done                                                     \
+general:                                                               \
+	XORQ      DX, DX                                                   \ // Result mask
+	XORQ      CX, CX                                                   \ // Bit offset of the current chunk
+loop16:                                                                \
+	CMPQ      DI, $16                                                  \
+	JB        tail8                                                    \
+	MOVOU     (SI), X1                                                 \
+	PCMPEQB   X0, X1                                                   \
+	PMOVMSKB  X1, BX                                                   \
+	SHLQ      CX, BX                                                   \
+	ORQ       BX, DX                                                   \
+	ADDQ      $16, SI                                                  \
+	SUBQ      $16, DI                                                  \
+	ADDQ      $16, CX                                                  \
+	JMP       loop16                                                   \
+tail8:                                                                 \
+	CMPQ      DI, $8                                                   \
+	JB        done                                                     \
+	MOVQ      (SI), X1                                                 \
+	PCMPEQB   X0, X1                                                   \
+	PMOVMSKB  X1, BX                                                   \
+	ANDQ      $0xff, BX                                                \ // Upper half of X1 is zeroed, drop its matches
+	SHLQ      CX, BX                                                   \
+	ORQ       BX, DX                                                   \
+done:
+
+// MATCH_AVX2 is MATCH with AVX2, it compares 32 bytes of the group at once.
+#define MATCH_AVX2 \
+	MOVQ         AX, X0                                                \
+	VPBROADCASTB X0, Y0                                                \ // Broadcast AX to all 32 bytes of Y0
+	XORQ         DX, DX                                                \ // Result mask
+	XORQ         CX, CX                                                \ // Bit offset of the current chunk
+avx2loop32:                                                                \
+	CMPQ         DI, $32                                               \
+	JB           avx2tail16                                                \
+	VMOVDQU      (SI), Y1                                              \
+	VPCMPEQB     Y0, Y1, Y1                                            \
+	VPMOVMSKB    Y1, BX                                                \
+	SHLQ         CX, BX                                                \
+	ORQ          BX, DX                                                \
+	ADDQ         $32, SI                                               \
+	SUBQ         $32, DI                                               \
+	ADDQ         $32, CX                                               \
+	JMP          avx2loop32                                                \
+avx2tail16:                                                                \
+	CMPQ         DI, $16                                               \
+	JB           avx2tail8                                                 \
+	VMOVDQU      (SI), X1                                              \
+	VPCMPEQB     X0, X1, X1                                            \
+	VPMOVMSKB    X1, BX                                                \
+	SHLQ         CX, BX                                                \
+	ORQ          BX, DX                                                \
+	ADDQ         $16, SI                                               \
+	SUBQ         $16, DI                                               \
+	ADDQ         $16, CX                                               \
+avx2tail8:                                                                 \
+	CMPQ         DI, $8                                                \
+	JB           avx2done                                                  \
+	VMOVQ        (SI), X1                                              \
+	VPCMPEQB     X0, X1, X1                                            \
+	VPMOVMSKB    X1, BX                                                \
+	ANDQ         $0xff, BX                                             \ // Upper half of X1 is zeroed, drop its matches
+	SHLQ         CX, BX                                                \
+	ORQ          BX, DX                                                \
+avx2done:                                                                  \
+	VZEROUPPER
+
+// AVX2_OR_SSE2 jumps to the label sse2 unless AVX2 is supported and the group length in DI is 32 bytes or more.
+#define AVX2_OR_SSE2 \
+	CMPB ·useAVX2(SB), $0                                              \
+	JEQ  sse2                                                          \
+	CMPQ DI, $32                                                       \
+	JB   sse2
+
+// func matchGroup(group []byte, fp byte) uint64
+TEXT ·matchGroup(SB), NOSPLIT, $0-40
+	MOVQ    group_base+0(FP), SI
+	MOVQ    group_len+8(FP), DI
+	MOVBQZX fp+24(FP), AX
+	AVX2_OR_SSE2
+	MATCH_AVX2
+	MOVQ    DX, ret+32(FP)
+	RET
+sse2:
+	MATCH
+	MOVQ    DX, ret+32(FP)
+	RET
+
+// func matchEmpty(group []byte) uint64
+TEXT ·matchEmpty(SB), NOSPLIT, $0-32
+	MOVQ group_base+0(FP), SI
+	MOVQ group_len+8(FP), DI
+	MOVQ $0x80, AX // ctrlEmpty
+	AVX2_OR_SSE2
+	MATCH_AVX2
+	MOVQ DX, ret+24(FP)
+	RET
+sse2:
+	MATCH
+	MOVQ DX, ret+24(FP)
+	RET
+
+// func matchGroupSSE2(group []byte, fp byte) uint64
+TEXT ·matchGroupSSE2(SB), NOSPLIT, $0-40
+	MOVQ    group_base+0(FP), SI
+	MOVQ    group_len+8(FP), DI
+	MOVBQZX fp+24(FP), AX
+	MATCH
+	MOVQ    DX, ret+32(FP)
+	RET
+
+// func matchEmptySSE2(group []byte) uint64
+TEXT ·matchEmptySSE2(SB), NOSPLIT, $0-32
+	MOVQ group_base+0(FP), SI
+	MOVQ group_len+8(FP), DI
+	MOVQ $0x80, AX // ctrlEmpty
+	MATCH
+	MOVQ DX, ret+24(FP)
+	RET
+
+// func matchGroupAVX2(group []byte, fp byte) uint64
+TEXT ·matchGroupAVX2(SB), NOSPLIT, $0-40
+	MOVQ    group_base+0(FP), SI
+	MOVQ    group_len+8(FP), DI
+	MOVBQZX fp+24(FP), AX
+	MATCH_AVX2
+	MOVQ    DX, ret+32(FP)
+	RET
+
+// func matchEmptyAVX2(group []byte) uint64
+TEXT ·matchEmptyAVX2(SB), NOSPLIT, $0-32
+	MOVQ group_base+0(FP), SI
+	MOVQ group_len+8(FP), DI
+	MOVQ $0x80, AX // ctrlEmpty
+	MATCH_AVX2
+	MOVQ DX, ret+24(FP)
+	RET
+
+// func cpuid(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL leaf+0(FP), AX
+	MOVL subleaf+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
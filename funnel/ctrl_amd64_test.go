@@ -0,0 +1,10 @@
+//go:build amd64 && !purego && !tinygo && !efhtiny
+
+package funnel
+
+func init() {
+	nativeKernels = append(nativeKernels, matchKernel{"sse2", matchGroupSSE2, matchEmptySSE2})
+	if useAVX2 {
+		nativeKernels = append(nativeKernels, matchKernel{"avx2", matchGroupAVX2, matchEmptyAVX2})
+	}
+}
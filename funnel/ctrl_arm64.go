@@ -0,0 +1,19 @@
+//go:build arm64 && !purego && !tinygo && !efhtiny
+
+package funnel
+
+const matchNative = true // matchGroup and matchEmpty are implemented in assembly
+
+// matchGroup returns a bitmask of control bytes in group equal to fp, the bit i corresponds to group[i].
+// The group length must be a multiple of ctrlWord and not greater than 64.
+//
+// Implemented in assembly with NEON, which is always available on arm64. Unlike matchGroupGeneric, the result
+// has no false positives.
+//
+//go:noescape
+func matchGroup(group []byte, fp byte) uint64
+
+// matchEmpty returns a bitmask of free slots in group, the bit i corresponds to group[i].
+//
+//go:noescape
+func matchEmpty(group []byte) uint64
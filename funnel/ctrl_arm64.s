@@ -0,0 +1,59 @@
+//go:build arm64 && !purego && !tinygo && !efhtiny
+
+#include "textflag.h"
+
+// MATCH compares every byte of the group at R0 of length R1 with the byte in R2, and puts the bitmask to R3.
+//
+// NEON has no byte mask move, so the compare result is ANDed with the bit weights 1, 2, 4, ..., 128 of every 8-byte
+// half, and three pairwise additions sum up the weights of each half into a byte of the mask.
+#define MATCH \
+	VMOV   R2, V0.B16                                                  \ // Broadcast R2 to all 16 bytes of V0
+	MOVD   $0x8040201008040201, R4                                     \
+	VMOV   R4, V5.D2                                                   \ // Bit weights of the bytes
+	MOVD   ZR, R3                                                      \ // Result mask
+	MOVD   ZR, R5                                                      \ // Bit offset of the current chunk
+loop16:                                                                \
+	CMP    $16, R1                                                     \
+	BLO    tail8                                                       \
+	VLD1.P 16(R0), [V1.B16]                                            \
+	VCMEQ  V0.B16, V1.B16, V1.B16                                      \
+	VAND   V5.B16, V1.B16, V1.B16                                      \
+	VADDP  V1.B16, V1.B16, V1.B16                                      \
+	VADDP  V1.B16, V1.B16, V1.B16                                      \
+	VADDP  V1.B16, V1.B16, V1.B16                                      \ // The low half mask in byte 0, the high in byte 1
+	VMOV   V1.H[0], R6                                                 \
+	LSL    R5, R6, R6                                                  \
+	ORR    R6, R3, R3                                                  \
+	SUB    $16, R1, R1                                                 \
+	ADD    $16, R5, R5                                                 \
+	B      loop16                                                      \
+tail8:                                                                 \
+	CBZ    R1, done                                                    \
+	VLD1   (R0), [V1.B8]                                               \
+	VCMEQ  V0.B8, V1.B8, V1.B8                                         \
+	VAND   V5.B8, V1.B8, V1.B8                                         \
+	VADDP  V1.B8, V1.B8, V1.B8                                         \
+	VADDP  V1.B8, V1.B8, V1.B8                                         \
+	VADDP  V1.B8, V1.B8, V1.B8                                         \
+	VMOV   V1.B[0], R6                                                 \
+	LSL    R5, R6, R6                                                  \
+	ORR    R6, R3, R3                                                  \
+done:
+
+// func matchGroup(group []byte, fp byte) uint64
+TEXT ·matchGroup(SB), NOSPLIT, $0-40
+	MOVD  group_base+0(FP), R0
+	MOVD  group_len+8(FP), R1
+	MOVBU fp+24(FP), R2
+	MATCH
+	MOVD  R3, ret+32(FP)
+	RET
+
+// func matchEmpty(group []byte) uint64
+TEXT ·matchEmpty(SB), NOSPLIT, $0-32
+	MOVD group_base+0(FP), R0
+	MOVD group_len+8(FP), R1
+	MOVD $0x80, R2 // ctrlEmpty
+	MATCH
+	MOVD R3, ret+24(FP)
+	RET
@@ -0,0 +1,7 @@
+//go:build arm64 && !purego && !tinygo && !efhtiny
+
+package funnel
+
+func init() {
+	nativeKernels = append(nativeKernels, matchKernel{"neon", matchGroup, matchEmpty})
+}
@@ -0,0 +1,15 @@
+//go:build !(amd64 || arm64) || purego || tinygo || efhtiny
+
+package funnel
+
+const matchNative = false // matchGroup and matchEmpty are the portable ones
+
+// matchGroup returns a bitmask of control bytes in group equal to fp, see matchGroupGeneric.
+func matchGroup(group []byte, fp byte) uint64 {
+	return matchGroupGeneric(group, fp)
+}
+
+// matchEmpty returns a bitmask of free slots in group, see matchEmptyGeneric.
+func matchEmpty(group []byte) uint64 {
+	return matchEmptyGeneric(group)
+}
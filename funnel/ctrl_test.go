@@ -0,0 +1,237 @@
+package funnel
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestMatchGroup(t *testing.T) {
+	t.Run("match fingerprint in multi-word group; should return its slots", func(t *testing.T) {
+		ctrl := newCtrl(10, 10) // One bucket, 16 control bytes
+		ctrl[0] = 0x15
+		ctrl[3] = 0x7f
+		ctrl[7] = 0x15
+		ctrl[9] = 0x15
+
+		assert.Equal(t, uint64(1<<0|1<<7|1<<9), matchGroup(ctrl, 0x15))
+		assert.Equal(t, uint64(1<<3), matchGroup(ctrl, 0x7f))
+		assert.Zero(t, matchGroup(ctrl, 0x16))
+	})
+
+	t.Run("match fingerprint in empty group; should fail", func(t *testing.T) {
+		ctrl := newCtrl(5, 5)
+		for fp := 0; fp < 0x80; fp++ {
+			assert.Zero(t, matchGroup(ctrl, byte(fp)), "fp: %v", fp)
+		}
+	})
+}
+
+func TestMatchEmpty(t *testing.T) {
+	t.Run("empty slots in partially filled group; should ignore padding", func(t *testing.T) {
+		ctrl := newCtrl(10, 10)
+		ctrl[0] = 0x00
+		ctrl[1] = 0x7f
+		ctrl[8] = 0x01
+
+		assert.Equal(t, uint64(0b10_1111_1100), matchEmpty(ctrl))
+	})
+
+	t.Run("full group; should return nothing", func(t *testing.T) {
+		ctrl := newCtrl(4, 4)
+		for i := 0; i < 4; i++ {
+			ctrl[i] = byte(i)
+		}
+
+		assert.Zero(t, matchEmpty(ctrl))
+		assert.Equal(t, 64, firstSlot(matchEmpty(ctrl)))
+	})
+}
+
+// matchKernel is a match implementation compared by the tests and benchmarks.
+type matchKernel struct {
+	name  string
+	group func([]byte, byte) uint64
+	empty func([]byte) uint64
+}
+
+// nativeKernels are the native match implementations of the platform, filled by the platform tests.
+var nativeKernels []matchKernel
+
+func TestMatchGroupNative(t *testing.T) {
+	t.Run("random groups; should be consistent with generic implementation", func(t *testing.T) {
+		if len(nativeKernels) == 0 {
+			t.Skip("no native implementation on this platform")
+		}
+		for _, size := range []int{3, 8, 10, 16, 40, 64} {
+			ctrl := newCtrl(size, size)
+			for i := 0; i < size; i++ {
+				if rand.IntN(4) > 0 {
+					ctrl[i] = byte(rand.IntN(ctrlEmpty))
+				}
+			}
+			t.Logf("ctrl: %#v", ctrl)
+
+			for fp := 0; fp < ctrlEmpty; fp++ {
+				var expect uint64
+				for i, c := range ctrl {
+					if c == byte(fp) {
+						expect |= 1 << i
+					}
+				}
+				for _, k := range nativeKernels {
+					assert.Equal(t, expect, k.group(ctrl, byte(fp)), "%v, size: %v, fp: %v", k.name, size, fp)
+				}
+				assert.Equal(t, expect, matchGroup(ctrl, byte(fp)), "size: %v, fp: %v", size, fp)
+				// Generic version may give false positives
+				assert.Equal(t, expect, matchGroupGeneric(ctrl, byte(fp))&expect, "size: %v, fp: %v", size, fp)
+			}
+			for _, k := range nativeKernels {
+				assert.Equal(t, matchEmptyGeneric(ctrl), k.empty(ctrl), "%v, size: %v", k.name, size)
+			}
+			assert.Equal(t, matchEmptyGeneric(ctrl), matchEmpty(ctrl), "size: %v", size)
+		}
+	})
+}
+
+func TestFingerprintBits(t *testing.T) {
+	t.Run("hashes with equal control byte; should be told apart by wide tags only", func(t *testing.T) {
+		const hsh, other = 0x12345678, 0x1234567a // Same bucket and top 7 bits
+		for bits, rejected := range map[int]bool{8: false, 16: true, 32: true} {
+			ovf := newTwoChoiceOverflow(make([]*Slot, 8), 4, 0)
+			ovf.TagSize = tagSize(bits)
+			ovf.Tags = make([]byte, len(ovf.Slots)*ovf.TagSize)
+			require.True(t, overflowTwoChoiceInsert(nil, &ovf, hsh, hsh, []byte("key"), 1))
+
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh, hsh, []byte("key"))
+			assert.True(t, ok, "bits: %v", bits)
+			_, ok = overflowTwoChoiceLookup(nil, &ovf, other, other, []byte("key"))
+			assert.Equal(t, !rejected, ok, "bits: %v", bits)
+		}
+	})
+
+	t.Run("overflow2 only layout with wide fingerprints; should find all keys", func(t *testing.T) {
+		for _, bits := range []int{16, 32} {
+			l := Layout{Capacity: 1000, BucketSize: 2, Overflow2: 600, FingerprintBits: bits}
+			table, err := Build(l)
+			require.NoError(t, err)
+			assert.Equal(t, bits, table.Layout().FingerprintBits)
+			assert.Equal(t, 600*bits/8, len(table.Overflow2.Tags))
+
+			var keys [][]byte
+			for i := 0; i < 400; i++ {
+				key := []byte(fmt.Sprintf("key%d", i))
+				if table.TryInsert(key, i) == nil {
+					keys = append(keys, key)
+				}
+			}
+			require.NotEmpty(t, keys)
+			for _, key := range keys {
+				_, ok := table.Get(key)
+				assert.True(t, ok, "bits: %v, key: %s", bits, key)
+			}
+		}
+	})
+
+	t.Run("wider fingerprints; should take more memory", func(t *testing.T) {
+		var prev int
+		for _, bits := range []int{0, 16, 32} {
+			l, err := Plan(Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75, FingerprintBits: bits})
+			require.NoError(t, err)
+			assert.Greater(t, l.Bytes(), prev, "bits: %v", bits)
+			prev = l.Bytes()
+		}
+	})
+}
+
+// BenchmarkMatchGroup compares the native match implementations of the platform with the portable one on 16-slot
+// buckets, and on 64-slot ones taking several registers.
+func BenchmarkMatchGroup(b *testing.B) {
+	kernels := append([]matchKernel{{"generic", matchGroupGeneric, matchEmptyGeneric}}, nativeKernels...)
+	for _, size := range []int{16, 64} {
+		// Half of slots are occupied
+		ctrl := newCtrl(size, size)
+		for i := 0; i < len(ctrl); i += 2 {
+			ctrl[i] = byte(i)
+		}
+
+		for _, k := range kernels {
+			b.Run(fmt.Sprintf("group/%d/%s", size, k.name), func(b *testing.B) {
+				var m uint64
+				for i := 0; i < b.N; i++ {
+					m |= k.group(ctrl, byte(i%16))
+				}
+				_ = m
+			})
+			b.Run(fmt.Sprintf("empty/%d/%s", size, k.name), func(b *testing.B) {
+				var m uint64
+				for i := 0; i < b.N; i++ {
+					m |= k.empty(ctrl)
+				}
+				_ = m
+			})
+		}
+	}
+}
+
+// BenchmarkOverflowTwoChoiceLookup measures the lookups in 16-slot buckets. Run with `-tags purego` to compare
+// the native match implementation with the portable one.
+func BenchmarkOverflowTwoChoiceLookup(b *testing.B) {
+	const (
+		bucketSize = 16
+		buckets    = 64
+	)
+	ovf := newTwoChoiceOverflow(make([]*Slot, bucketSize*buckets), bucketSize, 0)
+	var (
+		hashes []uint64
+		keys   [][]byte
+	)
+	for i := 0; i < bucketSize*buckets-bucketSize; i++ {
+		hsh := rand.Uint64()
+		key := []byte{byte(i), byte(i >> 8)}
+		if overflowTwoChoiceInsert(nil, &ovf, hsh, hsh^0x5bd1e995, key, i) {
+			hashes = append(hashes, hsh)
+			keys = append(keys, key)
+		}
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		j := i % len(hashes)
+		if _, ok := overflowTwoChoiceLookup(nil, &ovf, hashes[j], hashes[j]^0x5bd1e995, keys[j]); !ok {
+			b.Fatal("key not found")
+		}
+	}
+}
+
+func TestKeyLens(t *testing.T) {
+	t.Run("key length classes; should share the last class", func(t *testing.T) {
+		assert.Equal(t, byte(0), keyLenClass(0))
+		assert.Equal(t, byte(16), keyLenClass(16))
+		assert.Equal(t, byte(254), keyLenClass(254))
+		assert.Equal(t, byte(255), keyLenClass(255))
+		assert.Equal(t, byte(255), keyLenClass(1000))
+	})
+
+	t.Run("slot key length differs; should be rejected without comparing keys", func(t *testing.T) {
+		const hsh = 0x12345678
+		ovf := newTwoChoiceOverflow(make([]*Slot, 8), 4, 0)
+		ovf.KeyLens = make([]byte, len(ovf.Slots))
+		require.True(t, overflowTwoChoiceInsert(nil, &ovf, hsh, hsh, []byte("key"), 1))
+		idx := slices.IndexFunc(ovf.Slots, func(s *Slot) bool { return s != nil })
+		assert.Equal(t, byte(3), ovf.KeyLens[idx])
+
+		ovf.KeyLens[idx] = 4 // The key is not compared, since the lengths differ
+		_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh, hsh, []byte("key"))
+		assert.False(t, ok)
+
+		// Custom comparer may match the keys of other lengths
+		pr := &probe{equal: bytes.EqualFold}
+		_, ok = overflowTwoChoiceLookup(pr, &ovf, hsh, hsh, []byte("KEY"))
+		assert.True(t, ok)
+	})
+}
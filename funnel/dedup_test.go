@@ -0,0 +1,35 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	t.Run("duplicate inserts; should report the key and keep the latest value", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		for v := 1; v <= 3; v++ {
+			table.Insert([]byte("key"), v)
+		}
+		table.Insert([]byte("other"), 0)
+
+		assert.Equal(t, [][]byte{[]byte("key")}, table.FindDuplicates())
+		assert.Equal(t, 2, table.Dedup())
+
+		assert.Equal(t, 2, table.Len())
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 3, v)
+		assert.Empty(t, table.FindDuplicates())
+	})
+
+	t.Run("soft-deleted duplicate; should be ignored", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.Insert([]byte("key"), 1)
+		table.SoftDelete([]byte("key"))
+		table.Insert([]byte("key"), 2)
+
+		assert.Empty(t, table.FindDuplicates())
+		assert.Zero(t, table.Dedup())
+	})
+}
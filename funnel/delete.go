@@ -0,0 +1,208 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+	"slices"
+)
+
+// SoftDelete hides an entry from lookups, but keeps it in the table until Purge, so it can be restored with Undelete.
+// Returns false if the key does not exist. The soft-deleted entries still occupy their slots and are counted by Len.
+//
+// If the key is set again before Purge, the new entry is inserted, and the deleted one can no longer be restored.
+// The Spill entries are soft-deleted if it has the SoftDelete, Undelete and Purge methods.
+func (t *HashTable) SoftDelete(key []byte) bool {
+	slot, ok := t.slot(key)
+	if ok {
+		slot.Deleted = true
+		t.mutate(MutationDelete, slot.Key, slot.Value, slot.Version)
+		return true
+	}
+	if s, ok := t.Spill.(spillSoftDelete); ok {
+		return s.SoftDelete(t.canonKey(key))
+	}
+	return false
+}
+
+// Undelete restores an entry hidden by SoftDelete. Returns false if there is no such entry, it was purged, or the key
+// was set again after deletion.
+func (t *HashTable) Undelete(key []byte) bool {
+	key = t.canonKey(key)
+	if _, ok := t.slot(key); ok {
+		return false
+	}
+	pr := newProbe(t, OpLookup)
+	pr.deleted = true
+	slot, ok := lookup(t, pr, key)
+	if ok {
+		slot.Deleted = false
+		t.mutate(MutationInsert, slot.Key, slot.Value, slot.Version)
+		return true
+	}
+	if s, ok := t.Spill.(spillSoftDelete); ok {
+		return s.Undelete(key)
+	}
+	return false
+}
+
+// Delete removes the entry of a key and returns true, or returns false if the key does not exist. Unlike SoftDelete,
+// the slot is freed at once, so the entry cannot be restored. The soft-deleted entries are not found, see Purge.
+// The key is deleted from the Spill table on miss if it has the Delete method.
+func (t *HashTable) Delete(key []byte) bool {
+	key = t.canonKey(key)
+	var last Step // The slot holding the key is the last one checked
+	pr := newProbe(t, OpLookup)
+	pr.hooks = JoinHooks(t.Hooks, &Hooks{Slot: func(_ Op, step Step) { last = step }})
+	slot, ok := lookup(t, pr, key)
+	if !ok {
+		if s, ok := t.Spill.(spillDeleter); ok {
+			return s.Delete(key)
+		}
+		return false
+	}
+	if t.OnWatermark != nil {
+		defer t.crossWatermarks(t.Inserts)
+	}
+	t.mutateRemoved(slot)
+	t.account(slot.Key, slot.Value, -1)
+	switch last.Layer {
+	case LayerBanks:
+		b := t.Banks
+		for i := 0; i < last.Bank; i++ {
+			b = b.Next
+		}
+		t.shiftBack(b, last.Bucket*t.BucketSize, last.Slot)
+		t.releaseSlot(slot)
+	case LayerOverflow1:
+		t.tombstone(slot)
+	case LayerOverflow2:
+		t.freeOverflow2(last.Bucket*int(2*t.Overflow2.Loglogn) + last.Slot)
+	}
+	t.LayerInserts[last.Layer]--
+	t.Inserts--
+	return true
+}
+
+// Purge removes the soft-deleted entries from the table and returns their count. The freed slots are reused by
+// subsequent inserts. The Spill table is purged too if it supports soft deletion, see SoftDelete.
+func (t *HashTable) Purge() int {
+	n := t.remove(func(s *Slot) bool { return s.Deleted })
+	if s, ok := t.Spill.(spillSoftDelete); ok {
+		n += s.Purge()
+	}
+	return n
+}
+
+// DeleteIf removes the entries fn returns true for, and returns their count. The table is scanned once, so it's
+// cheaper than deleting the keys one by one. The soft-deleted entries are skipped. The Spill table is scanned too if
+// it has the DeleteIf method.
+//
+// fn must not modify the table.
+func (t *HashTable) DeleteIf(fn func(key []byte, value any) bool) int {
+	n := t.remove(func(s *Slot) bool { return !s.Deleted && fn(s.Key, s.Value) })
+	if s, ok := t.Spill.(spillDeleterIf); ok {
+		n += s.DeleteIf(fn)
+	}
+	return n
+}
+
+// remove removes the entries match returns true for, and returns their count. The freed slots are reused by
+// subsequent inserts.
+func (t *HashTable) remove(match func(s *Slot) bool) int {
+	if t.OnWatermark != nil {
+		defer t.crossWatermarks(t.Inserts)
+	}
+	var n int
+	var removed []*Slot // Matched entries of a bank bucket, they move while the others are removed
+	for b := t.Banks; b != nil; b = b.Next {
+		for offset := 0; offset < len(b.Data); offset += t.BucketSize {
+			bucket := b.Data[offset : offset+t.BucketSize]
+			removed = removed[:0]
+			for _, s := range bucket {
+				if removable(s, t.Epoch, match) {
+					removed = append(removed, s)
+				}
+			}
+			for _, s := range removed {
+				t.mutateRemoved(s)
+				t.account(s.Key, s.Value, -1)
+				t.shiftBack(b, offset, slices.Index(bucket, s))
+				t.releaseSlot(s)
+			}
+			t.LayerInserts[LayerBanks] -= len(removed)
+			n += len(removed)
+		}
+	}
+	// Overflow1 lookups stop at the first empty slot, so the slots there become tombstones
+	for _, s := range t.Overflow1.Slots {
+		if removable(s, t.Epoch, match) {
+			t.mutateRemoved(s)
+			t.account(s.Key, s.Value, -1)
+			t.tombstone(s)
+			t.LayerInserts[LayerOverflow1]--
+			n++
+		}
+	}
+	for i, s := range t.Overflow2.Slots {
+		if removable(s, t.Epoch, match) {
+			t.mutateRemoved(s)
+			t.account(s.Key, s.Value, -1)
+			t.freeOverflow2(i)
+			t.LayerInserts[LayerOverflow2]--
+			n++
+		}
+	}
+	t.Inserts -= n
+	return n
+}
+
+// tombstone frees an Overflow1 slot. Overflow1 lookups stop at the first empty slot, so the slot is marked as purged
+// instead of emptied.
+func (t *HashTable) tombstone(s *Slot) {
+	if t.Wipe {
+		wipeEntry(s, t.CopyKeys)
+	}
+	t.releaseKey(s.Key)
+	s.Key, s.Value, s.purged = nil, nil, true
+}
+
+// freeOverflow2 frees the Overflow2 slot at the given index.
+func (t *HashTable) freeOverflow2(i int) {
+	bucketSize := int(2 * t.Overflow2.Loglogn)
+	t.releaseSlot(t.Overflow2.Slots[i])
+	t.Overflow2.Slots[i] = nil
+	t.Overflow2.Ctrl[i/bucketSize*ctrlStride(bucketSize)+i%bucketSize] = ctrlEmpty
+}
+
+// shiftBack frees the slot at hole index of a bank bucket starting at offset, and shifts the following entries of
+// the probe run back into it (backward-shift deletion). So the buckets never have tombstones, and the lookups stop
+// at the first free slot.
+func (t *HashTable) shiftBack(b *Bank, offset, hole int) {
+	bucket := b.Data[offset : offset+t.BucketSize]
+	size := len(bucket)
+	bucket[hole] = nil
+	b.vacate(offset+hole, size)
+	for i, j := 1, (hole+1)%size; i < size; i, j = i+1, (j+1)%size {
+		s := bucket[j]
+		if vacant(s, t.Epoch) {
+			return // End of the probe run
+		}
+		// The entry may take the hole if the hole is not before its home slot in the probing order
+		home := hasher.Reduce(t.slotHash(s), size)
+		if (j-home+size)%size >= (j-hole+size)%size {
+			bucket[hole], bucket[j] = s, nil
+			b.occupy(offset+hole, size)
+			b.vacate(offset+j, size)
+			hole = j
+		}
+	}
+}
+
+// removable returns true if a slot holds an entry in the given table epoch, and match returns true for it.
+func removable(slot *Slot, epoch uint32, match func(s *Slot) bool) bool {
+	return live(slot, epoch) && !slot.purged && match(slot)
+}
+
+// vacant returns true if a slot is free or purged in the given table epoch.
+func vacant(slot *Slot, epoch uint32) bool {
+	return !live(slot, epoch) || slot.purged
+}
@@ -0,0 +1,226 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSoftDelete(t *testing.T) {
+	t.Run("delete and undelete; should hide and restore the entry", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		assert.True(t, table.SoftDelete([]byte("key")))
+		_, ok := table.Get([]byte("key"))
+		assert.False(t, ok)
+		assert.Equal(t, 1, table.Len())
+
+		assert.True(t, table.Undelete([]byte("key")))
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.False(t, table.Undelete([]byte("key")))
+	})
+
+	t.Run("set after delete; should insert new entry and not restore the old one", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		table.SoftDelete([]byte("key"))
+
+		assert.False(t, table.Set([]byte("key"), 2))
+
+		assert.False(t, table.Undelete([]byte("key")))
+		v, _ := table.Get([]byte("key"))
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("missing key; should fail", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+
+		assert.False(t, table.SoftDelete([]byte("missing")))
+		assert.False(t, table.Undelete([]byte("missing")))
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("delete entries in full table; should free their slots and keep other entries", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		mutations := recordMutations(table)
+		var deleted int
+		for i := 0; i < n; i += 2 {
+			assert.True(t, table.Delete([]byte(fmt.Sprint(i))), "key: %v", i)
+			deleted++
+		}
+
+		assert.Equal(t, n-deleted, table.Len())
+		assert.Len(t, *mutations, deleted)
+		var layers int
+		for _, n := range table.LayerInserts {
+			layers += n
+		}
+		assert.Equal(t, table.Len(), layers)
+		for i := 0; i < n; i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+			if ok {
+				assert.Equal(t, i, v)
+			}
+		}
+		assert.False(t, table.Delete([]byte("0")))
+		assert.False(t, table.Undelete([]byte("0")))
+		var inserted int
+		for i := 0; i < deleted; i++ {
+			if table.TryInsert([]byte(fmt.Sprint("new", i)), i) == nil {
+				inserted++
+			}
+		}
+		assert.NotZero(t, inserted)
+	})
+
+	t.Run("missing or soft-deleted key; should fail", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		table.SoftDelete([]byte("key"))
+
+		assert.False(t, table.Delete([]byte("key")))
+		assert.False(t, table.Delete([]byte("missing")))
+		assert.Equal(t, 1, table.Len())
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Run("purge deleted entries in full table; should keep other entries and reuse slots", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		var deleted int
+		for i := 0; i < n; i += 2 {
+			assert.True(t, table.SoftDelete([]byte(fmt.Sprint(i))))
+			deleted++
+		}
+
+		assert.Equal(t, deleted, table.Purge())
+
+		assert.Equal(t, n-deleted, table.Len())
+		assert.Zero(t, table.Purge())
+		for i := 0; i < n; i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+			if ok {
+				assert.Equal(t, i, v)
+			}
+		}
+		assert.False(t, table.Undelete([]byte("0")))
+
+		var inserted []int
+		for i := 0; i < deleted; i++ {
+			if table.TryInsert([]byte(fmt.Sprint("new", i)), i) == nil {
+				inserted = append(inserted, i)
+			}
+		}
+		assert.NotEmpty(t, inserted)
+		for _, i := range inserted {
+			v, ok := table.Get([]byte(fmt.Sprint("new", i)))
+			assert.True(t, ok, "key: new%v", i)
+			assert.Equal(t, i, v)
+		}
+	})
+}
+
+func TestDeleteIf(t *testing.T) {
+	t.Run("delete even values; should remove only the matching visible entries", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		table.SoftDelete([]byte("0"))
+		var even int
+		for i := 2; i < n; i += 2 {
+			even++
+		}
+
+		assert.Equal(t, even, table.DeleteIf(func(_ []byte, value any) bool { return value.(int)%2 == 0 }))
+
+		assert.Equal(t, n-even, table.Len())
+		for i := 1; i < n; i++ {
+			_, ok := table.Get([]byte(fmt.Sprint(i)))
+			assert.Equal(t, i%2 == 1, ok, "key: %v", i)
+		}
+		assert.True(t, table.Undelete([]byte("0")))
+		assert.Zero(t, table.DeleteIf(func([]byte, any) bool { return false }))
+	})
+}
+
+func TestShiftBack(t *testing.T) {
+	// The key's first byte is its home slot in the bucket
+	newTable := func(data ...byte) (*HashTable, *Bank) {
+		b := &Bank{Size: len(data)}
+		b.alloc(len(data))
+		for i, home := range data {
+			if home != 0xff {
+				b.Data[i] = &Slot{Key: []byte{home, byte(i)}}
+				b.occupy(i, len(data))
+			}
+		}
+		return &HashTable{BucketSize: len(data), Hasher: func(b []byte) uint64 { return uint64(b[0]) }}, b
+	}
+	homes := func(b *Bank) []int {
+		res := make([]int, len(b.Data))
+		for i, s := range b.Data {
+			res[i] = -1
+			if s != nil {
+				res[i] = int(s.Key[0])
+			}
+		}
+		return res
+	}
+	tests := []struct {
+		name   string
+		data   []byte // Home slots of entries, 0xff is a free slot
+		hole   int
+		expect []int
+	}{
+		{"run after hole; should shift the entries back", []byte{0, 0, 0, 0xff}, 0, []int{0, 0, -1, -1}},
+		{"entry at its home after hole; should stay", []byte{0, 1, 0xff, 0xff}, 0, []int{-1, 1, -1, -1}},
+		{"entry after the one at home; should shift over it", []byte{0, 1, 0, 0xff}, 0, []int{0, 1, -1, -1}},
+		{"run wrapped around the bucket; should shift the entries back", []byte{3, 0, 0xff, 3}, 3, []int{0, -1, -1, 3}},
+		{"full bucket; should stop at the hole", []byte{1, 1, 1, 1}, 1, []int{-1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, b := newTable(tt.data...)
+
+			table.shiftBack(b, 0, tt.hole)
+
+			assert.Equal(t, tt.expect, homes(b))
+			for i, s := range b.Data {
+				assert.Equal(t, s != nil, b.Occupied[0]&(1<<i) != 0, "slot %d", i)
+			}
+		})
+	}
+
+	t.Run("remove entries under churn; should keep other entries reachable", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		inserted := make(map[string]int)
+		for round := 0; round < 5; round++ {
+			for i := 0; i < 300; i++ {
+				key := fmt.Sprint(round, "-", i)
+				if table.TryInsert([]byte(key), i) == nil {
+					inserted[key] = i
+				}
+			}
+			table.DeleteIf(func(_ []byte, v any) bool { return v.(int)%3 == round%3 })
+			for key, v := range inserted {
+				if v%3 == round%3 {
+					delete(inserted, key)
+				}
+			}
+
+			assert.Equal(t, len(inserted), table.Len())
+			for key, v := range inserted {
+				got, ok := table.Get([]byte(key))
+				assert.True(t, ok, key)
+				assert.Equal(t, v, got, key)
+			}
+		}
+	})
+}
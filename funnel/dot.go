@@ -0,0 +1,82 @@
+package funnel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DumpDot writes the table layout in Graphviz DOT format to w: banks with their sizes and fill levels, and the
+// overflow layers occupancy. Render it with e.g. `dot -Tsvg`.
+func (t *HashTable) DumpDot(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph funnel {\n\trankdir=LR;\n\tnode [shape=record, style=filled, colorscheme=blues9];\n")
+	fmt.Fprintf(&buf, "\tlabel=\"capacity %d, inserts %d, bucket size %d\";\n", t.Capacity, t.Inserts, t.BucketSize)
+
+	var prev string
+	for b, i := t.Banks, 0; b != nil; b, i = b.Next, i+1 {
+		name := fmt.Sprintf("bank%d", i)
+		used := occupied(b.Data, t.Epoch)
+		fmt.Fprintf(
+			&buf, "\t%s [label=\"bank %d|size %d|buckets %d|used %d (%s)\", fillcolor=%d];\n",
+			name, i, b.Size, b.Size/t.BucketSize, used, percent(used, b.Size), fillColor(used, b.Size),
+		)
+		dotEdge(&buf, prev, name)
+		prev = name
+	}
+
+	used := occupied(t.Overflow1.Slots, t.Epoch)
+	fmt.Fprintf(
+		&buf, "\toverflow1 [label=\"overflow1|size %d|used %d (%s)\", fillcolor=%d];\n",
+		len(t.Overflow1.Slots), used, percent(used, len(t.Overflow1.Slots)), fillColor(used, len(t.Overflow1.Slots)),
+	)
+	dotEdge(&buf, prev, "overflow1")
+
+	if size := len(t.Overflow2.Slots); size > 0 {
+		bucketSize := int(2 * t.Overflow2.Loglogn)
+		used = occupied(t.Overflow2.Slots, t.Epoch)
+		fmt.Fprintf(
+			&buf, "\toverflow2 [label=\"overflow2|size %d|buckets %d of %d|used %d (%s)\", fillcolor=%d];\n",
+			size, size/bucketSize, bucketSize, used, percent(used, size), fillColor(used, size),
+		)
+	} else {
+		buf.WriteString("\toverflow2 [label=\"overflow2|disabled\", style=dashed];\n")
+	}
+	dotEdge(&buf, "overflow1", "overflow2")
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func dotEdge(buf *bytes.Buffer, from, to string) {
+	if from != "" {
+		fmt.Fprintf(buf, "\t%s -> %s;\n", from, to)
+	}
+}
+
+// occupied returns the number of non-empty slots.
+func occupied(slots []*Slot, epoch uint32) int {
+	var n int
+	for _, s := range slots {
+		if !vacant(s, epoch) {
+			n++
+		}
+	}
+	return n
+}
+
+func percent(used, size int) string {
+	if size == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(used)*100/float64(size))
+}
+
+// fillColor returns the color index in "blues9" Graphviz color scheme by fill level.
+func fillColor(used, size int) int {
+	if size == 0 {
+		return 1
+	}
+	return 1 + used*8/size
+}
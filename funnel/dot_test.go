@@ -0,0 +1,41 @@
+package funnel
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestDumpDot(t *testing.T) {
+	t.Run("table with entries; should describe all layers", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.Insert([]byte("key"), 1)
+		var buf bytes.Buffer
+
+		require.NoError(t, table.DumpDot(&buf))
+
+		out := buf.String()
+		assert.True(t, strings.HasPrefix(out, "digraph funnel {"))
+		assert.True(t, strings.HasSuffix(out, "}\n"))
+		assert.Contains(t, out, "bank0 [label=\"bank 0|size ")
+		assert.Contains(t, out, "|used 1 (")
+		var banks int
+		for b := table.Banks; b != nil; b = b.Next {
+			banks++
+		}
+		assert.Equal(t, banks+1, strings.Count(out, " -> "))
+		assert.Contains(t, out, "overflow1 [label=")
+		assert.Contains(t, out, "overflow2 [label=\"overflow2|size ")
+	})
+
+	t.Run("small table; should mark overflow2 as disabled", func(t *testing.T) {
+		table := NewHashTableDefault(10)
+		var buf bytes.Buffer
+
+		require.NoError(t, table.DumpDot(&buf))
+
+		assert.Contains(t, buf.String(), "overflow2 [label=\"overflow2|disabled\"")
+	})
+}
@@ -0,0 +1,35 @@
+package funnel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFindValues(t *testing.T) {
+	t.Run("soft-deleted and spilled entries; should skip deleted and find spilled", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		spill := NewHashTableDefault(100)
+		spill.Insert([]byte("key1"), 1)
+		table.Spill = spill
+		table.Insert([]byte("key2"), 1)
+		table.SoftDelete([]byte("key2"))
+
+		keys := table.FindValues(func(v any) bool { return v == 1 }, 0)
+
+		assert.Equal(t, [][]byte{[]byte("key1")}, keys)
+	})
+
+	t.Run("canceled context; should stop with error", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		keys, err := table.FindValuesContext(ctx, func(any) bool { return true }, 0)
+
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, keys)
+	})
+}
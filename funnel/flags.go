@@ -0,0 +1,16 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/entry"
+)
+
+// SetFlags sets the application-defined flags of an entry, e.g. to mark it dirty or pinned without wrapping the
+// value. Returns false if the key does not exist. The flags are kept when the entry value is updated with Set.
+func (t *HashTable) SetFlags(key []byte, flags uint8) bool {
+	return entry.SetFlags(entries{t}, key, flags)
+}
+
+// GetFlags returns the flags of an entry, see SetFlags. If the key does not exist, it returns 0 and false.
+func (t *HashTable) GetFlags(key []byte) (uint8, bool) {
+	return entry.GetFlags(entries{t}, key)
+}
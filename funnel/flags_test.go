@@ -0,0 +1,23 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	t.Run("set flags and update value; should keep the flags", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		flags, ok := table.GetFlags([]byte("key"))
+		assert.True(t, ok)
+		assert.Zero(t, flags)
+		assert.True(t, table.SetFlags([]byte("key"), 0b101))
+		table.Set([]byte("key"), 2)
+
+		flags, ok = table.GetFlags([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, uint8(0b101), flags)
+	})
+}
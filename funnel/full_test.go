@@ -0,0 +1,233 @@
+package funnel
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type mapSpill map[string]any
+
+func (m mapSpill) Set(key []byte, value any) bool {
+	_, ok := m[string(key)]
+	m[string(key)] = value
+	return ok
+}
+
+func (m mapSpill) Get(key []byte) (any, bool) {
+	v, ok := m[string(key)]
+	return v, ok
+}
+
+func (m mapSpill) Len() int {
+	return len(m)
+}
+
+func (m mapSpill) Delete(key []byte) bool {
+	_, ok := m[string(key)]
+	delete(m, string(key))
+	return ok
+}
+
+func (m mapSpill) Clear() {
+	clear(m)
+}
+
+func (m mapSpill) DeleteIf(fn func(key []byte, value any) bool) int {
+	var n int
+	for k, v := range m {
+		if fn([]byte(k), v) {
+			delete(m, k)
+			n++
+		}
+	}
+	return n
+}
+
+// fillTable inserts the keys until the first failure and returns the inserted keys count and the failed key.
+func fillTable(t *testing.T, table *HashTable) (int, []byte) {
+	for i := 0; ; i++ {
+		key := []byte(fmt.Sprint(i))
+		if err := table.TryInsert(key, i); err != nil {
+			require.ErrorIs(t, err, ErrFull)
+			return i, key
+		}
+	}
+}
+
+func TestOnFull(t *testing.T) {
+	t.Run("no callback; should return error and panic", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		n, key := fillTable(t, table)
+
+		assert.Equal(t, n, table.Len())
+		assert.PanicsWithError(t, ErrFull.Error(), func() { table.Insert(key, 1) })
+	})
+
+	t.Run("drop policy; should discard the key", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		n, key := fillTable(t, table)
+		var called int
+		table.OnFull = func(k []byte, value any) FullPolicy {
+			called++
+			assert.Equal(t, key, k)
+			assert.Equal(t, 1, value)
+			return FullDrop
+		}
+
+		require.NoError(t, table.TryInsert(key, 1))
+		assert.Equal(t, 1, called)
+		assert.Equal(t, n, table.Len())
+		_, ok := table.Get(key)
+		assert.False(t, ok)
+	})
+
+	t.Run("evict policy twice the capacity; should not grow past capacity", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.OnFull = func([]byte, any) FullPolicy { return FullEvictRandom }
+		var inserts, deletes int
+		table.OnMutation = func(m Mutation) {
+			switch m.Op {
+			case MutationInsert:
+				inserts++
+			case MutationDelete:
+				deletes++
+			}
+		}
+
+		for i := 0; i < 2*table.Cap(); i++ {
+			table.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		assert.LessOrEqual(t, table.Len(), table.Cap())
+		assert.Equal(t, table.Len(), inserts-deletes)
+		var n int
+		for range table.All() {
+			n++
+		}
+		assert.Equal(t, table.Len(), n)
+	})
+
+	t.Run("evict policy; should replace an entry", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		n, key := fillTable(t, table)
+		table.OnFull = func([]byte, any) FullPolicy { return FullEvictRandom }
+
+		require.NoError(t, table.TryInsert(key, -1))
+		assert.Equal(t, n, table.Len())
+		v, ok := table.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, -1, v)
+		var lost int
+		for i := 0; i < n; i++ {
+			if _, ok := table.Get([]byte(fmt.Sprint(i))); !ok {
+				lost++
+			}
+		}
+		assert.Equal(t, 1, lost)
+	})
+
+	t.Run("spill policy; should insert to secondary table", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		_, key := fillTable(t, table)
+		spill := mapSpill{}
+		table.Spill = spill
+		table.OnFull = func([]byte, any) FullPolicy { return FullSpill }
+
+		require.NoError(t, table.TryInsert(key, 1))
+		assert.Equal(t, mapSpill{string(key): 1}, spill)
+		v, ok := table.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+
+		assert.True(t, table.Set(key, 2))
+		assert.Equal(t, mapSpill{string(key): 2}, spill)
+	})
+
+	t.Run("spilled key; should be counted, deleted and cleared with the table", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		n, key := fillTable(t, table)
+		spill := mapSpill{}
+		table.Spill = spill
+		table.OnFull = func([]byte, any) FullPolicy { return FullSpill }
+		require.NoError(t, table.TryInsert(key, 1))
+
+		assert.Equal(t, n+1, table.Len())
+		assert.True(t, table.Delete(key))
+		assert.False(t, table.Delete(key))
+		_, ok := table.Get(key)
+		assert.False(t, ok)
+		assert.Empty(t, spill)
+
+		require.NoError(t, table.TryInsert(key, 1))
+		assert.Equal(t, 1, table.DeleteIf(func(k []byte, _ any) bool { return string(k) == string(key) }))
+		assert.Empty(t, spill)
+
+		require.NoError(t, table.TryInsert(key, 1))
+		table.Clear()
+		assert.Empty(t, spill)
+		assert.Zero(t, table.Len())
+	})
+
+	t.Run("spill policy without spill table; should return error", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		_, key := fillTable(t, table)
+		table.OnFull = func([]byte, any) FullPolicy { return FullSpill }
+
+		assert.ErrorIs(t, table.TryInsert(key, 1), ErrFull)
+	})
+}
+
+func TestMaxProbes(t *testing.T) {
+	t.Run("lookup inserted keys with budget; should find them or report exceeded budget", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		var inserted []int
+		for i := 0; i < table.Cap(); i++ {
+			if table.TryInsert([]byte(fmt.Sprint(i)), i) == nil {
+				inserted = append(inserted, i)
+			}
+		}
+		table.MaxProbes = 2
+		table.Hooks = &Hooks{Done: func(op Op, probes int, ok bool) {
+			assert.LessOrEqual(t, probes, table.MaxProbes)
+		}}
+
+		var found, exceeded int
+		for _, i := range inserted {
+			v, ok, err := table.TryGet([]byte(fmt.Sprint(i)))
+			if ok {
+				assert.Equal(t, i, v)
+				assert.NoError(t, err)
+				found++
+			} else {
+				assert.ErrorIs(t, err, ErrProbeBudget)
+				exceeded++
+			}
+		}
+		assert.NotZero(t, found)
+		assert.NotZero(t, exceeded)
+	})
+
+	t.Run("insert with budget; should not exceed it", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		table.MaxProbes = 2
+		table.Hooks = &Hooks{Done: func(op Op, probes int, ok bool) {
+			assert.LessOrEqual(t, probes, table.MaxProbes)
+		}}
+
+		var exceeded int
+		for i := 0; i < table.Cap(); i++ {
+			if err := table.TryInsert([]byte(fmt.Sprint(i)), i); errors.Is(err, ErrProbeBudget) {
+				exceeded++
+			}
+		}
+		assert.NotZero(t, exceeded)
+		assert.Panics(t, func() {
+			for i := 0; i < table.Cap(); i++ {
+				table.Set([]byte(fmt.Sprint(i)), i)
+			}
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package funnel
+
+// HashKeys returns the hashes of the keys for InsertHashed and GetHashed, so that a pipeline may hash the keys once,
+// e.g. to fan them out to the shards, and on another goroutine than the one using the table. HashKeys only reads
+// Hasher and KeyCanon, so it may run concurrently with the table operations if they are not changed.
+func (t *HashTable) HashKeys(keys [][]byte) []uint64 {
+	res := make([]uint64, len(keys))
+	for i, key := range keys {
+		res[i] = t.Hasher(t.canonKey(key))
+	}
+	return res
+}
+
+// InsertHashed is like Insert, but takes the key hash returned by HashKeys instead of computing it. The entry keeps
+// the hash, so the table never rehashes its key, e.g. on the removal of a neighbour entry. A wrong hash places the key
+// where the lookups do not find it.
+func (t *HashTable) InsertHashed(key []byte, hsh uint64, value any) {
+	if err := t.tryInsert(hashedProbe(t, OpInsert, hsh), key, value); err != nil {
+		panic(err)
+	}
+}
+
+// GetHashed is like Get, but takes the key hash returned by HashKeys instead of computing it.
+func (t *HashTable) GetHashed(key []byte, hsh uint64) (any, bool) {
+	v, ok, _ := t.tryGet(hashedProbe(t, OpLookup, hsh), key)
+	return v, ok
+}
+
+// hashedProbe returns the probe of a table operation with the key hash given.
+func hashedProbe(t *HashTable, op Op, hsh uint64) *probe {
+	pr := newProbe(t, op)
+	pr.hash, pr.hashed, pr.given = hsh, true, op == OpInsert
+	return pr
+}
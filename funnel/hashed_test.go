@@ -0,0 +1,72 @@
+package funnel
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHashed(t *testing.T) {
+	t.Run("precomputed hashes; should not hash the keys again", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		keys := make([][]byte, 50)
+		for i := range keys {
+			keys[i] = []byte(fmt.Sprint("key", i))
+		}
+		hashes := table.HashKeys(keys)
+		hasher := table.Hasher
+		var calls int
+		table.Hasher = func(b []byte) uint64 {
+			calls++
+			return hasher(b)
+		}
+
+		for i, key := range keys {
+			table.InsertHashed(key, hashes[i], i)
+		}
+		for i, key := range keys {
+			v, ok := table.GetHashed(key, hashes[i])
+			require.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+
+		assert.Zero(t, calls)
+		v, ok := table.Get([]byte("key42"))
+		assert.True(t, ok)
+		assert.Equal(t, 42, v)
+	})
+
+	t.Run("canonical keys; should hash the canonical form", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.KeyCanon = bytes.ToLower
+		table.Insert([]byte("key"), 1)
+
+		hashes := table.HashKeys([][]byte{[]byte("KEY")})
+
+		assert.Equal(t, []uint64{table.Hasher([]byte("key"))}, hashes)
+		v, ok := table.GetHashed([]byte("Key"), hashes[0])
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+	t.Run("neighbour of given hash entry removed; should shift it back by the given hash", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("a"), 1)
+		hsh := table.Hasher([]byte("a"))
+		home := int(hsh % uint64(table.BucketSize))
+		// The key is placed after "a" by the given hash, while its own hash points to the slot it takes, so the key
+		// would stay there if rehashed
+		key := []byte("b")
+		for i := 0; int(table.Hasher(key)%uint64(table.BucketSize)) != (home+1)%table.BucketSize; i++ {
+			key = []byte(fmt.Sprint("b", i))
+		}
+		table.InsertHashed(key, hsh, 2)
+
+		require.True(t, table.Delete([]byte("a")))
+
+		v, ok := table.GetHashed(key, hsh)
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+	})
+}
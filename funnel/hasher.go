@@ -0,0 +1,11 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+)
+
+// SeededHasher returns the hasher used by default. Unlike maphash, its seed is a plain number, so a table persisted
+// along with its HashSeed hashes the keys the same way after reload.
+func SeededHasher(seed uint64) func(b []byte) uint64 {
+	return hasher.Seeded(seed)
+}
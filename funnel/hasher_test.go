@@ -0,0 +1,18 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSeededHasher(t *testing.T) {
+	t.Run("table with restored seed; should hash keys the same way", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		restored := NewHashTableDefault(100)
+		restored.HashSeed = table.HashSeed
+		restored.Hasher = SeededHasher(restored.HashSeed)
+
+		assert.NotZero(t, table.HashSeed)
+		assert.Equal(t, table.Hasher([]byte("key")), restored.Hasher([]byte("key")))
+	})
+}
@@ -0,0 +1,40 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUniqueKeysEstimate(t *testing.T) {
+	t.Run("duplicate keys inserted; should not count them", func(t *testing.T) {
+		table := NewHashTableDefault(2000)
+		table.UniqueKeys = NewHyperLogLog(12)
+		for i := 0; i < 500; i++ {
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+		}
+
+		assert.Equal(t, 1000, table.Len())
+		assert.InEpsilon(t, 500, table.UniqueKeysEstimate(), 0.05)
+	})
+
+	t.Run("spilled keys; should count them", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.UniqueKeys = NewHyperLogLog(12)
+		n, key := fillTable(t, table)
+		table.Spill = mapSpill{}
+		table.OnFull = func([]byte, any) FullPolicy { return FullSpill }
+
+		table.Insert(key, 1)
+
+		assert.InDelta(t, n+1, table.UniqueKeysEstimate(), float64(n)*0.05)
+	})
+
+	t.Run("not set; should return zero", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		assert.Zero(t, table.UniqueKeysEstimate())
+	})
+}
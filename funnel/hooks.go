@@ -0,0 +1,294 @@
+package funnel
+
+import (
+	"math/bits"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Op is a table operation reported to hooks.
+type Op string
+
+const (
+	OpInsert Op = "insert"
+	OpLookup Op = "lookup"
+)
+
+// Layer is a part of the table probed by an operation.
+type Layer int
+
+const (
+	LayerBanks     Layer = iota // Main data banks, the A' array in Paper
+	LayerOverflow1              // Overflow1 bucket with uniform random probing, the B array in Paper
+	LayerOverflow2              // Overflow2 bucket with two-choice hashing, the C array in Paper
+
+	layersCount = 3
+)
+
+func (l Layer) String() string {
+	switch l {
+	case LayerBanks:
+		return "banks"
+	case LayerOverflow1:
+		return "overflow1"
+	case LayerOverflow2:
+		return "overflow2"
+	}
+	return "Layer(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Hooks are the instrumentation callbacks called on the table hot paths. Every callback is optional.
+type Hooks struct {
+	// Layer is called when an operation starts probing a table layer. The bank is the bank index in LayerBanks,
+	// or -1 for the overflow layers. The returned function (if not nil) is called when the layer probing is done.
+	Layer func(op Op, layer Layer, bank int) func()
+	// Done is called when an operation is finished. The probes is the number of slots checked by the operation (for
+	// Overflow2, every checked bucket counts as one). The ok is false if a key is not found on lookup, or if there is
+	// no room for a key on insert.
+	Done func(op Op, probes int, ok bool)
+	// Slot is called for every slot checked on lookup, in probing order.
+	Slot func(op Op, step Step)
+}
+
+// Step is a slot checked by an operation.
+type Step struct {
+	Layer  Layer
+	Bank   int // Bank index in LayerBanks, -1 otherwise
+	Bucket int // Bucket index in LayerBanks and LayerOverflow2, -1 otherwise
+	Slot   int // Slot index in a bucket, or in the layer for LayerOverflow1. -1 if no fingerprint matched in LayerOverflow2
+	// Match is true if the slot key was compared with the looked up one, i.e. the slot is occupied (in LayerOverflow2,
+	// its fingerprint also matched)
+	Match bool
+}
+
+// JoinHooks returns hooks calling all the given hooks in order. The nil hooks are skipped.
+func JoinHooks(hooks ...*Hooks) *Hooks {
+	hooks = slices.DeleteFunc(slices.Clone(hooks), func(h *Hooks) bool { return h == nil })
+	return &Hooks{
+		Layer: func(op Op, layer Layer, bank int) func() {
+			var dones []func()
+			for _, h := range hooks {
+				if h.Layer != nil {
+					if done := h.Layer(op, layer, bank); done != nil {
+						dones = append(dones, done)
+					}
+				}
+			}
+			return func() {
+				for i := len(dones) - 1; i >= 0; i-- {
+					dones[i]()
+				}
+			}
+		},
+		Done: func(op Op, probes int, ok bool) {
+			for _, h := range hooks {
+				if h.Done != nil {
+					h.Done(op, probes, ok)
+				}
+			}
+		},
+		Slot: func(op Op, step Step) {
+			for _, h := range hooks {
+				if h.Slot != nil {
+					h.Slot(op, step)
+				}
+			}
+		},
+	}
+}
+
+// bankBucketLabel returns the range of bank indexes of the same power of 2 as bank, e.g. "4-7" for 5.
+func bankBucketLabel(bank int) string {
+	if bank < 2 {
+		return strconv.Itoa(bank)
+	}
+	lo := 1 << (bits.Len(uint(bank)) - 1)
+	return strconv.Itoa(lo) + "-" + strconv.Itoa(2*lo-1)
+}
+
+// probe keeps the state of a table operation passed through the probing functions. The nil probe is valid, it
+// disables the instrumentation.
+type probe struct {
+	op     Op
+	hooks  *Hooks
+	probes int // Slots checked so far
+	layer  Layer
+	bank   int
+	budget int                    // Maximum slots to check, 0 is unlimited
+	equal  func(a, b []byte) bool // Key comparer, slices.Equal if nil
+	epoch  uint32                 // Table epoch, the slots of other epochs are free. See Clear
+	seq    uint64                 // Insertion order of the entry created by the operation, see Dedup
+	// deleted is true if the operation looks for the soft-deleted entries instead of the visible ones
+	deleted bool
+	// exhausted is true if the operation was cut because of the budget
+	exhausted bool
+	// alloc allocates the slots created by the operation and takes the replaced ones, the Go heap if nil.
+	// The slot keys are its copies if ownKeys is set, see HashTable.CopyKeys
+	alloc   Allocator
+	ownKeys bool
+	// hash is the key hash of the operation, computed once. The found slots must have the same hash if hashes is
+	// set, see HashTable.CacheHashes
+	hash   uint64
+	hashed bool
+	hashes bool
+	given  bool // The hash is given by InsertHashed, the slots keep it, see Slot.ownHash
+	// latency is the layer probing times to add to, if the operation is sampled, see HashTable.LatencySample
+	latency *[layersCount]Latency
+	// sampler is the sampler to record the probe trace to, if the operation is sampled, see HashTable.ProbeSampler
+	sampler *ProbeSampler
+	trace   ProbeSample
+}
+
+// newProbe returns the probe of a table operation.
+func newProbe(table *HashTable, op Op) *probe {
+	return &probe{
+		op: op, hooks: table.Hooks, budget: table.MaxProbes, equal: table.KeyEqual, epoch: table.Epoch,
+		alloc: table.Allocator, ownKeys: table.CopyKeys, hashes: table.CacheHashes, latency: table.sampleLatency(),
+		sampler: table.ProbeSampler.Sample(),
+	}
+}
+
+// enter notifies the hooks that the operation starts probing a layer. Returns the function to call on leaving it.
+func (p *probe) enter(layer Layer, bank int) func() {
+	if p == nil || p.hooks == nil && p.latency == nil && p.sampler == nil {
+		return nop
+	}
+	p.layer, p.bank = layer, bank
+	done := nop
+	if p.hooks != nil && p.hooks.Layer != nil {
+		if d := p.hooks.Layer(p.op, layer, bank); d != nil {
+			done = d
+		}
+	}
+	if p.latency == nil {
+		return done
+	}
+	start, l := time.Now(), &p.latency[layer]
+	return func() {
+		l.Add(time.Since(start))
+		done()
+	}
+}
+
+// count adds n checked slots to the operation. Returns false if the slots would exceed the probe budget, then
+// they must not be checked and the operation must fail.
+func (p *probe) count(n int) bool {
+	if p == nil {
+		return true
+	}
+	if p.budget > 0 && p.probes+n > p.budget {
+		p.exhausted = true
+		return false
+	}
+	p.probes += n
+	return true
+}
+
+// found returns true if an occupied slot holds the key and is visible to the operation.
+func (p *probe) found(slot *Slot, key []byte) bool {
+	if slot.purged || slot.Deleted != (p != nil && p.deleted) {
+		return false
+	}
+	if p != nil && p.hashes && slot.hash != p.hash {
+		return false // Different keys, no need to compare them
+	}
+	return p.match(slot.Key, key)
+}
+
+// bytewise returns true if the keys are compared byte by byte, so the equal keys have the same length.
+func (p *probe) bytewise() bool {
+	return p == nil || p.equal == nil
+}
+
+// keyHash returns the hash of the operation key. It's computed by hasher on the first call, unless it's given.
+func (p *probe) keyHash(hasher func(b []byte) uint64, key []byte) uint64 {
+	if p == nil {
+		return hasher(key)
+	}
+	if !p.hashed {
+		p.hash, p.hashed = hasher(key), true
+	}
+	return p.hash
+}
+
+// match returns true if the keys are equal.
+func (p *probe) match(a, b []byte) bool {
+	if p != nil && p.equal != nil {
+		return p.equal(a, b)
+	}
+	return slices.Equal(a, b)
+}
+
+// tableEpoch returns the table epoch of the operation.
+func (p *probe) tableEpoch() uint32 {
+	if p == nil {
+		return 0
+	}
+	return p.epoch
+}
+
+// live returns true if a slot is occupied in the table epoch of the operation.
+func (p *probe) live(slot *Slot) bool {
+	return live(slot, p.tableEpoch())
+}
+
+// vacant returns true if a slot may be taken by an insert in the table epoch of the operation.
+func (p *probe) vacant(slot *Slot) bool {
+	return vacant(slot, p.tableEpoch())
+}
+
+// slot returns a new slot written in the table epoch of the operation in place of the old one (if any), which is
+// released.
+func (p *probe) slot(old *Slot, key []byte, value any) *Slot {
+	if p == nil || p.alloc == nil {
+		s := newSlot(key, value, p.tableEpoch())
+		if p != nil {
+			s.seq, s.hash, s.ownHash = p.seq, p.hash, p.given
+		}
+		return s
+	}
+	p.release(old)
+	s := p.alloc.NewSlot()
+	*s = Slot{Value: value, Epoch: p.epoch, seq: p.seq, hash: p.hash, ownHash: p.given}
+	s.setKey(key)
+	return s
+}
+
+// release returns a slot dropped by the operation to the allocator.
+func (p *probe) release(slot *Slot) {
+	if p != nil && p.alloc != nil {
+		release(p.alloc, slot, p.ownKeys)
+	}
+}
+
+// visit notifies the hooks that the operation checked a slot in the current layer.
+func (p *probe) visit(bucket, slot int, match bool) {
+	if p == nil || p.hooks == nil && p.sampler == nil {
+		return
+	}
+	step := Step{Layer: p.layer, Bank: p.bank, Bucket: bucket, Slot: slot, Match: match}
+	if p.sampler != nil {
+		p.trace.Steps = append(p.trace.Steps, step)
+	}
+	if p.hooks != nil && p.hooks.Slot != nil {
+		p.hooks.Slot(p.op, step)
+	}
+}
+
+// done notifies the hooks that the operation of a key is finished, and records its trace if it's sampled.
+func (p *probe) done(key []byte, ok bool) {
+	if p == nil {
+		return
+	}
+	if p.sampler != nil {
+		p.trace.Op, p.trace.Key, p.trace.Probes, p.trace.OK = p.op, slices.Clone(key), p.probes, ok
+		p.sampler.Add(p.trace)
+		p.sampler = nil
+	}
+	if p.hooks != nil && p.hooks.Done != nil {
+		p.hooks.Done(p.op, p.probes, ok)
+	}
+}
+
+func nop() {}
@@ -0,0 +1,98 @@
+package funnel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	type visit struct {
+		op    Op
+		layer Layer
+		bank  int
+	}
+
+	t.Run("insert and lookup; should report probed layers in order", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		var visits []visit
+		var left int
+		table.Hooks = &Hooks{Layer: func(op Op, layer Layer, bank int) func() {
+			visits = append(visits, visit{op, layer, bank})
+			return func() { left++ }
+		}}
+
+		table.Insert([]byte("key"), 1)
+		require.Equal(t, []visit{{OpInsert, LayerBanks, 0}}, visits) // Empty table, the 1st bank has room
+		visits = nil
+
+		_, ok := table.Get([]byte("missing"))
+		assert.False(t, ok)
+		var banks int
+		for b := table.Banks; b != nil; b = b.Next {
+			assert.Equal(t, visit{OpLookup, LayerBanks, banks}, visits[banks])
+			banks++
+		}
+		assert.Equal(t, visit{OpLookup, LayerOverflow1, -1}, visits[banks])
+		assert.Equal(t, 1+len(visits), left)
+	})
+
+	t.Run("profile hooks; should not affect results", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Hooks = ProfileHooks(context.Background())
+
+		table.Insert([]byte("key"), 1)
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+}
+
+func TestBankBucketLabel(t *testing.T) {
+	tests := map[int]string{0: "0", 1: "1", 2: "2-3", 3: "2-3", 5: "4-7", 8: "8-15", 15: "8-15"}
+	for bank, expect := range tests {
+		assert.Equal(t, expect, bankBucketLabel(bank), "bank: %v", bank)
+	}
+}
+
+func TestGetWithTrace(t *testing.T) {
+	t.Run("existing key; should end with matched slot", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		v, ok, steps := table.GetWithTrace([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		require.NotEmpty(t, steps)
+		last := steps[len(steps)-1]
+		assert.Equal(t, LayerBanks, last.Layer)
+		assert.Equal(t, 0, last.Bank)
+		assert.True(t, last.Match)
+		bucket, bucketIdx, _ := bankBucket(table.Banks, table.Hasher([]byte("key")), table.BucketSize)
+		assert.Equal(t, bucketIdx, last.Bucket)
+		assert.Equal(t, []byte("key"), bucket[last.Slot].Key)
+	})
+
+	t.Run("missing key; should report every probed slot", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		var probes int
+		table.Hooks = &Hooks{Done: func(_ Op, p int, _ bool) { probes = p }}
+
+		_, ok, steps := table.GetWithTrace([]byte("missing"))
+		assert.False(t, ok)
+		assert.Len(t, steps, probes)
+		var banks int
+		for b := table.Banks; b != nil; b = b.Next {
+			banks++
+		}
+		for i, step := range steps {
+			assert.False(t, step.Match)
+			if i < len(steps)-1 {
+				assert.Equal(t, LayerBanks, step.Layer)
+				assert.Less(t, step.Bank, banks)
+			}
+		}
+		assert.Equal(t, Step{Layer: LayerOverflow1, Bank: -1, Bucket: -1, Slot: steps[len(steps)-1].Slot}, steps[len(steps)-1])
+	})
+}
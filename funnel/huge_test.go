@@ -0,0 +1,77 @@
+//go:build linux && (amd64 || arm64) && !tinygo && !efhtiny
+
+package funnel
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestHugeBank(t *testing.T) {
+	t.Run("keys with bucket index above 2^32; should insert and get them", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("maps 128 GiB of address space")
+		}
+		const buckets = 1 << 33
+		table, err := Build(Layout{
+			Capacity:   buckets,
+			BucketSize: 1,
+			Banks:      []int{buckets},
+			Overflow1:  16,
+			Hasher:     func(b []byte) uint64 { return binary.BigEndian.Uint64(b) },
+		})
+		require.NoError(t, err)
+		// The bank is mapped without reserving memory, only the touched pages are allocated. The garbage collector
+		// does not scan it, so the allocator keeps the slots alive
+		alloc := &keepAllocator{}
+		table.Allocator = alloc
+		bank := table.Banks
+		bank.Data, bank.Occupied, bank.Epoch = mapSlice[*Slot](t, bank.Size), mapSlice[uint64](t, bank.Size), table.Epoch
+
+		hashes := []uint64{1 << 32, 1<<32 + 1, 3 << 31, buckets - 1, buckets + 1<<32 + 2}
+		for i, h := range hashes {
+			require.NoError(t, table.TryInsert(binary.BigEndian.AppendUint64(nil, h), i))
+		}
+
+		for i, h := range hashes {
+			key := binary.BigEndian.AppendUint64(nil, h)
+			v, ok := table.Get(key)
+			assert.True(t, ok, "hash: %#x", h)
+			assert.Equal(t, i, v, "hash: %#x", h)
+			require.NotNil(t, bank.Data[h%buckets], "hash: %#x", h)
+			assert.Equal(t, key, bank.Data[h%buckets].Key, "hash: %#x", h)
+		}
+		_, ok := table.Get(binary.BigEndian.AppendUint64(nil, 1<<32+3))
+		assert.False(t, ok)
+		assert.Len(t, alloc.slots, len(hashes))
+	})
+}
+
+// keepAllocator allocates on the Go heap and keeps the slots reachable.
+type keepAllocator struct {
+	HeapAllocator
+	slots []*Slot
+}
+
+func (a *keepAllocator) NewSlot() *Slot {
+	s := a.HeapAllocator.NewSlot()
+	a.slots = append(a.slots, s)
+	return s
+}
+
+// mapSlice returns a zeroed slice of n elements in the memory mapped without reserving it, so its pages are
+// allocated on the first touch. It's unmapped on the test cleanup.
+func mapSlice[T any](t *testing.T, n int) []T {
+	var zero T
+	mem, err := syscall.Mmap(
+		-1, 0, n*int(unsafe.Sizeof(zero)), syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_ANON|syscall.MAP_PRIVATE|syscall.MAP_NORESERVE,
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, syscall.Munmap(mem)) })
+	return unsafe.Slice((*T)(unsafe.Pointer(unsafe.SliceData(mem))), n)
+}
@@ -2,137 +2,255 @@ package funnel
 
 import (
 	"encoding/binary"
-	"math/rand/v2"
-	"slices"
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
 )
 
 type Bank struct {
 	Data []*Slot // Contains ``buckets * β'' slots
 	Size int
 	Next *Bank // Ai+1 bank
+	// Occupied is the bitmap of occupied slots of every bucket, so that inserts find the first free slot (e.g. freed
+	// by a removal) without touching the others. Valid in the table epoch Epoch only. Nil if β is over 64
+	Occupied []uint64
+	Epoch    uint32
+	// BucketMask is the buckets count minus 1 if the count is a power of 2, so the bucket is selected by masking
+	// the hash instead of the division. Zero selects it by the hash modulo the count. See Config.PowerOfTwoBuckets
+	BucketMask uint64
 }
 
 type Slot struct {
 	Key   []byte
 	Value any
+	// Version is incremented on every value update, see GetVersioned
+	Version uint64
+	seq     uint64 // Insertion order of the entry, see Dedup
+	hash    uint64 // Key hash, set if HashTable.CacheHashes is set
+	Epoch   uint32 // Table epoch the slot was written in, see Clear
+	Flags   uint8  // Application-defined entry marks, see SetFlags
+	Deleted bool   // The entry is hidden by SoftDelete
+	purged  bool   // The entry is removed by Purge, the slot may be reused
+	// ownHash is true if hash was given by InsertHashed, so it's kept regardless of HashTable.CacheHashes, since
+	// the Hasher may hash the key differently
+	ownHash bool
+	// inline keeps the key if it's not longer than inlineKeySize, so the short keys take no allocation and are read
+	// along with the slot. Key refers to it then
+	inline [inlineKeySize]byte
+}
+
+// ProbeSource generates the random probe sequences of Overflow1. It's reset by Seed to the sequence of a key before
+// every operation, so the sequences must depend on the seed only. *rand.ChaCha8 implements it.
+type ProbeSource interface {
+	Seed(seed [32]byte)
+	Uint64() uint64
 }
 
 type Overflow struct {
 	Slots   []*Slot
-	Loglogn float64 // log2(log2(capacity))
-	Seed    uint32
-	Rnd     *rand.ChaCha8
+	Ctrl    []byte   // Control bytes with slot fingerprints, grouped by buckets. Overflow2 only
+	Epochs  []uint32 // Table epoch of every bucket control group, see Clear. Overflow2 only
+	Loglogn float64  // log2(log2(capacity))
+	Probes  int      // Slots probed by an operation, ⌊Loglogn⌋ if zero. Overflow1 only
+	// FullProbe makes an operation probe all slots instead of Probes, see Overflow1FullScan. Overflow1 only
+	FullProbe bool
+	Seed      uint64
+	Rnd       ProbeSource // See Config.Rand. Overflow1 only
+	// Tags are the wide fingerprints of slots, TagSize bytes each, checked after the control bytes match. Empty if
+	// only the control bytes are used, see Config.FingerprintBits. Overflow2 only
+	Tags    []byte
+	TagSize int
+	// KeyLens are the key length classes of slots, checked after the tags match, so the keys of other lengths are
+	// rejected without reading the slot. See keyLenClass. Overflow2 only
+	KeyLens []byte
 }
 
-func insert(table *HashTable, key []byte, value any) {
-	hsh := table.Hasher(key)
-	ok := bankInsert(table.Banks, hsh, key, value, table.BucketSize)
+// insert inserts a key-value pair into the table layers one by one. Returns false if no slot was found.
+func insert(table *HashTable, pr *probe, key []byte, value any) bool {
+	hsh := pr.keyHash(table.Hasher, key)
+	layer := LayerBanks
+	ok := bankInsert(pr, table.Banks, hsh, key, value, table.BucketSize)
 	if len(table.Overflow1.Slots) > 0 && !ok {
-		ok = overflowUniformInsert(table.Overflow1, hsh, key, value, len(table.Overflow2.Slots) == 0)
+		layer = LayerOverflow1
+		done := pr.enter(LayerOverflow1, -1)
+		ok = overflowUniformInsert(pr, table.Overflow1, hsh, key, value, table.Overflow1.FullProbe)
+		done()
 	}
 	if len(table.Overflow2.Slots) > 0 && !ok {
-		hsh = table.Hasher(key) ^ table.Overflow1.Seed
-		hsh2 := table.Hasher(key) ^ table.Overflow2.Seed
-		ok = overflowTwoChoiceInsert(table.Overflow2, hsh, hsh2, key, value)
+		layer = LayerOverflow2
+		done := pr.enter(LayerOverflow2, -1)
+		hsh2 := hsh ^ table.Overflow2.Seed
+		hsh ^= table.Overflow1.Seed
+		ok = overflowTwoChoiceInsert(pr, table.Overflow2, hsh, hsh2, key, value)
+		done()
 	}
-	if !ok {
-		panic("no free slots")
+	pr.done(key, ok)
+	if ok {
+		table.Inserts++
+		table.LayerInserts[layer]++
+		table.countInsert(layer)
 	}
-	table.Inserts++
+	return ok
+}
+
+// lookup searches for a key in the table.
+func lookup(table *HashTable, pr *probe, key []byte) (*Slot, bool) {
+	slot, ok := layersLookup(table, pr, key)
+	pr.done(key, ok)
+	return slot, ok
 }
 
-func lookup(table *HashTable, key []byte) (*Slot, bool) {
-	hsh := table.Hasher(key)
-	if value, ok := bankLookup(table.Banks, hsh, key, table.BucketSize); ok {
+// layersLookup searches for a key in the table layers one by one.
+func layersLookup(table *HashTable, pr *probe, key []byte) (*Slot, bool) {
+	hsh := pr.keyHash(table.Hasher, key)
+	if value, ok := bankLookup(pr, table.Banks, hsh, key, table.BucketSize); ok {
 		return value, true
 	}
 	if len(table.Overflow1.Slots) > 0 {
-		if value, ok := overflowUniformLookup(table.Overflow1, hsh, key, len(table.Overflow2.Slots) == 0); ok {
+		done := pr.enter(LayerOverflow1, -1)
+		value, ok := overflowUniformLookup(pr, table.Overflow1, hsh, key, table.Overflow1.FullProbe)
+		done()
+		if ok {
 			return value, true
 		}
 	}
 	if len(table.Overflow2.Slots) > 0 {
-		hsh = table.Hasher(key) ^ table.Overflow1.Seed
-		hsh2 := table.Hasher(key) ^ table.Overflow2.Seed
-		return overflowTwoChoiceLookup(table.Overflow2, hsh, hsh2, key)
+		defer pr.enter(LayerOverflow2, -1)()
+		hsh2 := hsh ^ table.Overflow2.Seed
+		hsh ^= table.Overflow1.Seed
+		return overflowTwoChoiceLookup(pr, table.Overflow2, hsh, hsh2, key)
 	}
 
 	return nil, false
 }
 
 // bankInsert makes "attempted insertion" a key-value pair into a banks except overflow banks.
-func bankInsert(bank *Bank, hsh uint32, key []byte, value any, bucketSize int) bool {
-	if bank == nil {
-		return false
-	}
-	slots := bank.Size
-	if bank.Data == nil {
-		bank.Data = make([]*Slot, slots)
+func bankInsert(pr *probe, bank *Bank, hsh uint64, key []byte, value any, bucketSize int) bool {
+	for i := 0; bank != nil; bank, i = bank.Next, i+1 {
+		done := pr.enter(LayerBanks, i)
+		ok := bucketInsert(pr, bank, hsh, key, value, bucketSize)
+		done()
+		if ok {
+			return true
+		}
 	}
+	return false
+}
 
-	buckets := slots / bucketSize
-	bucketOffset := int(hsh%uint32(buckets)) * bucketSize
-	innerOffset := int(hsh % uint32(bucketSize))
+// bucketInsert tries to insert a key-value pair into a bucket of the bank selected by hash.
+func bucketInsert(pr *probe, bank *Bank, hsh uint64, key []byte, value any, bucketSize int) bool {
+	bank.alloc(bucketSize)
+	bucket, bucketIdx, innerOffset := bankBucket(bank, hsh, bucketSize)
 
-	// Linear circular probing one bucket, starting from slot depending on hash
-	for j := 0; j < bucketSize; j++ {
-		idx := bucketOffset + (innerOffset+j)%bucketSize
-		if bank.Data[idx] == nil {
-			bank.Data[idx] = newSlot(key, value)
-			return true
+	if occupied := bank.occupancy(pr.tableEpoch()); occupied != nil {
+		// The slots before the free one are counted as probed, as the linear probing below does
+		distance := freeSlot(occupied[bucketIdx], int(innerOffset), bucketSize)
+		if distance < 0 {
+			pr.count(bucketSize)
+			return false
+		}
+		if !pr.count(distance + 1) {
+			return false
 		}
+		j := (innerOffset + uint(distance)) % uint(len(bucket))
+		bucket[j] = pr.slot(bucket[j], key, value)
+		occupied[bucketIdx] |= 1 << j
+		return true
 	}
 
-	return bankInsert(bank.Next, hsh, key, value, bucketSize)
+	// Linear circular probing one bucket, starting from slot depending on hash
+	for _, part := range [2][]*Slot{bucket[innerOffset:], bucket[:innerOffset]} {
+		for i := range part {
+			if !pr.count(1) {
+				return false
+			}
+			if pr.vacant(part[i]) {
+				part[i] = pr.slot(part[i], key, value)
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // bankLookup searches for a key-value pair in a banks except overflow banks.
-func bankLookup(bank *Bank, hsh uint32, key []byte, bucketSize int) (*Slot, bool) {
-	if bank == nil {
-		return nil, false
+func bankLookup(pr *probe, bank *Bank, hsh uint64, key []byte, bucketSize int) (*Slot, bool) {
+	for i := 0; bank != nil; bank, i = bank.Next, i+1 {
+		done := pr.enter(LayerBanks, i)
+		slot, ok := bucketLookup(pr, bank, hsh, key, bucketSize)
+		done()
+		if ok {
+			return slot, true
+		}
 	}
-	slots := len(bank.Data)
+	return nil, false
+}
 
-	buckets := slots / bucketSize
-	bucketOffset := int(hsh%uint32(buckets)) * bucketSize
-	innerOffset := int(hsh % uint32(bucketSize))
+// bucketLookup searches for a key-value pair in a bucket of the bank selected by hash.
+func bucketLookup(pr *probe, bank *Bank, hsh uint64, key []byte, bucketSize int) (*Slot, bool) {
+	if bank.Data == nil {
+		return nil, false // Nothing was inserted into this bank yet
+	}
+	bucket, bucketIdx, innerOffset := bankBucket(bank, hsh, bucketSize)
 
 	// Linear circular probing one bucket, starting from slot depending on hash
-	for j := 0; j < bucketSize; j++ {
-		idx := bucketOffset + (innerOffset+j)%bucketSize
-		if bank.Data[idx] == nil {
-			continue
-		}
-		if slices.Equal(bank.Data[idx].Key, key) {
-			return bank.Data[idx], true
+	j := int(innerOffset) // Slot index in bucket
+	for _, part := range [2][]*Slot{bucket[innerOffset:], bucket[:innerOffset]} {
+		for _, slot := range part {
+			if !pr.count(1) {
+				return nil, false
+			}
+			live := pr.live(slot)
+			pr.visit(bucketIdx, j%bucketSize, live)
+			j++
+			if !live {
+				return nil, false // Removals shift the entries back, so the key cannot be after a free slot
+			}
+			if pr.found(slot, key) {
+				return slot, true
+			}
 		}
 	}
+	return nil, false
+}
 
-	return bankLookup(bank.Next, hsh, key, bucketSize)
+// bankBucket returns the bucket in bank selected by hash, its index and the slot offset in it to start probing from.
+//
+// The offset is taken modulo the bucket length and the bucket capacity is cut to it, so that the compiler proves
+// the probing of the bucket in bounds and drops the bounds checks (see `-gcflags=-d=ssa/check_bce`).
+func bankBucket(bank *Bank, hsh uint64, bucketSize int) ([]*Slot, int, uint) {
+	var bucketIdx uint64
+	if bank.BucketMask != 0 {
+		bucketIdx = hsh & bank.BucketMask // Same as the modulo for the power of 2
+	} else {
+		bucketIdx = hsh % uint64(len(bank.Data)/bucketSize)
+	}
+	off := uint(bucketIdx) * uint(bucketSize)
+	bucket := bank.Data[off : off+uint(bucketSize) : off+uint(bucketSize)]
+	return bucket, int(bucketIdx), uint(hsh % uint64(len(bucket)))
 }
 
 // overflowUniformInsert tries to insert a key-value pair into the overflow1 bank. This bank behaves as a separate
 // open-addressed hash table with uniform random probing. Returns true if the insertion was successful, otherwise false.
 // The fullProbe is true if the insertion must probe the whole table instead of the log(log(n)) slots.
-func overflowUniformInsert(ovf *Overflow, hsh uint32, key []byte, value any, fullProbe bool) bool {
+func overflowUniformInsert(pr *probe, ovf *Overflow, hsh uint64, key []byte, value any, fullProbe bool) bool {
 	var seed [32]byte
-	binary.BigEndian.PutUint32(seed[:], hsh^ovf.Seed)
+	binary.BigEndian.PutUint64(seed[:], hsh^ovf.Seed)
 	ovf.Rnd.Seed(seed)
 
-	slots := len(ovf.Slots)
+	slots := ovf.Slots
 
 	// Random probing
-	idx := int(hsh % uint32(slots))
-	probes := int(ovf.Loglogn)
+	probes := ovf.probeLimit()
 	if fullProbe {
-		probes = slots
+		probes = len(slots)
 	}
-	for i := 0; i < probes; i++ {
-		if ovf.Slots[idx] == nil {
-			ovf.Slots[idx] = newSlot(key, value)
+	for i, r := 0, uint64(hasher.Reduce(hsh, len(slots))); i < probes; i, r = i+1, ovf.Rnd.Uint64() {
+		if !pr.count(1) {
+			return false
+		}
+		if slot := &slots[r%uint64(len(slots))]; pr.vacant(*slot) {
+			*slot = pr.slot(*slot, key, value)
 			return true
 		}
-		idx = int(ovf.Rnd.Uint64() % uint64(slots))
 	}
 
 	return false
@@ -141,84 +259,138 @@ func overflowUniformInsert(ovf *Overflow, hsh uint32, key []byte, value any, ful
 // overflowUniformLookup searches for a key-value pair in the overflow1 bank. This bank behaves as a separate
 // open-addressed hash table with uniform random probing. Returns a found slot and true if the slot was found, otherwise
 // nil and false. The fullProbe is true if the insertion must probe the whole table instead of the log(log(n)) slots.
-func overflowUniformLookup(ovf *Overflow, hsh uint32, key []byte, fullProbe bool) (*Slot, bool) {
+func overflowUniformLookup(pr *probe, ovf *Overflow, hsh uint64, key []byte, fullProbe bool) (*Slot, bool) {
 	var seed [32]byte
-	binary.BigEndian.PutUint32(seed[:], hsh^ovf.Seed)
+	binary.BigEndian.PutUint64(seed[:], hsh^ovf.Seed)
 	ovf.Rnd.Seed(seed)
 
-	slots := len(ovf.Slots)
+	slots := ovf.Slots
 
-	idx := int(hsh % uint32(slots))
-	probes := int(ovf.Loglogn)
+	probes := ovf.probeLimit()
 	if fullProbe {
-		probes = slots
+		probes = len(slots)
 	}
-	for i := 0; i < probes; i++ {
-		if ovf.Slots[idx] == nil {
+	for i, r := 0, uint64(hasher.Reduce(hsh, len(slots))); i < probes; i, r = i+1, ovf.Rnd.Uint64() {
+		if !pr.count(1) {
+			return nil, false
+		}
+		idx := r % uint64(len(slots))
+		slot := slots[idx]
+		live := pr.live(slot)
+		pr.visit(-1, int(idx), live)
+		if !live {
 			return nil, false
 		}
-		if slices.Equal(ovf.Slots[idx].Key, key) {
-			return ovf.Slots[idx], true
+		if pr.found(slot, key) {
+			return slot, true
 		}
-		idx = int(ovf.Rnd.Uint64() % uint64(slots))
 	}
 
 	return nil, false
 }
 
+// probeLimit returns the number of slots probed in the overflow1 bank.
+func (ovf *Overflow) probeLimit() int {
+	if ovf.Probes > 0 {
+		return ovf.Probes
+	}
+	return int(ovf.Loglogn)
+}
+
 // overflowTwoChoiceInsert tries to insert a key-value pair into the overflow2 bank. This bank behaves as a separate
 // open-addressed hash table with buckets and two-choice hashing.
 // Returns a found slot and true if the slot was found, otherwise nil and false.
-func overflowTwoChoiceInsert(ovf *Overflow, hsh1, hsh2 uint32, key []byte, value any) bool {
-	// Linear probing two buckets, fail if both are full
+func overflowTwoChoiceInsert(pr *probe, ovf *Overflow, hsh1, hsh2 uint64, key []byte, value any) bool {
 	bucketSize := int(2 * ovf.Loglogn)
 	buckets := len(ovf.Slots) / bucketSize
-	bucket1 := int(hsh1%uint32(buckets)) * bucketSize
-	bucket2 := int(hsh2%uint32(buckets)) * bucketSize
-	for j := 0; j < bucketSize; j++ {
-		if ovf.Slots[bucket1+j] == nil {
-			ovf.Slots[bucket1+j] = newSlot(key, value)
-			return true
-		}
-		if ovf.Slots[bucket2+j] == nil {
-			ovf.Slots[bucket2+j] = newSlot(key, value)
-			return true
-		}
+	bucket1 := hasher.Reduce(hsh1, buckets)
+	bucket2 := hasher.Reduce(hsh2, buckets)
+
+	// Take the first free slot in order bucket1[0], bucket2[0], bucket1[1], ..., fail if both buckets are full
+	if !pr.count(2) {
+		return false
+	}
+	j1 := firstSlot(matchEmpty(overflowGroup(pr, ovf, bucket1, bucketSize)))
+	j2 := firstSlot(matchEmpty(overflowGroup(pr, ovf, bucket2, bucketSize)))
+	bucket, j := bucket1, j1
+	if j2 < j1 {
+		bucket, j = bucket2, j2
 	}
+	if j >= bucketSize {
+		return false
+	}
+	ovf.Ctrl[bucket*ctrlStride(bucketSize)+j] = fingerprint(hsh1)
+	ovf.setTag(bucket*bucketSize+j, hsh1)
+	ovf.setKeyLen(bucket*bucketSize+j, len(key))
+	ovf.Slots[bucket*bucketSize+j] = pr.slot(ovf.Slots[bucket*bucketSize+j], key, value)
 
-	return false
+	return true
 }
 
 // overflowTwoChoiceLookup searches for a key-value pair in the overflow2 bank. This bank behaves as a separate
 // open-addressed hash table with buckets and two-choice hashing.
 // Returns a found slot and true if the slot was found, otherwise nil and false.
-func overflowTwoChoiceLookup(ovf *Overflow, hsh1, hsh2 uint32, key []byte) (*Slot, bool) {
-	// Linear probing two buckets
+func overflowTwoChoiceLookup(pr *probe, ovf *Overflow, hsh1, hsh2 uint64, key []byte) (*Slot, bool) {
 	bucketSize := int(2 * ovf.Loglogn)
 	buckets := len(ovf.Slots) / bucketSize
-	bucket1 := int(hsh1%uint32(buckets)) * bucketSize
-	bucket2 := int(hsh2%uint32(buckets)) * bucketSize
-	for j := 0; j < bucketSize; j++ {
-		if ovf.Slots[bucket1+j] == nil {
+	fp := fingerprint(hsh1)
+
+	// Compare keys only in slots which fingerprints match
+	for _, bucket := range [2]int{hasher.Reduce(hsh1, buckets), hasher.Reduce(hsh2, buckets)} {
+		if !pr.count(1) {
 			return nil, false
 		}
-		if slices.Equal(ovf.Slots[bucket1+j].Key, key) {
-			return ovf.Slots[bucket1+j], true
+		m := matchGroup(overflowGroup(pr, ovf, bucket, bucketSize), fp)
+		if m == 0 {
+			pr.visit(bucket, -1, false)
 		}
-		if ovf.Slots[bucket2+j] == nil {
-			return nil, false
-		}
-		if slices.Equal(ovf.Slots[bucket2+j].Key, key) {
-			return ovf.Slots[bucket2+j], true
+		for ; m != 0; m &= m - 1 {
+			pr.visit(bucket, firstSlot(m), true)
+			idx := bucket*bucketSize + firstSlot(m)
+			slot := ovf.Slots[idx]
+			if !ovf.tagMatch(idx, hsh1) || pr.bytewise() && !ovf.keyLenMatch(idx, len(key)) {
+				continue
+			}
+			if slot != nil && pr.found(slot, key) {
+				return slot, true
+			}
 		}
 	}
 
 	return nil, false
 }
 
-func newSlot(key []byte, value any) *Slot {
-	return &Slot{
-		Key:   key,
+// overflowGroup returns the control group of the overflow2 bucket. If the bucket was written before the last Clear,
+// it's emptied first.
+func overflowGroup(pr *probe, ovf *Overflow, bucket, bucketSize int) []byte {
+	stride := ctrlStride(bucketSize)
+	group := ovf.Ctrl[bucket*stride : bucket*stride+stride]
+	if epoch := pr.tableEpoch(); ovf.Epochs[bucket] != epoch {
+		copy(group, newCtrl(bucketSize, bucketSize))
+		for _, slot := range ovf.Slots[bucket*bucketSize : bucket*bucketSize+bucketSize] {
+			pr.release(slot)
+		}
+		clear(ovf.Slots[bucket*bucketSize : bucket*bucketSize+bucketSize])
+		ovf.Epochs[bucket] = epoch
+	}
+	return group
+}
+
+func newSlot(key []byte, value any, epoch uint32) *Slot {
+	s := &Slot{
 		Value: value,
+		Epoch: epoch,
+	}
+	s.setKey(key)
+	return s
+}
+
+// setKey sets the slot key, the short keys are copied into the slot itself.
+func (s *Slot) setKey(key []byte) {
+	if n := len(key); n > 0 && n <= inlineKeySize {
+		s.Key = s.inline[:n:n]
+		copy(s.Key, key)
+		return
 	}
+	s.Key = key
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"math/rand/v2"
+	"slices"
 	"testing"
 )
 
@@ -16,27 +17,27 @@ func TestOverflowTwoChoiceInsert(t *testing.T) {
 	)
 
 	t.Run("insert and lookup; should return value by key", func(t *testing.T) {
-		ovf := Overflow{Slots: make([]*Slot, bucketSize*buckets), Loglogn: bucketSize / 2}
+		ovf := newTwoChoiceOverflow(make([]*Slot, bucketSize*buckets), bucketSize, 0)
 
 		keys := []byte{4, 19, 33, 47}
 		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
 		t.Logf("keys: %#v", keys)
-		hashes1 := make([]uint32, bucketSize*buckets)
-		hashes2 := make([]uint32, bucketSize*buckets)
+		hashes1 := make([]uint64, bucketSize*buckets)
+		hashes2 := make([]uint64, bucketSize*buckets)
 		for i, k := range keys {
-			hashes1[i] = uint32(k * k)
-			hashes2[i] = uint32(k * k)
+			hashes1[i] = uint64(k * k)
+			hashes2[i] = uint64(k * k)
 		}
 
 		for i, k := range keys {
 			assert.True(
-				t, overflowTwoChoiceInsert(&ovf, hashes1[i], hashes2[i], []byte{k}, []byte{k}),
+				t, overflowTwoChoiceInsert(nil, &ovf, hashes1[i], hashes2[i], []byte{k}, []byte{k}),
 				"[%v]: %v, %v", i, hashes1[i], hashes2[i],
 			)
 		}
 
 		for i, k := range keys {
-			slot, ok := overflowTwoChoiceLookup(&ovf, hashes1[i], hashes2[i], []byte{k})
+			slot, ok := overflowTwoChoiceLookup(nil, &ovf, hashes1[i], hashes2[i], []byte{k})
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -57,13 +58,13 @@ func TestOverflowTwoChoiceInsert(t *testing.T) {
 				Value: []byte{byte(i)},
 			}
 		}
-		ovf := Overflow{Slots: slots, Loglogn: bucketSize / 2}
 
-		hsh1 := uint32(8657) // bucket 1
-		hsh2 := uint32(9812) // bucket 4
+		hsh1 := uint64(8657) // bucket 1
+		hsh2 := uint64(9812) // bucket 4
+		ovf := newTwoChoiceOverflow(slots, bucketSize, fingerprint(hsh1))
 
 		assert.False(
-			t, overflowTwoChoiceInsert(&ovf, hsh1, hsh2, []byte{0}, []byte{0}),
+			t, overflowTwoChoiceInsert(nil, &ovf, hsh1, hsh2, []byte{0}, []byte{0}),
 			"table overflow",
 		)
 	})
@@ -84,18 +85,18 @@ func TestOverflowTwoChoiceLookup(t *testing.T) {
 				Value: []byte{byte(i)},
 			})
 		}
-		ovf := Overflow{Slots: slots, Loglogn: bucketSize / 2}
 
-		hsh1 := uint32(8663) // bucket 7
-		hsh2 := uint32(9811) // bucket 3
+		hsh1 := uint64(8663) // bucket 7
+		hsh2 := uint64(9811) // bucket 3
+		ovf := newTwoChoiceOverflow(slots, bucketSize, fingerprint(hsh1))
 
-		for i := uint32(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
-			slot, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(i)})
+		for i := uint64(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
+			slot, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(i)})
 			assert.True(t, ok)
 			assert.Equal(t, slots[i], slot)
 		}
-		for i := uint32(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
-			slot, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(i)})
+		for i := uint64(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
+			slot, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(i)})
 			assert.True(t, ok)
 			assert.Equal(t, slots[i], slot)
 		}
@@ -109,48 +110,48 @@ func TestOverflowTwoChoiceLookup(t *testing.T) {
 				Value: []byte{byte(i)},
 			})
 		}
-		ovf := Overflow{Slots: slots, Loglogn: bucketSize / 2}
 
-		hsh1 := uint32(8663) // bucket 7
-		hsh2 := uint32(9811) // bucket 3
+		hsh1 := uint64(8663) // bucket 7
+		hsh2 := uint64(9811) // bucket 3
+		ovf := newTwoChoiceOverflow(slots, bucketSize, fingerprint(hsh1))
 
 		// Hash matches, but key is different
-		for i := uint32(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
-			_, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(i + 100)})
+		for i := uint64(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(i + 100)})
 			assert.False(t, ok)
 		}
-		for i := uint32(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
-			_, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(i + 100)})
+		for i := uint64(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(i + 100)})
 			assert.False(t, ok)
 		}
 		// Key matches, but hash is different
-		for i := uint32(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
+		for i := uint64(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
 			h1 := hsh1 + 1
 			h2 := hsh2 + 1
-			_, ok := overflowTwoChoiceLookup(&ovf, h1, h2, []byte{byte(i)})
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, h1, h2, []byte{byte(i)})
 			assert.False(t, ok)
 		}
-		for i := uint32(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
+		for i := uint64(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
 			h1 := hsh1 + 1
 			h2 := hsh2 + 1
-			_, ok := overflowTwoChoiceLookup(&ovf, h1, h2, []byte{byte(i)})
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, h1, h2, []byte{byte(i)})
 			assert.False(t, ok)
 		}
 	})
 
 	t.Run("lookup in empty table; should fail", func(t *testing.T) {
 		// Ensure that the lookup function does not look outside a bucket that hash points to.
-		ovf := Overflow{Slots: make([]*Slot, bucketSize*buckets), Loglogn: bucketSize / 2}
+		ovf := newTwoChoiceOverflow(make([]*Slot, bucketSize*buckets), bucketSize, 0)
 
-		hsh1 := uint32(8663) // bucket 7
-		hsh2 := uint32(9811) // bucket 3
+		hsh1 := uint64(8663) // bucket 7
+		hsh2 := uint64(9811) // bucket 3
 
-		for i := uint32(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
-			_, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(i)})
+		for i := uint64(7 * bucketSize); i < 7*bucketSize+bucketSize; i++ {
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(i)})
 			assert.False(t, ok)
 		}
-		for i := uint32(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
-			_, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(i)})
+		for i := uint64(3 * bucketSize); i < 3*bucketSize+bucketSize; i++ {
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(i)})
 			assert.False(t, ok)
 		}
 	})
@@ -164,21 +165,38 @@ func TestOverflowTwoChoiceLookup(t *testing.T) {
 				Value: []byte{byte(i)},
 			})
 		}
-		ovf := Overflow{Slots: slots, Loglogn: bucketSize / 2}
 
-		hsh1 := uint32(8662) // bucket 6
-		hsh2 := uint32(9812) // bucket 4
+		hsh1 := uint64(8662) // bucket 6
+		hsh2 := uint64(9812) // bucket 4
+		ovf := newTwoChoiceOverflow(slots, bucketSize, fingerprint(hsh1))
 
-		tests := []uint32{
+		tests := []uint64{
 			5 * bucketSize, 5*bucketSize + bucketSize - 1, // Keys are located in bucket 5
 		}
 		for _, tt := range tests {
-			_, ok := overflowTwoChoiceLookup(&ovf, hsh1, hsh2, []byte{byte(tt)})
+			_, ok := overflowTwoChoiceLookup(nil, &ovf, hsh1, hsh2, []byte{byte(tt)})
 			assert.False(t, ok)
 		}
 	})
 }
 
+// newTwoChoiceOverflow makes the overflow2 bank from slots, marking every occupied slot with the fp fingerprint.
+func newTwoChoiceOverflow(slots []*Slot, bucketSize int, fp byte) Overflow {
+	ovf := Overflow{
+		Slots:   slots,
+		Ctrl:    newCtrl(len(slots), bucketSize),
+		Epochs:  make([]uint32, len(slots)/bucketSize),
+		Loglogn: float64(bucketSize) / 2,
+	}
+	stride := ctrlStride(bucketSize)
+	for i, s := range slots {
+		if s != nil {
+			ovf.Ctrl[i/bucketSize*stride+i%bucketSize] = fp
+		}
+	}
+	return ovf
+}
+
 func TestOverflowUniformInsert(t *testing.T) {
 	const (
 		slotsCount = 32
@@ -189,25 +207,25 @@ func TestOverflowUniformInsert(t *testing.T) {
 	binary.BigEndian.PutUint32(rndSeed[:], seed)
 
 	t.Run("insert and lookup with limited probes; should be ok", func(t *testing.T) {
-		rnd := rand.NewChaCha8(rndSeed)
+		rnd := newProbeRand(rndSeed)
 		ovf := Overflow{Slots: make([]*Slot, slotsCount), Loglogn: probeLimit, Rnd: rnd, Seed: seed}
 		keys := []byte{4, 19, 33, 47}
 		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
 		t.Logf("keys: %#v", keys)
-		hashes := make([]uint32, slotsCount)
+		hashes := make([]uint64, slotsCount)
 		for i, k := range keys {
-			hashes[i] = uint32(k * k)
+			hashes[i] = uint64(k * k)
 		}
 
 		for i, k := range keys {
 			assert.True(
-				t, overflowUniformInsert(&ovf, hashes[i], []byte{k}, []byte{k}, false),
+				t, overflowUniformInsert(nil, &ovf, hashes[i], []byte{k}, []byte{k}, false),
 				"[%v]: %v", i, hashes[i],
 			)
 		}
 
 		for i, k := range keys {
-			slot, ok := overflowUniformLookup(&ovf, hashes[i], []byte{k}, false)
+			slot, ok := overflowUniformLookup(nil, &ovf, hashes[i], []byte{k}, false)
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -215,25 +233,25 @@ func TestOverflowUniformInsert(t *testing.T) {
 	})
 
 	t.Run("insert and lookup will full probes; should be ok", func(t *testing.T) {
-		rnd := rand.NewChaCha8(rndSeed)
+		rnd := newProbeRand(rndSeed)
 		ovf := Overflow{Slots: make([]*Slot, slotsCount), Loglogn: probeLimit, Rnd: rnd, Seed: seed}
 		keys := []byte{4, 19, 33, 47}
 		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
 		t.Logf("keys: %#v", keys)
-		hashes := make([]uint32, slotsCount)
+		hashes := make([]uint64, slotsCount)
 		for i, k := range keys {
-			hashes[i] = uint32(k * k)
+			hashes[i] = uint64(k * k)
 		}
 
 		for i, k := range keys {
 			assert.True(
-				t, overflowUniformInsert(&ovf, hashes[i], []byte{k}, []byte{k}, true),
+				t, overflowUniformInsert(nil, &ovf, hashes[i], []byte{k}, []byte{k}, true),
 				"[%v]: %v", i, hashes[i],
 			)
 		}
 
 		for i, k := range keys {
-			slot, ok := overflowUniformLookup(&ovf, hashes[i], []byte{k}, true)
+			slot, ok := overflowUniformLookup(nil, &ovf, hashes[i], []byte{k}, true)
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -246,41 +264,33 @@ func TestOverflowUniformLookup(t *testing.T) {
 		probeLimit = 3
 		seed       = 1009
 	)
-	var rndSeed [32]byte
-	binary.BigEndian.PutUint32(rndSeed[:], seed)
 
 	t.Run("lookup key before probe limit exceeds; should be ok", func(t *testing.T) {
 		const slotsCount = 40
 		ovf := Overflow{Slots: make([]*Slot, slotsCount), Loglogn: probeLimit}
-
-		keys := []byte{4, 19, 33, 47}
-		hashes := make([]uint32, slotsCount)
-		for i, k := range keys {
-			hashes[i] = uint32(k * k)
-		}
+		keys := uniformProbeKeys(slotsCount, probeLimit-1, seed, 4)
+		require.Len(t, keys, 4) // Tune slotsCount if constantly fails
 
 		// Place items to the slots unreachable by the uniform probing
-		for i, k := range keys {
-			var s [32]byte
-			binary.BigEndian.PutUint32(s[:], hashes[i]^seed)
-			rnd := rand.NewChaCha8(s)
-
-			idx := hashes[i] % slotsCount
-			for p := 0; p < probeLimit-1; p++ {
-				ovf.Slots[idx] = &Slot{} // Dummy item to keep the probes going
-				idx = uint32(rnd.Uint64() % slotsCount)
+		for _, k := range keys {
+			path := uniformProbePath(keyHash(k), slotsCount, probeLimit-1, seed)
+			for _, idx := range path[:len(path)-1] {
+				if ovf.Slots[idx] == nil {
+					ovf.Slots[idx] = &Slot{} // Dummy item to keep the probes going
+				}
 			}
-			require.Nil(t, ovf.Slots[idx], "[%v]: %v", idx, k) // Tune slotsCount or keys count if constantly fails
+			idx := path[len(path)-1]
+			require.Nil(t, ovf.Slots[idx])
 			ovf.Slots[idx] = &Slot{
 				Key:   []byte{k},
 				Value: []byte{k},
 			}
 		}
 
-		for i, k := range keys {
-			ovf.Rnd = rand.NewChaCha8([32]byte{})
+		for _, k := range keys {
+			ovf.Rnd = newProbeRand([32]byte{})
 			ovf.Seed = seed
-			slot, ok := overflowUniformLookup(&ovf, hashes[i], []byte{k}, false)
+			slot, ok := overflowUniformLookup(nil, &ovf, keyHash(k), []byte{k}, false)
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -290,40 +300,69 @@ func TestOverflowUniformLookup(t *testing.T) {
 	t.Run("lookup key with probe limit exceeded; should fail", func(t *testing.T) {
 		const slotsCount = 45
 		ovf := Overflow{Slots: make([]*Slot, slotsCount), Loglogn: probeLimit}
-
-		keys := []byte{5, 19, 33, 48}
-		hashes := make([]uint32, slotsCount)
-		for i, k := range keys {
-			hashes[i] = uint32(k * k)
-		}
+		keys := uniformProbeKeys(slotsCount, probeLimit, seed, 4)
+		require.Len(t, keys, 4) // Tune slotsCount if constantly fails
 
 		// Make items unreachable for random probing
-		for i, k := range keys {
-			var s [32]byte
-			binary.BigEndian.PutUint32(s[:], hashes[i]^seed)
-			rnd := rand.NewChaCha8(s)
-
-			idx := hashes[i] % slotsCount
-			for j := 0; j < probeLimit; j++ {
+		for _, k := range keys {
+			path := uniformProbePath(keyHash(k), slotsCount, probeLimit, seed)
+			for _, idx := range path[:len(path)-1] {
 				ovf.Slots[idx] = &Slot{} // Dummy item to keep the probes going
-				idx = uint32(rnd.Uint64() % slotsCount)
 			}
-			require.Nil(t, ovf.Slots[idx], "[%v]: %v", idx, k) // Tune slotsCount or keys count if constantly fails
+			idx := path[len(path)-1]
+			require.Nil(t, ovf.Slots[idx])
 			ovf.Slots[idx] = &Slot{
 				Key:   []byte{k},
 				Value: []byte{k},
 			}
 		}
 
-		for i, k := range keys {
-			ovf.Rnd = rand.NewChaCha8([32]byte{})
+		for _, k := range keys {
+			ovf.Rnd = newProbeRand([32]byte{})
 			ovf.Seed = seed
-			_, ok := overflowUniformLookup(&ovf, hashes[i], []byte{k}, false)
+			_, ok := overflowUniformLookup(nil, &ovf, keyHash(k), []byte{k}, false)
 			assert.False(t, ok)
 		}
 	})
 }
 
+// keyHash returns the test hash of a one-byte key.
+func keyHash(k byte) uint64 {
+	return uint64(k * k)
+}
+
+// uniformProbePath returns the slot indexes the uniform probing visits for a hash: the home slot, then the given
+// number of random probes. The probe sequence depends on the build profile, so it's computed with the active
+// newProbeRand.
+func uniformProbePath(hash uint64, slotsCount, probes int, seed uint64) []uint64 {
+	var s [32]byte
+	binary.BigEndian.PutUint64(s[:], hash^seed)
+	rnd := newProbeRand(s)
+	path := []uint64{hash % uint64(slotsCount)}
+	for i := 0; i < probes; i++ {
+		path = append(path, rnd.Uint64()%uint64(slotsCount))
+	}
+	return path
+}
+
+// uniformProbeKeys returns up to n one-byte keys whose probe paths do not cross their own final slots or the final
+// slots of each other, so every key can be placed at the end of its path.
+func uniformProbeKeys(slotsCount, probes int, seed uint64, n int) []byte {
+	var keys []byte
+	var paths [][]uint64
+	for k := 1; k < 256 && len(keys) < n; k++ {
+		path := uniformProbePath(keyHash(byte(k)), slotsCount, probes, seed)
+		if slices.Contains(path[:len(path)-1], path[len(path)-1]) || slices.ContainsFunc(paths, func(p []uint64) bool {
+			return slices.Contains(path, p[len(p)-1]) || slices.Contains(p, path[len(path)-1])
+		}) {
+			continue
+		}
+		keys = append(keys, byte(k))
+		paths = append(paths, path)
+	}
+	return keys
+}
+
 func TestBankInsert(t *testing.T) {
 	// 8 banks with the following bucket counts of 4 slots
 	const (
@@ -342,20 +381,20 @@ func TestBankInsert(t *testing.T) {
 		keys := []byte{4, 19, 33, 47}
 		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
 		t.Logf("keys: %#v", keys)
-		var hashes []uint32
+		var hashes []uint64
 		for _, k := range keys {
-			hashes = append(hashes, uint32(k*k))
+			hashes = append(hashes, uint64(k*k))
 		}
 
 		for i, k := range keys {
 			assert.True(
-				t, bankInsert(banks[0], hashes[i], []byte{k}, []byte{k}, bucketSize),
+				t, bankInsert(nil, banks[0], hashes[i], []byte{k}, []byte{k}, bucketSize),
 				"[%v]: %v", i, hashes[i],
 			)
 		}
 
 		for i, k := range keys {
-			slot, ok := bankLookup(banks[0], hashes[i], []byte{k}, bucketSize)
+			slot, ok := bankLookup(nil, banks[0], hashes[i], []byte{k}, bucketSize)
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -372,12 +411,12 @@ func TestBankInsert(t *testing.T) {
 
 		// Fully fill with dummy items only the buckets where the keys are going to be placed on insertion
 		keys := []byte{4, 19, 33, 47}
-		var hashes []uint32
+		var hashes []uint64
 		for _, k := range keys {
-			hsh := uint32(k * k)
+			hsh := uint64(k * k)
 			hashes = append(hashes, hsh)
 			for bank, count := range bucketCounts {
-				bucket := int(hsh % uint32(count))
+				bucket := int(hsh % uint64(count))
 				for j := bucket * bucketSize; j < bucket*bucketSize+bucketSize; j++ {
 					banks[bank].Data[j] = &Slot{}
 				}
@@ -385,7 +424,7 @@ func TestBankInsert(t *testing.T) {
 		}
 
 		for i, k := range keys {
-			assert.False(t, bankInsert(banks[0], hashes[i], []byte{k}, []byte{k}, bucketSize))
+			assert.False(t, bankInsert(nil, banks[0], hashes[i], []byte{k}, []byte{k}, bucketSize))
 		}
 	})
 }
@@ -405,16 +444,16 @@ func TestBankLookup(t *testing.T) {
 			b = banks[i]
 		}
 
-		// Put items to each bank to slot 0 of every bucket it should be placed
+		// Put items to each bank to the first probed slot of every bucket it should be placed
 		keys := []byte{3, 37, 110}
-		var hashes []uint32
+		var hashes []uint64
 		for _, k := range keys {
-			hsh := uint32(k)
+			hsh := uint64(k)
 			hashes = append(hashes, hsh)
 			for bank, size := range bucketCounts {
-				bucket := int(hsh % uint32(size))
-				require.Nil(t, banks[bank].Data[bucket*bucketSize], "[%v]: %v", bank, k) // Tune bucketsCounts or keys if constantly fails
-				banks[bank].Data[bucket*bucketSize] = &Slot{
+				idx := int(hsh%uint64(size))*bucketSize + int(hsh%bucketSize)
+				require.Nil(t, banks[bank].Data[idx], "[%v]: %v", bank, k) // Tune bucketsCounts or keys if constantly fails
+				banks[bank].Data[idx] = &Slot{
 					Key:   []byte{k},
 					Value: []byte{k + byte(bank)}, // The result should come from the first bank, so value should be k
 				}
@@ -422,7 +461,7 @@ func TestBankLookup(t *testing.T) {
 		}
 
 		for i, k := range keys {
-			slot, ok := bankLookup(banks[0], hashes[i], []byte{k}, bucketSize)
+			slot, ok := bankLookup(nil, banks[0], hashes[i], []byte{k}, bucketSize)
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -437,25 +476,25 @@ func TestBankLookup(t *testing.T) {
 			b = banks[i]
 		}
 
-		// Put items to the last bank to slot 0 of bucket it should be placed
+		// Put items to the last bank to the first probed slot of bucket it should be placed
 		keys := []byte{3, 37, 110}
-		var hashes []uint32
+		var hashes []uint64
 		bank := len(bucketCounts) - 1
 		for _, k := range keys {
-			hsh := uint32(k)
+			hsh := uint64(k)
 			hashes = append(hashes, hsh)
-			bucket := int(hsh % uint32(bucketCounts[bank]))
-			require.Nil(t, banks[bank].Data[bucket*bucketSize], "[%v]: %v", bank, k) // Tune bucketsCounts or keys if constantly fails
-			banks[bank].Data[bucket*bucketSize] = &Slot{
+			idx := int(hsh%uint64(bucketCounts[bank]))*bucketSize + int(hsh%bucketSize)
+			require.Nil(t, banks[bank].Data[idx], "[%v]: %v", bank, k) // Tune bucketsCounts or keys if constantly fails
+			banks[bank].Data[idx] = &Slot{
 				Key:   []byte{k},
 				Value: []byte{k},
 			}
-			bank0Bucket := int(hsh % uint32(bucketCounts[0]))
-			banks[0].Data[bank0Bucket*bucketSize] = &Slot{} // Dummy item in bank 0 to make sure the lookup does not stop there
+			bank0Idx := int(hsh%uint64(bucketCounts[0]))*bucketSize + int(hsh%bucketSize)
+			banks[0].Data[bank0Idx] = &Slot{} // Dummy item in bank 0 to make sure the lookup does not stop there
 		}
 
 		for i, k := range keys {
-			slot, ok := bankLookup(banks[0], hashes[i], []byte{k}, bucketSize)
+			slot, ok := bankLookup(nil, banks[0], hashes[i], []byte{k}, bucketSize)
 			assert.True(t, ok)
 			assert.Equal(t, []byte{k}, slot.Key)
 			assert.Equal(t, []byte{k}, slot.Value)
@@ -472,12 +511,12 @@ func TestBankLookup(t *testing.T) {
 
 		// Put items to each bank to slot 0 of buckets it should not be placed
 		keys := []byte{3, 37, 110}
-		var hashes []uint32
+		var hashes []uint64
 		for _, k := range keys {
-			hsh := uint32(k)
+			hsh := uint64(k)
 			hashes = append(hashes, hsh)
 			for bank, size := range bucketCounts {
-				bucket := int(hsh%uint32(size)) + 1
+				bucket := int(hsh%uint64(size)) + 1
 				if bucket > size-1 {
 					bucket = 0
 				}
@@ -490,7 +529,7 @@ func TestBankLookup(t *testing.T) {
 		}
 
 		for i, k := range keys {
-			_, ok := bankLookup(banks[0], hashes[i], []byte{k}, bucketSize)
+			_, ok := bankLookup(nil, banks[0], hashes[i], []byte{k}, bucketSize)
 			assert.False(t, ok)
 		}
 	})
@@ -505,15 +544,24 @@ func TestBankLookup(t *testing.T) {
 
 		// Put items to each bank to slot 0 of buckets it should not be placed
 		keys := []byte{4, 19, 33, 47}
-		var hashes []uint32
+		var hashes []uint64
 		for _, k := range keys {
-			hsh := uint32(k * k)
+			hsh := uint64(k * k)
 			hashes = append(hashes, hsh)
 		}
 
 		for i, k := range keys {
-			_, ok := bankLookup(banks[0], hashes[i], []byte{k}, bucketSize)
+			_, ok := bankLookup(nil, banks[0], hashes[i], []byte{k}, bucketSize)
 			assert.False(t, ok)
 		}
 	})
+	t.Run("lookup in banks not allocated yet; should fail", func(t *testing.T) {
+		var b *Bank
+		for i := len(bucketCounts) - 1; i >= 0; i-- {
+			b = &Bank{Size: bucketCounts[i] * bucketSize, Next: b}
+		}
+
+		_, ok := bankLookup(nil, b, 37, []byte{37}, bucketSize)
+		assert.False(t, ok)
+	})
 }
@@ -0,0 +1,48 @@
+package funnel
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the table entries, e.g. to copy them into another table. The soft-deleted entries are
+// skipped. If Spill has the All method of the same signature, the spilled entries follow the table ones.
+//
+// The order is unspecified. The table must not be modified during iteration.
+func (t *HashTable) All() iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		for slot := range t.slots() {
+			if !yield(t.exportKey(slot.Key), slot.Value) {
+				return
+			}
+		}
+		if s, ok := t.Spill.(interface{ All() iter.Seq2[[]byte, any] }); ok {
+			for k, v := range s.All() {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// slots returns an iterator over the slots of visible entries in the table.
+func (t *HashTable) slots() iter.Seq[*Slot] {
+	return func(yield func(*Slot) bool) {
+		visit := func(slots []*Slot) bool {
+			for _, s := range slots {
+				if !vacant(s, t.Epoch) && !s.Deleted && !yield(s) {
+					return false
+				}
+			}
+			return true
+		}
+		for b := t.Banks; b != nil; b = b.Next {
+			if !visit(b.Data) {
+				return
+			}
+		}
+		if visit(t.Overflow1.Slots) {
+			visit(t.Overflow2.Slots)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package funnel
+
+import (
+	"encoding"
+	"github.com/bdragon300/elastic-funnel-hash/internal/keys"
+)
+
+// KeyAppender is implemented by structured keys that can append their binary form to a buffer. InsertK and GetK
+// prefer it to MarshalBinary, since it lets lookups reuse the table scratch buffer instead of allocating.
+type KeyAppender = keys.Appender
+
+// KeyByter is implemented by structured keys that already keep their binary form.
+type KeyByter = keys.Byter
+
+// InsertK is like TryInsert, but accepts a structured key. The key is encoded with AppendKey or KeyBytes if it
+// implements KeyAppender or KeyByter, or with MarshalBinary otherwise.
+func (t *HashTable) InsertK(key encoding.BinaryMarshaler, value any) error {
+	b, err := keys.Bytes(key)
+	if err != nil {
+		return err
+	}
+	return t.TryInsert(b, value)
+}
+
+// GetK is like TryGet, but accepts a structured key, see InsertK.
+func (t *HashTable) GetK(key encoding.BinaryMarshaler) (any, bool, error) {
+	if k, ok := key.(KeyAppender); ok {
+		t.scratch = k.AppendKey(t.scratch[:0])
+		return t.TryGet(t.scratch)
+	}
+	b, err := keys.Bytes(key)
+	if err != nil {
+		return nil, false, err
+	}
+	return t.TryGet(b)
+}
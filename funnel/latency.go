@@ -0,0 +1,37 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/latency"
+)
+
+// Latency is a metric of the sampled probing times of a layer, see HashTable.LatencySample.
+type Latency = latency.Latency
+
+// HitRatio returns the fraction of lookups that found the key, or 0 if there were no lookups.
+func (t *HashTable) HitRatio() float64 {
+	if t.Hits+t.Misses == 0 {
+		return 0
+	}
+	return float64(t.Hits) / float64(t.Hits+t.Misses)
+}
+
+// countLookup counts a lookup result in Hits or Misses.
+func (t *HashTable) countLookup(ok bool) {
+	if ok {
+		t.Hits++
+	} else {
+		t.Misses++
+	}
+}
+
+// sampleLatency returns LayerLatency if an operation should measure the layer probing times, or nil otherwise.
+func (t *HashTable) sampleLatency() *[layersCount]Latency {
+	if t.LatencySample <= 0 {
+		return nil
+	}
+	t.sampled++
+	if t.sampled%t.LatencySample != 0 {
+		return nil
+	}
+	return &t.LayerLatency
+}
@@ -0,0 +1,18 @@
+package funnel
+
+// SpillRate returns the fraction of inserts that did not fit into the main banks and were placed into the overflow
+// layers (not into the Spill table). A growing spill rate is the leading indicator that Delta is too small for the workload.
+func (t *HashTable) SpillRate() float64 {
+	if t.TotalInserts == 0 {
+		return 0
+	}
+	return float64(t.Spills) / float64(t.TotalInserts)
+}
+
+// countInsert counts a successful insert into the layer.
+func (t *HashTable) countInsert(layer Layer) {
+	t.TotalInserts++
+	if layer != LayerBanks {
+		t.Spills++
+	}
+}
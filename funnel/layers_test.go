@@ -0,0 +1,56 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLayerInserts(t *testing.T) {
+	assertLayers := func(t *testing.T, table *HashTable) {
+		var banks int
+		for b := table.Banks; b != nil; b = b.Next {
+			banks += occupied(b.Data, table.Epoch)
+		}
+		assert.Equal(t, [layersCount]int{
+			banks,
+			occupied(table.Overflow1.Slots, table.Epoch),
+			occupied(table.Overflow2.Slots, table.Epoch),
+		}, table.LayerInserts)
+	}
+
+	t.Run("fill table; should count entries in every layer and spills", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+
+		assertLayers(t, table)
+		assert.Equal(t, n, table.TotalInserts)
+		assert.Equal(t, table.LayerInserts[LayerOverflow1]+table.LayerInserts[LayerOverflow2], table.Spills)
+		assert.Positive(t, table.SpillRate())
+	})
+
+	t.Run("purge and clear; should update layer counters and keep totals", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		n, _ := fillTable(t, table)
+		for i := 0; i < n; i += 3 {
+			table.SoftDelete([]byte(fmt.Sprint(i)))
+		}
+
+		table.Purge()
+		assertLayers(t, table)
+
+		table.Clear()
+		assertLayers(t, table)
+		assert.Equal(t, n, table.TotalInserts)
+	})
+
+	t.Run("lightly loaded table; should not spill", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		for i := 0; i < 10; i++ {
+			table.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		assert.Zero(t, table.SpillRate())
+		assert.Equal(t, 10, table.LayerInserts[LayerBanks])
+	})
+}
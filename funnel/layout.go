@@ -0,0 +1,370 @@
+package funnel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+// Layout is the table geometry: banks and overflow layers sizes. Plan calculates it from a config, then it may be
+// inspected or adjusted before allocating the table with Build.
+type Layout struct {
+	Capacity   int     // Total slots, the table is full when it has this number of entries
+	BucketSize int     // Banks bucket size
+	Banks      []int   // Bank sizes, every one is a multiple of BucketSize
+	Overflow1  int     // Overflow1 slots
+	Overflow2  int     // Overflow2 slots, a multiple of Overflow2BucketSize. Zero disables Overflow2
+	Delta      float64 // Fraction of free slots the layout is planned for, informational
+	// BucketRounding is the slots added to the banks (negative if removed) by Config.PowerOfTwoBuckets. Informational,
+	// reported by Plan only
+	BucketRounding int
+	// Overflow1Probes is the slots probed in Overflow1 by an operation, ⌊log2(log2(Capacity))⌋ if zero
+	Overflow1Probes int
+	Overflow1Policy Overflow1Policy // What Overflow1 does if Overflow2 is disabled
+	FingerprintBits int             // Overflow2 fingerprint width: 8, 16 or 32, see Config.FingerprintBits. 8 if zero
+
+	Hasher   func(b []byte) uint64 // Key hash function, SeededHasher with HashSeed if nil
+	HashSeed uint64                // Seed of the default hasher, random if zero
+	Seed     uint64                // Seed of overflow probe sequences, time-based if zero
+	// Rand creates the generator of Overflow1 probe sequences, the default one if nil. Not reported by
+	// HashTable.Layout
+	Rand     func() ProbeSource
+	CopyKeys bool // Copy the keys on insert, see HashTable.CopyKeys
+}
+
+// Overflow2BucketSize returns the Overflow2 bucket size, it depends on Capacity.
+func (l Layout) Overflow2BucketSize() int {
+	return overflow2BucketSize(l.Capacity)
+}
+
+// Slots returns the total number of slots in all layers.
+func (l Layout) Slots() int {
+	n := l.Overflow1 + l.Overflow2
+	for _, size := range l.Banks {
+		n += size
+	}
+	return n
+}
+
+// slotsFit reports whether the total number of slots fits maxSlots. Unlike Slots, it does not overflow. The sizes
+// must not be negative.
+func (l Layout) slotsFit() bool {
+	n := 0
+	for _, size := range append([]int{l.Overflow1, l.Overflow2}, l.Banks...) {
+		if size > maxSlots-n {
+			return false
+		}
+		n += size
+	}
+	return true
+}
+
+// Bytes returns the expected memory allocated by Build for slots, control bytes, key lengths and occupancy bitmaps.
+// The stored entries are not included.
+func (l Layout) Bytes() int {
+	var ctrl int
+	if l.Overflow2 > 0 {
+		buckets := l.Overflow2 / l.Overflow2BucketSize()
+		ctrl = buckets*ctrlStride(l.Overflow2BucketSize()) + buckets*int(unsafe.Sizeof(uint32(0))) +
+			l.Overflow2*(tagSize(l.FingerprintBits)+1) // Tags and key lengths
+	}
+	if l.BucketSize > 0 && l.BucketSize <= maxOccupancyBucket {
+		for _, size := range l.Banks {
+			ctrl += size / l.BucketSize * int(unsafe.Sizeof(uint64(0)))
+		}
+	}
+	return l.Slots()*int(unsafe.Sizeof((*Slot)(nil))) + ctrl +
+		len(l.Banks)*int(unsafe.Sizeof(Bank{})) + 2*int(unsafe.Sizeof(Overflow{}))
+}
+
+// Plan calculates the table layout for the given config without allocating the table. See also Config.Validate.
+func Plan(c Config) (Layout, error) {
+	if c.Capacity <= 0 {
+		return Layout{}, errors.New("capacity must be positive")
+	}
+	if c.Delta <= 0 || c.Delta >= 1 {
+		return Layout{}, errors.New("delta must be in range (0, 1)")
+	}
+	if c.BankShrink < minBankShrink || c.BankShrink >= 1 {
+		return Layout{}, fmt.Errorf("bankShrink must be in range [%v, 1)", minBankShrink)
+	}
+	if float64(c.Capacity)*(1+c.Delta) > float64(maxSlots) {
+		return Layout{}, fmt.Errorf("capacity %d is too large for this platform, %d slots at most", c.Capacity, maxSlots)
+	}
+	if c.BucketSize < 0 || c.Banks < 0 {
+		return Layout{}, errors.New("bucket size and banks count must not be negative")
+	}
+	if c.Overflow1Probes < 0 {
+		return Layout{}, errors.New("overflow1 probes must not be negative")
+	}
+	if c.Overflow1Policy < Overflow1FullScan || c.Overflow1Policy > Overflow1WithOverflow2 {
+		return Layout{}, fmt.Errorf("unknown overflow1 policy %d", c.Overflow1Policy)
+	}
+	if err := checkFingerprintBits(c.FingerprintBits); err != nil {
+		return Layout{}, err
+	}
+	if c.MinBankBuckets < 0 {
+		return Layout{}, errors.New("min bank buckets must not be negative")
+	}
+	if c.MinBanks < NoMinBanks {
+		return Layout{}, errors.New("min banks must not be negative, except NoMinBanks")
+	}
+	if c.Overflow1Frac < 0 || c.Overflow2Frac < 0 || c.Overflow1Frac+c.Overflow2Frac >= 1 {
+		return Layout{}, errors.New("overflow fractions must be non-negative and less than 1 in sum")
+	}
+
+	minBanks := float64(banksMinCount)
+	switch {
+	case c.MinBanks == NoMinBanks:
+		minBanks = 0
+	case c.MinBanks > 0:
+		minBanks = float64(c.MinBanks)
+	}
+	alpha := math.Ceil(4*math.Log2(1/c.Delta)) + minBanks // Banks count
+	if c.Banks > 0 {
+		alpha = float64(c.Banks)
+	}
+	beta := math.Ceil(2 * math.Log2(1/c.Delta)) // Bucket size
+	if c.BucketSize > 0 {
+		beta = float64(c.BucketSize)
+	}
+	capacity := c.Capacity + int(float64(c.Capacity)*c.Delta)
+	ovf2BucketSize := overflow2BucketSize(capacity) // Overflow banks have their own bucket size
+	explicitOverflow := c.Overflow1Frac > 0 || c.Overflow2Frac > 0
+
+	var overflowSlots, ovf2Slots int
+	if explicitOverflow {
+		ovf2Slots = int(c.Overflow2Frac * float64(capacity))
+		overflowSlots = int(c.Overflow1Frac*float64(capacity)) + ovf2Slots
+	} else {
+		// Average on range: ⌊δn*bankShrink⌋ ≥ |Aα+1| ≥ ⌈δn*minShrinkRatio⌉
+		overflowSlots = int(math.Floor(c.Delta*float64(c.Capacity)*c.BankShrink)+math.Ceil(c.Delta*float64(c.Capacity)*minBankShrink)) / 2
+	}
+	slots := capacity - overflowSlots
+
+	// Create the banks with non-zero size, their count could be less than α
+	var banks []int
+	var rounding int
+	for i := 0; i < int(alpha) && slots > int(beta); i++ {
+		size := float64(slots) * (1 - c.BankShrink)
+		size = beta * math.Ceil(size/beta) // Round up to the nearest multiple of β
+		slots -= int(size)
+		if c.PowerOfTwoBuckets {
+			// The next banks are derived from the unrounded sizes, so the rounding does not accumulate
+			rounded := int(beta) * nearestPowerOf2(int(size/beta))
+			rounding += rounded - int(size)
+			size = float64(rounded)
+		}
+		banks = append(banks, int(size))
+	}
+	if c.Banks > 0 && len(banks) < c.Banks {
+		return Layout{}, fmt.Errorf("only %d of %d banks fit the capacity", len(banks), c.Banks)
+	}
+	// The bank sizes do not increase, so the banks of too few buckets are at the tail. Merge them into the last
+	// bank before them
+	if tail := slices.IndexFunc(banks, func(size int) bool { return size < c.MinBankBuckets*int(beta) }); tail >= 0 {
+		tail = max(tail, 1)
+		for _, size := range banks[tail:] {
+			banks[tail-1] += size
+		}
+		banks = banks[:tail]
+	}
+	if slots < int(beta) {
+		overflowSlots += slots // Give the remaining slots (if any) to the overflow bank
+	}
+	if !explicitOverflow {
+		ovf2Slots = overflowSlots / 2
+	}
+
+	// Disable overflow2 if it uses too few buckets, and yield the remaining space to overflow1.
+	if ovf2BucketSize == 0 || ovf2Slots/ovf2BucketSize < minOverflow2Buckets {
+		if explicitOverflow && ovf2Slots > 0 {
+			return Layout{}, fmt.Errorf(
+				"overflow2 gets %d slots, which is less than %d buckets of %d slots", ovf2Slots, minOverflow2Buckets, ovf2BucketSize,
+			)
+		}
+		ovf2Slots = 0
+		if c.Overflow1Policy == Overflow1WithOverflow2 {
+			ovf2Slots = minOverflow2Buckets * ovf2BucketSize
+			if ovf2BucketSize == 0 || ovf2Slots > overflowSlots {
+				return Layout{}, fmt.Errorf("overflow gets %d slots, which is not enough for the minimal overflow2", overflowSlots)
+			}
+		}
+	} else if explicitOverflow {
+		ovf2Slots = ovf2Slots / ovf2BucketSize * ovf2BucketSize // Round down, to not take the overflow1 slots
+	} else {
+		ovf2Slots = int(float64(ovf2BucketSize) * math.Ceil(float64(ovf2Slots)/float64(ovf2BucketSize))) // Round up to the nearest bucket size
+	}
+
+	return Layout{
+		Capacity:        capacity,
+		BucketSize:      int(beta),
+		Banks:           banks,
+		Overflow1:       overflowSlots - ovf2Slots,
+		Overflow2:       ovf2Slots,
+		Delta:           c.Delta,
+		BucketRounding:  rounding,
+		Overflow1Probes: c.Overflow1Probes,
+		Overflow1Policy: c.Overflow1Policy,
+		FingerprintBits: max(c.FingerprintBits, fingerprint8),
+		Hasher:          c.Hasher,
+		HashSeed:        c.HashSeed,
+		Seed:            c.Seed,
+		Rand:            c.Rand,
+		CopyKeys:        c.CopyKeys,
+	}, nil
+}
+
+// Build allocates a hash table with the given layout.
+func Build(l Layout) (*HashTable, error) {
+	if l.Capacity <= 0 || l.BucketSize <= 0 {
+		return nil, errors.New("capacity and bucket size must be positive")
+	}
+	for i, size := range l.Banks {
+		if size <= 0 || size%l.BucketSize != 0 {
+			return nil, fmt.Errorf("bank %d size %d is not a positive multiple of bucket size %d", i, size, l.BucketSize)
+		}
+	}
+	if l.Overflow1 < 0 || l.Overflow2 < 0 || l.Overflow1Probes < 0 {
+		return nil, errors.New("overflow sizes and probes must not be negative")
+	}
+	if ovf2BucketSize := l.Overflow2BucketSize(); l.Overflow2 > 0 &&
+		(l.Overflow2%ovf2BucketSize != 0 || l.Overflow2/ovf2BucketSize < minOverflow2Buckets) {
+		return nil, fmt.Errorf(
+			"overflow2 size %d must be a multiple of %d and have at least %d buckets", l.Overflow2, ovf2BucketSize, minOverflow2Buckets,
+		)
+	}
+	if err := checkFingerprintBits(l.FingerprintBits); err != nil {
+		return nil, err
+	}
+	if l.Capacity > maxSlots || !l.slotsFit() {
+		return nil, fmt.Errorf("layout is too large for this platform, %d slots at most", maxSlots)
+	}
+	if len(l.Banks) == 0 && l.Overflow1 == 0 && l.Overflow2 == 0 {
+		return nil, errors.New("layout has no slots")
+	}
+
+	var bb, bb2 *Bank
+	for _, size := range l.Banks {
+		b := &Bank{Size: size}
+		if buckets := size / l.BucketSize; uint64(buckets) < 1<<32 && bits.OnesCount(uint(buckets)) == 1 {
+			b.BucketMask = uint64(buckets - 1)
+		}
+		if bb2 != nil {
+			bb2.Next = b
+		} else {
+			bb = b
+		}
+		bb2 = b
+	}
+
+	hasher, hashSeed := l.Hasher, uint64(0)
+	if hasher == nil {
+		hashSeed = l.HashSeed
+		if hashSeed == 0 {
+			hashSeed = rand.Uint64()
+		}
+		hasher = SeededHasher(hashSeed)
+	}
+	seed := l.Seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	var rnd ProbeSource = newProbeRand([32]byte{})
+	if l.Rand != nil {
+		rnd = l.Rand()
+	}
+	logLogn := loglogn(l.Capacity)
+
+	return &HashTable{
+		Hasher:     hasher,
+		HashSeed:   hashSeed,
+		BucketSize: l.BucketSize,
+		Capacity:   l.Capacity,
+		Delta:      l.Delta,
+		CopyKeys:   l.CopyKeys,
+		Banks:      bb,
+		Overflow1: &Overflow{
+			Slots:     make([]*Slot, l.Overflow1),
+			Rnd:       rnd,
+			Seed:      seed,
+			Loglogn:   logLogn,
+			Probes:    l.Overflow1Probes,
+			FullProbe: l.Overflow2 == 0 && l.Overflow1Policy == Overflow1FullScan,
+		},
+		Overflow2: &Overflow{
+			Slots:   make([]*Slot, l.Overflow2),
+			Ctrl:    newCtrl(l.Overflow2, overflow2BucketSize(l.Capacity)),
+			Epochs:  make([]uint32, l.Overflow2/max(overflow2BucketSize(l.Capacity), 1)),
+			Tags:    make([]byte, l.Overflow2*tagSize(l.FingerprintBits)),
+			TagSize: tagSize(l.FingerprintBits),
+			KeyLens: make([]byte, l.Overflow2),
+			Loglogn: logLogn,
+		},
+	}, nil
+}
+
+// Layout returns the actual table layout, e.g. the derived α (the banks count), β (the bucket size) and
+// the overflow layers sizes.
+func (t *HashTable) Layout() Layout {
+	var banks []int
+	for b := t.Banks; b != nil; b = b.Next {
+		banks = append(banks, b.Size)
+	}
+	return Layout{
+		Capacity:        t.Capacity,
+		BucketSize:      t.BucketSize,
+		Banks:           banks,
+		Overflow1:       len(t.Overflow1.Slots),
+		Overflow2:       len(t.Overflow2.Slots),
+		Delta:           t.Delta,
+		Overflow1Probes: t.Overflow1.Probes,
+		Overflow1Policy: overflow1Policy(t),
+		FingerprintBits: max(8*t.Overflow2.TagSize, fingerprint8),
+		Hasher:          t.Hasher,
+		HashSeed:        t.HashSeed,
+		Seed:            t.Overflow1.Seed,
+		CopyKeys:        t.CopyKeys,
+	}
+}
+
+// overflow1Policy returns the policy the table was built with. The policies are indistinguishable if Overflow2 is
+// enabled, then it returns the default one.
+func overflow1Policy(t *HashTable) Overflow1Policy {
+	if len(t.Overflow2.Slots) == 0 && !t.Overflow1.FullProbe {
+		return Overflow1Bounded
+	}
+	return Overflow1FullScan
+}
+
+// checkFingerprintBits returns an error if the fingerprint width is not supported. Zero means the default one.
+func checkFingerprintBits(bits int) error {
+	switch bits {
+	case 0, fingerprint8, fingerprint16, fingerprint32:
+		return nil
+	}
+	return fmt.Errorf("fingerprint bits must be %d, %d or %d, got %d", fingerprint8, fingerprint16, fingerprint32, bits)
+}
+
+// nearestPowerOf2 returns the power of 2 nearest to n > 0, the greater one on a tie.
+func nearestPowerOf2(n int) int {
+	lo := 1 << (bits.Len(uint(n)) - 1)
+	if n-lo < 2*lo-n {
+		return lo
+	}
+	return 2 * lo
+}
+
+// loglogn returns log2(log2(capacity)) used by the overflow layers.
+func loglogn(capacity int) float64 {
+	return math.Log2(math.Log2(float64(max(capacity, 2))))
+}
+
+func overflow2BucketSize(capacity int) int {
+	return int(2 * loglogn(capacity))
+}
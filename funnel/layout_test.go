@@ -0,0 +1,126 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"unsafe"
+)
+
+func TestPlan(t *testing.T) {
+	t.Run("plan and build; should allocate the planned layout", func(t *testing.T) {
+		l, err := Plan(Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75})
+		require.NoError(t, err)
+		require.NotEmpty(t, l.Banks)
+		assert.Equal(t, 1100, l.Capacity)
+		assert.Equal(t, 6, l.Overflow2BucketSize())
+		assert.Greater(t, l.Bytes(), l.Slots()*int(unsafe.Sizeof((*Slot)(nil))))
+
+		table, err := Build(l)
+		require.NoError(t, err)
+		assert.Equal(t, l.Capacity, table.Capacity)
+		assert.Equal(t, l.BucketSize, table.BucketSize)
+		assert.Equal(t, l.Banks, bankSizes(table))
+		assert.Equal(t, l.Overflow1, len(table.Overflow1.Slots))
+		assert.Equal(t, l.Overflow2, len(table.Overflow2.Slots))
+	})
+
+	t.Run("adjusted layout; should build it", func(t *testing.T) {
+		l, err := Plan(Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75})
+		require.NoError(t, err)
+		l.Banks = append(l.Banks[:2], l.BucketSize*10)
+		l.Overflow2 = 0
+
+		table, err := Build(l)
+		require.NoError(t, err)
+		assert.Equal(t, l.Banks, bankSizes(table))
+		assert.Empty(t, table.Overflow2.Slots)
+		table.Insert([]byte("key"), 1)
+		v, ok := table.Get([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("invalid layout; should return error", func(t *testing.T) {
+		tests := map[string]Layout{
+			"zero capacity":            {BucketSize: 2, Banks: []int{4}},
+			"bank size not multiple":   {Capacity: 10, BucketSize: 2, Banks: []int{5}},
+			"negative overflow":        {Capacity: 10, BucketSize: 2, Banks: []int{4}, Overflow1: -1},
+			"overflow2 not multiple":   {Capacity: 1000, BucketSize: 2, Banks: []int{4}, Overflow2: 13},
+			"overflow2 too few bucket": {Capacity: 1000, BucketSize: 2, Banks: []int{4}, Overflow2: 6},
+			"no slots":                 {Capacity: 10, BucketSize: 2},
+			"slots overflow int":       {Capacity: 10, BucketSize: 2, Banks: []int{math.MaxInt - 1, 4}},
+		}
+		for name, l := range tests {
+			_, err := Build(l)
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestPowerOfTwoBuckets(t *testing.T) {
+	t.Run("power of two buckets; should round the bucket counts and report the rounding", func(t *testing.T) {
+		cfg := Config{Capacity: 10000, Delta: 0.1, BankShrink: 0.75}
+		derived, err := Plan(cfg)
+		require.NoError(t, err)
+		cfg.PowerOfTwoBuckets = true
+
+		l, err := Plan(cfg)
+
+		require.NoError(t, err)
+		require.Len(t, l.Banks, len(derived.Banks))
+		var rounding int
+		for i, size := range l.Banks {
+			buckets := size / l.BucketSize
+			assert.Zero(t, buckets&(buckets-1), "bank %d has %d buckets", i, buckets)
+			rounding += size - derived.Banks[i]
+		}
+		assert.NotZero(t, l.BucketRounding)
+		assert.Equal(t, rounding, l.BucketRounding)
+		assert.Equal(t, derived.Capacity, l.Capacity)
+		assert.Zero(t, derived.BucketRounding)
+	})
+
+	t.Run("table with power of two buckets; should mask the hash", func(t *testing.T) {
+		table, err := New(Config{Capacity: 10000, Delta: 0.1, BankShrink: 0.75, PowerOfTwoBuckets: true})
+		require.NoError(t, err)
+		for b := table.Banks; b != nil; b = b.Next {
+			if buckets := b.Size / table.BucketSize; buckets > 1 {
+				assert.Equal(t, uint64(buckets-1), b.BucketMask)
+			}
+		}
+
+		n, _ := fillTable(t, table)
+
+		for i := 0; i < n; i++ {
+			v, ok := table.Get([]byte(fmt.Sprint(i)))
+			require.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("nearest power of 2; should round to the closer one", func(t *testing.T) {
+		for n, expect := range map[int]int{1: 1, 2: 2, 3: 4, 5: 4, 6: 8, 7: 8, 12: 16, 11: 8, 100: 128, 96: 128, 95: 64} {
+			assert.Equal(t, expect, nearestPowerOf2(n), n)
+		}
+	})
+}
+
+func TestHashTableLayout(t *testing.T) {
+	t.Run("table created from config; should return the planned layout", func(t *testing.T) {
+		cfg := Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75, HashSeed: 1, Seed: 2}
+		planned, err := Plan(cfg)
+		require.NoError(t, err)
+		table, err := New(cfg)
+		require.NoError(t, err)
+
+		l := table.Layout()
+
+		assert.NotNil(t, l.Hasher)
+		l.Hasher = nil
+		assert.Equal(t, planned, l)
+		assert.Equal(t, 0.1, l.Delta)
+	})
+}
@@ -0,0 +1,64 @@
+package funnel
+
+import (
+	"unsafe"
+)
+
+// Memory is the memory held by the table in bytes, see HashTable.Memory.
+type Memory struct {
+	Keys   int // Keys of the entries, see KeyBytes
+	Values int // Values of the entries, only []byte and string values are measured, see ValueBytes
+	Slots  int // Slot structures of the entries
+	Arrays int // Allocated arrays of slot pointers and control bytes, and the layer structures
+}
+
+// Total returns the sum of all parts.
+func (m Memory) Total() int {
+	return m.Keys + m.Values + m.Slots + m.Arrays
+}
+
+// Memory returns the memory held by the table, e.g. to enforce a memory budget. It's calculated from the metrics
+// and the allocated arrays, so it's cheap to call. The Spill table is not counted.
+//
+// The keys are shared with the caller unless CopyKeys is set, and the removed entries stay reachable until their
+// slots are reused, see Clear. So the actual memory may differ.
+func (t *HashTable) Memory() Memory {
+	ptrSize := int(unsafe.Sizeof((*Slot)(nil)))
+	arrays := 2 * int(unsafe.Sizeof(Overflow{}))
+	for b := t.Banks; b != nil; b = b.Next {
+		arrays += int(unsafe.Sizeof(Bank{})) + cap(b.Data)*ptrSize + cap(b.Occupied)*int(unsafe.Sizeof(uint64(0)))
+	}
+	arrays += (cap(t.Overflow1.Slots)+cap(t.Overflow2.Slots))*ptrSize + cap(t.Overflow2.Ctrl) + cap(t.Overflow2.Tags) +
+		cap(t.Overflow2.KeyLens) + cap(t.Overflow2.Epochs)*int(unsafe.Sizeof(uint32(0)))
+	return Memory{
+		Keys:   t.KeyBytes,
+		Values: t.ValueBytes,
+		Slots:  t.Inserts * int(unsafe.Sizeof(Slot{})),
+		Arrays: arrays,
+	}
+}
+
+// account adds the sizes of an entry to KeyBytes and ValueBytes metrics if sign is 1, or subtracts them if sign is -1.
+func (t *HashTable) account(key []byte, value any, sign int) {
+	t.KeyBytes += sign * len(key)
+	t.ValueBytes += sign * valueBytes(value)
+}
+
+// setValue updates the value of an entry.
+func (t *HashTable) setValue(slot *Slot, value any) {
+	t.ValueBytes += valueBytes(value) - valueBytes(slot.Value)
+	slot.Value = value
+	slot.Version++
+	t.mutate(MutationUpdate, slot.Key, value, slot.Version)
+}
+
+// valueBytes returns the bytes held in a value, or 0 if it's not measurable.
+func valueBytes(value any) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"unsafe"
+)
+
+func TestMemory(t *testing.T) {
+	t.Run("insert, update and delete entries; should track keys and values bytes", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		var keyBytes, valueBytes, n int
+		for i := 0; i < 500; i++ {
+			key := []byte(fmt.Sprint("key", i))
+			if table.TryInsert(key, "value") == nil {
+				keyBytes += len(key)
+				valueBytes += len("value")
+				n++
+			}
+		}
+		require.Positive(t, n)
+
+		m := table.Memory()
+		assert.Equal(t, keyBytes, m.Keys)
+		assert.Equal(t, valueBytes, m.Values)
+		assert.Equal(t, n*int(unsafe.Sizeof(Slot{})), m.Slots)
+		assert.Positive(t, m.Arrays)
+		assert.Equal(t, m.Keys+m.Values+m.Slots+m.Arrays, m.Total())
+
+		var key []byte
+		for k := range table.All() {
+			key = k
+			break
+		}
+		table.Set(key, []byte("longer value"))
+		assert.Equal(t, valueBytes-len("value")+len("longer value"), table.ValueBytes)
+		table.Set(key, 1)
+		assert.Equal(t, valueBytes-len("value"), table.ValueBytes)
+
+		table.DeleteIf(func(k []byte, _ any) bool { return string(k) == string(key) })
+		assert.Equal(t, keyBytes-len(key), table.KeyBytes)
+
+		table.Clear()
+		assert.Zero(t, table.Memory().Keys)
+		assert.Zero(t, table.Memory().Values)
+		assert.Zero(t, table.Memory().Slots)
+	})
+}
@@ -0,0 +1,65 @@
+//go:build unix && !tinygo && !efhtiny
+
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMmapAllocator(t *testing.T) {
+	t.Run("table with mmap allocator; should keep the keys in mapped memory", func(t *testing.T) {
+		alloc := &MmapAllocator{ChunkSize: 64}
+		table := NewHashTableDefault(100)
+		table.CopyKeys, table.Allocator = true, alloc
+		inserted := fillAllocator(table)
+		require.NotEmpty(t, inserted)
+		assert.Greater(t, alloc.Mapped(), 64)
+
+		for _, i := range inserted {
+			v, ok := table.Get(allocKey(i))
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+
+		// All chunks but the current one are unmapped once their keys are freed
+		assert.Equal(t, len(inserted), table.DeleteIf(func([]byte, any) bool { return true }))
+		assert.Equal(t, 64, alloc.Mapped())
+		assert.NoError(t, alloc.Close())
+		assert.Zero(t, alloc.Mapped())
+	})
+
+	t.Run("keys got from table and removed; should stay readable", func(t *testing.T) {
+		alloc := &MmapAllocator{ChunkSize: 64}
+		table := NewHashTableDefault(100)
+		table.CopyKeys, table.Allocator = true, alloc
+		var mutated [][]byte
+		table.OnMutation = func(m Mutation) { mutated = append(mutated, m.Key) }
+		table.Insert([]byte("short"), -1)
+		inserted := fillAllocator(table)
+		var keys, sampled [][]byte
+		for k := range table.All() {
+			keys = append(keys, k)
+		}
+		for k := range table.Sample(10) {
+			sampled = append(sampled, k)
+		}
+
+		for _, k := range keys {
+			require.True(t, table.Delete(k))
+		}
+		table.Insert([]byte("reused"), 0) // Takes a freed slot
+		require.NoError(t, alloc.Close())
+
+		want := [][]byte{[]byte("short")}
+		for _, i := range inserted {
+			want = append(want, allocKey(i))
+		}
+		assert.ElementsMatch(t, want, keys)
+		assert.Subset(t, want, sampled)
+		require.Len(t, mutated, 2*len(want)+1)
+		assert.ElementsMatch(t, want, mutated[:len(want)])
+		assert.ElementsMatch(t, want, mutated[len(want):2*len(want)])
+	})
+}
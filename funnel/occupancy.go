@@ -0,0 +1,55 @@
+package funnel
+
+import (
+	"math/bits"
+)
+
+// maxOccupancyBucket is the largest bucket size tracked by the occupancy bitmaps, one word per bucket.
+const maxOccupancyBucket = 64
+
+// alloc allocates the bank slots and the occupancy bitmap on the first insert.
+func (b *Bank) alloc(bucketSize int) {
+	if b.Data != nil {
+		return
+	}
+	b.Data = make([]*Slot, b.Size)
+	if bucketSize <= maxOccupancyBucket {
+		b.Occupied = make([]uint64, b.Size/bucketSize)
+	}
+}
+
+// occupancy returns the occupied slots bitmap valid in the given table epoch, or nil if the bank has none. The bitmap
+// written in another epoch is emptied first, since all its slots are free now.
+func (b *Bank) occupancy(epoch uint32) []uint64 {
+	if b.Occupied != nil && b.Epoch != epoch {
+		clear(b.Occupied)
+		b.Epoch = epoch
+	}
+	return b.Occupied
+}
+
+// occupy marks the slot at the given index in bank data as occupied.
+func (b *Bank) occupy(idx, bucketSize int) {
+	if b.Occupied != nil {
+		b.Occupied[idx/bucketSize] |= 1 << (idx % bucketSize)
+	}
+}
+
+// vacate marks the slot at the given index in bank data as free.
+func (b *Bank) vacate(idx, bucketSize int) {
+	if b.Occupied != nil {
+		b.Occupied[idx/bucketSize] &^= 1 << (idx % bucketSize)
+	}
+}
+
+// freeSlot returns the distance from start to the first free slot of the bucket in the circular probing order, or -1
+// if the bucket is full.
+func freeSlot(occupied uint64, start, bucketSize int) int {
+	free := ^occupied & (1<<bucketSize - 1)
+	if free == 0 {
+		return -1
+	}
+	// Rotate the bucket, so that the start slot is the lowest bit
+	rotated := free>>start | free<<(bucketSize-start)&(1<<bucketSize-1)
+	return bits.TrailingZeros64(rotated)
+}
@@ -0,0 +1,91 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFreeSlot(t *testing.T) {
+	tests := []struct {
+		occupied          uint64
+		start, bucketSize int
+		expect            int
+	}{
+		{0b0000, 0, 4, 0},
+		{0b0001, 0, 4, 1},
+		{0b0111, 1, 4, 2},
+		{0b1110, 2, 4, 2}, // Wraps around to slot 0
+		{0b1111, 3, 4, -1},
+		{0b0101, 3, 3, 1}, // Start is out of the occupied bits
+		{1<<64 - 1, 0, 64, -1},
+		{1<<64 - 1 - 1<<10, 63, 64, 11},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, freeSlot(tt.occupied, tt.start%tt.bucketSize, tt.bucketSize), "%+v", tt)
+	}
+}
+
+func TestOccupancy(t *testing.T) {
+	t.Run("inserts and removals churn; should keep bitmaps consistent with slots", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		for round := 0; round < 5; round++ {
+			for i := 0; i < 800; i++ {
+				table.Set([]byte(fmt.Sprint("key", round, i)), i)
+			}
+			assertOccupancy(t, table)
+			table.DeleteIf(func(key []byte, _ any) bool { return len(key)%2 == 0 })
+			assertOccupancy(t, table)
+			if round == 2 {
+				table.Clear()
+				assertOccupancy(t, table)
+			}
+		}
+	})
+
+	t.Run("freed slots; should be refilled with the same probes as the linear probing", func(t *testing.T) {
+		cfg := Config{Capacity: 1000, Delta: 0.1, BankShrink: 0.75, HashSeed: 1, Seed: 2}
+		probes := func(bitmaps bool) []int {
+			table, err := New(cfg)
+			require.NoError(t, err)
+			if !bitmaps {
+				for b := table.Banks; b != nil; b = b.Next {
+					b.Data = make([]*Slot, b.Size) // Allocated without bitmap
+				}
+			}
+			var res []int
+			table.Hooks = &Hooks{Done: func(op Op, n int, _ bool) {
+				if op == OpInsert {
+					res = append(res, n)
+				}
+			}}
+			for i := 0; i < 1000; i++ {
+				table.Insert([]byte(fmt.Sprint("key", i)), i)
+			}
+			table.DeleteIf(func(_ []byte, v any) bool { return v.(int)%3 == 0 })
+			for i := 0; i < 300; i++ {
+				table.Insert([]byte(fmt.Sprint("new", i)), i)
+			}
+			return res
+		}
+
+		assert.Equal(t, probes(false), probes(true))
+	})
+}
+
+// assertOccupancy checks that the bank bitmaps mark exactly the occupied slots.
+func assertOccupancy(t *testing.T, table *HashTable) {
+	t.Helper()
+	for b := table.Banks; b != nil; b = b.Next {
+		if b.Data == nil {
+			continue
+		}
+		require.NotNil(t, b.Occupied)
+		occupied := b.occupancy(table.Epoch)
+		for i, s := range b.Data {
+			bit := occupied[i/table.BucketSize]&(1<<(i%table.BucketSize)) != 0
+			assert.Equal(t, !vacant(s, table.Epoch), bit, "slot %d", i)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/prefix"
+)
+
+// PrefixIndex is a sorted copy of the table keys, so the keys starting with a prefix are found without scanning
+// the whole table, see HashTable.PrefixIndex.
+type PrefixIndex = prefix.Index
+
+// PrefixIndex builds the sorted index of the keys in the table (including Spill, see All). It takes a copy of every
+// key, so build it once for the occasional prefix scans rather than on every scan.
+//
+// The index does not see the keys inserted after it was built, build a new one then. The value updates and
+// removals are seen, since the values are looked up on scan.
+func (t *HashTable) PrefixIndex() *PrefixIndex {
+	return prefix.New(t.All(), t.peek)
+}
+
+// peek returns the value of a key in the table or Spill. The lookup metrics are not counted.
+func (t *HashTable) peek(key []byte) (any, bool) {
+	if slot, ok := t.slot(key); ok {
+		return slot.Value, true
+	}
+	if t.Spill != nil {
+		return t.Spill.Get(key)
+	}
+	return nil, false
+}
@@ -0,0 +1,54 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPrefixIndex(t *testing.T) {
+	t.Run("keys with prefix; should yield them in order", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		for _, k := range []string{"user:2", "order:1", "user:10", "user:1", "use", "users"} {
+			table.Insert([]byte(k), k)
+		}
+
+		var keys []string
+		for k, v := range table.PrefixIndex().PrefixScan([]byte("user:")) {
+			assert.Equal(t, string(k), v)
+			keys = append(keys, string(k))
+		}
+
+		assert.Equal(t, []string{"user:1", "user:10", "user:2"}, keys)
+	})
+
+	t.Run("table modified after build; should see updates and removals only", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key1"), 1)
+		table.Insert([]byte("key2"), 2)
+		x := table.PrefixIndex()
+		table.Set([]byte("key1"), 10)
+		table.SoftDelete([]byte("key2"))
+		table.Insert([]byte("key3"), 3)
+
+		assert.Equal(t, map[string]any{"key1": 10}, scanPrefix(x, []byte("key")))
+	})
+
+	t.Run("spilled keys; should be indexed", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		spill := NewHashTableDefault(100)
+		spill.Insert([]byte("key1"), 1)
+		table.Spill = spill
+		table.Insert([]byte("key2"), 2)
+
+		assert.Equal(t, map[string]any{"key1": 1, "key2": 2}, scanPrefix(table.PrefixIndex(), []byte("key")))
+	})
+}
+
+// scanPrefix returns the entries yielded by PrefixScan.
+func scanPrefix(x *PrefixIndex, prefix []byte) map[string]any {
+	res := make(map[string]any)
+	for k, v := range x.PrefixScan(prefix) {
+		res[string(k)] = v
+	}
+	return res
+}
@@ -0,0 +1,66 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestProbeSampler(t *testing.T) {
+	t.Run("sample every 2nd operation; should keep the latest traces", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.ProbeSampler = NewProbeSampler(2, 3)
+		for i := 0; i < 10; i++ {
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+		}
+
+		samples := table.DumpProbeSamples()
+
+		require.Len(t, samples, 3)
+		for i, s := range samples {
+			assert.Equal(t, OpInsert, s.Op)
+			assert.Equal(t, []byte(fmt.Sprint("key", 5+2*i)), s.Key)
+			assert.True(t, s.OK)
+			assert.Positive(t, s.Probes)
+		}
+	})
+
+	t.Run("sampled lookup; should record the checked slots", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		for i := 0; i < 50; i++ {
+			table.Insert([]byte(fmt.Sprint("key", i)), i)
+		}
+		table.ProbeSampler = NewProbeSampler(1, 10)
+
+		_, ok, steps := table.GetWithTrace([]byte("key42"))
+		_, _, missSteps := table.GetWithTrace([]byte("missing"))
+
+		require.True(t, ok)
+		samples := table.DumpProbeSamples()
+		require.Len(t, samples, 2)
+		assert.Equal(t, ProbeSample{Op: OpLookup, Key: []byte("key42"), Steps: steps, Probes: samples[0].Probes, OK: true}, samples[0])
+		assert.Equal(t, []byte("missing"), samples[1].Key)
+		assert.Equal(t, missSteps, samples[1].Steps)
+		assert.False(t, samples[1].OK)
+	})
+
+	t.Run("reset; should drop the traces", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.ProbeSampler = NewProbeSampler(1, 2)
+		for i := 0; i < 5; i++ {
+			table.Get([]byte("key"))
+		}
+
+		table.ProbeSampler.Reset()
+
+		assert.Empty(t, table.DumpProbeSamples())
+	})
+
+	t.Run("no sampler; should return nil", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		assert.Nil(t, table.DumpProbeSamples())
+	})
+}
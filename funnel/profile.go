@@ -0,0 +1,33 @@
+//go:build !tinygo && !efhtiny
+
+package funnel
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// ProfileHooks returns hooks that attribute the table operations in CPU profiles and execution traces.
+//
+// Probing of every layer is wrapped in runtime/trace region named “op/layer” and labeled with pprof labels
+// "efh.op", "efh.layer" and "efh.bank". Bank indexes are bucketed by powers of 2 to keep the labels cardinality low.
+//
+// ctx is the caller context: its pprof labels are added to ours and restored after every layer.
+func ProfileHooks(ctx context.Context) *Hooks {
+	return &Hooks{
+		Layer: func(op Op, layer Layer, bank int) func() {
+			labels := pprof.Labels("efh.op", string(op), "efh.layer", layer.String())
+			if layer == LayerBanks {
+				labels = pprof.Labels("efh.op", string(op), "efh.layer", layer.String(), "efh.bank", bankBucketLabel(bank))
+			}
+			lctx := pprof.WithLabels(ctx, labels)
+			pprof.SetGoroutineLabels(lctx)
+			region := trace.StartRegion(lctx, string(op)+"/"+layer.String())
+			return func() {
+				region.End()
+				pprof.SetGoroutineLabels(ctx)
+			}
+		},
+	}
+}
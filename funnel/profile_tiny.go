@@ -0,0 +1,13 @@
+//go:build tinygo || efhtiny
+
+package funnel
+
+import (
+	"context"
+)
+
+// ProfileHooks returns empty hooks in the tiny build profile, since runtime/pprof and runtime/trace are not
+// available on TinyGo.
+func ProfileHooks(ctx context.Context) *Hooks {
+	return &Hooks{}
+}
@@ -0,0 +1,41 @@
+package funnel
+
+import (
+	"time"
+)
+
+// Progress is the state of a long-running operation, e.g. Unbounded.Rebuild, reported to its progress callback.
+type Progress struct {
+	Done int // Entries processed so far
+	// Total is the entries to process. It's an estimate, e.g. the soft-deleted entries are counted, but skipped
+	Total   int
+	Elapsed time.Duration // Time since the operation start
+}
+
+// ETA returns the estimated time left, extrapolated from the processing rate so far. Returns 0 if nothing was
+// processed yet, or the operation is done.
+func (p Progress) ETA() time.Duration {
+	if p.Done == 0 || p.Done >= p.Total {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * float64(p.Total-p.Done) / float64(p.Done))
+}
+
+// progressReporter reports the progress of an operation to a callback, if it's set.
+type progressReporter struct {
+	fn    func(p Progress)
+	total int
+	start time.Time
+}
+
+// newProgressReporter starts reporting the progress of an operation processing total entries.
+func newProgressReporter(fn func(p Progress), total int) progressReporter {
+	return progressReporter{fn: fn, total: total, start: time.Now()}
+}
+
+// report reports the entries processed so far.
+func (r progressReporter) report(done int) {
+	if r.fn != nil {
+		r.fn(Progress{Done: done, Total: max(r.total, done), Elapsed: time.Since(r.start)})
+	}
+}
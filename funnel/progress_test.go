@@ -0,0 +1,20 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestProgressETA(t *testing.T) {
+	t.Run("part done; should extrapolate the rate", func(t *testing.T) {
+		p := Progress{Done: 250, Total: 1000, Elapsed: time.Second}
+
+		assert.Equal(t, 3*time.Second, p.ETA())
+	})
+
+	t.Run("nothing or all done; should return zero", func(t *testing.T) {
+		assert.Zero(t, Progress{Total: 1000, Elapsed: time.Second}.ETA())
+		assert.Zero(t, Progress{Done: 1000, Total: 1000, Elapsed: time.Second}.ETA())
+	})
+}
@@ -0,0 +1,13 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/prand"
+)
+
+// probeRand generates the random probe sequences, see prand.Rand.
+type probeRand = prand.Rand
+
+// newProbeRand returns a probe sequences generator with the given seed.
+func newProbeRand(seed [32]byte) *probeRand {
+	return prand.New(seed)
+}
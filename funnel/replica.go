@@ -0,0 +1,24 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/replica"
+)
+
+// ErrMutationGap is returned by Replica.Apply if a mutation does not follow the last applied one.
+var ErrMutationGap = replica.ErrGap
+
+// Replica is a read-only copy of a table, fed with the mutations of the primary table, e.g. to scale the reads of
+// a mutable table. It's safe for concurrent use, so the mutations may be applied from another goroutine:
+//
+//	replica := NewReplica(NewHashTableDefault(capacity))
+//	primary.OnMutation = func(m Mutation) { replica.Apply(m) }
+//
+// If the feed may break, e.g. when it's sent over the network, record the mutations in a MutationLog too, and resume
+// with CatchUp.
+type Replica = replica.Replica[*HashTable]
+
+// NewReplica creates a new replica keeping the entries in the given empty table. The replica must get the mutations
+// from the first one, since the primary table was created.
+func NewReplica(table *HashTable) *Replica {
+	return replica.New(table)
+}
@@ -0,0 +1,120 @@
+package funnel
+
+import (
+	"iter"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+)
+
+// Sample returns an iterator over n entries picked uniformly at random without replacement, e.g. for the cache
+// warmers, the estimators or finding out the key skew. If the table has fewer entries, all of them are returned.
+// The soft-deleted entries and the Spill entries are not sampled.
+//
+// The entries are counted and picked by the occupancy bitmaps of the banks, so the free slots are not checked.
+// The table must not be modified during iteration.
+func (t *HashTable) Sample(n int) iter.Seq2[[]byte, any] {
+	slots := t.sampleSlots(n)
+	return func(yield func([]byte, any) bool) {
+		for _, s := range slots {
+			if !yield(t.exportKey(s.Key), s.Value) {
+				return
+			}
+		}
+	}
+}
+
+// sampleSlots picks n random visible slots in random order.
+func (t *HashTable) sampleSlots(n int) []*Slot {
+	// The candidates are the occupied bits of the bank bitmaps, then the visible slots of the areas without bitmap.
+	// The bits of the soft-deleted entries are still set, such candidates are dropped and more are picked instead
+	var banks []*Bank
+	var rest []*Slot
+	var total int
+	visible := func(slots []*Slot) {
+		for _, s := range slots {
+			if !vacant(s, t.Epoch) && !s.Deleted {
+				rest = append(rest, s)
+			}
+		}
+	}
+	for b := t.Banks; b != nil; b = b.Next {
+		if occupied := b.occupancy(t.Epoch); occupied != nil {
+			banks = append(banks, b)
+			for _, w := range occupied {
+				total += bits.OnesCount64(w)
+			}
+		} else {
+			visible(b.Data)
+		}
+	}
+	visible(t.Overflow1.Slots)
+	visible(t.Overflow2.Slots)
+	total += len(rest)
+
+	var res []*Slot
+	picked := make(map[int]bool)
+	for len(res) < n && len(picked) < total {
+		for _, s := range t.rankSlots(banks, rest, pickRanks(n-len(res), total, picked)) {
+			if !vacant(s, t.Epoch) && !s.Deleted {
+				res = append(res, s)
+			}
+		}
+	}
+	rand.Shuffle(len(res), func(i, j int) { res[i], res[j] = res[j], res[i] })
+	return res
+}
+
+// rankSlots returns the slots of the sorted candidate ranks, see sampleSlots.
+func (t *HashTable) rankSlots(banks []*Bank, rest []*Slot, ranks []int) []*Slot {
+	res := make([]*Slot, 0, len(ranks))
+	var base int // Rank of the first candidate in the current word
+	for _, b := range banks {
+		for i, w := range b.Occupied {
+			cnt := bits.OnesCount64(w)
+			for len(ranks) > 0 && ranks[0] < base+cnt {
+				bit := w
+				for k := ranks[0] - base; k > 0; k-- {
+					bit &= bit - 1 // Drop the lowest set bit
+				}
+				res = append(res, b.Data[i*t.BucketSize+bits.TrailingZeros64(bit)])
+				ranks = ranks[1:]
+			}
+			base += cnt
+		}
+	}
+	for _, r := range ranks {
+		res = append(res, rest[r-base])
+	}
+	return res
+}
+
+// pickRanks picks up to n distinct ranks in range [0,total) uniformly at random, skipping and marking the picked ones.
+// Returns the ranks in ascending order.
+func pickRanks(n, total int, picked map[int]bool) []int {
+	left := total - len(picked)
+	n = min(n, left)
+	var ranks []int
+	if n > left/2 {
+		// Most of the ranks are needed, so pick them from the not yet picked ones instead of retrying
+		for r := 0; r < total; r++ {
+			if !picked[r] {
+				ranks = append(ranks, r)
+			}
+		}
+		rand.Shuffle(len(ranks), func(i, j int) { ranks[i], ranks[j] = ranks[j], ranks[i] })
+		ranks = ranks[:n]
+	} else {
+		for len(ranks) < n {
+			if r := rand.IntN(total); !picked[r] {
+				picked[r] = true
+				ranks = append(ranks, r)
+			}
+		}
+	}
+	for _, r := range ranks {
+		picked[r] = true
+	}
+	slices.Sort(ranks)
+	return ranks
+}
@@ -0,0 +1,26 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/sketch"
+)
+
+// Sketch is a count-min sketch of the key frequencies, that keeps the approximately hottest keys, see
+// HashTable.HotKeys. The counts are overestimated by at most 2N/width with probability 1-(1/2)^depth, where N is the
+// number of added keys.
+type Sketch = sketch.Sketch
+
+// HotKey is a key with its estimated access count.
+type HotKey = sketch.HotKey
+
+// NewSketch creates a new sketch of depth rows of width counters, that keeps k hottest keys. Panics if any argument
+// is not positive.
+func NewSketch(width, depth, k int) *Sketch {
+	return sketch.New(width, depth, k)
+}
+
+// hit counts a key access in HotKeys, if it's set.
+func (t *HashTable) hit(key []byte) {
+	if t.HotKeys != nil {
+		t.HotKeys.Add(key)
+	}
+}
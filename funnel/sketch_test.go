@@ -0,0 +1,29 @@
+package funnel
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	t.Run("table hot keys; should count the accesses and be in stats", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.HotKeys = NewSketch(64, 2, 2)
+		table.Insert([]byte("a"), 1)
+		table.Set([]byte("a"), 2)
+		table.Get([]byte("a"))
+		table.Get([]byte("b"))
+
+		assert.Equal(t, uint32(3), table.HotKeys.Estimate([]byte("a")))
+		var buf bytes.Buffer
+		require.NoError(t, table.StatsJSON(&buf))
+		var stats struct {
+			HotKeys []HotKey `json:"hot_keys"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &stats))
+		assert.Equal(t, []HotKey{{Key: "a", Count: 3}, {Key: "b", Count: 1}}, stats.HotKeys)
+	})
+}
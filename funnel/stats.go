@@ -0,0 +1,109 @@
+package funnel
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"slices"
+)
+
+const statsTopProbes = 10 // Number of the longest probe sequences in stats
+
+type tableStats struct {
+	Capacity   int          `json:"capacity"`
+	Inserts    int          `json:"inserts"`
+	BucketSize int          `json:"bucket_size"`
+	SpillRate  float64      `json:"spill_rate"` // Fraction of inserts placed into the overflow layers
+	Banks      []layerStats `json:"banks"`
+	Overflow1  layerStats   `json:"overflow1"`
+	Overflow2  layerStats   `json:"overflow2"`
+	TopProbes  []probeStats `json:"top_probes"`         // Keys with the longest lookup probe sequences, longest first
+	HotKeys    []HotKey     `json:"hot_keys,omitempty"` // See HashTable.HotKeys
+	Hits       int          `json:"hits"`
+	Misses     int          `json:"misses"`
+	// LayerLatency is the sampled probing time by layer, if HashTable.LatencySample is set
+	LayerLatency map[string]latencyStats `json:"layer_latency,omitempty"`
+}
+
+type layerStats struct {
+	Size    int   `json:"size"`
+	Used    int   `json:"used"`
+	Buckets []int `json:"buckets,omitempty"` // Used slots in every bucket
+}
+
+type latencyStats struct {
+	Count  int   `json:"count"`
+	MeanNs int64 `json:"mean_ns"`
+	MaxNs  int64 `json:"max_ns"`
+}
+
+type probeStats struct {
+	Key    []byte `json:"key"`
+	Probes int    `json:"probes"`
+}
+
+// StatsJSON writes the table occupancy per bank and per bucket, the keys with the longest lookup probe sequences, and
+// the hottest keys if HotKeys is set to w in JSON.
+//
+// Every key in the table is looked up to find the probe lengths, so it's slow on large tables.
+func (t *HashTable) StatsJSON(w io.Writer) error {
+	stats := tableStats{
+		Capacity:   t.Capacity,
+		Inserts:    t.Inserts,
+		BucketSize: t.BucketSize,
+		SpillRate:  t.SpillRate(),
+		Overflow1:  layerStats{Size: len(t.Overflow1.Slots), Used: occupied(t.Overflow1.Slots, t.Epoch)},
+		Overflow2:  layerStats{Size: len(t.Overflow2.Slots), Buckets: bucketsUsed(t.Overflow2.Slots, int(2*t.Overflow2.Loglogn), t.Epoch)},
+	}
+	stats.Overflow2.Used = occupied(t.Overflow2.Slots, t.Epoch)
+
+	var probes []probeStats
+	addProbes := func(slots []*Slot) {
+		for _, s := range slots {
+			if !vacant(s, t.Epoch) {
+				pr := probe{op: OpLookup, equal: t.KeyEqual, epoch: t.Epoch}
+				lookup(t, &pr, s.Key)
+				probes = append(probes, probeStats{Key: s.Key, Probes: pr.probes})
+			}
+		}
+	}
+	for b := t.Banks; b != nil; b = b.Next {
+		stats.Banks = append(stats.Banks, layerStats{
+			Size:    b.Size,
+			Used:    occupied(b.Data, t.Epoch),
+			Buckets: bucketsUsed(b.Data, t.BucketSize, t.Epoch),
+		})
+		addProbes(b.Data)
+	}
+	addProbes(t.Overflow1.Slots)
+	addProbes(t.Overflow2.Slots)
+
+	slices.SortStableFunc(probes, func(a, b probeStats) int { return cmp.Compare(b.Probes, a.Probes) })
+	stats.TopProbes = probes[:min(len(probes), statsTopProbes)]
+	if t.HotKeys != nil {
+		stats.HotKeys = t.HotKeys.TopK()
+	}
+	stats.Hits, stats.Misses = t.Hits, t.Misses
+	if t.LatencySample > 0 {
+		stats.LayerLatency = make(map[string]latencyStats, layersCount)
+		for l, lat := range t.LayerLatency {
+			stats.LayerLatency[Layer(l).String()] = latencyStats{
+				Count: lat.Count, MeanNs: lat.Mean().Nanoseconds(), MaxNs: lat.Max.Nanoseconds(),
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(stats)
+}
+
+// bucketsUsed returns the number of non-empty slots in every bucket.
+func bucketsUsed(slots []*Slot, bucketSize int, epoch uint32) []int {
+	if bucketSize == 0 {
+		return nil
+	}
+	res := make([]int, len(slots)/bucketSize)
+	for i := range res {
+		res[i] = occupied(slots[i*bucketSize:i*bucketSize+bucketSize], epoch)
+	}
+	return res
+}
@@ -0,0 +1,49 @@
+package funnel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStatsJSON(t *testing.T) {
+	t.Run("table with entries; should count them and sort the probe lengths", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		var n int
+		for i := 0; i < 500; i++ {
+			if table.TryInsert([]byte(fmt.Sprint(i)), i) == nil {
+				n++
+			}
+		}
+		var buf bytes.Buffer
+
+		require.NoError(t, table.StatsJSON(&buf))
+
+		var stats tableStats
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &stats))
+		assert.Equal(t, n, stats.Inserts)
+		var used int
+		for _, b := range stats.Banks {
+			used += b.Used
+		}
+		used += stats.Overflow1.Used + stats.Overflow2.Used
+		for _, b := range stats.Banks {
+			var bucketsUsed int
+			for _, u := range b.Buckets {
+				bucketsUsed += u
+			}
+			assert.Equal(t, b.Used, bucketsUsed)
+		}
+		assert.Equal(t, n, used)
+		require.Len(t, stats.TopProbes, statsTopProbes)
+		for i := 1; i < len(stats.TopProbes); i++ {
+			assert.GreaterOrEqual(t, stats.TopProbes[i-1].Probes, stats.TopProbes[i].Probes)
+		}
+		pr := probe{op: OpLookup}
+		lookup(table, &pr, stats.TopProbes[0].Key)
+		assert.Equal(t, pr.probes, stats.TopProbes[0].Probes)
+	})
+}
@@ -0,0 +1,32 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTypedMaps(t *testing.T) {
+	t.Run("string map; should store the values in the table", func(t *testing.T) {
+		m := NewStringMap[int](100)
+
+		require.False(t, m.Set("key", 1))
+
+		v, ok := m.Get("key")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.Equal(t, 1, m.Table().Len())
+	})
+
+	t.Run("uint64 map; should store the values in the table", func(t *testing.T) {
+		m := NewUint64Map[string](100)
+
+		require.False(t, m.Set(42, "v"))
+
+		v, ok := m.Get(42)
+		assert.True(t, ok)
+		assert.Equal(t, "v", v)
+		assert.Equal(t, 1, m.Table().Len())
+		assert.LessOrEqual(t, testing.AllocsPerRun(100, func() { m.Get(42) }), 1.0) // The key escapes to the Hasher
+	})
+}
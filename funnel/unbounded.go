@@ -0,0 +1,246 @@
+package funnel
+
+import (
+	"context"
+	"github.com/bdragon300/elastic-funnel-hash/internal/ctxcheck"
+	"math"
+	"sync"
+)
+
+// Unbounded is a map that never runs out of space. It keeps a chain of tables: once the newest table is full,
+// the next one of twice the capacity is allocated for the new keys. Lookups check the tables from the oldest to
+// the newest, so the dense primary table keeps serving most of the keys.
+//
+// A long chain makes the misses slower, call Rebuild or StartRebuild to migrate all entries into a single
+// right-sized table. Unlike the tables, Unbounded is safe for concurrent use, so it serves the operations while
+// the entries are migrated in the background.
+type Unbounded struct {
+	Delta      float64 // Delta of the tables, see NewHashTable
+	BankShrink float64 // Bank shrink ratio of the tables, see NewHashTable
+	// OnProgress is called during Rebuild every 1024 migrated entries and once it's done, e.g. to log the progress
+	// of rebuilding a large table. Optional
+	OnProgress func(p Progress)
+
+	mu        sync.Mutex
+	rebuildMu sync.Mutex // Serializes the rebuilds
+	tables    []*HashTable
+	dirty     map[string]struct{} // Keys written during a rebuild, to apply to the rebuilt tables on handoff
+}
+
+// NewUnbounded creates a new unbounded map with the primary table of the given parameters, see NewHashTable.
+func NewUnbounded(capacity int, delta, bankShrink float64) *Unbounded {
+	return &Unbounded{
+		Delta:      delta,
+		BankShrink: bankShrink,
+		tables:     []*HashTable{NewHashTable(capacity, delta, bankShrink)},
+	}
+}
+
+// Insert inserts a new key-value pair into the newest table, allocating the next one if it's full. The next table
+// inherits the settings of the primary one, see inherit. It does not deduplicate keys, see HashTable.Insert.
+func (u *Unbounded) Insert(key []byte, value any) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.touch(key)
+	u.insert(key, value, nil)
+}
+
+// insert is Insert of an entry moved from a slot of the chain tables, if from is not nil. The tables share Hasher,
+// so the cached key hash of the entry is reused.
+func (u *Unbounded) insert(key []byte, value any, from *Slot) {
+	last := u.tables[len(u.tables)-1]
+	if err := last.tryInsert(insertProbe(last, from), key, value); err == nil {
+		return
+	}
+	next := NewHashTable(2*last.Cap(), u.Delta, u.BankShrink)
+	u.inherit(next)
+	u.tables = append(u.tables, next)
+	if err := next.tryInsert(insertProbe(next, from), key, value); err != nil {
+		panic(err)
+	}
+}
+
+// inherit copies the settings of the primary table to a table of the chain. The key hashing, comparison and storage
+// settings must be the same in the whole chain, and the instrumentation makes the chain look like a single table.
+// OnFull and Spill are not copied, since the chain grows instead, nor are Watermarks, OnWatermark, Loader and Writer,
+// since they concern the primary table.
+func (u *Unbounded) inherit(t *HashTable) {
+	p := u.tables[0]
+	t.Hasher, t.HashSeed, t.CacheHashes = p.Hasher, p.HashSeed, p.CacheHashes
+	t.KeyEqual, t.KeyCanon, t.CopyKeys, t.Allocator = p.KeyEqual, p.KeyCanon, p.CopyKeys, p.Allocator
+	t.MaxProbes, t.LatencySample = p.MaxProbes, p.LatencySample
+	t.Hooks, t.HotKeys, t.ProbeSampler, t.UniqueKeys = p.Hooks, p.HotKeys, p.ProbeSampler, p.UniqueKeys
+	t.OnMutation = p.OnMutation
+}
+
+// insertProbe returns the probe of an insert into a table, with the cached key hash of the entry moved from a slot,
+// if any.
+func insertProbe(t *HashTable, from *Slot) *probe {
+	pr := newProbe(t, OpInsert)
+	if from != nil && (t.CacheHashes || from.ownHash) {
+		pr.hash, pr.hashed, pr.given = from.hash, true, from.ownHash
+	}
+	return pr
+}
+
+// Set sets a value for a key. If the key already exists in any table, it updates the value there. Otherwise, it
+// inserts a new key-value pair.
+func (u *Unbounded) Set(key []byte, value any) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.touch(key)
+	return u.set(key, value)
+}
+
+// set is Set without locking.
+func (u *Unbounded) set(key []byte, value any) bool {
+	if t, slot, ok := u.slot(key); ok {
+		t.setValue(slot, value)
+		return true
+	}
+	u.insert(key, value, nil)
+	return false
+}
+
+// slot returns the slot of a key and its table, looking up the tables in order.
+func (u *Unbounded) slot(key []byte) (*HashTable, *Slot, bool) {
+	for _, t := range u.tables {
+		if slot, ok := t.slot(key); ok {
+			return t, slot, true
+		}
+	}
+	return nil, nil, false
+}
+
+// touch records a key written during a rebuild, see RebuildContext.
+func (u *Unbounded) touch(key []byte) {
+	if u.dirty != nil {
+		u.dirty[string(key)] = struct{}{}
+	}
+}
+
+// UpdateInPlace calls fn with the value of an existing key in any table, see HashTable.UpdateInPlace. The value
+// pointer is also invalidated by Rebuild, since it moves the entries to new slots.
+func (u *Unbounded) UpdateInPlace(key []byte, fn func(value *any)) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.touch(key)
+	for _, t := range u.tables {
+		if t.UpdateInPlace(key, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a value for a key. If the key does not exist, it returns nil and false.
+func (u *Unbounded) Get(key []byte) (any, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, t := range u.tables {
+		if v, ok := t.Get(key); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of elements in all tables.
+func (u *Unbounded) Len() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.len()
+}
+
+// len is Len without locking.
+func (u *Unbounded) len() int {
+	var n int
+	for _, t := range u.tables {
+		n += t.Len()
+	}
+	return n
+}
+
+// Tables returns the chain of tables, the primary one first. The tables are not safe for concurrent use, so they must
+// not be used along with the map operations or a rebuild.
+func (u *Unbounded) Tables() []*HashTable {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.tables
+}
+
+// Rebuild migrates all entries into a new primary table sized to keep Delta slots free, and drops the old tables.
+// The soft-deleted entries are dropped, the entries flags and versions are reset. The entries of the old tables are
+// not released to the Allocator. The keys are not rehashed if CacheHashes is set.
+//
+// Rebuild touches every entry, so call it when the chain has grown, e.g. when Tables returns more than two tables.
+// The map serves other operations during a rebuild: the entries are migrated in batches, and the operations go to
+// the old tables in between. The keys written meanwhile are copied once more when the rebuilt tables are handed off.
+// The migration does not report to OnMutation, Hooks and other instrumentation of the tables.
+func (u *Unbounded) Rebuild() {
+	_, _ = u.RebuildContext(context.Background())
+}
+
+// RebuildContext is like Rebuild, but stops once ctx is done. Then the old tables are kept, and it returns
+// the number of entries migrated so far along with the context error. Otherwise, it returns the number of
+// migrated entries.
+func (u *Unbounded) RebuildContext(ctx context.Context) (int, error) {
+	u.rebuildMu.Lock()
+	defer u.rebuildMu.Unlock()
+	u.mu.Lock()
+	capacity := int(math.Ceil(float64(u.len()) / (1 - u.Delta)))
+	rebuilt := NewUnbounded(max(capacity, u.tables[0].Cap()), u.Delta, u.BankShrink)
+	u.inherit(rebuilt.tables[0])
+	quiet(rebuilt.tables[0])
+	u.dirty = make(map[string]struct{})
+	var n int
+	progress := newProgressReporter(u.OnProgress, u.len())
+	// The chain may grow in between the batches, so the tables are indexed anew
+	for i := 0; i < len(u.tables); i++ {
+		for slot := range u.tables[i].slots() {
+			if n%ctxcheck.Interval == 0 {
+				if ctx.Err() != nil {
+					u.dirty = nil
+					u.mu.Unlock()
+					return n, ctx.Err()
+				}
+				if n > 0 {
+					u.mu.Unlock() // Let the operations in
+					progress.report(n)
+					u.mu.Lock()
+				}
+			}
+			rebuilt.insert(slot.Key, slot.Value, slot)
+			n++
+		}
+	}
+	for key := range u.dirty {
+		if _, slot, ok := u.slot([]byte(key)); ok {
+			rebuilt.set(slot.Key, slot.Value)
+		}
+	}
+	for _, t := range rebuilt.tables {
+		u.inherit(t)
+	}
+	u.tables, u.dirty = rebuilt.tables, nil
+	u.mu.Unlock()
+	progress.report(n)
+	return n, nil
+}
+
+// StartRebuild runs RebuildContext in a new goroutine and returns at once. The returned channel receives its error
+// once the rebuilt tables are handed off or ctx is done.
+func (u *Unbounded) StartRebuild(ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := u.RebuildContext(ctx)
+		done <- err
+	}()
+	return done
+}
+
+// quiet turns off the instrumentation of a table, so that the migrated entries are not reported.
+func quiet(t *HashTable) {
+	t.Hooks, t.HotKeys, t.ProbeSampler, t.UniqueKeys, t.OnMutation = nil, nil, nil, nil, nil
+	t.LatencySample = 0
+}
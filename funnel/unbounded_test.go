@@ -0,0 +1,218 @@
+package funnel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/internal/ctxcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUnbounded(t *testing.T) {
+	t.Run("insert more keys than capacity; should chain tables and find all keys", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		assert.Equal(t, 1000, u.Len())
+		assert.Greater(t, len(u.Tables()), 1)
+		for i := 0; i < 1000; i++ {
+			v, ok := u.Get([]byte(fmt.Sprint(i)))
+			require.True(t, ok, "key: %v", i)
+			assert.Equal(t, i, v)
+		}
+		_, ok := u.Get([]byte("missing"))
+		assert.False(t, ok)
+	})
+
+	t.Run("set key in primary table after chaining; should update it in place", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		assert.True(t, u.Set([]byte("0"), -1))
+		assert.False(t, u.Set([]byte("new"), 1))
+
+		assert.Equal(t, 1001, u.Len())
+		v, _ := u.Get([]byte("0"))
+		assert.Equal(t, -1, v)
+		v, _ = u.Get([]byte("new"))
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("rebuild chained tables; should keep all keys in fewer tables", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		u.Tables()[0].SoftDelete([]byte("0"))
+		tables := len(u.Tables())
+
+		u.Rebuild()
+
+		assert.Equal(t, 999, u.Len())
+		assert.Less(t, len(u.Tables()), tables)
+		for i := 1; i < 1000; i++ {
+			v, ok := u.Get([]byte(fmt.Sprint(i)))
+			require.True(t, ok, "key: %v", i)
+			assert.Equal(t, i, v)
+		}
+		_, ok := u.Get([]byte("0"))
+		assert.False(t, ok)
+	})
+	t.Run("rebuild canceled; should keep the chained tables", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		tables := u.Tables()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		n, err := u.RebuildContext(ctx)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, n)
+		assert.Equal(t, tables, u.Tables())
+		assert.Equal(t, 1000, u.Len())
+	})
+
+	t.Run("rebuild with context; should return the migrated count", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		n, err := u.RebuildContext(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1000, n)
+		assert.Len(t, u.Tables(), 1)
+	})
+
+	t.Run("rebuild with progress callback; should report the migrated entries", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 3000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		var reports []Progress
+		u.OnProgress = func(p Progress) { reports = append(reports, p) }
+
+		u.Rebuild()
+
+		require.Len(t, reports, 3)
+		for i, p := range reports[:2] {
+			assert.Equal(t, (i+1)*ctxcheck.Interval, p.Done)
+			assert.Equal(t, 3000, p.Total)
+			assert.LessOrEqual(t, reports[0].Elapsed, p.Elapsed)
+		}
+		assert.Equal(t, Progress{Done: 3000, Total: 3000, Elapsed: reports[2].Elapsed}, reports[2])
+		assert.Zero(t, reports[2].ETA())
+	})
+
+	t.Run("rebuild with cached hashes; should not rehash the keys", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		var hashed int
+		hasher := u.Tables()[0].Hasher
+		u.Tables()[0].Hasher = func(b []byte) uint64 {
+			hashed++
+			return hasher(b)
+		}
+		u.Tables()[0].CacheHashes = true
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+		hashed = 0
+
+		u.Rebuild()
+
+		assert.Zero(t, hashed)
+		assert.Equal(t, 1000, u.Len())
+		for i := 0; i < 1000; i++ {
+			v, ok := u.Get([]byte(fmt.Sprint(i)))
+			require.True(t, ok, "key: %v", i)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("chained tables; should inherit the primary table settings", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		var mutations int
+		primary := u.Tables()[0]
+		primary.KeyCanon = bytes.ToLower
+		primary.MaxProbes = 1000
+		primary.OnMutation = func(Mutation) { mutations++ }
+
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint("Key", i)), i)
+		}
+
+		require.Greater(t, len(u.Tables()), 1)
+		for _, table := range u.Tables()[1:] {
+			assert.Equal(t, 1000, table.MaxProbes)
+		}
+		assert.Equal(t, 1000, mutations)
+		v, ok := u.Get([]byte("KEY999"))
+		assert.True(t, ok)
+		assert.Equal(t, 999, v)
+	})
+
+	t.Run("rebuild; should not report the migrated entries", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		var mutations int
+		u.Tables()[0].OnMutation = func(Mutation) { mutations++ }
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		u.Rebuild()
+		u.Insert([]byte("new"), 1)
+
+		assert.Equal(t, 1001, mutations)
+	})
+
+	t.Run("rebuild in background along with writes; should keep the written values", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 5000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		done := u.StartRebuild(context.Background())
+		for i := 0; i < 5000; i++ {
+			u.Set([]byte(fmt.Sprint(i)), -i)
+			u.Insert([]byte(fmt.Sprint("new", i)), i)
+		}
+		require.NoError(t, <-done)
+
+		assert.Equal(t, 10000, u.Len())
+		for i := 0; i < 5000; i++ {
+			v, ok := u.Get([]byte(fmt.Sprint(i)))
+			require.True(t, ok, "key: %v", i)
+			assert.Equal(t, -i, v)
+			v, ok = u.Get([]byte(fmt.Sprint("new", i)))
+			require.True(t, ok, "key: new%v", i)
+			assert.Equal(t, i, v)
+		}
+	})
+}
+
+func TestUnboundedUpdateInPlace(t *testing.T) {
+	t.Run("key in chained table; should update it", func(t *testing.T) {
+		u := NewUnbounded(100, 0.1, 0.75)
+		for i := 0; i < 1000; i++ {
+			u.Insert([]byte(fmt.Sprint(i)), i)
+		}
+
+		ok := u.UpdateInPlace([]byte("999"), func(value *any) { *value = -1 })
+
+		assert.True(t, ok)
+		v, _ := u.Get([]byte("999"))
+		assert.Equal(t, -1, v)
+		assert.False(t, u.UpdateInPlace([]byte("missing"), func(*any) {}))
+	})
+}
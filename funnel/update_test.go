@@ -0,0 +1,42 @@
+package funnel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUpdateInPlace(t *testing.T) {
+	t.Run("pointer value; should be kept across updates of other entries", func(t *testing.T) {
+		table := NewHashTableDefault(1000)
+		counter := new(int)
+		table.Insert([]byte("counter"), counter)
+		for i := 0; i < 50; i++ {
+			table.Set([]byte(fmt.Sprint(i)), i)
+			table.SoftDelete([]byte(fmt.Sprint(i - 1)))
+		}
+		table.Purge()
+
+		for i := 0; i < 10; i++ {
+			require.True(t, table.UpdateInPlace([]byte("counter"), func(value *any) {
+				*(*value).(*int)++
+			}))
+		}
+
+		v, _ := table.Get([]byte("counter"))
+		assert.Same(t, counter, v)
+		assert.Equal(t, 10, *counter)
+	})
+
+	t.Run("value size changed; should account value bytes", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), "abc")
+
+		table.UpdateInPlace([]byte("key"), func(value *any) {
+			*value = (*value).(string) + "de"
+		})
+
+		assert.Equal(t, 5, table.ValueBytes)
+	})
+}
@@ -0,0 +1,20 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/entry"
+)
+
+// GetVersioned is like Get, but also returns the entry version. The version is 0 for a new entry and is incremented
+// on every value update, so it may be used as an optimistic concurrency token, see SetIfVersion.
+//
+// Entries in the Spill table are not versioned and are not returned.
+func (t *HashTable) GetVersioned(key []byte) (any, uint64, bool) {
+	return entry.GetVersioned(entries{t}, key)
+}
+
+// SetIfVersion updates the value of an existing key only if its version is equal to the given one, i.e. the entry
+// was not updated since it was got by GetVersioned. Returns the new version and true on success, or the current
+// version and false otherwise. If the key does not exist, it returns 0 and false.
+func (t *HashTable) SetIfVersion(key []byte, value any, version uint64) (uint64, bool) {
+	return entry.SetIfVersion(entries{t}, key, value, version)
+}
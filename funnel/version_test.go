@@ -0,0 +1,37 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	t.Run("update with current version; should succeed and bump version", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+
+		v, version, ok := table.GetVersioned([]byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.Zero(t, version)
+
+		version, ok = table.SetIfVersion([]byte("key"), 2, version)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1), version)
+		v, _ = table.Get([]byte("key"))
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("update with stale version; should fail and keep value", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		_, stale, _ := table.GetVersioned([]byte("key"))
+		table.Set([]byte("key"), 2)
+
+		version, ok := table.SetIfVersion([]byte("key"), 3, stale)
+		assert.False(t, ok)
+		assert.Equal(t, uint64(1), version)
+		v, _ := table.Get([]byte("key"))
+		assert.Equal(t, 2, v)
+	})
+}
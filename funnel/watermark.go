@@ -0,0 +1,14 @@
+package funnel
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/watermark"
+)
+
+// crossWatermarks calls OnWatermark for every level in Watermarks crossed by the load factor since the table had
+// the given number of entries.
+func (t *HashTable) crossWatermarks(before int) {
+	if before != t.Inserts {
+		prev, cur := float64(before)/float64(t.Capacity), float64(t.Inserts)/float64(t.Capacity)
+		watermark.Cross(t.Watermarks, prev, cur, t.OnWatermark)
+	}
+}
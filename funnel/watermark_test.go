@@ -0,0 +1,38 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWatermarks(t *testing.T) {
+	t.Run("table filling; should report every level once", func(t *testing.T) {
+		table := NewHashTableDefault(100)
+		table.Watermarks = []float64{0.01, 0.02, 0.5}
+		type crossing struct {
+			level  float64
+			rising bool
+			len    int
+		}
+		var crossings []crossing
+		table.OnWatermark = func(level float64, rising bool) {
+			crossings = append(crossings, crossing{level, rising, table.Len()})
+		}
+
+		for i := 0; table.Len() < 3; i++ {
+			table.TryInsert([]byte{byte(i)}, i)
+		}
+
+		capacity := float64(table.Cap())
+		var expect []crossing
+		for _, level := range table.Watermarks[:2] {
+			n := int(level * capacity)
+			if float64(n) < level*capacity {
+				n++
+			}
+			expect = append(expect, crossing{level, true, n})
+		}
+		assert.Equal(t, expect, crossings)
+	})
+
+}
@@ -0,0 +1,36 @@
+// Package alloc provides the allocators of the slot records and the key copies, shared by the table implementations.
+// The tables alias them with their slot types, e.g. funnel.Allocator.
+package alloc
+
+// Allocator allocates the slot records of type S and the key copies of a table. Embedders with custom memory
+// management implement it to control the placement. The table calls it from its operations only, so it needs no
+// locking unless shared by the tables used concurrently.
+type Allocator[S any] interface {
+	// NewSlot returns a slot record, the table overwrites all its fields
+	NewSlot() *S
+	// FreeSlot releases a slot record the table no longer references
+	FreeSlot(s *S)
+	// Bytes returns a slice of length n to copy a key to
+	Bytes(n int) []byte
+	// FreeBytes releases a slice returned by Bytes
+	FreeBytes(b []byte)
+}
+
+// Heap allocates on the Go heap and leaves the freeing to the garbage collector, as the table with nil allocator does.
+type Heap[S any] struct{}
+
+// NewSlot returns a new slot record.
+func (Heap[S]) NewSlot() *S {
+	return new(S)
+}
+
+// FreeSlot does nothing, the record is collected by the garbage collector.
+func (Heap[S]) FreeSlot(*S) {}
+
+// Bytes returns a new slice of length n.
+func (Heap[S]) Bytes(n int) []byte {
+	return make([]byte, n)
+}
+
+// FreeBytes does nothing, the slice is collected by the garbage collector.
+func (Heap[S]) FreeBytes([]byte) {}
@@ -0,0 +1,124 @@
+//go:build unix && !tinygo && !efhtiny
+
+package alloc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"syscall"
+	"unsafe"
+)
+
+const mmapChunkSize = 1 << 20
+
+// Mmap keeps the key copies in the memory mapped outside the Go heap, so they add nothing to the garbage
+// collector work. Set CopyKeys along with it, otherwise the keys are not copied. The slot records hold Go pointers, so
+// they stay on the heap, the freed ones are reused.
+//
+// A chunk of mapped memory is unmapped once all slices carved from it are freed, or by Close. So the slices must not
+// be used after they are freed, the tables copy the keys they hand out for that. Not safe for concurrent use.
+type Mmap[S any] struct {
+	ChunkSize int // Bytes mapped at once, 1 MiB if zero. A longer key gets its own chunk
+
+	chunks []*mmapChunk // The last one is the current
+	free   []*S         // Freed slot records to reuse
+}
+
+type mmapChunk struct {
+	mem  []byte
+	used int // Bytes handed out
+	live int // Slices handed out and not freed yet
+}
+
+// NewSlot returns a freed slot record or a new one.
+func (a *Mmap[S]) NewSlot() *S {
+	if n := len(a.free); n > 0 {
+		s := a.free[n-1]
+		a.free = a.free[:n-1]
+		return s
+	}
+	return new(S)
+}
+
+// FreeSlot keeps the slot record for reuse.
+func (a *Mmap[S]) FreeSlot(s *S) {
+	var zero S
+	*s = zero
+	a.free = append(a.free, s)
+}
+
+// Bytes returns a slice of length n from the current chunk, mapping a new one if it has no room. Panics if mmap fails.
+func (a *Mmap[S]) Bytes(n int) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	var c *mmapChunk
+	if len(a.chunks) > 0 {
+		c = a.chunks[len(a.chunks)-1]
+	}
+	if c == nil || len(c.mem)-c.used < n {
+		size := a.ChunkSize
+		if size <= 0 {
+			size = mmapChunkSize
+		}
+		mem, err := syscall.Mmap(-1, 0, max(n, size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+		if err != nil {
+			panic(fmt.Errorf("mmap %d bytes: %w", max(n, size), err))
+		}
+		c = &mmapChunk{mem: mem}
+		a.chunks = append(a.chunks, c)
+	}
+	b := c.mem[c.used : c.used+n : c.used+n]
+	c.used += n
+	c.live++
+	return b
+}
+
+// FreeBytes releases a slice returned by Bytes, the slices allocated elsewhere are ignored. The chunk is unmapped
+// once all its slices are freed, the current one is reused instead.
+func (a *Mmap[S]) FreeBytes(b []byte) {
+	if cap(b) == 0 {
+		return
+	}
+	p := uintptr(unsafe.Pointer(unsafe.SliceData(b)))
+	for i, c := range a.chunks {
+		base := uintptr(unsafe.Pointer(unsafe.SliceData(c.mem)))
+		if p < base || p >= base+uintptr(len(c.mem)) {
+			continue
+		}
+		if c.live--; c.live > 0 {
+			return
+		}
+		if i == len(a.chunks)-1 {
+			c.used = 0
+			return
+		}
+		a.chunks = slices.Delete(a.chunks, i, i+1)
+		if err := syscall.Munmap(c.mem); err != nil {
+			panic(fmt.Errorf("munmap: %w", err))
+		}
+		return
+	}
+}
+
+// Mapped returns the bytes of memory mapped by the allocator.
+func (a *Mmap[S]) Mapped() int {
+	var n int
+	for _, c := range a.chunks {
+		n += len(c.mem)
+	}
+	return n
+}
+
+// Close unmaps all memory. The table using the allocator must not be used after that.
+func (a *Mmap[S]) Close() error {
+	var errs []error
+	for _, c := range a.chunks {
+		if err := syscall.Munmap(c.mem); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	a.chunks, a.free = nil, nil
+	return errors.Join(errs...)
+}
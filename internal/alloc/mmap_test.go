@@ -0,0 +1,51 @@
+//go:build unix && !tinygo && !efhtiny
+
+package alloc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testSlot struct {
+	Key []byte
+}
+
+func TestMmap(t *testing.T) {
+	t.Run("slices; should be carved from chunks", func(t *testing.T) {
+		alloc := &Mmap[testSlot]{ChunkSize: 16}
+		defer alloc.Close()
+
+		a, b := alloc.Bytes(10), alloc.Bytes(10) // Does not fit the first chunk
+		assert.Len(t, a, 10)
+		assert.Len(t, b, 10)
+		assert.Equal(t, 32, alloc.Mapped())
+		assert.Len(t, alloc.Bytes(100), 100) // Own chunk
+		assert.Equal(t, 132, alloc.Mapped())
+		assert.Empty(t, alloc.Bytes(0))
+
+		alloc.FreeBytes(make([]byte, 10)) // Not allocated by it, ignored
+		alloc.FreeBytes(a)
+		assert.Equal(t, 116, alloc.Mapped())
+	})
+
+	t.Run("freed slot; should be reused", func(t *testing.T) {
+		alloc := &Mmap[testSlot]{}
+		s := alloc.NewSlot()
+		s.Key = []byte("key")
+		alloc.FreeSlot(s)
+
+		reused := alloc.NewSlot()
+		assert.Same(t, s, reused)
+		assert.Nil(t, reused.Key)
+	})
+
+	t.Run("closed; should unmap all chunks", func(t *testing.T) {
+		alloc := &Mmap[testSlot]{ChunkSize: 16}
+		alloc.Bytes(10)
+		alloc.Bytes(10)
+
+		assert.NoError(t, alloc.Close())
+		assert.Zero(t, alloc.Mapped())
+	})
+}
@@ -0,0 +1,17 @@
+// Package ctxcheck throttles the context checks of the long scans, e.g. the table rebuilds, copies and searches.
+package ctxcheck
+
+import (
+	"context"
+)
+
+// Interval is the number of entries processed between the context checks. The progress reports use it as well.
+const Interval = 1024
+
+// Err returns the ctx error once in Interval entries, n is the number of entries processed so far.
+func Err(ctx context.Context, n int) error {
+	if n%Interval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
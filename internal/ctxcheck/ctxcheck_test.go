@@ -0,0 +1,19 @@
+package ctxcheck
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestErr(t *testing.T) {
+	t.Run("canceled context; should return the error once in interval", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, Err(ctx, 0), context.Canceled)
+		assert.NoError(t, Err(ctx, 1))
+		assert.NoError(t, Err(ctx, Interval-1))
+		assert.ErrorIs(t, Err(ctx, 2*Interval), context.Canceled)
+	})
+}
@@ -0,0 +1,47 @@
+// Package dedup finds the duplicate table entries, shared by the table implementations, since Insert does not
+// deduplicate keys.
+package dedup
+
+import (
+	"cmp"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Groups returns the entries grouped by key. Only the keys having more than one entry are included. The keys are
+// compared bytewise.
+func Groups[S any](entries iter.Seq[S], key func(S) []byte) map[string][]S {
+	groups := make(map[string][]S)
+	for e := range entries {
+		k := key(e)
+		groups[string(k)] = append(groups[string(k)], e)
+	}
+	maps.DeleteFunc(groups, func(_ string, g []S) bool { return len(g) < 2 })
+	return groups
+}
+
+// Keys returns the copies of the grouped keys in bytewise order.
+func Keys[S any](groups map[string][]S) [][]byte {
+	keys := slices.SortedFunc(maps.Keys(groups), strings.Compare)
+	res := make([][]byte, len(keys))
+	for i, k := range keys {
+		res[i] = []byte(k)
+	}
+	return res
+}
+
+// Stale returns the entries to remove, i.e. all of every group but the one with the greatest insertion order seq.
+func Stale[S comparable](groups map[string][]S, seq func(S) uint64) map[S]bool {
+	stale := make(map[S]bool)
+	for _, g := range groups {
+		latest := slices.MaxFunc(g, func(a, b S) int { return cmp.Compare(seq(a), seq(b)) })
+		for _, e := range g {
+			if e != latest {
+				stale[e] = true
+			}
+		}
+	}
+	return stale
+}
@@ -0,0 +1,55 @@
+package dedup
+
+import (
+	"github.com/stretchr/testify/assert"
+	"slices"
+	"testing"
+)
+
+type entry struct {
+	key string
+	seq uint64
+}
+
+func TestGroups(t *testing.T) {
+	t.Run("duplicate keys; should group only them", func(t *testing.T) {
+		a1, b1, a2, c1, a3, b2 := &entry{"a", 1}, &entry{"b", 2}, &entry{"a", 3}, &entry{"c", 4}, &entry{"a", 5}, &entry{"b", 6}
+
+		groups := Groups(slices.Values([]*entry{a1, b1, a2, c1, a3, b2}), entryKey)
+
+		assert.Equal(t, map[string][]*entry{"a": {a1, a2, a3}, "b": {b1, b2}}, groups)
+	})
+
+	t.Run("no duplicates; should return empty", func(t *testing.T) {
+		groups := Groups(slices.Values([]*entry{{"a", 1}, {"b", 2}}), entryKey)
+
+		assert.Empty(t, groups)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("groups; should return the keys in bytewise order", func(t *testing.T) {
+		groups := map[string][]*entry{"b": nil, "a": nil, "ab": nil}
+
+		assert.Equal(t, [][]byte{[]byte("a"), []byte("ab"), []byte("b")}, Keys(groups))
+	})
+}
+
+func TestStale(t *testing.T) {
+	t.Run("groups; should return all entries but the latest of every group", func(t *testing.T) {
+		a1, a2, a3, b1, b2 := &entry{"a", 1}, &entry{"a", 5}, &entry{"a", 3}, &entry{"b", 4}, &entry{"b", 2}
+		groups := map[string][]*entry{"a": {a1, a2, a3}, "b": {b1, b2}}
+
+		stale := Stale(groups, func(e *entry) uint64 { return e.seq })
+
+		assert.Equal(t, map[*entry]bool{a1: true, a3: true, b2: true}, stale)
+	})
+
+	t.Run("no groups; should return empty", func(t *testing.T) {
+		assert.Empty(t, Stale(map[string][]*entry{}, func(e *entry) uint64 { return e.seq }))
+	})
+}
+
+func entryKey(e *entry) []byte {
+	return []byte(e.key)
+}
@@ -0,0 +1,76 @@
+// Package entry reads and updates the table entries in place, shared by the table implementations.
+package entry
+
+// Fields points to the fields of a table entry, so they are updated without another lookup.
+type Fields struct {
+	Key     []byte
+	Value   *any
+	Version *uint64
+	Flags   *uint8
+}
+
+// Table is the table whose entries are updated in place.
+type Table interface {
+	// Lookup returns the fields of the visible entry of a key.
+	Lookup(key []byte) (Fields, bool)
+	// Updated accounts and reports the update of an entry value, the version is already incremented. before is
+	// the value before the update.
+	Updated(f Fields, before any)
+}
+
+// UpdateInPlace calls fn with the value of an existing key and increments the entry version. Returns false if the
+// key does not exist, fn is not called then.
+func UpdateInPlace(t Table, key []byte, fn func(value *any)) bool {
+	f, ok := t.Lookup(key)
+	if !ok {
+		return false
+	}
+	before := *f.Value
+	fn(f.Value)
+	*f.Version++
+	t.Updated(f, before)
+	return true
+}
+
+// GetVersioned returns the value and the version of an existing key.
+func GetVersioned(t Table, key []byte) (any, uint64, bool) {
+	if f, ok := t.Lookup(key); ok {
+		return *f.Value, *f.Version, true
+	}
+	return nil, 0, false
+}
+
+// SetIfVersion updates the value of an existing key only if its version is equal to the given one. Returns the new
+// version and true on success, or the current version and false otherwise. If the key does not exist, it returns
+// 0 and false.
+func SetIfVersion(t Table, key []byte, value any, version uint64) (uint64, bool) {
+	f, ok := t.Lookup(key)
+	if !ok {
+		return 0, false
+	}
+	if *f.Version != version {
+		return *f.Version, false
+	}
+	before := *f.Value
+	*f.Value = value
+	*f.Version++
+	t.Updated(f, before)
+	return *f.Version, true
+}
+
+// SetFlags sets the flags of an existing key. Returns false if the key does not exist.
+func SetFlags(t Table, key []byte, flags uint8) bool {
+	f, ok := t.Lookup(key)
+	if ok {
+		*f.Flags = flags
+	}
+	return ok
+}
+
+// GetFlags returns the flags of an existing key. If the key does not exist, it returns 0 and false.
+func GetFlags(t Table, key []byte) (uint8, bool) {
+	if f, ok := t.Lookup(key); ok {
+		return *f.Flags, true
+	}
+	return 0, false
+}
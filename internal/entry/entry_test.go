@@ -0,0 +1,147 @@
+package entry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUpdateInPlace(t *testing.T) {
+	t.Run("existing key; should update value, bump version and report it", func(t *testing.T) {
+		table := newMapTable()
+		table.put("key", 1)
+
+		ok := UpdateInPlace(table, []byte("key"), func(value *any) {
+			*value = (*value).(int) + 1
+		})
+
+		assert.True(t, ok)
+		assert.Equal(t, &mapEntry{value: 2, version: 1}, table.entries["key"])
+		assert.Equal(t, []update{{key: "key", before: 1, after: 2, version: 1}}, table.updates)
+	})
+
+	t.Run("pointer value; should be updated in place", func(t *testing.T) {
+		table := newMapTable()
+		counter := new(int)
+		table.put("key", counter)
+
+		for i := 0; i < 10; i++ {
+			assert.True(t, UpdateInPlace(table, []byte("key"), func(value *any) { *(*value).(*int)++ }))
+		}
+
+		assert.Same(t, counter, table.entries["key"].value)
+		assert.Equal(t, 10, *counter)
+		assert.Equal(t, uint64(10), table.entries["key"].version)
+	})
+
+	t.Run("missing key; should not call fn", func(t *testing.T) {
+		table := newMapTable()
+
+		ok := UpdateInPlace(table, []byte("missing"), func(*any) { t.Fatal("fn is called") })
+
+		assert.False(t, ok)
+		assert.Empty(t, table.updates)
+	})
+}
+
+func TestVersion(t *testing.T) {
+	t.Run("update with current version; should succeed, bump version and report it", func(t *testing.T) {
+		table := newMapTable()
+		table.put("key", 1)
+
+		v, version, ok := GetVersioned(table, []byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		assert.Zero(t, version)
+
+		version, ok = SetIfVersion(table, []byte("key"), 2, version)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1), version)
+		assert.Equal(t, &mapEntry{value: 2, version: 1}, table.entries["key"])
+		assert.Equal(t, []update{{key: "key", before: 1, after: 2, version: 1}}, table.updates)
+	})
+
+	t.Run("update with stale version; should fail and keep value", func(t *testing.T) {
+		table := newMapTable()
+		table.entries["key"] = &mapEntry{value: 2, version: 1}
+
+		version, ok := SetIfVersion(table, []byte("key"), 3, 0)
+		assert.False(t, ok)
+		assert.Equal(t, uint64(1), version)
+		assert.Equal(t, &mapEntry{value: 2, version: 1}, table.entries["key"])
+		assert.Empty(t, table.updates)
+	})
+
+	t.Run("missing key; should fail", func(t *testing.T) {
+		table := newMapTable()
+
+		_, _, ok := GetVersioned(table, []byte("missing"))
+		assert.False(t, ok)
+		_, ok = SetIfVersion(table, []byte("missing"), 1, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestFlags(t *testing.T) {
+	t.Run("set flags and update value; should keep the flags", func(t *testing.T) {
+		table := newMapTable()
+		table.put("key", 1)
+
+		flags, ok := GetFlags(table, []byte("key"))
+		assert.True(t, ok)
+		assert.Zero(t, flags)
+		assert.True(t, SetFlags(table, []byte("key"), 0b101))
+		UpdateInPlace(table, []byte("key"), func(value *any) { *value = 2 })
+
+		flags, ok = GetFlags(table, []byte("key"))
+		assert.True(t, ok)
+		assert.Equal(t, uint8(0b101), flags)
+	})
+
+	t.Run("missing key; should fail", func(t *testing.T) {
+		table := newMapTable()
+
+		assert.False(t, SetFlags(table, []byte("missing"), 1))
+		_, ok := GetFlags(table, []byte("missing"))
+		assert.False(t, ok)
+	})
+}
+
+// mapEntry is an entry of mapTable.
+type mapEntry struct {
+	value   any
+	version uint64
+	flags   uint8
+}
+
+// update is a value update reported to mapTable.
+type update struct {
+	key           string
+	before, after any
+	version       uint64
+}
+
+// mapTable is a fake table recording the reported updates.
+type mapTable struct {
+	entries map[string]*mapEntry
+	updates []update
+}
+
+func newMapTable() *mapTable {
+	return &mapTable{entries: make(map[string]*mapEntry)}
+}
+
+func (m *mapTable) put(key string, value any) {
+	m.entries[key] = &mapEntry{value: value}
+}
+
+func (m *mapTable) Lookup(key []byte) (Fields, bool) {
+	e, ok := m.entries[string(key)]
+	if !ok {
+		return Fields{}, false
+	}
+	return Fields{Key: key, Value: &e.value, Version: &e.version, Flags: &e.flags}, true
+}
+
+func (m *mapTable) Updated(f Fields, before any) {
+	m.updates = append(m.updates, update{key: string(f.Key), before: before, after: *f.Value, version: *f.Version})
+}
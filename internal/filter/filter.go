@@ -0,0 +1,98 @@
+// Package filter provides a Bloom filter of the table keys, shared by the table implementations.
+package filter
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+	"math"
+)
+
+// headerSize is the size of the Filter binary form before the bit words: K, Seq and the words count.
+const headerSize = 4 + 8 + 8
+
+// Filter is a Bloom filter of the table keys. The keys not added are reported with the false positive rate given
+// on creation, the keys added are always reported.
+type Filter struct {
+	Bits []uint64
+	K    int // Bits set per key
+	// Seq is the Seq of the last mutation reported by the table before the export, see Mutation. A replica that
+	// applied the mutations up to Seq has the same keys as the filter
+	Seq uint64
+}
+
+// New creates an empty filter sized for n keys with the given false positive rate. Panics if fpRate is not in
+// range (0,1).
+func New(n int, fpRate float64, seq uint64) *Filter {
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("fpRate must be in range (0,1)")
+	}
+	// The optimal bits count m = -n·ln(p)/ln²2 and bits per key k = m/n·ln2
+	bits := max(int(math.Ceil(-float64(max(n, 1))*math.Log(fpRate)/(math.Ln2*math.Ln2))), 64)
+	return &Filter{
+		Bits: make([]uint64, (bits+63)/64),
+		K:    max(int(math.Round(float64(bits)/float64(max(n, 1))*math.Ln2)), 1),
+		Seq:  seq,
+	}
+}
+
+// Has returns false if a key was not added, or true if it may be.
+func (f *Filter) Has(key []byte) bool {
+	h1, h2 := hashes(key)
+	m := uint64(len(f.Bits)) * 64
+	for i := 0; i < f.K; i++ {
+		b := (h1 + uint64(i)*h2) % m
+		if f.Bits[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add sets the bits of a key.
+func (f *Filter) Add(key []byte) {
+	h1, h2 := hashes(key)
+	m := uint64(len(f.Bits)) * 64
+	for i := 0; i < f.K; i++ {
+		b := (h1 + uint64(i)*h2) % m
+		f.Bits[b/64] |= 1 << (b % 64)
+	}
+}
+
+// hashes returns the two hashes of a key, whose combinations give the bit indexes (double hashing).
+func hashes(key []byte) (uint64, uint64) {
+	h := hasher.Wyhash(0, key)
+	return h & math.MaxUint32, h>>32 | 1
+}
+
+// MarshalBinary encodes the filter to distribute it. The encoding is little-endian and does not depend on
+// the platform.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	b := make([]byte, headerSize, headerSize+8*len(f.Bits))
+	binary.LittleEndian.PutUint32(b, uint32(f.K))
+	binary.LittleEndian.PutUint64(b[4:], f.Seq)
+	binary.LittleEndian.PutUint64(b[12:], uint64(len(f.Bits)))
+	for _, w := range f.Bits {
+		b = binary.LittleEndian.AppendUint64(b, w)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the filter encoded by MarshalBinary.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return errors.New("filter data is too short")
+	}
+	k := binary.LittleEndian.Uint32(data)
+	words := binary.LittleEndian.Uint64(data[12:])
+	if size := len(data) - headerSize; k == 0 || k > math.MaxInt32 || words == 0 || size%8 != 0 ||
+		uint64(size/8) != words {
+		return errors.New("malformed filter data")
+	}
+	f.K, f.Seq = int(k), binary.LittleEndian.Uint64(data[4:])
+	f.Bits = make([]uint64, words)
+	for i := range f.Bits {
+		f.Bits[i] = binary.LittleEndian.Uint64(data[headerSize+8*i:])
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	t.Run("added keys; should report all of them and few absent ones", func(t *testing.T) {
+		f := New(1000, 0.01, 0)
+		for i := 0; i < 1000; i++ {
+			f.Add([]byte(fmt.Sprint("key", i)))
+		}
+
+		for i := 0; i < 1000; i++ {
+			assert.True(t, f.Has([]byte(fmt.Sprint("key", i))))
+		}
+		var positives int
+		for i := 0; i < 10000; i++ {
+			if f.Has([]byte(fmt.Sprint("absent", i))) {
+				positives++
+			}
+		}
+		assert.Less(t, positives, 200)
+	})
+
+	t.Run("empty filter; should report no keys", func(t *testing.T) {
+		assert.False(t, New(0, 0.01, 0).Has([]byte("key")))
+	})
+
+	t.Run("invalid rate; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { New(100, 1, 0) })
+	})
+}
+
+func TestFilterBinary(t *testing.T) {
+	t.Run("marshal and unmarshal; should keep the filter", func(t *testing.T) {
+		f := New(50, 0.01, 50)
+		for i := 0; i < 50; i++ {
+			f.Add([]byte(fmt.Sprint("key", i)))
+		}
+
+		data, err := f.MarshalBinary()
+		require.NoError(t, err)
+		var got Filter
+		require.NoError(t, got.UnmarshalBinary(data))
+
+		assert.Equal(t, f, &got)
+	})
+
+	t.Run("malformed data; should return error", func(t *testing.T) {
+		data, err := New(100, 0.01, 0).MarshalBinary()
+		require.NoError(t, err)
+		var f Filter
+
+		assert.Error(t, f.UnmarshalBinary(data[:10]))
+		assert.Error(t, f.UnmarshalBinary(data[:len(data)-1]))
+		assert.Error(t, f.UnmarshalBinary(append(data, 0, 0, 0, 0, 0, 0, 0, 0)))
+	})
+}
@@ -0,0 +1,28 @@
+// Package find searches the table entries by value, shared by the table implementations.
+package find
+
+import (
+	"context"
+	"github.com/bdragon300/elastic-funnel-hash/internal/ctxcheck"
+	"iter"
+)
+
+// Values returns the keys of the entries whose values satisfy pred. Returns at most limit keys if limit is positive.
+// Stops once ctx is done, then returns the keys found so far along with the context error.
+func Values(ctx context.Context, entries iter.Seq2[[]byte, any], pred func(value any) bool, limit int) ([][]byte, error) {
+	var keys [][]byte
+	var n int
+	for k, v := range entries {
+		if err := ctxcheck.Err(ctx, n); err != nil {
+			return keys, err
+		}
+		n++
+		if pred(v) {
+			keys = append(keys, k)
+			if len(keys) == limit {
+				break
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,64 @@
+package find
+
+import (
+	"context"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/internal/ctxcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iter"
+	"testing"
+)
+
+func TestValues(t *testing.T) {
+	t.Run("matching values; should return their keys in order", func(t *testing.T) {
+		keys, err := Values(context.Background(), entries(1000), func(v any) bool { return v.(int)%100 == 42 }, 0)
+
+		require.NoError(t, err)
+		var want [][]byte
+		for i := 42; i < 1000; i += 100 {
+			want = append(want, []byte(fmt.Sprint("key", i)))
+		}
+		assert.Equal(t, want, keys)
+	})
+
+	t.Run("limit; should return at most limit keys", func(t *testing.T) {
+		keys, err := Values(context.Background(), entries(50), func(any) bool { return true }, 3)
+
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("key0"), []byte("key1"), []byte("key2")}, keys)
+	})
+
+	t.Run("no matches; should return nil", func(t *testing.T) {
+		keys, err := Values(context.Background(), entries(10), func(any) bool { return false }, 0)
+
+		require.NoError(t, err)
+		assert.Nil(t, keys)
+	})
+
+	t.Run("context canceled during scan; should return the keys found so far with error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var n int
+
+		keys, err := Values(ctx, entries(3*ctxcheck.Interval), func(any) bool {
+			if n++; n == ctxcheck.Interval {
+				cancel()
+			}
+			return true
+		}, 0)
+
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, keys, ctxcheck.Interval)
+	})
+}
+
+// entries returns n entries with keys "key<i>" and values i.
+func entries(n int) iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		for i := 0; i < n; i++ {
+			if !yield([]byte(fmt.Sprint("key", i)), i) {
+				return
+			}
+		}
+	}
+}
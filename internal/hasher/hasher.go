@@ -0,0 +1,75 @@
+// Package hasher provides the key hashing shared by the table implementations: the wyhash function, the default
+// seeded hasher and the reduction of the hashes to the index ranges.
+package hasher
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Constants of the wyhash algorithm
+const (
+	wyp0 = 0xa0761d6478bd642f
+	wyp1 = 0xe7037ed1a0b428db
+	wyp2 = 0x8ebc6af09c88c6e3
+	wyp3 = 0x589965cc75374cc3
+)
+
+// Seeded returns the wyhash hasher with the given seed, the tables use it by default.
+func Seeded(seed uint64) func(b []byte) uint64 {
+	return func(b []byte) uint64 {
+		return Wyhash(seed, b)
+	}
+}
+
+// Reduce maps a hash to an index in [0, n), taking the hash modulo n. The hash is 64-bit, so the ranges beyond 2^32
+// are covered entirely.
+func Reduce(hsh uint64, n int) int {
+	return int(hsh % uint64(n))
+}
+
+// Wyhash is the wyhash (final version) of b.
+func Wyhash(seed uint64, b []byte) uint64 {
+	n := len(b)
+	seed ^= wymix(seed^wyp0, wyp1)
+	var a, c uint64
+	switch {
+	case n == 0:
+	case n < 4:
+		a = uint64(b[0])<<16 | uint64(b[n>>1])<<8 | uint64(b[n-1])
+	case n <= 16:
+		a = wyr4(b)<<32 | wyr4(b[n>>3<<2:])
+		c = wyr4(b[n-4:])<<32 | wyr4(b[n-4-n>>3<<2:])
+	default:
+		p, i := b, n
+		if i > 48 {
+			s1, s2 := seed, seed
+			for ; i > 48; p, i = p[48:], i-48 {
+				seed = wymix(wyr8(p)^wyp1, wyr8(p[8:])^seed)
+				s1 = wymix(wyr8(p[16:])^wyp2, wyr8(p[24:])^s1)
+				s2 = wymix(wyr8(p[32:])^wyp3, wyr8(p[40:])^s2)
+			}
+			seed ^= s1 ^ s2
+		}
+		for ; i > 16; p, i = p[16:], i-16 {
+			seed = wymix(wyr8(p)^wyp1, wyr8(p[8:])^seed)
+		}
+		a = wyr8(b[n-16:])
+		c = wyr8(b[n-8:])
+	}
+	hi, lo := bits.Mul64(a^wyp1, c^seed)
+	return wymix(lo^wyp0^uint64(n), hi^wyp1)
+}
+
+func wymix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func wyr8(b []byte) uint64 {
+	return binary.LittleEndian.Uint64(b)
+}
+
+func wyr4(b []byte) uint64 {
+	return uint64(binary.LittleEndian.Uint32(b))
+}
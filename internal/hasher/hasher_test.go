@@ -0,0 +1,77 @@
+package hasher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+func TestSeeded(t *testing.T) {
+	t.Run("same seed; should give the same hashes", func(t *testing.T) {
+		data := make([]byte, 100)
+		for i := range data {
+			data[i] = byte(i * 31)
+		}
+		h1, h2, h3 := Seeded(1), Seeded(1), Seeded(2)
+
+		seen := make(map[uint64]bool)
+		for n := 0; n <= len(data); n++ {
+			assert.Equal(t, h1(data[:n]), h2(data[:n]), "len: %v", n)
+			assert.NotEqual(t, h1(data[:n]), h3(data[:n]), "len: %v", n)
+			seen[h1(data[:n])] = true
+		}
+		assert.Len(t, seen, len(data)+1)
+	})
+}
+
+func BenchmarkSeeded(b *testing.B) {
+	for _, size := range []int{8, 32, 256} {
+		key := make([]byte, size)
+		h := Seeded(1)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				key[0] = byte(i)
+				h(key)
+			}
+		})
+	}
+}
+
+func TestReduce(t *testing.T) {
+	t.Run("any range; should take hash modulo range", func(t *testing.T) {
+		for _, n64 := range []uint64{1, 6, 1 << 20, math.MaxInt32, math.MaxUint32, 1<<34 + 6, math.MaxInt64} {
+			if n64 > math.MaxInt {
+				continue // Not representable on 32-bit platforms
+			}
+			n := int(n64)
+			for i := 0; i < 100; i++ {
+				hsh := rand.Uint64()
+				assert.Equal(t, int(hsh%n64), Reduce(hsh, n), "n: %v, hsh: %v", n, hsh)
+			}
+		}
+	})
+
+	t.Run("keys hashed to range beyond 2^32; should reach the offsets over 2^32", func(t *testing.T) {
+		if strconv.IntSize < 64 {
+			t.Skip("the range is not representable on 32-bit platforms")
+		}
+		var n64 uint64 = 1<<34 + 6
+		n := int(n64)
+		h := Seeded(1)
+		seen := make(map[int]bool)
+		var high int
+		for i := 0; i < 1000; i++ {
+			idx := Reduce(h([]byte(strconv.Itoa(i))), n)
+			assert.True(t, idx >= 0 && idx < n, "idx: %v", idx)
+			if uint64(idx) > math.MaxUint32 {
+				high++
+			}
+			seen[idx] = true
+		}
+		assert.Greater(t, high, 650) // 3/4 of the range is above 2^32
+		assert.Len(t, seen, 1000)
+	})
+}
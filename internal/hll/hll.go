@@ -0,0 +1,68 @@
+// Package hll provides the HyperLogLog estimator of the distinct keys count, shared by the table implementations.
+package hll
+
+import (
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog estimates the number of distinct keys added to it in a fixed memory of 2^precision bytes. The standard
+// error of the estimate is 1.04/√(2^precision), e.g. 1.6% for precision 12.
+//
+// Not safe for concurrent use.
+type HyperLogLog struct {
+	precision int
+	registers []uint8 // The maximum rank seen in every register
+}
+
+// New creates a new estimator of 2^precision registers. Panics if precision is not in range [4,18].
+func New(precision int) *HyperLogLog {
+	if precision < 4 || precision > 18 {
+		panic("precision must be in range [4,18]")
+	}
+	return &HyperLogLog{precision: precision, registers: make([]uint8, 1<<precision)}
+}
+
+// Add adds a key to the estimate.
+func (h *HyperLogLog) Add(key []byte) {
+	hsh := hasher.Wyhash(0, key)
+	idx := hsh >> (64 - h.precision)
+	// The rank is the position of the first set bit in the rest of the hash, bounded by its length
+	rank := uint8(bits.LeadingZeros64(hsh<<h.precision|1<<(h.precision-1)) + 1)
+	h.registers[idx] = max(h.registers[idx], rank)
+}
+
+// Estimate returns the estimated number of distinct keys added.
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(len(h.registers))
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros)) // Linear counting is more accurate on small cardinalities
+	}
+	return uint64(math.Round(est))
+}
+
+// Reset forgets the added keys.
+func (h *HyperLogLog) Reset() {
+	clear(h.registers)
+}
@@ -0,0 +1,42 @@
+package hll
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	t.Run("many keys added twice; should estimate distinct count within error", func(t *testing.T) {
+		h := New(12)
+		for i := 0; i < 100000; i++ {
+			h.Add([]byte(fmt.Sprint("key", i)))
+			h.Add([]byte(fmt.Sprint("key", i)))
+		}
+
+		assert.InEpsilon(t, 100000, h.Estimate(), 0.05)
+	})
+
+	t.Run("few keys; should estimate almost exactly", func(t *testing.T) {
+		h := New(12)
+		for i := 0; i < 10; i++ {
+			h.Add([]byte(fmt.Sprint("key", i)))
+		}
+
+		assert.InDelta(t, 10, h.Estimate(), 1)
+	})
+
+	t.Run("reset; should estimate zero", func(t *testing.T) {
+		h := New(4)
+		h.Add([]byte("key"))
+
+		h.Reset()
+
+		assert.Zero(t, h.Estimate())
+	})
+
+	t.Run("precision out of range; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { New(3) })
+		assert.Panics(t, func() { New(19) })
+	})
+}
@@ -0,0 +1,27 @@
+// Package keys encodes the structured keys, shared by the table implementations.
+package keys
+
+import "encoding"
+
+// Appender is implemented by structured keys that can append their binary form to a buffer. It lets lookups reuse
+// a buffer instead of allocating.
+type Appender interface {
+	AppendKey(b []byte) []byte
+}
+
+// Byter is implemented by structured keys that already keep their binary form.
+type Byter interface {
+	KeyBytes() []byte
+}
+
+// Bytes returns the binary form of key in a new buffer. The key is encoded with AppendKey or KeyBytes if it
+// implements Appender or Byter, or with MarshalBinary otherwise.
+func Bytes(key encoding.BinaryMarshaler) ([]byte, error) {
+	switch k := key.(type) {
+	case Appender:
+		return k.AppendKey(nil), nil
+	case Byter:
+		return k.KeyBytes(), nil
+	}
+	return key.MarshalBinary()
+}
@@ -0,0 +1,60 @@
+package keys
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// appenderID is a key implementing Appender.
+type appenderID string
+
+func (a appenderID) MarshalBinary() ([]byte, error) { return nil, errors.New("not used") }
+
+func (a appenderID) AppendKey(b []byte) []byte { return append(b, "appended:"+a...) }
+
+// byterID is a key implementing Byter.
+type byterID string
+
+func (b byterID) MarshalBinary() ([]byte, error) { return nil, errors.New("not used") }
+
+func (b byterID) KeyBytes() []byte { return []byte("bytes:" + b) }
+
+// marshalerID is a key implementing only encoding.BinaryMarshaler.
+type marshalerID string
+
+func (m marshalerID) MarshalBinary() ([]byte, error) {
+	if m == "" {
+		return nil, errors.New("empty id")
+	}
+	return []byte("marshaled:" + m), nil
+}
+
+func TestBytes(t *testing.T) {
+	t.Run("appender key; should append it to a new buffer", func(t *testing.T) {
+		b, err := Bytes(appenderID("a"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("appended:a"), b)
+	})
+
+	t.Run("byter key; should return its bytes", func(t *testing.T) {
+		b, err := Bytes(byterID("a"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("bytes:a"), b)
+	})
+
+	t.Run("marshaler key; should marshal it", func(t *testing.T) {
+		b, err := Bytes(marshalerID("a"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("marshaled:a"), b)
+	})
+
+	t.Run("marshaler error; should return it", func(t *testing.T) {
+		_, err := Bytes(marshalerID(""))
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,28 @@
+// Package latency provides the sampled probing time metric, shared by the table implementations.
+package latency
+
+import (
+	"time"
+)
+
+// Latency is a metric of the sampled probing times.
+type Latency struct {
+	Count int           // Measured probings
+	Total time.Duration // Total time of the measured probings
+	Max   time.Duration
+}
+
+// Mean returns the mean probing time, or 0 if nothing was measured.
+func (l Latency) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+// Add adds a measured probing time.
+func (l *Latency) Add(d time.Duration) {
+	l.Count++
+	l.Total += d
+	l.Max = max(l.Max, d)
+}
@@ -0,0 +1,20 @@
+package latency
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	t.Run("measured times; should keep the mean and maximum", func(t *testing.T) {
+		var l Latency
+		assert.Zero(t, l.Mean())
+
+		l.Add(time.Second)
+		l.Add(3 * time.Second)
+
+		assert.Equal(t, Latency{Count: 2, Total: 4 * time.Second, Max: 3 * time.Second}, l)
+		assert.Equal(t, 2*time.Second, l.Mean())
+	})
+}
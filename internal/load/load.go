@@ -0,0 +1,67 @@
+// Package load deduplicates the concurrent read-through loads of the same key, shared by the table implementations.
+package load
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoaderPanic is returned by Do to the waiting callers if the loader has panicked.
+var errLoaderPanic = errors.New("loader panicked")
+
+// call is an in-flight loader call shared by the callers waiting for the same key.
+type call struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// Group tracks the in-flight loader calls by key. The zero value is ready to use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// Do returns a value for a key. get looks the key up in the table. If the key is missing, Do calls loader and
+// inserts its result with insert. Concurrent Do calls for the same missing key share a single loader call.
+//
+// get and insert are called under the group lock, loader is called without it. If loader fails, its error is
+// returned to all waiting callers and nothing is inserted. If the loaded value cannot be inserted, it is returned
+// along with the insert error.
+func (g *Group) Do(key []byte, get func() (any, bool), loader func() (any, error), insert func([]byte, any) error) (any, error) {
+	g.mu.Lock()
+	if v, ok := get(); ok {
+		g.mu.Unlock()
+		return v, nil
+	}
+	if c, ok := g.calls[string(key)]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	k := string(key) // The caller's key may be a reused buffer
+	g.calls[k] = c
+	g.mu.Unlock()
+
+	g.load(k, c, loader, insert)
+	return c.value, c.err
+}
+
+// load calls loader and inserts its result. The waiters are released even if loader panics.
+func (g *Group) load(key string, c *call, loader func() (any, error), insert func([]byte, any) error) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		if c.err == nil {
+			c.err = insert([]byte(key), c.value)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.err = errLoaderPanic // Overwritten if loader returns
+	c.value, c.err = loader()
+}
@@ -0,0 +1,109 @@
+package load
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("concurrent loads of missing key; should call loader once", func(t *testing.T) {
+		var g Group
+		m := newMapTable()
+		var calls atomic.Int32
+		release := make(chan struct{})
+		loader := func() (any, error) {
+			calls.Add(1)
+			<-release
+			return 42, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := g.Do([]byte("key"), m.get("key"), loader, m.insert)
+				assert.NoError(t, err)
+				assert.Equal(t, 42, v)
+			}()
+		}
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, map[string]any{"key": 42}, m.entries)
+		assert.Empty(t, g.calls)
+	})
+
+	t.Run("existing key; should not call loader", func(t *testing.T) {
+		var g Group
+		m := newMapTable()
+		m.entries["key"] = 1
+
+		v, err := g.Do([]byte("key"), m.get("key"), func() (any, error) {
+			t.Fatal("loader called")
+			return nil, nil
+		}, m.insert)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("loader error; should return it and insert nothing", func(t *testing.T) {
+		var g Group
+		m := newMapTable()
+		loadErr := errors.New("load failed")
+
+		_, err := g.Do([]byte("key"), m.get("key"), func() (any, error) { return nil, loadErr }, m.insert)
+		assert.ErrorIs(t, err, loadErr)
+		assert.Empty(t, m.entries)
+	})
+
+	t.Run("insert error; should return it along with the value", func(t *testing.T) {
+		var g Group
+		m := newMapTable()
+		insertErr := errors.New("full")
+
+		v, err := g.Do([]byte("key"), m.get("key"), func() (any, error) { return 1, nil }, func([]byte, any) error {
+			return insertErr
+		})
+		assert.ErrorIs(t, err, insertErr)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("loader panics; should release the key", func(t *testing.T) {
+		var g Group
+		m := newMapTable()
+
+		assert.Panics(t, func() {
+			g.Do([]byte("key"), m.get("key"), func() (any, error) { panic("boom") }, m.insert)
+		})
+		v, err := g.Do([]byte("key"), m.get("key"), func() (any, error) { return 1, nil }, m.insert)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+		assert.Empty(t, g.calls)
+	})
+}
+
+// mapTable is a fake table.
+type mapTable struct {
+	entries map[string]any
+}
+
+func newMapTable() *mapTable {
+	return &mapTable{entries: make(map[string]any)}
+}
+
+func (m *mapTable) get(key string) func() (any, bool) {
+	return func() (any, bool) {
+		v, ok := m.entries[key]
+		return v, ok
+	}
+}
+
+func (m *mapTable) insert(key []byte, value any) error {
+	m.entries[string(key)] = value
+	return nil
+}
@@ -0,0 +1,49 @@
+package mutation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Target is a table the mutations are applied to.
+type Target interface {
+	TrySet(key []byte, value any) (bool, error)
+	SoftDelete(key []byte) bool
+	Purge() int
+	Clear()
+	// SetVersion sets the version of an existing entry
+	SetVersion(key []byte, version uint64)
+	// CopiesKeys returns true if the table copies the inserted keys, see HashTable.CopyKeys
+	CopiesKeys() bool
+}
+
+// Apply applies a mutation reported by another table to the target, so it becomes its copy. The inserts and updates
+// set the value and the version of the key, purging the soft-deleted entries once if TrySet returns the full error.
+// The deletions soft-delete the key.
+//
+// The mutation key is copied, since it's valid only during the OnMutation call.
+func Apply(t Target, m Mutation, full error) error {
+	switch m.Op {
+	case Insert, Update:
+		key := m.Key
+		if !t.CopiesKeys() {
+			key = bytes.Clone(key)
+		}
+		_, err := t.TrySet(key, m.Value)
+		if errors.Is(err, full) && t.Purge() > 0 {
+			_, err = t.TrySet(key, m.Value)
+		}
+		if err != nil {
+			return err
+		}
+		t.SetVersion(key, m.Version)
+	case Delete:
+		t.SoftDelete(m.Key)
+	case Clear:
+		t.Clear()
+	default:
+		return fmt.Errorf("unknown mutation %v", m.Op)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package mutation
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var errFull = errors.New("full")
+
+// mapTarget is a Target over a Go map, holding at most limit entries. The soft-deleted keys are kept until Purge.
+type mapTarget struct {
+	entries  map[string]any
+	versions map[string]uint64
+	deleted  map[string]bool
+	limit    int
+	copies   bool
+}
+
+func newMapTarget(limit int) *mapTarget {
+	return &mapTarget{entries: map[string]any{}, versions: map[string]uint64{}, deleted: map[string]bool{}, limit: limit}
+}
+
+func (m *mapTarget) TrySet(key []byte, value any) (bool, error) {
+	_, ok := m.entries[string(key)]
+	if !ok && len(m.entries) >= m.limit {
+		return false, errFull
+	}
+	m.entries[string(key)] = value
+	delete(m.deleted, string(key))
+	return ok, nil
+}
+
+func (m *mapTarget) SoftDelete(key []byte) bool {
+	_, ok := m.entries[string(key)]
+	m.deleted[string(key)] = ok
+	return ok
+}
+
+func (m *mapTarget) Purge() int {
+	n := len(m.deleted)
+	for k := range m.deleted {
+		delete(m.entries, k)
+	}
+	clear(m.deleted)
+	return n
+}
+
+func (m *mapTarget) Clear() {
+	clear(m.entries)
+	clear(m.deleted)
+}
+
+func (m *mapTarget) SetVersion(key []byte, version uint64) {
+	m.versions[string(key)] = version
+}
+
+func (m *mapTarget) CopiesKeys() bool {
+	return m.copies
+}
+
+func TestApply(t *testing.T) {
+	t.Run("insert and update; should set the value and the version", func(t *testing.T) {
+		target := newMapTarget(10)
+
+		require.NoError(t, Apply(target, Mutation{Op: Insert, Key: []byte("a"), Value: 1}, errFull))
+		require.NoError(t, Apply(target, Mutation{Op: Update, Key: []byte("a"), Value: 2, Version: 1}, errFull))
+
+		assert.Equal(t, map[string]any{"a": 2}, target.entries)
+		assert.Equal(t, uint64(1), target.versions["a"])
+	})
+
+	t.Run("delete; should soft-delete the key", func(t *testing.T) {
+		target := newMapTarget(10)
+		require.NoError(t, Apply(target, Mutation{Op: Insert, Key: []byte("a"), Value: 1}, errFull))
+
+		require.NoError(t, Apply(target, Mutation{Op: Delete, Key: []byte("a")}, errFull))
+
+		assert.True(t, target.deleted["a"])
+	})
+
+	t.Run("target full of deleted keys; should purge them once and insert", func(t *testing.T) {
+		target := newMapTarget(1)
+		require.NoError(t, Apply(target, Mutation{Op: Insert, Key: []byte("a"), Value: 1}, errFull))
+		require.NoError(t, Apply(target, Mutation{Op: Delete, Key: []byte("a")}, errFull))
+
+		require.NoError(t, Apply(target, Mutation{Op: Insert, Key: []byte("b"), Value: 2}, errFull))
+
+		assert.Equal(t, map[string]any{"b": 2}, target.entries)
+		assert.ErrorIs(t, Apply(target, Mutation{Op: Insert, Key: []byte("c"), Value: 3}, errFull), errFull)
+	})
+
+	t.Run("clear; should clear the target", func(t *testing.T) {
+		target := newMapTarget(10)
+		require.NoError(t, Apply(target, Mutation{Op: Insert, Key: []byte("a"), Value: 1}, errFull))
+
+		require.NoError(t, Apply(target, Mutation{Op: Clear}, errFull))
+
+		assert.Empty(t, target.entries)
+	})
+
+	t.Run("unknown mutation; should return an error", func(t *testing.T) {
+		assert.ErrorContains(t, Apply(newMapTarget(10), Mutation{Op: Op(10)}, errFull), "unknown mutation")
+	})
+}
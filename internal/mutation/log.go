@@ -0,0 +1,74 @@
+package mutation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrGap is returned if a mutation does not follow the last applied one, or the mutations after it are lost.
+var ErrGap = errors.New("mutation sequence gap")
+
+// Log keeps the latest mutations of a table in memory, so a replicator may resume the feed from the last mutation
+// it applied, e.g. after a reconnect. It's safe for concurrent use, so the feed may be read from another goroutine.
+type Log struct {
+	mu    sync.Mutex
+	ring  []Mutation
+	first uint64 // Seq of the first recorded mutation, the log may be set up after the table
+	last  uint64 // Seq of the latest recorded mutation
+}
+
+// NewLog creates a new log keeping size latest mutations. Panics if size is not positive.
+func NewLog(size int) *Log {
+	if size <= 0 {
+		panic("size must be positive")
+	}
+	return &Log{ring: make([]Mutation, size)}
+}
+
+// Record records a mutation, overwriting the oldest one if the log is full. Set it to HashTable.OnMutation, the
+// mutations must come in Seq order. The key is copied.
+func (l *Log) Record(m Mutation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m.Key = bytes.Clone(m.Key)
+	l.ring[m.Seq%uint64(len(l.ring))] = m
+	if l.first == 0 {
+		l.first = m.Seq
+	}
+	l.last = m.Seq
+}
+
+// Since returns the recorded mutations after the one of the given Seq, the oldest first. Returns ErrGap if some of
+// them are overwritten already, so the replicator has to start over from a table copy.
+func (l *Log) Since(seq uint64) ([]Mutation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if seq > l.last {
+		return nil, fmt.Errorf("%w: mutation %d is not recorded yet, the last one is %d", ErrGap, seq, l.last)
+	}
+	if seq < l.last && seq+1 < l.oldest() {
+		return nil, fmt.Errorf("%w: mutations after %d are not in the log", ErrGap, seq)
+	}
+	ms := make([]Mutation, 0, l.last-seq)
+	for s := seq + 1; s <= l.last; s++ {
+		ms = append(ms, l.ring[s%uint64(len(l.ring))])
+	}
+	return ms, nil
+}
+
+// oldest returns the Seq of the oldest mutation kept.
+func (l *Log) oldest() uint64 {
+	if size := uint64(len(l.ring)); l.last >= size {
+		return max(l.first, l.last-size+1)
+	}
+	return l.first
+}
+
+// Last returns the Seq of the latest recorded mutation, 0 if none.
+func (l *Log) Last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
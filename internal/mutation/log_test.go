@@ -0,0 +1,87 @@
+package mutation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// record records the mutations of the given seqs.
+func record(l *Log, from, to uint64) {
+	for seq := from; seq <= to; seq++ {
+		l.Record(Mutation{Op: Insert, Key: []byte{byte(seq)}, Seq: seq})
+	}
+}
+
+func TestLog(t *testing.T) {
+	t.Run("mutations kept; should return the ones after the seq", func(t *testing.T) {
+		l := NewLog(4)
+		record(l, 1, 6)
+
+		ms, err := l.Since(3)
+
+		require.NoError(t, err)
+		require.Len(t, ms, 3)
+		for i, m := range ms {
+			assert.Equal(t, uint64(4+i), m.Seq)
+		}
+		assert.Equal(t, uint64(6), l.Last())
+	})
+
+	t.Run("no new mutations; should return none", func(t *testing.T) {
+		l := NewLog(4)
+		record(l, 1, 2)
+
+		ms, err := l.Since(2)
+
+		assert.NoError(t, err)
+		assert.Empty(t, ms)
+		ms, err = NewLog(4).Since(0)
+		assert.NoError(t, err)
+		assert.Empty(t, ms)
+	})
+
+	t.Run("mutations overwritten; should return ErrGap", func(t *testing.T) {
+		l := NewLog(4)
+		record(l, 1, 6)
+
+		_, err := l.Since(1)
+
+		assert.ErrorIs(t, err, ErrGap)
+	})
+
+	t.Run("log set up after the table; should return ErrGap for the earlier mutations", func(t *testing.T) {
+		l := NewLog(4)
+		record(l, 10, 11)
+
+		_, err := l.Since(5)
+		assert.ErrorIs(t, err, ErrGap)
+		ms, err := l.Since(9)
+		assert.NoError(t, err)
+		assert.Len(t, ms, 2)
+	})
+
+	t.Run("seq ahead of the log; should return ErrGap", func(t *testing.T) {
+		l := NewLog(4)
+		record(l, 1, 2)
+
+		_, err := l.Since(3)
+
+		assert.ErrorIs(t, err, ErrGap)
+	})
+
+	t.Run("recorded key; should be copied", func(t *testing.T) {
+		l := NewLog(4)
+		key := []byte("key")
+
+		l.Record(Mutation{Op: Insert, Key: key, Seq: 1})
+		key[0] = 'x'
+
+		ms, _ := l.Since(0)
+		assert.Equal(t, []byte("key"), ms[0].Key)
+	})
+
+	t.Run("not positive size; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewLog(0) })
+	})
+}
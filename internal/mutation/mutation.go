@@ -0,0 +1,42 @@
+// Package mutation provides the entries changes reported by the tables, shared by the table implementations. So
+// a table of one implementation may replicate another one.
+package mutation
+
+import (
+	"strconv"
+)
+
+// Op is a kind of entries change.
+type Op int
+
+const (
+	Insert Op = iota // A new entry, or an entry restored by Undelete
+	Update           // An entry value update
+	Delete           // An entry removal, or hiding by SoftDelete
+	Clear            // Removal of all entries by Clear, the mutation has no key and value
+)
+
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	case Clear:
+		return "clear"
+	}
+	return "MutationOp(" + strconv.Itoa(int(op)) + ")"
+}
+
+// Mutation is a change of the table entries.
+type Mutation struct {
+	Op      Op
+	Key     []byte
+	Value   any
+	Version uint64 // Entry version after the change, see GetVersioned
+	// Seq is the number of the reported mutation starting from 1, so a replicator may persist the last applied one
+	// and detect the gaps
+	Seq uint64
+}
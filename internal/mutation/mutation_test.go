@@ -0,0 +1,13 @@
+package mutation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOp(t *testing.T) {
+	t.Run("op names; should be readable", func(t *testing.T) {
+		assert.Equal(t, "delete", Delete.String())
+		assert.Equal(t, "MutationOp(10)", Op(10).String())
+	})
+}
@@ -0,0 +1,18 @@
+//go:build !tinygo && !efhtiny
+
+// Package prand provides the generator of the random probe sequences, shared by the table implementations. It's
+// ChaCha8, or splitmix64 in the tiny build profile, since ChaCha8 is slow on TinyGo and WebAssembly. The sequences of
+// the profiles differ.
+package prand
+
+import (
+	"math/rand/v2"
+)
+
+// Rand generates the random probe sequences.
+type Rand = rand.ChaCha8
+
+// New returns a generator with the given seed.
+func New(seed [32]byte) *Rand {
+	return rand.NewChaCha8(seed)
+}
@@ -0,0 +1,18 @@
+package prand
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRand(t *testing.T) {
+	t.Run("same seed; should generate the same sequence", func(t *testing.T) {
+		a, b, c := New([32]byte{1}), New([32]byte{1}), New([32]byte{2})
+
+		for i := 0; i < 100; i++ {
+			x := a.Uint64()
+			assert.Equal(t, x, b.Uint64())
+			assert.NotEqual(t, x, c.Uint64())
+		}
+	})
+}
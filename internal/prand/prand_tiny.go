@@ -0,0 +1,40 @@
+//go:build tinygo || efhtiny
+
+package prand
+
+import (
+	"encoding/binary"
+)
+
+// seedMul mixes the seed words, it's the wyhash secret the sequences were seeded with so far.
+const seedMul = 0xe7037ed1a0b428db
+
+// Rand generates the random probe sequences with splitmix64.
+type Rand struct {
+	state uint64
+}
+
+// New returns a generator with the given seed.
+func New(seed [32]byte) *Rand {
+	r := &Rand{}
+	r.Seed(seed)
+	return r
+}
+
+// Seed resets the generator to the given seed.
+func (r *Rand) Seed(seed [32]byte) {
+	var s uint64
+	for i := 0; i < len(seed); i += 8 {
+		s = (s ^ binary.LittleEndian.Uint64(seed[i:])) * seedMul
+	}
+	r.state = s
+}
+
+// Uint64 returns the next number of the sequence.
+func (r *Rand) Uint64() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
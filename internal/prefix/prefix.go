@@ -0,0 +1,44 @@
+// Package prefix provides the sorted index of the table keys for prefix scans, shared by the table implementations.
+package prefix
+
+import (
+	"bytes"
+	"iter"
+	"slices"
+)
+
+// Index is a sorted copy of the table keys, so the keys starting with a prefix are found without scanning the whole
+// table.
+type Index struct {
+	keys  [][]byte                     // Distinct keys in bytewise order
+	value func(key []byte) (any, bool) // Looks up the current value of a key
+}
+
+// New builds the index of the keys, taking a copy of every key. value looks up the current value of an indexed key
+// on scan, so the value updates and removals are seen.
+func New(keys iter.Seq2[[]byte, any], value func(key []byte) (any, bool)) *Index {
+	var sorted [][]byte
+	for k := range keys {
+		sorted = append(sorted, bytes.Clone(k))
+	}
+	slices.SortFunc(sorted, bytes.Compare)
+	return &Index{keys: slices.CompactFunc(sorted, bytes.Equal), value: value}
+}
+
+// PrefixScan returns an iterator over the entries whose keys start with a prefix, in the keys order. The keys must
+// be canonical, see HashTable.KeyCanon. The table must not be modified during iteration.
+func (x *Index) PrefixScan(prefix []byte) iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		i, _ := slices.BinarySearchFunc(x.keys, prefix, bytes.Compare)
+		for ; i < len(x.keys) && bytes.HasPrefix(x.keys[i], prefix); i++ {
+			if v, ok := x.value(x.keys[i]); ok && !yield(x.keys[i], v) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of indexed keys.
+func (x *Index) Len() int {
+	return len(x.keys)
+}
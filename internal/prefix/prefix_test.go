@@ -0,0 +1,93 @@
+package prefix
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// mapKeys returns an iterator over the map entries as key bytes.
+func mapKeys(m map[string]any) func(yield func([]byte, any) bool) {
+	return func(yield func([]byte, any) bool) {
+		for k, v := range m {
+			if !yield([]byte(k), v) {
+				return
+			}
+		}
+	}
+}
+
+// newIndex returns the index of a map, looking up the values in it.
+func newIndex(m map[string]any) *Index {
+	return New(mapKeys(m), func(key []byte) (any, bool) {
+		v, ok := m[string(key)]
+		return v, ok
+	})
+}
+
+// scanPrefix returns the entries yielded by PrefixScan.
+func scanPrefix(x *Index, prefix []byte) map[string]any {
+	res := make(map[string]any)
+	for k, v := range x.PrefixScan(prefix) {
+		res[string(k)] = v
+	}
+	return res
+}
+
+func TestIndex(t *testing.T) {
+	t.Run("keys with prefix; should yield them in order", func(t *testing.T) {
+		m := make(map[string]any)
+		for _, k := range []string{"user:2", "order:1", "user:10", "user:1", "use", "users"} {
+			m[k] = k
+		}
+
+		var keys []string
+		for k, v := range newIndex(m).PrefixScan([]byte("user:")) {
+			assert.Equal(t, string(k), v)
+			keys = append(keys, string(k))
+		}
+
+		assert.Equal(t, []string{"user:1", "user:10", "user:2"}, keys)
+	})
+
+	t.Run("empty prefix; should yield all keys", func(t *testing.T) {
+		m := make(map[string]any)
+		for i := 0; i < 1000; i++ {
+			m[fmt.Sprint("key", i)] = i
+		}
+		x := newIndex(m)
+
+		assert.Equal(t, 1000, x.Len())
+		assert.Equal(t, m, scanPrefix(x, nil))
+	})
+
+	t.Run("duplicate keys; should index them once", func(t *testing.T) {
+		keys := func(yield func([]byte, any) bool) {
+			_ = yield([]byte("key"), 1) && yield([]byte("key"), 2)
+		}
+
+		assert.Equal(t, 1, New(keys, nil).Len())
+	})
+
+	t.Run("values changed after build; should see updates and removals only", func(t *testing.T) {
+		live := map[string]any{"key1": 10, "key3": 3} // key1 is updated, key2 is removed, key3 is new
+		x := New(mapKeys(map[string]any{"key1": 1, "key2": 2}), func(key []byte) (any, bool) {
+			v, ok := live[string(key)]
+			return v, ok
+		})
+
+		assert.Equal(t, map[string]any{"key1": 10}, scanPrefix(x, []byte("key")))
+	})
+
+	t.Run("break iteration; should stop", func(t *testing.T) {
+		x := newIndex(map[string]any{"key1": 1, "key2": 2})
+
+		var n int
+		for range x.PrefixScan([]byte("key")) {
+			n++
+			break
+		}
+
+		assert.Equal(t, 1, n)
+	})
+}
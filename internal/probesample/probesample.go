@@ -0,0 +1,59 @@
+// Package probesample provides the ring buffer of the sampled probe traces, shared by the table implementations.
+package probesample
+
+import (
+	"slices"
+)
+
+// Sampler records the traces of every n-th operation into a ring buffer of the latest ones.
+//
+// Not safe for concurrent use.
+type Sampler[S any] struct {
+	every   int
+	counted int // Operations counted for every
+	ring    []S
+	next    int // Index of the oldest sample, the next one overwrites it
+	full    bool
+}
+
+// New creates a new sampler tracing every n-th operation and keeping size latest traces. Panics if any argument is
+// not positive.
+func New[S any](n, size int) *Sampler[S] {
+	if n <= 0 || size <= 0 {
+		panic("n and size must be positive")
+	}
+	return &Sampler[S]{every: n, ring: make([]S, size)}
+}
+
+// Samples returns the recorded traces, the oldest first.
+func (s *Sampler[S]) Samples() []S {
+	if !s.full {
+		return slices.Clone(s.ring[:s.next])
+	}
+	return slices.Concat(s.ring[s.next:], s.ring[:s.next])
+}
+
+// Reset drops the recorded traces.
+func (s *Sampler[S]) Reset() {
+	clear(s.ring)
+	s.counted, s.next, s.full = 0, 0, false
+}
+
+// Sample returns the sampler if an operation should record its trace, or nil otherwise. The sampler may be nil.
+func (s *Sampler[S]) Sample() *Sampler[S] {
+	if s != nil {
+		if s.counted++; s.counted%s.every == 0 {
+			return s
+		}
+	}
+	return nil
+}
+
+// Add records a finished trace, overwriting the oldest one if the buffer is full.
+func (s *Sampler[S]) Add(sample S) {
+	s.ring[s.next] = sample
+	s.next++
+	if s.next == len(s.ring) {
+		s.next, s.full = 0, true
+	}
+}
@@ -0,0 +1,55 @@
+package probesample
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	t.Run("sample every 2nd operation; should keep the latest traces", func(t *testing.T) {
+		s := New[int](2, 3)
+
+		for i := 0; i < 10; i++ {
+			if sampled := s.Sample(); sampled != nil {
+				sampled.Add(i)
+			}
+		}
+
+		assert.Equal(t, []int{5, 7, 9}, s.Samples())
+	})
+
+	t.Run("buffer not full; should return the traces recorded so far", func(t *testing.T) {
+		s := New[int](1, 3)
+
+		s.Sample().Add(1)
+		s.Sample().Add(2)
+
+		assert.Equal(t, []int{1, 2}, s.Samples())
+	})
+
+	t.Run("reset; should drop the traces and restart counting", func(t *testing.T) {
+		s := New[int](2, 2)
+		for i := 0; i < 5; i++ {
+			if sampled := s.Sample(); sampled != nil {
+				sampled.Add(i)
+			}
+		}
+
+		s.Reset()
+
+		assert.Empty(t, s.Samples())
+		assert.Nil(t, s.Sample())
+		assert.NotNil(t, s.Sample())
+	})
+
+	t.Run("nil sampler; should not sample", func(t *testing.T) {
+		var s *Sampler[int]
+
+		assert.Nil(t, s.Sample())
+	})
+
+	t.Run("not positive arguments; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { New[int](0, 1) })
+		assert.Panics(t, func() { New[int](1, 0) })
+	})
+}
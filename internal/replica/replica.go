@@ -0,0 +1,113 @@
+// Package replica provides a read-only table copy fed with the mutations of a primary table, shared by the table
+// implementations.
+package replica
+
+import (
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/internal/mutation"
+	"iter"
+	"sync"
+)
+
+// ErrGap is returned by Replica.Apply if a mutation does not follow the last applied one, and by Replica.CatchUp if
+// the log lost the mutations after it.
+var ErrGap = mutation.ErrGap
+
+// Table is a table keeping the replica entries.
+type Table interface {
+	Apply(m mutation.Mutation) error
+	Get(key []byte) (any, bool)
+	GetVersioned(key []byte) (any, uint64, bool)
+	Len() int
+	All() iter.Seq2[[]byte, any]
+}
+
+// Replica is a read-only copy of a table, fed with the mutations of the primary table. It's safe for concurrent use,
+// so the mutations may be applied from another goroutine.
+type Replica[T Table] struct {
+	mu    sync.Mutex
+	table T
+	seq   uint64 // Seq of the last applied mutation
+}
+
+// New creates a new replica keeping the entries in the given empty table. The replica must get the mutations
+// from the first one, since the primary table was created.
+func New[T Table](table T) *Replica[T] {
+	return &Replica[T]{table: table}
+}
+
+// Apply applies a mutation of the primary table. Returns ErrGap if the mutation Seq does not follow the last applied
+// one, e.g. if some mutations were lost. The replica is not changed then.
+func (r *Replica[T]) Apply(m mutation.Mutation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m.Seq != r.seq+1 {
+		return fmt.Errorf("%w: mutation %d after %d", ErrGap, m.Seq, r.seq)
+	}
+	if err := r.table.Apply(m); err != nil {
+		return err
+	}
+	r.seq = m.Seq
+	return nil
+}
+
+// CatchUp applies the mutations recorded by the log after the last applied one, e.g. to resume the feed after
+// a reconnect. Returns ErrGap if the log has dropped some of them, the replica has to be rebuilt then.
+func (r *Replica[T]) CatchUp(log *mutation.Log) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ms, err := log.Since(r.seq)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if err = r.table.Apply(m); err != nil {
+			return err
+		}
+		r.seq = m.Seq
+	}
+	return nil
+}
+
+// Seq returns the Seq of the last applied mutation, 0 if none.
+func (r *Replica[T]) Seq() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.seq
+}
+
+// Get returns a value for a key.
+func (r *Replica[T]) Get(key []byte) (any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.table.Get(key)
+}
+
+// GetVersioned returns a value and its version for a key. The versions are equal to the primary table ones.
+func (r *Replica[T]) GetVersioned(key []byte) (any, uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.table.GetVersioned(key)
+}
+
+// Len returns the number of entries. The deleted entries are counted until the replica table purges them to get room
+// for new ones, so it may differ from the primary table Len.
+func (r *Replica[T]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.table.Len()
+}
+
+// All returns an iterator over the entries. The replica is locked during iteration, so the mutations are not applied
+// until it's done.
+func (r *Replica[T]) All() iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for k, v := range r.table.All() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package replica
+
+import (
+	"errors"
+	"github.com/bdragon300/elastic-funnel-hash/internal/mutation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iter"
+	"testing"
+)
+
+type mapTable map[string]any
+
+func (t mapTable) Apply(m mutation.Mutation) error {
+	switch m.Op {
+	case mutation.Insert, mutation.Update:
+		t[string(m.Key)] = m.Value
+	case mutation.Delete:
+		delete(t, string(m.Key))
+	case mutation.Clear:
+		clear(t)
+	default:
+		return errors.New("unknown mutation")
+	}
+	return nil
+}
+
+func (t mapTable) Get(key []byte) (any, bool) {
+	v, ok := t[string(key)]
+	return v, ok
+}
+
+func (t mapTable) GetVersioned(key []byte) (any, uint64, bool) {
+	v, ok := t.Get(key)
+	return v, 0, ok
+}
+
+func (t mapTable) Len() int {
+	return len(t)
+}
+
+func (t mapTable) All() iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		for k, v := range t {
+			if !yield([]byte(k), v) {
+				return
+			}
+		}
+	}
+}
+
+func TestReplica(t *testing.T) {
+	t.Run("mutations in order; should be applied", func(t *testing.T) {
+		replica := New(mapTable{})
+
+		require.NoError(t, replica.Apply(mutation.Mutation{Op: mutation.Insert, Key: []byte("a"), Value: 1, Seq: 1}))
+		require.NoError(t, replica.Apply(mutation.Mutation{Op: mutation.Insert, Key: []byte("b"), Value: 2, Seq: 2}))
+		require.NoError(t, replica.Apply(mutation.Mutation{Op: mutation.Delete, Key: []byte("a"), Seq: 3}))
+
+		got := make(map[string]any)
+		for k, v := range replica.All() {
+			got[string(k)] = v
+		}
+		assert.Equal(t, map[string]any{"b": 2}, got)
+		assert.Equal(t, 1, replica.Len())
+		assert.Equal(t, uint64(3), replica.Seq())
+	})
+
+	t.Run("lost mutation; should return ErrGap", func(t *testing.T) {
+		replica := New(mapTable{})
+		require.NoError(t, replica.Apply(mutation.Mutation{Op: mutation.Insert, Key: []byte("a"), Value: 1, Seq: 1}))
+
+		err := replica.Apply(mutation.Mutation{Op: mutation.Insert, Key: []byte("b"), Value: 2, Seq: 3})
+
+		assert.ErrorIs(t, err, ErrGap)
+		_, ok := replica.Get([]byte("b"))
+		assert.False(t, ok)
+		assert.Equal(t, uint64(1), replica.Seq())
+	})
+
+	t.Run("failed mutation; should not advance Seq", func(t *testing.T) {
+		replica := New(mapTable{})
+
+		assert.Error(t, replica.Apply(mutation.Mutation{Op: mutation.Op(10), Seq: 1}))
+		assert.Equal(t, uint64(0), replica.Seq())
+		assert.Empty(t, replica.table)
+	})
+
+	t.Run("feed broken; should catch up from the log", func(t *testing.T) {
+		replica := New(mapTable{})
+		log := mutation.NewLog(10)
+		for seq := uint64(1); seq <= 5; seq++ {
+			m := mutation.Mutation{Op: mutation.Insert, Key: []byte{byte(seq)}, Value: seq, Seq: seq}
+			log.Record(m)
+			if seq <= 2 {
+				require.NoError(t, replica.Apply(m)) // The feed breaks after the 2nd mutation
+			}
+		}
+
+		require.NoError(t, replica.CatchUp(log))
+
+		assert.Equal(t, uint64(5), replica.Seq())
+		assert.Equal(t, 5, replica.Len())
+		require.NoError(t, replica.CatchUp(log))
+	})
+
+	t.Run("log dropped the mutations; should return ErrGap", func(t *testing.T) {
+		replica := New(mapTable{})
+		log := mutation.NewLog(2)
+		for seq := uint64(1); seq <= 5; seq++ {
+			log.Record(mutation.Mutation{Op: mutation.Insert, Key: []byte{byte(seq)}, Seq: seq})
+		}
+
+		assert.ErrorIs(t, replica.CatchUp(log), ErrGap)
+		assert.Zero(t, replica.Seq())
+	})
+}
@@ -0,0 +1,112 @@
+// Package sketch provides the count-min sketch of the key frequencies, shared by the table implementations.
+package sketch
+
+import (
+	"cmp"
+	"github.com/bdragon300/elastic-funnel-hash/internal/hasher"
+	"iter"
+	"math"
+	"slices"
+)
+
+// Sketch is a count-min sketch of the key frequencies, that keeps the approximately hottest keys. The counts are
+// overestimated by at most 2N/width with probability 1-(1/2)^depth, where N is the number of added keys.
+//
+// Not safe for concurrent use.
+type Sketch struct {
+	width  int
+	counts [][]uint32 // Rows of width counters
+	k      int
+	top    map[string]uint32 // Up to k hottest keys seen with their estimated counts
+}
+
+// HotKey is a key with its estimated access count.
+type HotKey struct {
+	Key   string `json:"key"`
+	Count uint32 `json:"count"`
+}
+
+// New creates a new sketch of depth rows of width counters, that keeps k hottest keys. Panics if any argument
+// is not positive.
+func New(width, depth, k int) *Sketch {
+	if width <= 0 || depth <= 0 || k <= 0 {
+		panic("width, depth and k must be positive")
+	}
+	counts := make([][]uint32, depth)
+	for i := range counts {
+		counts[i] = make([]uint32, width)
+	}
+	return &Sketch{width: width, counts: counts, k: k, top: make(map[string]uint32, k+1)}
+}
+
+// Add counts a key access and returns its estimated count.
+func (s *Sketch) Add(key []byte) uint32 {
+	est := uint32(math.MaxUint32)
+	for i, idx := range s.indexes(key) {
+		row := s.counts[i]
+		if row[idx] < math.MaxUint32 {
+			row[idx]++
+		}
+		est = min(est, row[idx])
+	}
+
+	if _, ok := s.top[string(key)]; ok || len(s.top) < s.k {
+		s.top[string(key)] = est
+		return est
+	}
+	// Replace the coldest of the hottest keys, if the key is hotter
+	coldest, count := "", est
+	for k, c := range s.top {
+		if c < count {
+			coldest, count = k, c
+		}
+	}
+	if count < est {
+		delete(s.top, coldest)
+		s.top[string(key)] = est
+	}
+	return est
+}
+
+// Estimate returns the estimated access count of a key.
+func (s *Sketch) Estimate(key []byte) uint32 {
+	est := uint32(math.MaxUint32)
+	for i, idx := range s.indexes(key) {
+		est = min(est, s.counts[i][idx])
+	}
+	return est
+}
+
+// TopK returns the hottest keys, up to k, from the hottest one.
+func (s *Sketch) TopK() []HotKey {
+	res := make([]HotKey, 0, len(s.top))
+	for k, c := range s.top {
+		res = append(res, HotKey{Key: k, Count: c})
+	}
+	slices.SortFunc(res, func(a, b HotKey) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	return res
+}
+
+// Reset zeroes the counters and forgets the hottest keys, e.g. to start a new observation window.
+func (s *Sketch) Reset() {
+	for _, row := range s.counts {
+		clear(row)
+	}
+	clear(s.top)
+}
+
+// indexes returns an iterator over the counter indexes of a key in every row. The indexes are derived from a single
+// hash by double hashing.
+func (s *Sketch) indexes(key []byte) iter.Seq2[int, int] {
+	h := hasher.Wyhash(0, key)
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	return func(yield func(int, int) bool) {
+		for i := range s.counts {
+			if !yield(i, int((h1+uint64(i)*h2)%uint64(s.width))) {
+				return
+			}
+		}
+	}
+}
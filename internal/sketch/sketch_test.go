@@ -0,0 +1,62 @@
+package sketch
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	t.Run("skewed accesses; should return the hottest keys", func(t *testing.T) {
+		s := New(1024, 4, 3)
+		rnd := rand.New(rand.NewPCG(1, 2))
+		for i := 0; i < 10000; i++ {
+			s.Add([]byte(fmt.Sprint("cold-", rnd.IntN(1000))))
+			if i%10 == 0 {
+				s.Add([]byte("hot-1"))
+			}
+			if i%20 == 0 {
+				s.Add([]byte("hot-2"))
+			}
+			if i%40 == 0 {
+				s.Add([]byte("hot-3"))
+			}
+		}
+
+		top := s.TopK()
+
+		require.Len(t, top, 3)
+		assert.Equal(t, []string{"hot-1", "hot-2", "hot-3"}, []string{top[0].Key, top[1].Key, top[2].Key})
+		assert.GreaterOrEqual(t, top[0].Count, uint32(1000))
+		assert.GreaterOrEqual(t, s.Estimate([]byte("hot-2")), uint32(500))
+	})
+
+	t.Run("estimate; should not be less than the count", func(t *testing.T) {
+		s := New(16, 2, 1)
+		for i := 0; i < 100; i++ {
+			for j := 0; j <= i%10; j++ {
+				s.Add([]byte(fmt.Sprint(i % 10)))
+			}
+		}
+
+		for i := 0; i < 10; i++ {
+			assert.GreaterOrEqual(t, s.Estimate([]byte(fmt.Sprint(i))), uint32(10*(i+1)))
+		}
+	})
+
+	t.Run("reset; should forget the keys", func(t *testing.T) {
+		s := New(16, 2, 1)
+		s.Add([]byte("key"))
+
+		s.Reset()
+
+		assert.Zero(t, s.Estimate([]byte("key")))
+		assert.Empty(t, s.TopK())
+	})
+
+	t.Run("invalid size; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { New(0, 1, 1) })
+	})
+}
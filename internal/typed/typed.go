@@ -0,0 +1,97 @@
+// Package typed provides the typed map wrappers over a table, shared by the table implementations.
+package typed
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// Table is a table the maps are built on.
+type Table interface {
+	Set(key []byte, value any) bool
+	Get(key []byte) (any, bool)
+	Len() int
+}
+
+// StringMap is a table with string keys and values of type V.
+type StringMap[T Table, V any] struct {
+	t T
+}
+
+// NewStringMap creates a new StringMap over a table.
+func NewStringMap[T Table, V any](t T) StringMap[T, V] {
+	return StringMap[T, V]{t: t}
+}
+
+// Set sets a value for a key, see HashTable.Set.
+func (m *StringMap[T, V]) Set(key string, value V) bool {
+	return m.t.Set([]byte(key), value)
+}
+
+// Get returns a value for a key. The key is not copied.
+func (m *StringMap[T, V]) Get(key string) (V, bool) {
+	// Lookups do not keep the key, so it is safe to look at the string bytes directly
+	v, ok := m.t.Get(unsafe.Slice(unsafe.StringData(key), len(key)))
+	return value[V](v, ok)
+}
+
+// Len returns the number of elements in the map.
+func (m *StringMap[T, V]) Len() int {
+	return m.t.Len()
+}
+
+// Table returns the underlying hash table, e.g. to set hooks or policies.
+func (m *StringMap[T, V]) Table() T {
+	return m.t
+}
+
+// Uint64Map is a table with uint64 keys and values of type V. The table should hash the keys with Uint64Hasher.
+type Uint64Map[T Table, V any] struct {
+	t T
+}
+
+// NewUint64Map creates a new Uint64Map over a table.
+func NewUint64Map[T Table, V any](t T) Uint64Map[T, V] {
+	return Uint64Map[T, V]{t: t}
+}
+
+// Set sets a value for a key, see HashTable.Set.
+func (m *Uint64Map[T, V]) Set(key uint64, value V) bool {
+	return m.t.Set(binary.LittleEndian.AppendUint64(nil, key), value)
+}
+
+// Get returns a value for a key.
+func (m *Uint64Map[T, V]) Get(key uint64) (V, bool) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], key)
+	v, ok := m.t.Get(b[:])
+	return value[V](v, ok)
+}
+
+// Len returns the number of elements in the map.
+func (m *Uint64Map[T, V]) Len() int {
+	return m.t.Len()
+}
+
+// Table returns the underlying hash table, e.g. to set hooks or policies.
+func (m *Uint64Map[T, V]) Table() T {
+	return m.t
+}
+
+// Uint64Hasher returns a hasher of 8-byte keys, that mixes the bits as splitmix64 finalizer does.
+func Uint64Hasher(seed uint64) func(b []byte) uint64 {
+	return func(b []byte) uint64 {
+		x := binary.LittleEndian.Uint64(b) ^ seed
+		x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+		x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+		return x ^ x>>31
+	}
+}
+
+func value[V any](v any, ok bool) (V, bool) {
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return v.(V), true
+}
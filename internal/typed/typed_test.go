@@ -0,0 +1,115 @@
+package typed
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+// mapTable is a Table over a Go map.
+type mapTable map[string]any
+
+func (m mapTable) Set(key []byte, value any) bool {
+	_, ok := m[string(key)]
+	m[string(key)] = value
+	return ok
+}
+
+func (m mapTable) Get(key []byte) (any, bool) {
+	v, ok := m[string(key)]
+	return v, ok
+}
+
+func (m mapTable) Len() int {
+	return len(m)
+}
+
+func TestStringMap(t *testing.T) {
+	t.Run("set and get; should return typed values", func(t *testing.T) {
+		m := NewStringMap[mapTable, int](mapTable{})
+		for i := 0; i < 100; i++ {
+			assert.False(t, m.Set(strconv.Itoa(i), i))
+		}
+
+		assert.Equal(t, 100, m.Len())
+		for i := 0; i < 100; i++ {
+			v, ok := m.Get(strconv.Itoa(i))
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+		v, ok := m.Get("missing")
+		assert.False(t, ok)
+		assert.Zero(t, v)
+		assert.True(t, m.Set("0", -1))
+		v, _ = m.Get("0")
+		assert.Equal(t, -1, v)
+		assert.Equal(t, -1, m.Table()["0"])
+	})
+}
+
+func TestUint64Map(t *testing.T) {
+	t.Run("set and get; should return typed values", func(t *testing.T) {
+		m := NewUint64Map[mapTable, string](mapTable{})
+		for i := uint64(0); i < 100; i++ {
+			assert.False(t, m.Set(i*7919, strconv.FormatUint(i, 10)))
+		}
+
+		assert.Equal(t, 100, m.Len())
+		for i := uint64(0); i < 100; i++ {
+			v, ok := m.Get(i * 7919)
+			assert.True(t, ok)
+			assert.Equal(t, strconv.FormatUint(i, 10), v)
+		}
+		v, ok := m.Get(1)
+		assert.False(t, ok)
+		assert.Zero(t, v)
+	})
+
+	t.Run("nested lookups; should not share the key buffer", func(t *testing.T) {
+		var m Uint64Map[*reentrantTable, int]
+		m = NewUint64Map[*reentrantTable, int](&reentrantTable{
+			mapTable: mapTable{},
+			onGet:    func() { m.Get(2) }, // E.g. a hook looking up another key
+		})
+		m.Set(1, 1)
+
+		v, ok := m.Get(1)
+
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+}
+
+func TestUint64Hasher(t *testing.T) {
+	t.Run("keys differing in one bit; should differ in about half of the hash bits", func(t *testing.T) {
+		h := Uint64Hasher(42)
+		var b1, b2 [8]byte
+		var flipped int
+		for i := 0; i < 64; i++ {
+			b2 = b1
+			b2[i/8] ^= 1 << (i % 8)
+			x := h(b1[:]) ^ h(b2[:])
+			for ; x != 0; x &= x - 1 {
+				flipped++
+			}
+		}
+
+		assert.InDelta(t, 32, float64(flipped)/64, 4)
+	})
+}
+
+// reentrantTable calls onGet before every lookup, once at a time.
+type reentrantTable struct {
+	mapTable
+	onGet func()
+	in    bool
+}
+
+func (r *reentrantTable) Get(key []byte) (any, bool) {
+	if !r.in {
+		r.in = true
+		r.onGet()
+		r.in = false
+	}
+	return r.mapTable.Get(key)
+}
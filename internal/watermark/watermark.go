@@ -0,0 +1,15 @@
+// Package watermark reports the load factor crossing the levels, shared by the table implementations.
+package watermark
+
+// Cross calls fn for every level crossed by the load factor changed from prev to cur. The rising is true if it has
+// grown above the level.
+func Cross(levels []float64, prev, cur float64, fn func(level float64, rising bool)) {
+	for _, level := range levels {
+		switch {
+		case prev < level && cur >= level:
+			fn(level, true)
+		case prev >= level && cur < level:
+			fn(level, false)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package watermark
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCross(t *testing.T) {
+	type crossing struct {
+		level  float64
+		rising bool
+	}
+	levels := []float64{0.25, 0.5, 0.75}
+
+	t.Run("load factor grows; should report the levels reached", func(t *testing.T) {
+		var crossings []crossing
+		record := func(level float64, rising bool) { crossings = append(crossings, crossing{level, rising}) }
+
+		Cross(levels, 0.2, 0.5, record)
+
+		assert.Equal(t, []crossing{{0.25, true}, {0.5, true}}, crossings)
+	})
+
+	t.Run("load factor drops; should report the levels left", func(t *testing.T) {
+		var crossings []crossing
+		record := func(level float64, rising bool) { crossings = append(crossings, crossing{level, rising}) }
+
+		Cross(levels, 0.75, 0.6, record)
+
+		assert.Equal(t, []crossing{{0.75, false}}, crossings)
+	})
+
+	t.Run("load factor within levels; should report nothing", func(t *testing.T) {
+		Cross(levels, 0.3, 0.4, func(float64, bool) { t.Fail() })
+	})
+}
@@ -0,0 +1,124 @@
+// Package kvhttp serves a funnel table as a key-value store over HTTP, for tests and small internal services:
+//
+//	GET /keys/{key}     returns the value, 404 if the key does not exist
+//	PUT /keys/{key}     sets the value to the request body, 201 if the key is new, 204 otherwise
+//	DELETE /keys/{key}  deletes the key, 204 on success, 404 if the key does not exist
+//	GET /metrics        returns the table metrics in OpenMetrics text format
+//
+// The table is kept in memory. Use SaveSnapshot on shutdown and LoadSnapshot on start to persist it across restarts.
+package kvhttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const defaultMaxValueBytes = 1 << 20
+
+// Handler is an http.Handler serving a table. The values are kept as []byte. The table is guarded by a mutex, so it
+// must not be used directly while the handler serves requests.
+type Handler struct {
+	MaxValueBytes int64  // Longest value accepted by PUT, 1 MiB if zero
+	Name          string // Table name in the metrics labels, "kv" if empty
+
+	mu    sync.Mutex
+	table *funnel.HashTable
+	mux   *http.ServeMux
+}
+
+// NewHandler creates a new handler serving the table.
+func NewHandler(table *funnel.HashTable) *Handler {
+	h := &Handler{table: table, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /keys/{key}", h.get)
+	h.mux.HandleFunc("PUT /keys/{key}", h.put)
+	h.mux.HandleFunc("DELETE /keys/{key}", h.delete)
+	h.mux.HandleFunc("GET /metrics", h.metrics)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	v, ok := h.table.Get([]byte(r.PathValue("key")))
+	h.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		http.Error(w, fmt.Sprintf("value of type %T is not bytes", v), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(b)
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	limit := h.MaxValueBytes
+	if limit <= 0 {
+		limit = defaultMaxValueBytes
+	}
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	updated, err := h.table.TrySet([]byte(r.PathValue("key")), value)
+	h.mu.Unlock()
+	switch {
+	case errors.Is(err, funnel.ErrFull):
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case updated:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	ok := h.table.Delete([]byte(r.PathValue("key")))
+	h.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) metrics(w http.ResponseWriter, _ *http.Request) {
+	name := h.Name
+	if name == "" {
+		name = "kv"
+	}
+	// The metrics are rendered under the lock, and sent after it's released, so a slow client does not block the
+	// other requests
+	var buf bytes.Buffer
+	h.mu.Lock()
+	err := h.table.WriteOpenMetrics(&buf, name)
+	h.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	w.Write(buf.Bytes())
+}
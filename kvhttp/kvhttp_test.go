@@ -0,0 +1,119 @@
+package kvhttp
+
+import (
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func do(t *testing.T, h http.Handler, method, path, body string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	b, err := io.ReadAll(rec.Result().Body)
+	require.NoError(t, err)
+	return rec.Code, string(b)
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("put, get and delete key; should return the stored value", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+
+		code, _ := do(t, h, http.MethodPut, "/keys/a", "1")
+		assert.Equal(t, http.StatusCreated, code)
+		code, _ = do(t, h, http.MethodPut, "/keys/a", "2")
+		assert.Equal(t, http.StatusNoContent, code)
+		code, body := do(t, h, http.MethodGet, "/keys/a", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2", body)
+
+		code, _ = do(t, h, http.MethodDelete, "/keys/a", "")
+		assert.Equal(t, http.StatusNoContent, code)
+		code, _ = do(t, h, http.MethodGet, "/keys/a", "")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = do(t, h, http.MethodDelete, "/keys/a", "")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("value longer than limit; should reject it", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+		h.MaxValueBytes = 3
+
+		code, _ := do(t, h, http.MethodPut, "/keys/a", "1234")
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	})
+
+	t.Run("table full of deleted keys; should free their slots and set the key", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+		code, n := http.StatusCreated, 0
+		for ; code == http.StatusCreated; n++ {
+			code, _ = do(t, h, http.MethodPut, fmt.Sprint("/keys/", n), "v")
+		}
+		require.Equal(t, http.StatusInsufficientStorage, code)
+		for i := 0; i < n; i++ {
+			do(t, h, http.MethodDelete, fmt.Sprint("/keys/", i), "")
+		}
+		_, metrics := do(t, h, http.MethodGet, "/metrics", "")
+
+		code, _ = do(t, h, http.MethodPut, "/keys/new", "v")
+
+		assert.Contains(t, metrics, `efh_entries{table="kv"} 0`)
+		assert.Equal(t, http.StatusCreated, code)
+	})
+
+	t.Run("table full; should return insufficient storage", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+		code := http.StatusCreated
+		for i := 0; code == http.StatusCreated; i++ {
+			code, _ = do(t, h, http.MethodPut, fmt.Sprint("/keys/", i), "v")
+		}
+
+		assert.Equal(t, http.StatusInsufficientStorage, code)
+	})
+
+	t.Run("metrics; should be labeled with the table name", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+		h.Name = "cache"
+		do(t, h, http.MethodPut, "/keys/a", "1")
+
+		code, body := do(t, h, http.MethodGet, "/metrics", "")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `efh_entries{table="cache"} 1`)
+	})
+
+	t.Run("concurrent requests; should keep all keys", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(1000))
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					key := fmt.Sprintf("/keys/%d-%d", g, i)
+					rec := httptest.NewRecorder()
+					h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, key, strings.NewReader(key)))
+					h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, key, nil))
+				}
+			}()
+		}
+		wg.Wait()
+
+		for g := 0; g < 4; g++ {
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("/keys/%d-%d", g, i)
+				code, body := do(t, h, http.MethodGet, key, "")
+				require.Equal(t, http.StatusOK, code, "key: %v", key)
+				assert.Equal(t, key, body)
+			}
+		}
+	})
+}
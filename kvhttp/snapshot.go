@@ -0,0 +1,127 @@
+package kvhttp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// snapshotMagic starts a snapshot, so a file of another format is not loaded.
+const snapshotMagic = "EFHKV\x01"
+
+// maxChunk is the longest key or value read from a snapshot, so a corrupted length does not allocate too much.
+const maxChunk = 1 << 30
+
+// ErrSnapshotFormat is returned by ReadSnapshot if the data is not a snapshot or is truncated.
+var ErrSnapshotFormat = errors.New("bad snapshot format")
+
+// WriteSnapshot writes all table entries to w, see ReadSnapshot. The entries are encoded under the lock, and written
+// after it's released, so a slow w does not block the requests.
+func (h *Handler) WriteSnapshot(w io.Writer) error {
+	b, err := h.snapshot()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadSnapshot sets the entries written by WriteSnapshot to the table. The entries set before an error are kept.
+func (h *Handler) ReadSnapshot(r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return ErrSnapshotFormat
+	}
+	for {
+		key, err := readChunk(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err := readChunk(br)
+		if err != nil {
+			return ErrSnapshotFormat // A key without a value
+		}
+		h.mu.Lock()
+		_, err = h.table.TrySet(key, value)
+		h.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("set key %q: %w", key, err)
+		}
+	}
+}
+
+// SaveSnapshot writes a snapshot to the file at path. The file is replaced atomically, so a crash in the middle
+// keeps the previous snapshot.
+func (h *Handler) SaveSnapshot(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op after rename
+	if err = h.WriteSnapshot(f); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadSnapshot reads a snapshot from the file at path, see ReadSnapshot. If there is no file yet, the error wraps
+// os.ErrNotExist.
+func (h *Handler) LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return h.ReadSnapshot(f)
+}
+
+// snapshot returns the encoded table entries. Every key and value is prefixed by its length as uvarint.
+func (h *Handler) snapshot() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := []byte(snapshotMagic)
+	for k, v := range h.table.All() {
+		value, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("value of key %q of type %T is not bytes", k, v)
+		}
+		b = binary.AppendUvarint(b, uint64(len(k)))
+		b = append(b, k...)
+		b = binary.AppendUvarint(b, uint64(len(value)))
+		b = append(b, value...)
+	}
+	return b, nil
+}
+
+// readChunk reads a length-prefixed chunk. It returns io.EOF if there are no more chunks.
+func readChunk(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, ErrSnapshotFormat
+	}
+	if n > maxChunk {
+		return nil, ErrSnapshotFormat
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, ErrSnapshotFormat
+	}
+	return b, nil
+}
@@ -0,0 +1,62 @@
+package kvhttp
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("save and load snapshot; should restore all keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "kv.snap")
+		h := NewHandler(funnel.NewHashTableDefault(100))
+		for i := 0; i < 50; i++ {
+			do(t, h, http.MethodPut, fmt.Sprint("/keys/", i), fmt.Sprint("value", i))
+		}
+		do(t, h, http.MethodPut, "/keys/empty", "")
+
+		require.NoError(t, h.SaveSnapshot(path))
+		restored := NewHandler(funnel.NewHashTableDefault(100))
+		require.NoError(t, restored.LoadSnapshot(path))
+
+		for i := 0; i < 50; i++ {
+			code, body := do(t, restored, http.MethodGet, fmt.Sprint("/keys/", i), "")
+			require.Equal(t, http.StatusOK, code, "key: %v", i)
+			assert.Equal(t, fmt.Sprint("value", i), body)
+		}
+		code, body := do(t, restored, http.MethodGet, "/keys/empty", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, body)
+	})
+
+	t.Run("no snapshot file; should return not exist error", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+
+		assert.ErrorIs(t, h.LoadSnapshot(filepath.Join(t.TempDir(), "missing")), os.ErrNotExist)
+	})
+
+	t.Run("truncated snapshot; should return format error", func(t *testing.T) {
+		h := NewHandler(funnel.NewHashTableDefault(100))
+		do(t, h, http.MethodPut, "/keys/a", "value")
+		var buf bytes.Buffer
+		require.NoError(t, h.WriteSnapshot(&buf))
+
+		err := NewHandler(funnel.NewHashTableDefault(100)).ReadSnapshot(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+
+		assert.ErrorIs(t, err, ErrSnapshotFormat)
+		assert.ErrorIs(t, NewHandler(funnel.NewHashTableDefault(100)).ReadSnapshot(bytes.NewReader(nil)), ErrSnapshotFormat)
+	})
+
+	t.Run("value of another type; should return error", func(t *testing.T) {
+		table := funnel.NewHashTableDefault(100)
+		table.Insert([]byte("a"), 1)
+
+		assert.ErrorContains(t, NewHandler(table).WriteSnapshot(&bytes.Buffer{}), "is not bytes")
+	})
+}
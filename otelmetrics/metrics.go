@@ -0,0 +1,228 @@
+// Package otelmetrics records the funnel and elastic hash tables metrics with OpenTelemetry.
+//
+// It is a separate module, so the main module does not depend on OpenTelemetry.
+//
+// Recorded instruments, every measurement has the "efh.table" attribute with the table name:
+//
+//   - efh.operations: counter of operations with "efh.op" (insert, lookup) and "efh.result" (ok, fail) attributes
+//   - efh.probes: histogram of slots checked by an operation, with the "efh.op" attribute
+//   - efh.entries: gauge of entries in a table
+//   - efh.capacity: gauge of a table capacity
+//   - efh.occupancy: gauge of entries to capacity ratio
+//   - efh.layer.entries: gauge of entries in a funnel table layer, with the "efh.layer" attribute
+//   - efh.spill_rate: gauge of the fraction of funnel table inserts placed into the overflow layers
+//   - efh.layer.latency.count: counter of sampled layer probings, with the "efh.layer" attribute (the funnel table
+//     layer, or the elastic table bank in pair, "bank1" or "bank2"). See HashTable.LatencySample
+//   - efh.layer.latency.total: counter of the total time of sampled layer probings in seconds, with the "efh.layer"
+//     attribute
+package otelmetrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"time"
+)
+
+// FunnelHooks registers the instruments for a funnel table in meter, and returns the hooks recording the operations.
+// Set them to the table.Hooks, or join with other hooks using funnel.JoinHooks.
+func FunnelHooks(meter metric.Meter, name string, table *funnel.HashTable) (*funnel.Hooks, error) {
+	ins, err := newInstruments(meter, name, table)
+	if err != nil {
+		return nil, err
+	}
+	if err = registerFunnelLayers(meter, name, table); err != nil {
+		return nil, err
+	}
+	layers := []funnel.Layer{funnel.LayerBanks, funnel.LayerOverflow1, funnel.LayerOverflow2}
+	err = registerLatency(meter, name, layerNames(layers), func(i int) (int, time.Duration) {
+		lat := table.LayerLatency[layers[i]]
+		return lat.Count, lat.Total
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &funnel.Hooks{
+		Done: func(op funnel.Op, probes int, ok bool) {
+			ins.record(string(op), probes, ok)
+		},
+	}, nil
+}
+
+// ElasticHooks registers the instruments for an elastic table in meter, and returns the hooks recording the operations.
+// Set them to the table.Hooks, or join with other hooks using elastic.JoinHooks.
+func ElasticHooks(meter metric.Meter, name string, table *elastic.HashTable) (*elastic.Hooks, error) {
+	ins, err := newInstruments(meter, name, table)
+	if err != nil {
+		return nil, err
+	}
+	layers := []elastic.Layer{elastic.LayerBank1, elastic.LayerBank2}
+	err = registerLatency(meter, name, layerNames(layers), func(i int) (int, time.Duration) {
+		lat := table.LayerLatency[layers[i]]
+		return lat.Count, lat.Total
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &elastic.Hooks{
+		Done: func(op elastic.Op, probes int, ok bool) {
+			ins.record(string(op), probes, ok)
+		},
+	}, nil
+}
+
+// registerFunnelLayers registers the funnel specific instruments.
+func registerFunnelLayers(meter metric.Meter, name string, table *funnel.HashTable) error {
+	entries, err := meter.Int64ObservableGauge("efh.layer.entries", metric.WithDescription("Funnel hash table layer entries"))
+	if err != nil {
+		return err
+	}
+	spillRate, err := meter.Float64ObservableGauge(
+		"efh.spill_rate",
+		metric.WithDescription("Fraction of funnel hash table inserts placed into the overflow layers"),
+	)
+	if err != nil {
+		return err
+	}
+
+	tableAttr := attribute.String("efh.table", name)
+	layers := []funnel.Layer{funnel.LayerBanks, funnel.LayerOverflow1, funnel.LayerOverflow2}
+	layerAttrs := make([]metric.ObserveOption, len(layers))
+	for i, l := range layers {
+		layerAttrs[i] = metric.WithAttributes(tableAttr, attribute.String("efh.layer", l.String()))
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for i, l := range layers {
+			o.ObserveInt64(entries, int64(table.LayerInserts[l]), layerAttrs[i])
+		}
+		o.ObserveFloat64(spillRate, table.SpillRate(), metric.WithAttributes(tableAttr))
+		return nil
+	}, entries, spillRate)
+	return err
+}
+
+// registerLatency registers the instruments of the sampled layer probing times. latency returns the measured
+// probings count and their total time of a layer by its index in layers.
+func registerLatency(meter metric.Meter, name string, layers []string, latency func(i int) (int, time.Duration)) error {
+	count, err := meter.Int64ObservableCounter(
+		"efh.layer.latency.count",
+		metric.WithDescription("Sampled hash table layer probings"),
+	)
+	if err != nil {
+		return err
+	}
+	total, err := meter.Float64ObservableCounter(
+		"efh.layer.latency.total",
+		metric.WithDescription("Total time of sampled hash table layer probings"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	tableAttr := attribute.String("efh.table", name)
+	layerAttrs := make([]metric.ObserveOption, len(layers))
+	for i, l := range layers {
+		layerAttrs[i] = metric.WithAttributes(tableAttr, attribute.String("efh.layer", l))
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for i := range layers {
+			n, d := latency(i)
+			o.ObserveInt64(count, int64(n), layerAttrs[i])
+			o.ObserveFloat64(total, d.Seconds(), layerAttrs[i])
+		}
+		return nil
+	}, count, total)
+	return err
+}
+
+// layerNames returns the names of the layers.
+func layerNames[L fmt.Stringer](layers []L) []string {
+	res := make([]string, len(layers))
+	for i, l := range layers {
+		res[i] = l.String()
+	}
+	return res
+}
+
+// sizer is a common part of the hash tables needed for gauges.
+type sizer interface {
+	Len() int
+	Cap() int
+}
+
+type instruments struct {
+	ops    metric.Int64Counter
+	probes metric.Int64Histogram
+	// Precomputed attribute sets for every op and result
+	opAttrs     map[string]metric.MeasurementOption
+	resultAttrs map[string][2]metric.AddOption
+}
+
+func newInstruments(meter metric.Meter, name string, table sizer) (*instruments, error) {
+	ops, err := meter.Int64Counter("efh.operations", metric.WithDescription("Hash table operations"))
+	if err != nil {
+		return nil, err
+	}
+	probes, err := meter.Int64Histogram(
+		"efh.probes",
+		metric.WithDescription("Slots checked by a hash table operation"),
+		metric.WithExplicitBucketBoundaries(1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024),
+	)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := meter.Int64ObservableGauge("efh.entries", metric.WithDescription("Hash table entries"))
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := meter.Int64ObservableGauge("efh.capacity", metric.WithDescription("Hash table capacity"))
+	if err != nil {
+		return nil, err
+	}
+	occupancy, err := meter.Float64ObservableGauge("efh.occupancy", metric.WithDescription("Hash table entries to capacity ratio"))
+	if err != nil {
+		return nil, err
+	}
+
+	tableAttr := attribute.String("efh.table", name)
+	observeAttrs := metric.WithAttributes(tableAttr)
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(entries, int64(table.Len()), observeAttrs)
+		o.ObserveInt64(capacity, int64(table.Cap()), observeAttrs)
+		o.ObserveFloat64(occupancy, float64(table.Len())/float64(table.Cap()), observeAttrs)
+		return nil
+	}, entries, capacity, occupancy)
+	if err != nil {
+		return nil, err
+	}
+
+	ins := &instruments{
+		ops:         ops,
+		probes:      probes,
+		opAttrs:     make(map[string]metric.MeasurementOption),
+		resultAttrs: make(map[string][2]metric.AddOption),
+	}
+	for _, op := range []string{string(funnel.OpInsert), string(funnel.OpLookup)} {
+		opAttr := attribute.String("efh.op", op)
+		ins.opAttrs[op] = metric.WithAttributes(tableAttr, opAttr)
+		ins.resultAttrs[op] = [2]metric.AddOption{
+			metric.WithAttributes(tableAttr, opAttr, attribute.String("efh.result", "fail")),
+			metric.WithAttributes(tableAttr, opAttr, attribute.String("efh.result", "ok")),
+		}
+	}
+	return ins, nil
+}
+
+func (ins *instruments) record(op string, probes int, ok bool) {
+	ctx := context.Background()
+	result := 0
+	if ok {
+		result = 1
+	}
+	ins.ops.Add(ctx, 1, ins.resultAttrs[op][result])
+	ins.probes.Record(ctx, int64(probes), ins.opAttrs[op])
+}
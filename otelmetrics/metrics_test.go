@@ -0,0 +1,97 @@
+package otelmetrics
+
+import (
+	"context"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"testing"
+)
+
+func TestFunnelHooks(t *testing.T) {
+	t.Run("insert and lookup; should record operations, probes and gauges", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+		table := funnel.NewHashTableDefault(100)
+		hooks, err := FunnelHooks(meter, "test-table", table)
+		require.NoError(t, err)
+		table.Hooks = hooks
+
+		table.Insert([]byte("key"), 1)
+		table.Get([]byte("key"))
+		table.Get([]byte("missing"))
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		metrics := make(map[string]metricdata.Aggregation)
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m.Data
+		}
+
+		ops := metrics["efh.operations"].(metricdata.Sum[int64])
+		counts := make(map[string]int64)
+		for _, dp := range ops.DataPoints {
+			table, _ := dp.Attributes.Value("efh.table")
+			assert.Equal(t, attribute.StringValue("test-table"), table)
+			op, _ := dp.Attributes.Value("efh.op")
+			result, _ := dp.Attributes.Value("efh.result")
+			counts[op.AsString()+"/"+result.AsString()] = dp.Value
+		}
+		assert.Equal(t, map[string]int64{"insert/ok": 1, "lookup/ok": 1, "lookup/fail": 1}, counts)
+
+		probes := metrics["efh.probes"].(metricdata.Histogram[int64])
+		var total uint64
+		for _, dp := range probes.DataPoints {
+			total += dp.Count
+		}
+		assert.Equal(t, uint64(3), total)
+
+		entries := metrics["efh.entries"].(metricdata.Gauge[int64])
+		assert.Equal(t, int64(1), entries.DataPoints[0].Value)
+		occupancy := metrics["efh.occupancy"].(metricdata.Gauge[float64])
+		assert.InDelta(t, 1.0/float64(table.Cap()), occupancy.DataPoints[0].Value, 1e-9)
+
+		layerEntries := make(map[string]int64)
+		for _, dp := range metrics["efh.layer.entries"].(metricdata.Gauge[int64]).DataPoints {
+			layer, _ := dp.Attributes.Value("efh.layer")
+			layerEntries[layer.AsString()] = dp.Value
+		}
+		assert.Equal(t, map[string]int64{"banks": 1, "overflow1": 0, "overflow2": 0}, layerEntries)
+		spillRate := metrics["efh.spill_rate"].(metricdata.Gauge[float64])
+		assert.Zero(t, spillRate.DataPoints[0].Value)
+	})
+}
+
+func TestLayerLatency(t *testing.T) {
+	t.Run("sampled operations; should record the layer probings", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+		table := elastic.NewHashTableDefault(100)
+		table.LatencySample = 1
+		_, err := ElasticHooks(meter, "test-table", table)
+		require.NoError(t, err)
+
+		table.Insert([]byte("key"), 1)
+		table.Get([]byte("key"))
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		counts := make(map[string]int64)
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name != "efh.layer.latency.count" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				layer, _ := dp.Attributes.Value("efh.layer")
+				counts[layer.AsString()] = dp.Value
+			}
+		}
+		assert.Equal(t, table.LayerLatency[elastic.LayerBank1].Count, int(counts["bank1"]))
+		assert.Equal(t, table.LayerLatency[elastic.LayerBank2].Count, int(counts["bank2"]))
+		assert.Positive(t, counts["bank1"]+counts["bank2"])
+	})
+}
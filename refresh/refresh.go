@@ -0,0 +1,151 @@
+// Package refresh keeps a periodically rebuilt read-only table, e.g. a lookup dataset refreshed from a database. A new
+// table is built in the background, verified, and atomically swapped in for the readers.
+package refresh
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Table is a table to refresh, e.g. *funnel.HashTable or *elastic.HashTable.
+type Table interface {
+	Get(key []byte) (any, bool)
+	Len() int
+	All() iter.Seq2[[]byte, any]
+}
+
+// Refresher keeps the current table and replaces it with a new one on Refresh. The readers use Get, which is safe
+// for concurrent use along with Refresh.
+//
+// The tables are not safe for concurrent use even for reads, since the lookups update the table statistics, so Get
+// serializes the reads of a table with a mutex. The reads do not scale with the number of readers then; split the
+// data across several Refreshers by key to spread the readers over several mutexes.
+type Refresher[T Table] struct {
+	// Build builds a new table from the data source, required
+	Build func(ctx context.Context) (T, error)
+	// Verify checks a new table before it's swapped in, e.g. with ExpectLen or KeysChecksum. Optional
+	Verify func(table T) error
+	// Retire is called on the old table once Grace has passed since the swap and the reads of it are done, e.g. to
+	// close its allocator. Optional
+	Retire func(table T)
+	Grace  time.Duration
+	// OnError is called by Run when a refresh fails, the current table is kept then. Optional
+	OnError func(err error)
+
+	current   atomic.Pointer[generation[T]]
+	refreshMu sync.Mutex // Serializes the refreshes
+}
+
+// generation is a table swapped in by Refresh. The tables are not safe for concurrent use, so the reads of it are
+// serialized.
+type generation[T Table] struct {
+	mu      sync.Mutex
+	table   T
+	retired bool // Set under mu before Retire, so the readers that loaded the generation late retry on the current one
+}
+
+// Refresh builds and verifies a new table, and swaps it in. The old table is retired after Grace. If Build or Verify
+// fails, the current table is kept.
+func (r *Refresher[T]) Refresh(ctx context.Context) error {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	table, err := r.Build(ctx)
+	if err != nil {
+		return fmt.Errorf("build table: %w", err)
+	}
+	if r.Verify != nil {
+		if err = r.Verify(table); err != nil {
+			return fmt.Errorf("verify table: %w", err)
+		}
+	}
+	old := r.current.Swap(&generation[T]{table: table})
+	if old != nil && r.Retire != nil {
+		time.AfterFunc(r.Grace, func() {
+			old.mu.Lock() // Wait for the reads in progress
+			defer old.mu.Unlock()
+			old.retired = true
+			r.Retire(old.table)
+		})
+	}
+	return nil
+}
+
+// Run refreshes the table every interval until ctx is done. The first refresh is made right away. The errors are
+// passed to OnError.
+func (r *Refresher[T]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Refresh(ctx); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Get returns a value for a key from the current table. If there is no table yet, it returns nil and false.
+func (r *Refresher[T]) Get(key []byte) (any, bool) {
+	for {
+		g := r.current.Load()
+		if g == nil {
+			return nil, false
+		}
+		if v, ok, retired := g.get(key); !retired {
+			return v, ok
+		}
+		// The generation was swapped out and retired after it's loaded, a newer one is current then
+	}
+}
+
+// get returns a value for a key, or retired true if the table is retired and must not be read.
+func (g *generation[T]) get(key []byte) (_ any, _ bool, retired bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.retired {
+		return nil, false, true
+	}
+	v, ok := g.table.Get(key)
+	return v, ok, false
+}
+
+// Current returns the current table and true, or false if there is no table yet. The table is not safe for
+// concurrent use, so the caller must serialize its reads, and must not use it after it's retired.
+func (r *Refresher[T]) Current() (T, bool) {
+	if g := r.current.Load(); g != nil {
+		return g.table, true
+	}
+	var zero T
+	return zero, false
+}
+
+// ExpectLen returns a Verify function, that checks the table has n entries.
+func ExpectLen[T Table](n int) func(table T) error {
+	return func(table T) error {
+		if l := table.Len(); l != n {
+			return fmt.Errorf("table has %d entries, want %d", l, n)
+		}
+		return nil
+	}
+}
+
+// KeysChecksum returns the checksum of the table keys, that does not depend on their order. Compare it with the
+// checksum of the data source keys in Verify.
+func KeysChecksum(keys iter.Seq2[[]byte, any]) uint64 {
+	var sum uint64
+	h := fnv.New64a()
+	for k := range keys {
+		h.Reset()
+		h.Write(k)
+		sum += h.Sum64()
+	}
+	return sum
+}
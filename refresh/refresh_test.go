@@ -0,0 +1,162 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildTable returns a Build function making the tables of n entries with the given value.
+func buildTable(n int, value any) func(ctx context.Context) (*funnel.HashTable, error) {
+	return func(context.Context) (*funnel.HashTable, error) {
+		t := funnel.NewHashTableDefault(2 * n)
+		for i := 0; i < n; i++ {
+			t.Insert([]byte(fmt.Sprint(i)), value)
+		}
+		return t, nil
+	}
+}
+
+func TestRefresher(t *testing.T) {
+	t.Run("no table yet; should miss", func(t *testing.T) {
+		r := &Refresher[*funnel.HashTable]{Build: buildTable(10, 1)}
+
+		_, ok := r.Get([]byte("1"))
+		assert.False(t, ok)
+		_, ok = r.Current()
+		assert.False(t, ok)
+	})
+
+	t.Run("refresh; should swap the table and retire the old one", func(t *testing.T) {
+		retired := make(chan *funnel.HashTable, 1)
+		r := &Refresher[*funnel.HashTable]{
+			Build:  buildTable(10, 1),
+			Verify: ExpectLen[*funnel.HashTable](10),
+			Retire: func(table *funnel.HashTable) { retired <- table },
+		}
+		require.NoError(t, r.Refresh(context.Background()))
+		first, _ := r.Current()
+
+		r.Build = buildTable(10, 2)
+		require.NoError(t, r.Refresh(context.Background()))
+
+		v, ok := r.Get([]byte("1"))
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+		select {
+		case table := <-retired:
+			assert.Same(t, first, table)
+		case <-time.After(time.Second):
+			t.Fatal("old table is not retired")
+		}
+	})
+
+	t.Run("verify failed; should keep the current table", func(t *testing.T) {
+		r := &Refresher[*funnel.HashTable]{Build: buildTable(10, 1)}
+		require.NoError(t, r.Refresh(context.Background()))
+
+		r.Build, r.Verify = buildTable(5, 2), ExpectLen[*funnel.HashTable](10)
+		err := r.Refresh(context.Background())
+
+		assert.ErrorContains(t, err, "has 5 entries, want 10")
+		v, _ := r.Get([]byte("1"))
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("build failed; should return the error", func(t *testing.T) {
+		errSource := errors.New("source is down")
+		r := &Refresher[*funnel.HashTable]{
+			Build: func(context.Context) (*funnel.HashTable, error) { return nil, errSource },
+		}
+
+		assert.ErrorIs(t, r.Refresh(context.Background()), errSource)
+	})
+
+	t.Run("reads during refreshes; should see a whole table", func(t *testing.T) {
+		r := &Refresher[*funnel.HashTable]{Build: buildTable(100, 0)}
+		require.NoError(t, r.Refresh(context.Background()))
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					_, ok := r.Get([]byte("42"))
+					assert.True(t, ok)
+				}
+			}()
+		}
+
+		for i := 1; i <= 20; i++ {
+			r.Build = buildTable(100, i)
+			require.NoError(t, r.Refresh(context.Background()))
+		}
+		cancel()
+		wg.Wait()
+
+		v, _ := r.Get([]byte("42"))
+		assert.Equal(t, 20, v)
+	})
+
+	t.Run("generation retired after a reader loaded it; should not be read", func(t *testing.T) {
+		retired := make(chan struct{})
+		r := &Refresher[*funnel.HashTable]{
+			Build: buildTable(10, 1),
+			Retire: func(table *funnel.HashTable) {
+				table.Clear()
+				close(retired)
+			},
+		}
+		require.NoError(t, r.Refresh(context.Background()))
+		g := r.current.Load() // The reader is preempted here
+
+		r.Build = buildTable(10, 2)
+		require.NoError(t, r.Refresh(context.Background()))
+		<-retired
+
+		_, _, isRetired := g.get([]byte("1"))
+		assert.True(t, isRetired)
+		v, ok := r.Get([]byte("1"))
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("run; should refresh until the context is done", func(t *testing.T) {
+		var builds int
+		r := &Refresher[*funnel.HashTable]{
+			Build: func(ctx context.Context) (*funnel.HashTable, error) {
+				builds++
+				return buildTable(10, builds)(ctx)
+			},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		r.Run(ctx, 5*time.Millisecond)
+
+		assert.Greater(t, builds, 1)
+		v, _ := r.Get([]byte("1"))
+		assert.Equal(t, builds, v)
+	})
+}
+
+func TestKeysChecksum(t *testing.T) {
+	t.Run("same keys in another table; should be equal", func(t *testing.T) {
+		a, b := funnel.NewHashTableDefault(100), funnel.NewHashTableDefault(200)
+		for i := 0; i < 50; i++ {
+			a.Insert([]byte(fmt.Sprint(i)), i)
+			b.Insert([]byte(fmt.Sprint(49-i)), nil)
+		}
+
+		assert.Equal(t, KeysChecksum(a.All()), KeysChecksum(b.All()))
+		b.Insert([]byte("extra"), nil)
+		assert.NotEqual(t, KeysChecksum(a.All()), KeysChecksum(b.All()))
+	})
+}
@@ -0,0 +1,22 @@
+module github.com/bdragon300/elastic-funnel-hash/remotegrpc
+
+go 1.23.0
+
+replace github.com/bdragon300/elastic-funnel-hash => ../
+
+require (
+	github.com/bdragon300/elastic-funnel-hash v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.10.0
+	google.golang.org/grpc v1.73.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.38.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250324211829-b45e905df463 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
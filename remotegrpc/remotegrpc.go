@@ -0,0 +1,245 @@
+// Package remotegrpc hosts a table in a dedicated process and shares it with several consumers over gRPC, with the
+// Table service defined in table.proto, so the consumers do not have to be written in Go. The server serializes the
+// operations, since the tables are not safe for concurrent use. The values are kept as []byte.
+//
+// It is a separate module, so the main module does not depend on gRPC.
+package remotegrpc
+
+//go:generate protoc --go_out=. --go_opt=module=github.com/bdragon300/elastic-funnel-hash/remotegrpc --go-grpc_out=. --go-grpc_opt=module=github.com/bdragon300/elastic-funnel-hash/remotegrpc table.proto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/bdragon300/elastic-funnel-hash/remotegrpc/tablepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"iter"
+	"sync"
+)
+
+// Table is a table to serve, e.g. *funnel.HashTable or *elastic.HashTable.
+type Table interface {
+	TrySet(key []byte, value any) (bool, error)
+	Get(key []byte) (any, bool)
+	Delete(key []byte) bool
+	All() iter.Seq2[[]byte, any]
+	StatsJSON(w io.Writer) error
+}
+
+// Entry is a table entry returned by Client.Snapshot.
+type Entry struct {
+	Key, Value []byte
+}
+
+// Server serves a table to the clients, see Register.
+type Server struct {
+	tablepb.UnimplementedTableServer
+
+	mu    sync.Mutex
+	table Table
+}
+
+// NewServer creates a new server of the table. The table must not be used directly while the server serves it.
+func NewServer(table Table) *Server {
+	return &Server{table: table}
+}
+
+// Register registers the Table service of a table server in a gRPC server.
+func Register(srv grpc.ServiceRegistrar, s *Server) {
+	tablepb.RegisterTableServer(srv, s)
+}
+
+// Get returns a value for a key, see HashTable.Get. The value must be []byte.
+func (s *Server) Get(_ context.Context, req *tablepb.GetRequest) (*tablepb.GetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.table.Get(req.Key)
+	if !ok {
+		return &tablepb.GetResponse{}, nil
+	}
+	b, err := valueBytes(value)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &tablepb.GetResponse{Value: b, Found: true}, nil
+}
+
+// Set sets a value for a key, see HashTable.TrySet.
+func (s *Server) Set(_ context.Context, req *tablepb.SetRequest) (*tablepb.SetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated, err := s.table.TrySet(req.Key, req.Value)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &tablepb.SetResponse{Updated: updated}, nil
+}
+
+// Delete deletes a key, see HashTable.Delete.
+func (s *Server) Delete(_ context.Context, req *tablepb.DeleteRequest) (*tablepb.DeleteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &tablepb.DeleteResponse{Deleted: s.table.Delete(req.Key)}, nil
+}
+
+// BatchGet returns the values and found flags of the keys, in the keys order.
+func (s *Server) BatchGet(_ context.Context, req *tablepb.BatchGetRequest) (*tablepb.BatchGetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &tablepb.BatchGetResponse{Values: make([]*tablepb.GetResponse, len(req.Keys))}
+	for i, key := range req.Keys {
+		resp.Values[i] = &tablepb.GetResponse{}
+		value, ok := s.table.Get(key)
+		if !ok {
+			continue
+		}
+		b, err := valueBytes(value)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		resp.Values[i].Value, resp.Values[i].Found = b, true
+	}
+	return resp, nil
+}
+
+// Stats returns the table stats in JSON, see HashTable.StatsJSON.
+func (s *Server) Stats(context.Context, *tablepb.StatsRequest) (*tablepb.StatsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf bytes.Buffer
+	if err := s.table.StatsJSON(&buf); err != nil {
+		return nil, statusError(err)
+	}
+	return &tablepb.StatsResponse{Json: buf.Bytes()}, nil
+}
+
+// Snapshot streams all table entries, see HashTable.All. The entries are copied under the lock and sent after it's
+// released, so a slow client does not block the others.
+func (s *Server) Snapshot(_ *tablepb.SnapshotRequest, stream grpc.ServerStreamingServer[tablepb.Entry]) error {
+	entries, err := s.entries()
+	if err != nil {
+		return statusError(err)
+	}
+	for _, e := range entries {
+		if err := stream.Send(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entries returns all table entries.
+func (s *Server) entries() ([]*tablepb.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []*tablepb.Entry
+	for k, value := range s.table.All() {
+		b, err := valueBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &tablepb.Entry{Key: k, Value: b})
+	}
+	return entries, nil
+}
+
+func valueBytes(value any) ([]byte, error) {
+	b, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("value of type %T is not bytes", value)
+	}
+	return b, nil
+}
+
+// statusError converts a table error to a gRPC status error. A full table is reported as ResourceExhausted.
+func statusError(err error) error {
+	if errors.Is(err, funnel.ErrFull) || errors.Is(err, elastic.ErrFull) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Client is a client of a table served over gRPC. The server errors are returned as gRPC status errors, see
+// status.Code.
+type Client struct {
+	c tablepb.TableClient
+}
+
+// NewClient creates a new client over the gRPC connection.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{c: tablepb.NewTableClient(cc)}
+}
+
+// Get returns a value for a key, see HashTable.Get.
+func (c *Client) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	resp, err := c.c.Get(ctx, &tablepb.GetRequest{Key: key})
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// Set sets a value for a key, see HashTable.TrySet.
+func (c *Client) Set(ctx context.Context, key, value []byte) (bool, error) {
+	resp, err := c.c.Set(ctx, &tablepb.SetRequest{Key: key, Value: value})
+	if err != nil {
+		return false, err
+	}
+	return resp.Updated, nil
+}
+
+// Delete deletes a key, see HashTable.Delete.
+func (c *Client) Delete(ctx context.Context, key []byte) (bool, error) {
+	resp, err := c.c.Delete(ctx, &tablepb.DeleteRequest{Key: key})
+	if err != nil {
+		return false, err
+	}
+	return resp.Deleted, nil
+}
+
+// BatchGet returns the values and found flags of the keys in a single call.
+func (c *Client) BatchGet(ctx context.Context, keys [][]byte) ([][]byte, []bool, error) {
+	resp, err := c.c.BatchGet(ctx, &tablepb.BatchGetRequest{Keys: keys})
+	if err != nil {
+		return nil, nil, err
+	}
+	values, found := make([][]byte, len(resp.Values)), make([]bool, len(resp.Values))
+	for i, v := range resp.Values {
+		values[i], found[i] = v.Value, v.Found
+	}
+	return values, found, nil
+}
+
+// Stats returns the table stats in JSON, see HashTable.StatsJSON.
+func (c *Client) Stats(ctx context.Context) ([]byte, error) {
+	resp, err := c.c.Stats(ctx, &tablepb.StatsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Json, nil
+}
+
+// Snapshot returns all table entries, see HashTable.All. The entries are streamed one by one.
+func (c *Client) Snapshot(ctx context.Context) ([]Entry, error) {
+	stream, err := c.c.Snapshot(ctx, &tablepb.SnapshotRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for {
+		e, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Key: e.Key, Value: e.Value})
+	}
+}
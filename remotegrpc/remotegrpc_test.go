@@ -0,0 +1,193 @@
+package remotegrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"iter"
+	"net"
+	"sync"
+	"testing"
+)
+
+// serve starts a gRPC server of the table and returns a client connected to it.
+func serve(t *testing.T, table Table) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	Register(srv, NewServer(table))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return dial(t, lis)
+}
+
+// dial returns a client connected to the server listening on lis.
+func dial(t *testing.T, lis *bufconn.Listener) *Client {
+	t.Helper()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func TestRemoteGRPC(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("set, get and delete key; should return the stored value", func(t *testing.T) {
+		c := serve(t, funnel.NewHashTableDefault(100))
+
+		updated, err := c.Set(ctx, []byte("key"), []byte("1"))
+		require.NoError(t, err)
+		assert.False(t, updated)
+		updated, err = c.Set(ctx, []byte("key"), []byte("2"))
+		require.NoError(t, err)
+		assert.True(t, updated)
+
+		v, ok, err := c.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("2"), v)
+
+		deleted, err := c.Delete(ctx, []byte("key"))
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		_, ok, err = c.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("batch get; should return values in the keys order", func(t *testing.T) {
+		table := elastic.NewHashTableDefault(100)
+		table.UseStash() // An elastic insert may fail below the table capacity
+		c := serve(t, table)
+		_, err := c.Set(ctx, []byte("a"), []byte("1"))
+		require.NoError(t, err)
+		_, err = c.Set(ctx, []byte("c"), []byte("3"))
+		require.NoError(t, err)
+
+		values, found, err := c.BatchGet(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false, true}, found)
+		assert.Equal(t, []byte("1"), values[0])
+		assert.Empty(t, values[1])
+		assert.Equal(t, []byte("3"), values[2])
+	})
+
+	t.Run("table full; should return resource exhausted", func(t *testing.T) {
+		c := serve(t, funnel.NewHashTableDefault(10))
+		var err error
+		for i := 0; err == nil; i++ {
+			_, err = c.Set(ctx, []byte(fmt.Sprint(i)), nil)
+		}
+
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("table full of deleted keys; should free their slots and set the key", func(t *testing.T) {
+		c := serve(t, funnel.NewHashTableDefault(10))
+		var n int
+		var err error
+		for ; err == nil; n++ {
+			_, err = c.Set(ctx, []byte(fmt.Sprint(n)), nil)
+		}
+		for i := 0; i < n; i++ {
+			_, err = c.Delete(ctx, []byte(fmt.Sprint(i)))
+			require.NoError(t, err)
+		}
+
+		_, err = c.Set(ctx, []byte("new"), nil)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("not bytes value; should return internal error", func(t *testing.T) {
+		table := funnel.NewHashTableDefault(100)
+		table.Insert([]byte("key"), 1)
+		c := serve(t, table)
+
+		_, _, err := c.Get(ctx, []byte("key"))
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.ErrorContains(t, err, "not bytes")
+	})
+
+	t.Run("stats and snapshot; should describe the table", func(t *testing.T) {
+		c := serve(t, funnel.NewHashTableDefault(100))
+		for i := 0; i < 10; i++ {
+			_, err := c.Set(ctx, []byte(fmt.Sprint(i)), []byte(fmt.Sprint(i)))
+			require.NoError(t, err)
+		}
+
+		stats, err := c.Stats(ctx)
+		require.NoError(t, err)
+		entries, err := c.Snapshot(ctx)
+		require.NoError(t, err)
+
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(stats, &decoded))
+		assert.Len(t, entries, 10)
+		for _, e := range entries {
+			assert.Equal(t, e.Key, e.Value)
+		}
+	})
+
+	t.Run("snapshot canceled; should stop waiting for the reply", func(t *testing.T) {
+		table := &blockingTable{HashTable: funnel.NewHashTableDefault(100), release: make(chan struct{})}
+		defer close(table.release)
+		c := serve(t, table)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		entries, err := c.Snapshot(ctx)
+
+		assert.Equal(t, codes.Canceled, status.Code(err))
+		assert.Nil(t, entries)
+	})
+
+	t.Run("several consumers; should share the table", func(t *testing.T) {
+		table := funnel.NewHashTableDefault(1000)
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer()
+		Register(srv, NewServer(table))
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			c := dial(t, lis)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					_, err := c.Set(ctx, []byte(fmt.Sprint(g, "-", i)), []byte{byte(g)})
+					assert.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 400, table.Len())
+	})
+}
+
+// blockingTable blocks the iteration until release is closed.
+type blockingTable struct {
+	*funnel.HashTable
+	release chan struct{}
+}
+
+func (b *blockingTable) All() iter.Seq2[[]byte, any] {
+	<-b.release
+	return b.HashTable.All()
+}
@@ -0,0 +1,148 @@
+// Package router dispatches the keys to one of several tables by a key prefix or a key hash range, e.g. to keep a hot
+// prefix in a small table and the rest in an mmap'd one, or to bound the cost of rebuilding a single table.
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+)
+
+// ErrNoRoute is returned if no route matches a key and Router.Default is not set.
+var ErrNoRoute = errors.New("no route for the key")
+
+// Table is a table to route the keys to, e.g. *funnel.HashTable or *elastic.HashTable.
+type Table interface {
+	TrySet(key []byte, value any) (bool, error)
+	Get(key []byte) (any, bool)
+	SoftDelete(key []byte) bool
+	All() iter.Seq2[[]byte, any]
+}
+
+// Route sends the keys Match returns true for to Table.
+type Route struct {
+	Match func(key []byte) bool
+	Table Table
+}
+
+// Prefix returns a Route.Match function, that matches the keys starting with prefix.
+func Prefix(prefix []byte) func(key []byte) bool {
+	return func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	}
+}
+
+// HashRange returns a Route.Match function, that matches the keys with hasher hash in [lo, hi].
+//
+// The tables select the banks by the key hash as well, so the hasher must differ from the tables ones, e.g. have
+// another seed. Otherwise, the keys of a range fill up only a part of the banks.
+func HashRange(hasher func(b []byte) uint64, lo, hi uint64) func(key []byte) bool {
+	return func(key []byte) bool {
+		h := hasher(key)
+		return h >= lo && h <= hi
+	}
+}
+
+// HashRanges returns the routes splitting the hash space into equal ranges, one per table, see HashRange.
+func HashRanges(hasher func(b []byte) uint64, tables ...Table) []Route {
+	if len(tables) == 0 {
+		panic("at least one table is required")
+	}
+	routes := make([]Route, len(tables))
+	width := math.MaxUint64 / uint64(len(tables))
+	for i, t := range tables {
+		lo, hi := uint64(i)*width, uint64(i+1)*width-1
+		if i == len(tables)-1 {
+			hi = math.MaxUint64
+		}
+		routes[i] = Route{Match: HashRange(hasher, lo, hi), Table: t}
+	}
+	return routes
+}
+
+// Router dispatches the operations to the table of the first route matching the key, or to Default if no route
+// matches. A table may be used in several routes.
+//
+// Routes and Default must not be changed after the first insert, since the keys already inserted would be looked up
+// in other tables.
+type Router struct {
+	Routes  []Route
+	Default Table // Table for the keys no route matches, optional
+}
+
+// Table returns the table a key is routed to, or nil if there is none.
+func (r *Router) Table(key []byte) Table {
+	for _, route := range r.Routes {
+		if route.Match(key) {
+			return route.Table
+		}
+	}
+	return r.Default
+}
+
+// Set sets a value for a key in its table. Returns true if the key existed and was updated.
+//
+// Panics if the key cannot be set, see TrySet.
+func (r *Router) Set(key []byte, value any) bool {
+	ok, err := r.TrySet(key, value)
+	if err != nil {
+		panic(err)
+	}
+	return ok
+}
+
+// TrySet is like Set, but returns an error instead of panicking. Returns ErrNoRoute if the key has no table.
+func (r *Router) TrySet(key []byte, value any) (bool, error) {
+	t := r.Table(key)
+	if t == nil {
+		return false, fmt.Errorf("%w: %q", ErrNoRoute, key)
+	}
+	return t.TrySet(key, value)
+}
+
+// Get returns a value for a key from its table. If the key does not exist or has no table, it returns nil and false.
+func (r *Router) Get(key []byte) (any, bool) {
+	if t := r.Table(key); t != nil {
+		return t.Get(key)
+	}
+	return nil, false
+}
+
+// Delete soft-deletes a key in its table. Returns false if the key does not exist or has no table.
+func (r *Router) Delete(key []byte) bool {
+	if t := r.Table(key); t != nil {
+		return t.SoftDelete(key)
+	}
+	return false
+}
+
+// All returns an iterator over the entries of all tables, in the order of Tables. The tables must not be modified
+// during iteration.
+func (r *Router) All() iter.Seq2[[]byte, any] {
+	return func(yield func([]byte, any) bool) {
+		for _, t := range r.Tables() {
+			for k, v := range t.All() {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Tables returns the distinct tables of the routes in the routes order, followed by Default if it's set.
+func (r *Router) Tables() []Table {
+	var res []Table
+	for _, route := range r.Routes {
+		if !slices.Contains(res, route.Table) {
+			res = append(res, route.Table)
+		}
+	}
+	if r.Default != nil && !slices.Contains(res, r.Default) {
+		res = append(res, r.Default)
+	}
+	return res
+}
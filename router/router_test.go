@@ -0,0 +1,124 @@
+package router
+
+import (
+	"fmt"
+	"github.com/bdragon300/elastic-funnel-hash/elastic"
+	"github.com/bdragon300/elastic-funnel-hash/funnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"maps"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	t.Run("prefix route; should keep the prefixed keys in its table", func(t *testing.T) {
+		hot, cold := funnel.NewHashTableDefault(100), funnel.NewHashTableDefault(1000)
+		r := &Router{Routes: []Route{{Match: Prefix([]byte("hot:")), Table: hot}}, Default: cold}
+
+		for i := 0; i < 50; i++ {
+			r.Set([]byte(fmt.Sprint("hot:", i)), i)
+			r.Set([]byte(fmt.Sprint("cold:", i)), i)
+		}
+
+		assert.Equal(t, 50, hot.Len())
+		assert.Equal(t, 50, cold.Len())
+		for i := 0; i < 50; i++ {
+			v, ok := r.Get([]byte(fmt.Sprint("hot:", i)))
+			require.True(t, ok)
+			assert.Equal(t, i, v)
+			_, ok = hot.Get([]byte(fmt.Sprint("hot:", i)))
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("existing key; should update it and delete it in its table", func(t *testing.T) {
+		table := funnel.NewHashTableDefault(100)
+		r := &Router{Routes: []Route{{Match: Prefix([]byte("a")), Table: table}}}
+
+		assert.False(t, r.Set([]byte("abc"), 1))
+		assert.True(t, r.Set([]byte("abc"), 2))
+		v, _ := r.Get([]byte("abc"))
+		assert.Equal(t, 2, v)
+
+		assert.True(t, r.Delete([]byte("abc")))
+		assert.False(t, r.Delete([]byte("abc")))
+		_, ok := r.Get([]byte("abc"))
+		assert.False(t, ok)
+	})
+
+	t.Run("no route and no default; should return ErrNoRoute", func(t *testing.T) {
+		r := &Router{Routes: []Route{{Match: Prefix([]byte("a")), Table: funnel.NewHashTableDefault(100)}}}
+
+		_, err := r.TrySet([]byte("b"), 1)
+
+		assert.ErrorIs(t, err, ErrNoRoute)
+		assert.Panics(t, func() { r.Set([]byte("b"), 1) })
+		_, ok := r.Get([]byte("b"))
+		assert.False(t, ok)
+		assert.False(t, r.Delete([]byte("b")))
+	})
+
+	t.Run("mixed implementations; should iterate over all entries once", func(t *testing.T) {
+		f, e := funnel.NewHashTableDefault(1000), elastic.NewHashTableDefault(1000)
+		e.UseStash()
+		r := &Router{
+			Routes: []Route{
+				{Match: Prefix([]byte("1")), Table: f},
+				{Match: Prefix([]byte("2")), Table: f},
+			},
+			Default: e,
+		}
+		want := make(map[string]any)
+		for i := 0; i < 500; i++ {
+			r.Set([]byte(fmt.Sprint(i)), i)
+			want[fmt.Sprint(i)] = i
+		}
+
+		got := make(map[string]any)
+		for k, v := range r.All() {
+			_, dup := got[string(k)]
+			require.False(t, dup, "key: %s", k)
+			got[string(k)] = v
+		}
+
+		assert.Equal(t, want, got)
+		assert.Equal(t, []Table{f, e}, r.Tables())
+	})
+}
+
+func TestHashRanges(t *testing.T) {
+	t.Run("split hash space; should route every key to exactly one table", func(t *testing.T) {
+		hasher := funnel.SeededHasher(1)
+		tables := []Table{funnel.NewHashTableDefault(1000), funnel.NewHashTableDefault(1000), funnel.NewHashTableDefault(1000)}
+		routes := HashRanges(hasher, tables...)
+
+		counts := make(map[Table]int)
+		for i := 0; i < 3000; i++ {
+			var matched []Table
+			for _, route := range routes {
+				if route.Match([]byte(fmt.Sprint(i))) {
+					matched = append(matched, route.Table)
+				}
+			}
+			require.Len(t, matched, 1, "key: %v", i)
+			counts[matched[0]]++
+		}
+
+		assert.Len(t, counts, 3)
+		for c := range maps.Values(counts) {
+			assert.InDelta(t, 1000, c, 150)
+		}
+	})
+
+	t.Run("range bounds; should include both ends", func(t *testing.T) {
+		hasher := func([]byte) uint64 { return 10 }
+
+		assert.True(t, HashRange(hasher, 10, 10)(nil))
+		assert.False(t, HashRange(hasher, 11, 20)(nil))
+		assert.True(t, HashRanges(hasher, funnel.NewHashTableDefault(10))[0].Match(nil))
+	})
+
+	t.Run("no tables; should panic", func(t *testing.T) {
+		assert.Panics(t, func() { HashRanges(funnel.SeededHasher(1)) })
+	})
+}